@@ -0,0 +1,116 @@
+package gcm
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultAbortWindow     = 1000
+	defaultAbortMinSamples = 100
+)
+
+// AbortPolicy defines when a broadcast is aborted automatically based on the
+// failure ratio of its most recent recipients.  Failures are the errors other
+// than stale registration tokens.
+type AbortPolicy struct {
+	// MaxFailureRate is the failure ratio (0 to 1) of the recent recipients
+	// above which the broadcast is aborted.  Zero disables automatic aborts.
+	MaxFailureRate float64
+	// Window is the number of recent recipients the failure ratio is computed
+	// over.  Zero means 1000.
+	Window int
+	// MinSamples is the number of recipients required before aborting.  Zero
+	// means 100.
+	MinSamples int
+	// OnAbort, if set, is called with the report of an abort, e.g. to roll
+	// back the waves of the broadcast scheduled elsewhere.
+	OnAbort func(*AbortReport)
+}
+
+// AbortReport details why a broadcast was aborted.
+type AbortReport struct {
+	Time time.Time
+	// FailureRate is the failure ratio of the recent recipients.
+	FailureRate float64
+	// Samples is the number of recent recipients FailureRate is computed
+	// over.
+	Samples int
+	// Errors counts the errors of the recent recipients by error string.
+	Errors map[string]int
+	// Sent is the number of registration IDs sent to before aborting.
+	Sent int
+	// Remaining lists the registration IDs left unsent.
+	Remaining []string
+}
+
+// BroadcastAbortedError is returned when a broadcast is aborted by its
+// AbortPolicy.
+type BroadcastAbortedError struct {
+	Report *AbortReport
+}
+
+func (e *BroadcastAbortedError) Error() string {
+	return fmt.Sprintf("broadcast aborted after %d recipients with failure rate %.3f over the last %d",
+		e.Report.Sent, e.Report.FailureRate, e.Report.Samples)
+}
+
+// abortMonitor tracks the rolling failure ratio of a broadcast.
+type abortMonitor struct {
+	policy   AbortPolicy
+	errors   []string // ring of the error strings of recent recipients
+	next     int
+	failures int
+}
+
+func newAbortMonitor(policy AbortPolicy) *abortMonitor {
+	window := policy.Window
+	if window <= 0 {
+		window = defaultAbortWindow
+	}
+	return &abortMonitor{policy: policy, errors: make([]string, 0, window)}
+}
+
+func isFailure(errCode string) bool {
+	return errCode != "" && Classify(errCode) != ActionRemoveToken
+}
+
+// observe records the results of a batch and returns a report if the
+// broadcast must be aborted.
+func (m *abortMonitor) observe(results []Result) *AbortReport {
+	if m.policy.MaxFailureRate <= 0 {
+		return nil
+	}
+	for _, res := range results {
+		if len(m.errors) < cap(m.errors) {
+			m.errors = append(m.errors, res.Error)
+		} else {
+			if isFailure(m.errors[m.next]) {
+				m.failures--
+			}
+			m.errors[m.next] = res.Error
+			m.next = (m.next + 1) % len(m.errors)
+		}
+		if isFailure(res.Error) {
+			m.failures++
+		}
+	}
+	minSamples := m.policy.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultAbortMinSamples
+	}
+	if len(m.errors) < minSamples {
+		return nil
+	}
+	rate := float64(m.failures) / float64(len(m.errors))
+	if rate <= m.policy.MaxFailureRate {
+		return nil
+	}
+	report := &AbortReport{Time: time.Now(), FailureRate: rate, Samples: len(m.errors), Errors: make(map[string]int)}
+	for _, errCode := range m.errors {
+		if errCode != "" {
+			report.Errors[errCode]++
+		}
+	}
+	return report
+}
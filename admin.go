@@ -0,0 +1,230 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeadLetter is a job that failed to be dispatched.
+type DeadLetter struct {
+	ID    int64     `json:"id"`
+	Job   *Job      `json:"job"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+	// History lists the failures of a quarantined job, oldest first.
+	History []Failure `json:"history,omitempty"`
+}
+
+// Failure is a failed attempt to dispatch a job.
+type Failure struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// DeadLetterQueue keeps the jobs that failed to be dispatched.
+//
+// DeadLetterQueue is safe for concurrent use.
+type DeadLetterQueue struct {
+	mu      sync.Mutex
+	nextID  int64
+	letters []*DeadLetter
+}
+
+// Add adds a failed job with the error it failed with.
+func (dl *DeadLetterQueue) Add(job *Job, err error) *DeadLetter {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.nextID++
+	letter := &DeadLetter{ID: dl.nextID, Job: job, Error: err.Error(), Time: time.Now()}
+	dl.letters = append(dl.letters, letter)
+	return letter
+}
+
+// Quarantine adds a poison job with its failure history, which must not be
+// empty.
+func (dl *DeadLetterQueue) Quarantine(job *Job, history []Failure) *DeadLetter {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.nextID++
+	last := history[len(history)-1]
+	letter := &DeadLetter{ID: dl.nextID, Job: job, Error: last.Error, Time: last.Time, History: history}
+	dl.letters = append(dl.letters, letter)
+	return letter
+}
+
+// List returns the dead letters, oldest first.
+func (dl *DeadLetterQueue) List() []*DeadLetter {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return append([]*DeadLetter(nil), dl.letters...)
+}
+
+// Get returns the dead letter with the given ID.
+func (dl *DeadLetterQueue) Get(id int64) (*DeadLetter, bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	for _, letter := range dl.letters {
+		if letter.ID == id {
+			return letter, true
+		}
+	}
+	return nil, false
+}
+
+// Remove removes the dead letter with the given ID and reports whether it
+// existed.
+func (dl *DeadLetterQueue) Remove(id int64) bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	for i, letter := range dl.letters {
+		if letter.ID == id {
+			dl.letters = append(dl.letters[:i], dl.letters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pause stops the workers from dispatching further jobs until Resume is called.
+// Jobs being sent are not interrupted.
+func (d *Dispatcher) Pause() {
+	d.pauseMu.Lock()
+	defer d.pauseMu.Unlock()
+	if d.resumed == nil {
+		d.resumed = make(chan struct{})
+	}
+}
+
+// Resume lets the workers dispatch jobs again after Pause.
+func (d *Dispatcher) Resume() {
+	d.pauseMu.Lock()
+	defer d.pauseMu.Unlock()
+	if d.resumed != nil {
+		close(d.resumed)
+		d.resumed = nil
+	}
+}
+
+// Paused reports whether the Dispatcher is paused.
+func (d *Dispatcher) Paused() bool {
+	d.pauseMu.Lock()
+	defer d.pauseMu.Unlock()
+	return d.resumed != nil
+}
+
+func (d *Dispatcher) waitResumed() {
+	d.pauseMu.Lock()
+	resumed := d.resumed
+	d.pauseMu.Unlock()
+	if resumed != nil {
+		<-resumed
+	}
+}
+
+// NewAdminHandler returns an http.Handler that lets operators intervene in a
+// Dispatcher without redeploying.  It serves:
+//
+//	GET    /pending            lists the pending jobs of the Queue
+//	GET    /failed             lists the dead letters
+//	POST   /failed/{id}/retry  enqueues a dead letter again
+//	DELETE /failed/{id}        deletes a dead letter
+//	GET    /paused             reports whether the workers are paused
+//	GET    /status             reports the health of the Dispatcher
+//	POST   /pause              pauses the workers
+//	POST   /resume             resumes the workers
+//
+// Mount it under a prefix with http.StripPrefix.  The failed endpoints require
+// the Dispatcher to have DeadLetters.
+func NewAdminHandler(d *Dispatcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pending", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "GET") {
+			return
+		}
+		writeJSON(w, d.Queue.Pending())
+	})
+	mux.HandleFunc("/failed", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "GET") || !hasDeadLetters(w, d) {
+			return
+		}
+		writeJSON(w, d.DeadLetters.List())
+	})
+	mux.HandleFunc("/failed/", func(w http.ResponseWriter, r *http.Request) {
+		if !hasDeadLetters(w, d) {
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/failed/"), "/")
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || len(parts) > 2 || len(parts) == 2 && parts[1] != "retry" {
+			http.NotFound(w, r)
+			return
+		}
+		letter, ok := d.DeadLetters.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if len(parts) == 2 {
+			if !allowMethod(w, r, "POST") {
+				return
+			}
+			if err := d.Queue.Enqueue(letter.Job); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		} else if !allowMethod(w, r, "DELETE") {
+			return
+		}
+		d.DeadLetters.Remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/paused", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, "GET") {
+			writeJSON(w, d.Paused())
+		}
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, "GET") {
+			writeJSON(w, d.Status())
+		}
+	})
+	mux.HandleFunc("/pause", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, "POST") {
+			d.Pause()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("/resume", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, "POST") {
+			d.Resume()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	return mux
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func hasDeadLetters(w http.ResponseWriter, d *Dispatcher) bool {
+	if d.DeadLetters == nil {
+		http.Error(w, "dead letters are not enabled", http.StatusNotImplemented)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
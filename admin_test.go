@@ -0,0 +1,108 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveAdmin(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestAdminHandlerDeadLetters(t *testing.T) {
+	// the job fails again once retried
+	server := startTestServer(t, &testResponse{statusCode: http.StatusBadRequest}, &testResponse{statusCode: http.StatusBadRequest})
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "regId"}))
+	dispatched := make(chan *JobResult, 1)
+	d := &Dispatcher{
+		Queue:       q,
+		Sender:      NewSender("test-api-key"),
+		DeadLetters: &DeadLetterQueue{},
+		OnResult:    func(jr *JobResult) { dispatched <- jr },
+	}
+	done := make(chan struct{})
+	go func() {
+		d.Run()
+		close(done)
+	}()
+	h := NewAdminHandler(d)
+
+	<-dispatched
+	w := serveAdmin(h, "GET", "/failed")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var letters []*DeadLetter
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &letters))
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, int64(1), letters[0].ID)
+		assert.Equal(t, "regId", letters[0].Job.To)
+		assert.Equal(t, "400 error: 400 Bad Request", letters[0].Error)
+	}
+
+	d.Pause()
+	assert.Equal(t, "true\n", serveAdmin(h, "GET", "/paused").Body.String())
+	assert.Equal(t, http.StatusMethodNotAllowed, serveAdmin(h, "GET", "/failed/1/retry").Code)
+	assert.Equal(t, http.StatusNotFound, serveAdmin(h, "POST", "/failed/2/retry").Code)
+	assert.Equal(t, http.StatusNoContent, serveAdmin(h, "POST", "/failed/1/retry").Code)
+	assert.Empty(t, d.DeadLetters.List())
+
+	w = serveAdmin(h, "GET", "/pending")
+	var pending []*Job
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, ClassReminder, pending[0].Class)
+	}
+
+	d.DeadLetters.Add(pending[0], errors.New("failed"))
+	assert.Equal(t, http.StatusNoContent, serveAdmin(h, "DELETE", "/failed/2").Code)
+	assert.Empty(t, d.DeadLetters.List())
+
+	q.Close()
+	d.Resume()
+	<-done
+}
+
+func TestAdminHandlerPauseResume(t *testing.T) {
+	d := &Dispatcher{Queue: NewQueue(QueueConfig{})}
+	h := NewAdminHandler(d)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveAdmin(h, "GET", "/pause").Code)
+	assert.Equal(t, http.StatusNoContent, serveAdmin(h, "POST", "/pause").Code)
+	assert.True(t, d.Paused())
+	assert.Equal(t, http.StatusNoContent, serveAdmin(h, "POST", "/resume").Code)
+	assert.False(t, d.Paused())
+	assert.Equal(t, http.StatusNotImplemented, serveAdmin(h, "GET", "/failed").Code)
+}
+
+func TestDispatcherPause(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "regId"}))
+	q.Close()
+	dispatched := make(chan *JobResult, 1)
+	d := &Dispatcher{Queue: q, Sender: NewSender("test-api-key"), OnResult: func(jr *JobResult) { dispatched <- jr }}
+	d.Pause()
+	done := make(chan struct{})
+	go func() {
+		d.Run()
+		close(done)
+	}()
+	select {
+	case <-dispatched:
+		t.Fatal("dispatched while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 1, q.Len())
+	d.Resume()
+	<-done
+	assert.NoError(t, (<-dispatched).Err)
+}
@@ -0,0 +1,186 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultAPIMaxResults is the MaxResults of an APIHandler when none is set.
+const DefaultAPIMaxResults = 10000
+
+// SendRequest is the body of POST /messages of an APIHandler.
+type SendRequest struct {
+	To      string   `json:"to"`
+	Message *Message `json:"message"`
+	Retries int      `json:"retries,omitempty"`
+}
+
+// MulticastRequest is the body of POST /multicast of an APIHandler.
+type MulticastRequest struct {
+	RegistrationIDs []string `json:"registration_ids"`
+	Message         *Message `json:"message"`
+	Retries         int      `json:"retries,omitempty"`
+}
+
+// SendResponse is the response to POST /messages of an APIHandler.
+type SendResponse struct {
+	ID     string  `json:"id"`
+	Result *Result `json:"result"`
+}
+
+// MulticastResponse is the response to POST /multicast of an APIHandler.
+type MulticastResponse struct {
+	ID     string           `json:"id"`
+	Result *MulticastResult `json:"result"`
+}
+
+// APIHandler is an http.Handler exposing a Sender as a REST API, for teams
+// standardizing on an internal push API.  It serves:
+//
+//	POST /messages       sends a SendRequest, responding with a SendResponse
+//	POST /multicast      sends a MulticastRequest, responding with a
+//	                     MulticastResponse
+//	GET  /results/{id}   returns the response to a send again
+//	GET  /openapi.json   returns the OpenAPI document of the API
+//
+// Mount it under a prefix with http.StripPrefix.  The ID of a send is the UUID
+// of its message when the Sender has a MessageUUIDKey.
+//
+// APIHandler is safe for concurrent use.
+type APIHandler struct {
+	Sender *Sender
+	// MaxResults is how many responses are kept for GET /results/{id}, the
+	// oldest being dropped first.  Zero means DefaultAPIMaxResults.
+	MaxResults int
+
+	once    sync.Once
+	mux     *http.ServeMux
+	mu      sync.Mutex
+	results map[string][]byte
+	ids     []string
+}
+
+// ServeHTTP serves the API.
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.results = make(map[string][]byte)
+		h.mux = http.NewServeMux()
+		h.mux.HandleFunc("/messages", h.serveSend)
+		h.mux.HandleFunc("/multicast", h.serveMulticast)
+		h.mux.HandleFunc("/results/", h.serveResult)
+		h.mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+			if !allowMethod(w, r, "GET") {
+				return
+			}
+			spec, err := OpenAPISpec()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(spec)
+		})
+	})
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *APIHandler) serveSend(w http.ResponseWriter, r *http.Request) {
+	var req SendRequest
+	if !allowMethod(w, r, "POST") || !readRequest(w, r, &req) {
+		return
+	}
+	result, err := h.Sender.SendWithRetries(req.Message, req.To, req.Retries)
+	if err != nil {
+		writeSendError(w, err)
+		return
+	}
+	resp := &SendResponse{ID: responseID(result.UUID), Result: result}
+	h.store(resp.ID, resp)
+	writeJSON(w, resp)
+}
+
+func (h *APIHandler) serveMulticast(w http.ResponseWriter, r *http.Request) {
+	var req MulticastRequest
+	if !allowMethod(w, r, "POST") || !readRequest(w, r, &req) {
+		return
+	}
+	result, err := h.Sender.SendMulticastWithRetries(req.Message, req.RegistrationIDs, req.Retries)
+	if err != nil {
+		writeSendError(w, err)
+		return
+	}
+	resp := &MulticastResponse{ID: responseID(result.UUID), Result: result}
+	h.store(resp.ID, resp)
+	writeJSON(w, resp)
+}
+
+func (h *APIHandler) serveResult(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	h.mu.Lock()
+	b, ok := h.results[strings.TrimPrefix(r.URL.Path, "/results/")]
+	h.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// responseID returns the ID of a send whose message has the UUID, if any.
+func responseID(uuid string) string {
+	if uuid != "" {
+		return uuid
+	}
+	return newUUID()
+}
+
+// store keeps the response under id for GET /results/{id}.
+func (h *APIHandler) store(id string, resp interface{}) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	max := h.MaxResults
+	if max <= 0 {
+		max = DefaultAPIMaxResults
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.results[id]; !ok {
+		h.ids = append(h.ids, id)
+	}
+	h.results[id] = b
+	for len(h.ids) > max {
+		delete(h.results, h.ids[0])
+		h.ids = h.ids[1:]
+	}
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeSendError responds with the error of a send: 503 when a retry may
+// succeed, 502 when the connection server rejected the request, and 400 when
+// the request was invalid.
+func writeSendError(w http.ResponseWriter, err error) {
+	var httpErr httpError
+	switch {
+	case isRetryable(err):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.As(err, &httpErr), errors.Is(err, ErrResultCountMismatch):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,82 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveAPI(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestAPIHandler(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &partialMulticast},
+		&testResponse{statusCode: http.StatusUnauthorized},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.MessageUUIDKey = "uuid"
+	h := &APIHandler{Sender: s, MaxResults: 1}
+
+	w := serveAPI(h, "POST", "/messages", `{"to":"regId","message":{"data":{"k":"v"},"time_to_live":0}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sent SendResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sent))
+	assert.Equal(t, "id", sent.Result.MessageID)
+	assert.Equal(t, sent.Result.UUID, sent.ID)
+	w = serveAPI(h, "GET", "/results/"+sent.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got SendResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, sent, got)
+
+	w = serveAPI(h, "POST", "/multicast", `{"registration_ids":["1","2"],"message":{"data":{"k":"v"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var multicast MulticastResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &multicast))
+	assert.Equal(t, 1, multicast.Result.Success)
+	assert.Equal(t, http.StatusOK, serveAPI(h, "GET", "/results/"+multicast.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, serveAPI(h, "GET", "/results/"+sent.ID, "").Code, "dropped above MaxResults")
+
+	assert.Equal(t, http.StatusBadGateway, serveAPI(h, "POST", "/messages", `{"to":"regId","message":{}}`).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveAPI(h, "POST", "/messages", `{"to":"regId","message":{}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serveAPI(h, "POST", "/messages", `{"to":"regId"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serveAPI(h, "POST", "/messages", `{`).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveAPI(h, "GET", "/messages", "").Code)
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	w := serveAPI(&APIHandler{}, "GET", "/openapi.json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var spec struct {
+		Paths      map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{}
+				Required   []string
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Len(t, spec.Paths, 3)
+	schemas := spec.Components.Schemas
+	for _, name := range []string{"SendRequest", "MulticastRequest", "SendResponse", "MulticastResponse", "Message", "Notification", "Result", "MulticastResult"} {
+		assert.Contains(t, schemas, name)
+	}
+	assert.Equal(t, []string{"to", "message"}, schemas["SendRequest"].Required)
+	assert.Equal(t, "#/components/schemas/Message", schemas["SendRequest"].Properties["message"]["$ref"])
+	assert.Equal(t, "integer", schemas["Message"].Properties["time_to_live"]["type"])
+	assert.Equal(t, []interface{}{"normal", "high"}, schemas["Message"].Properties["priority"]["enum"])
+	assert.NotContains(t, schemas["Message"].Properties, "Tags")
+	assert.Equal(t, "int64", schemas["MulticastResult"].Properties["multicast_id"]["format"])
+}
@@ -0,0 +1,336 @@
+package gcm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// APNsProductionEndpoint is the endpoint of the production APNs server.
+	APNsProductionEndpoint = "https://api.push.apple.com"
+	// APNsDevelopmentEndpoint is the endpoint of the development APNs server.
+	APNsDevelopmentEndpoint = "https://api.sandbox.push.apple.com"
+
+	// apnsTokenLifetime is how long a provider token is used before a new one
+	// is signed.  APNs rejects tokens older than an hour, and refreshes more
+	// frequent than every 20 minutes.
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// apnsErrorCodes maps the APNs error reasons to the equivalent GCM error
+// codes, so that the results of both can be handled alike.
+var apnsErrorCodes = map[string]string{
+	"BadDeviceToken":         ErrorInvalidRegistration,
+	"DeviceTokenNotForTopic": ErrorMismatchSenderID,
+	"Unregistered":           ErrorNotRegistered,
+	"PayloadTooLarge":        ErrorMessageTooBig,
+	"TooManyRequests":        ErrorDeviceMessageRateExceeded,
+	"BadExpirationDate":      ErrorInvalidTTL,
+	"InternalServerError":    ErrorInternalServerError,
+	"ServiceUnavailable":     ErrorUnavailable,
+	"Shutdown":               ErrorUnavailable,
+}
+
+// APNsNotification is a Message converted for APNs.
+type APNsNotification struct {
+	// PushType is "alert" or "background".
+	PushType string
+	// Priority is 10 to send immediately, or 5 to send at a time that
+	// conserves power.
+	Priority int
+	// Expiration is when APNs stops trying to deliver the notification.
+	// Zero means APNs decides, and the Unix epoch that APNs delivers it now
+	// or drops it.
+	Expiration time.Time
+	CollapseID string
+	// Payload is the JSON payload, with the aps dictionary and the data of
+	// the message as custom keys.
+	Payload []byte
+}
+
+// ToAPNs converts a message for APNs: the notification becomes the alert,
+// ClickAction the category, ContentAvailable a background push, and the data
+// custom keys of the payload.  InterruptionLevel and RelevanceScore become
+// interruption-level and relevance-score.
+func ToAPNs(msg *Message) (*APNsNotification, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	aps := make(map[string]interface{})
+	n := &APNsNotification{PushType: "alert", Priority: 10, CollapseID: msg.CollapseKey}
+	if notif := msg.Notification; notif != nil {
+		alert := make(map[string]interface{})
+		setString(alert, "title", notif.Title)
+		setString(alert, "body", notif.Body)
+		setString(alert, "title-loc-key", notif.TitleLocKey)
+		setString(alert, "loc-key", notif.BodyLocKey)
+		if len(notif.TitleLocArgs) > 0 {
+			alert["title-loc-args"] = notif.TitleLocArgs
+		}
+		if len(notif.BodyLocArgs) > 0 {
+			alert["loc-args"] = notif.BodyLocArgs
+		}
+		if len(alert) > 0 {
+			aps["alert"] = alert
+		}
+		setString(aps, "sound", notif.Sound)
+		setString(aps, "category", notif.ClickAction)
+		if notif.Badge != "" {
+			badge, err := strconv.Atoi(notif.Badge)
+			if err != nil {
+				return nil, fmt.Errorf("badge must be a number, got %q", notif.Badge)
+			}
+			aps["badge"] = badge
+		}
+		switch notif.InterruptionLevel {
+		case "":
+		case InterruptionLevelPassive, InterruptionLevelActive, InterruptionLevelTimeSensitive, InterruptionLevelCritical:
+			aps["interruption-level"] = notif.InterruptionLevel
+		default:
+			return nil, fmt.Errorf("unknown interruption level %q", notif.InterruptionLevel)
+		}
+		if notif.RelevanceScore < 0 || notif.RelevanceScore > 1 {
+			return nil, fmt.Errorf("relevance score should be between 0 and 1, got %v", notif.RelevanceScore)
+		}
+		if notif.RelevanceScore > 0 {
+			aps["relevance-score"] = notif.RelevanceScore
+		}
+	}
+	if msg.ContentAvailable {
+		aps["content-available"] = 1
+		if msg.Notification == nil {
+			n.PushType, n.Priority = "background", 5
+		}
+	}
+	if msg.Priority == PriorityNormal {
+		n.Priority = 5
+	}
+	if msg.TimeToLive > 0 {
+		n.Expiration = time.Now().Add(time.Duration(msg.TimeToLive) * time.Second)
+	} else if msg.TimeToLiveSet {
+		// now or never
+		n.Expiration = time.Unix(0, 0)
+	}
+
+	payload := make(map[string]interface{}, len(msg.Data)+1)
+	for k, v := range msg.Data {
+		if k == "aps" {
+			return nil, errors.New("data key aps is reserved by APNs")
+		}
+		payload[k] = v
+	}
+	payload["aps"] = aps
+	var err error
+	n.Payload, err = json.Marshal(payload)
+	return n, err
+}
+
+func setString(m map[string]interface{}, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
+
+// ParseAPNsKey parses the PEM encoded PKCS #8 private key of a .p8 file
+// downloaded from the Apple developer account.
+func ParseAPNsKey(p8 []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(p8)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// APNsTransport sends messages directly to iOS devices with APNs over HTTP/2,
+// authenticating with provider tokens, without going through FCM.  Messages
+// are converted with ToAPNs, and the errors of APNs are reported as the
+// equivalent GCM error codes in the results.
+//
+// APNsTransport is safe for concurrent use.
+type APNsTransport struct {
+	// Endpoint is the APNs server.  Empty means APNsProductionEndpoint.
+	Endpoint string
+	// Key is the signing key of the provider tokens, see ParseAPNsKey.
+	Key *ecdsa.PrivateKey
+	// KeyID is the ID of Key.
+	KeyID string
+	// TeamID is the ID of the developer team.
+	TeamID string
+	// Topic is the bundle ID of the app.
+	Topic string
+	// Client sends the requests.  It must support HTTP/2, as the default
+	// transport of net/http does.  Nil means http.DefaultClient.
+	Client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+}
+
+// NewAPNsTransport instantiates an APNsTransport for the app with the given
+// bundle ID, given the contents of a .p8 key file, its ID and the team ID.
+func NewAPNsTransport(p8 []byte, keyID, teamID, topic string) (*APNsTransport, error) {
+	key, err := ParseAPNsKey(p8)
+	if err != nil {
+		return nil, err
+	}
+	return &APNsTransport{Key: key, KeyID: keyID, TeamID: teamID, Topic: topic}, nil
+}
+
+// providerToken returns the current provider token, signing a new one when
+// needed.
+func (t *APNsTransport) providerToken(now time.Time) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && now.Sub(t.issued) < apnsTokenLifetime {
+		return t.token, nil
+	}
+	if t.Key == nil {
+		return "", errors.New("missing APNs key")
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": t.KeyID})
+	claims, _ := json.Marshal(map[string]interface{}{"iss": t.TeamID, "iat": now.Unix()})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, t.Key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	t.token, t.issued = unsigned+"."+enc.EncodeToString(sig), now
+	return t.token, nil
+}
+
+// invalidateToken forgets token so that the next request signs a new one.
+func (t *APNsTransport) invalidateToken(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+// Send sends a message to an APNs device token.  Errors about the device token
+// or the message are reported in the result, while other errors, e.g. of
+// authentication or of the APNs server, are returned.
+func (t *APNsTransport) Send(msg *Message, deviceToken string) (*Result, error) {
+	if deviceToken == "" {
+		return nil, errors.New("missing device token")
+	}
+	n, err := ToAPNs(msg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := t.providerToken(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = APNsProductionEndpoint
+	}
+	req, err := http.NewRequest("POST", endpoint+"/3/device/"+deviceToken, bytes.NewReader(n.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", t.Topic)
+	req.Header.Set("apns-push-type", n.PushType)
+	req.Header.Set("apns-priority", strconv.Itoa(n.Priority))
+	if !n.Expiration.IsZero() {
+		req.Header.Set("apns-expiration", strconv.FormatInt(n.Expiration.Unix(), 10))
+	}
+	if n.CollapseID != "" {
+		req.Header.Set("apns-collapse-id", n.CollapseID)
+	}
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return &Result{MessageID: resp.Header.Get("apns-id")}, nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(body, &apnsErr)
+	if resp.StatusCode == http.StatusForbidden {
+		// e.g. ExpiredProviderToken
+		t.invalidateToken(token)
+	}
+	if errCode, ok := apnsErrorCodes[apnsErr.Reason]; ok && resp.StatusCode < http.StatusInternalServerError {
+		return &Result{Error: errCode}, nil
+	}
+	status := apnsErr.Reason
+	if status == "" {
+		status = resp.Status
+	}
+	return nil, httpError{statusCode: resp.StatusCode, status: status}
+}
+
+// APNsFallback sends messages to iOS devices with a Sender, or directly with
+// an APNsTransport when FCM is bypassed, degraded or unavailable.
+type APNsFallback struct {
+	Sender *Sender
+	APNs   *APNsTransport
+	// Bypass sends all messages with APNs.
+	Bypass bool
+	// Degraded, if set, reports whether FCM is degraded, in which case
+	// messages are sent with APNs.  A panic in Degraded is handled according
+	// to the PanicPolicy of the Sender, and FCM is then assumed healthy.
+	Degraded func() bool
+}
+
+// Send sends a message to an iOS device given its FCM registration token and
+// its APNs device token.  A message that FCM fails to send with a server
+// error is sent again with APNs.
+func (f *APNsFallback) Send(msg *Message, regID, deviceToken string, retries int) (*Result, error) {
+	degraded := f.Bypass
+	if !degraded && f.Degraded != nil {
+		protect(f.Sender.PanicPolicy, "Degraded", func() { degraded = f.Degraded() })
+	}
+	if degraded {
+		return f.APNs.Send(msg, deviceToken)
+	}
+	result, err := f.Sender.SendWithRetries(msg, regID, retries)
+	if err != nil && isRetryable(err) || result != nil && isUnavailable(result.Error) {
+		return f.APNs.Send(msg, deviceToken)
+	}
+	return result, err
+}
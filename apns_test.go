@@ -0,0 +1,207 @@
+package gcm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAPNsKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// verifyProviderToken checks the signature of a provider token and returns
+// its claims.
+func verifyProviderToken(t *testing.T, key *ecdsa.PublicKey, token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	assert.True(t, ecdsa.Verify(key, digest[:], r, s))
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &claims))
+	return claims
+}
+
+func assertJSON(t *testing.T, expected string, actual []byte) {
+	var e, a interface{}
+	assert.NoError(t, json.Unmarshal([]byte(expected), &e))
+	assert.NoError(t, json.Unmarshal(actual, &a))
+	assert.Equal(t, e, a)
+}
+
+func TestToAPNs(t *testing.T) {
+	n, err := ToAPNs(&Message{
+		CollapseKey: "score",
+		TimeToLive:  60,
+		Data:        map[string]string{"id": "42"},
+		Notification: &Notification{
+			Title:       "Goal",
+			Body:        "1-0",
+			Sound:       "default",
+			ClickAction: "SCORE",
+			BodyLocArgs: []string{"a"},
+			Badge:       "3",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "alert", n.PushType)
+	assert.Equal(t, 10, n.Priority)
+	assert.Equal(t, "score", n.CollapseID)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), n.Expiration, time.Second)
+	assertJSON(t, `{"id":"42","aps":{"alert":{"title":"Goal","body":"1-0","loc-args":["a"]},"sound":"default","category":"SCORE","badge":3}}`, n.Payload)
+
+	n, err = ToAPNs(&Message{ContentAvailable: true, Priority: PriorityHigh})
+	assert.NoError(t, err)
+	assert.Equal(t, "background", n.PushType)
+	assert.Equal(t, 5, n.Priority)
+	assert.True(t, n.Expiration.IsZero())
+	assertJSON(t, `{"aps":{"content-available":1}}`, n.Payload)
+
+	_, err = ToAPNs(&Message{Notification: &Notification{Badge: "many"}})
+	assert.NotNil(t, err)
+	_, err = ToAPNs(&Message{Data: map[string]string{"aps": "x"}})
+	assert.NotNil(t, err)
+}
+
+func TestToAPNsInterruptionLevel(t *testing.T) {
+	n, err := ToAPNs(&Message{Notification: &Notification{Title: "Delayed", InterruptionLevel: InterruptionLevelTimeSensitive, RelevanceScore: 0.8}})
+	assert.NoError(t, err)
+	assertJSON(t, `{"aps":{"alert":{"title":"Delayed"},"interruption-level":"time-sensitive","relevance-score":0.8}}`, n.Payload)
+
+	_, err = ToAPNs(&Message{Notification: &Notification{InterruptionLevel: "urgent"}})
+	assert.NotNil(t, err)
+	_, err = ToAPNs(&Message{Notification: &Notification{RelevanceScore: 2}})
+	assert.NotNil(t, err)
+
+	// GCM has no equivalent, so they are not sent to it
+	b, err := json.Marshal(&Notification{Title: "Delayed", InterruptionLevel: InterruptionLevelPassive, RelevanceScore: 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"title":"Delayed"}`, string(b))
+}
+
+func TestAPNsZeroTimeToLive(t *testing.T) {
+	key, p8 := newAPNsKey(t)
+	var expirations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyProviderToken(t, &key.PublicKey, strings.TrimPrefix(r.Header.Get("Authorization"), "bearer "))
+		expirations = append(expirations, r.Header.Get("apns-expiration"))
+		w.Header().Set("apns-id", "uuid")
+	}))
+	defer server.Close()
+	tr, err := NewAPNsTransport(p8, "KEY", "TEAM", "com.example.app")
+	assert.NoError(t, err)
+	tr.Endpoint = server.URL
+
+	_, err = tr.Send(&Message{TimeToLiveSet: true}, "good")
+	assert.NoError(t, err)
+	_, err = tr.Send(&Message{}, "good")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", ""}, expirations, "an explicit zero TTL is now or never")
+}
+
+func TestAPNsTransportSend(t *testing.T) {
+	key, p8 := newAPNsKey(t)
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "com.example.app", r.Header.Get("apns-topic"))
+		assert.Equal(t, "alert", r.Header.Get("apns-push-type"))
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "bearer ")
+		tokens = append(tokens, token)
+		claims := verifyProviderToken(t, &key.PublicKey, token)
+		assert.Equal(t, "TEAM", claims["iss"])
+		switch r.URL.Path {
+		case "/3/device/good":
+			w.Header().Set("apns-id", "uuid")
+		case "/3/device/gone":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		case "/3/device/expired":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"reason":"ExpiredProviderToken"}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"reason":"ServiceUnavailable"}`))
+		}
+	}))
+	defer server.Close()
+
+	tr, err := NewAPNsTransport(p8, "KEY", "TEAM", "com.example.app")
+	assert.NoError(t, err)
+	tr.Endpoint = server.URL
+	_, err = NewAPNsTransport([]byte("garbage"), "KEY", "TEAM", "com.example.app")
+	assert.NotNil(t, err)
+
+	result, err := tr.Send(msg, "good")
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{MessageID: "uuid"}, result)
+
+	result, err = tr.Send(msg, "gone")
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorNotRegistered, result.Error)
+	assert.Equal(t, ActionRemoveToken, Classify(result.Error))
+
+	_, err = tr.Send(msg, "down")
+	assert.True(t, isRetryable(err))
+
+	_, err = tr.Send(msg, "expired")
+	assert.NotNil(t, err)
+	assert.False(t, isRetryable(err))
+	// the token is signed again after being rejected
+	_, err = tr.Send(msg, "good")
+	assert.NoError(t, err)
+	assert.Equal(t, tokens[0], tokens[3])
+	assert.NotEqual(t, tokens[3], tokens[4])
+}
+
+func TestAPNsFallback(t *testing.T) {
+	_, p8 := newAPNsKey(t)
+	apnsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apns-id", "apns")
+	}))
+	defer apnsServer.Close()
+	tr, err := NewAPNsTransport(p8, "KEY", "TEAM", "com.example.app")
+	assert.NoError(t, err)
+	tr.Endpoint = apnsServer.URL
+
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+	)
+	defer server.Close()
+	degraded := false
+	f := &APNsFallback{Sender: NewSender("test-api-key"), APNs: tr, Degraded: func() bool { return degraded }}
+
+	result, err := f.Send(msg, "regId", "device", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", result.MessageID)
+	// FCM fails
+	result, err = f.Send(msg, "regId", "device", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "apns", result.MessageID)
+
+	degraded = true
+	result, err = f.Send(msg, "regId", "device", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "apns", result.MessageID)
+}
@@ -0,0 +1,233 @@
+package gcm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BlobStore stores archived payloads.
+type BlobStore interface {
+	// Put stores the blob under key.
+	Put(key string, blob []byte) error
+	// DeleteBefore deletes the blobs put before t.
+	DeleteBefore(t time.Time) error
+}
+
+// NewMemoryBlobStore instantiates an in-memory BlobStore.  It is mostly useful
+// for tests.
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{blobs: make(map[string]memoryBlob)}
+}
+
+// MemoryBlobStore is an in-memory BlobStore.
+type MemoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	put  time.Time
+	blob []byte
+}
+
+// Put stores the blob under key.
+func (m *MemoryBlobStore) Put(key string, blob []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = memoryBlob{time.Now(), blob}
+	return nil
+}
+
+// DeleteBefore deletes the blobs put before t.
+func (m *MemoryBlobStore) DeleteBefore(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, b := range m.blobs {
+		if b.put.Before(t) {
+			delete(m.blobs, key)
+		}
+	}
+	return nil
+}
+
+// Delete deletes the blob stored under key, if any.
+func (m *MemoryBlobStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+// Keys returns the keys of the stored blobs in sorted order.
+func (m *MemoryBlobStore) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.blobs))
+	for key := range m.blobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the blob stored under key, or nil.
+func (m *MemoryBlobStore) Get(key string) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.blobs[key].blob
+}
+
+// ArchiveRecord is an archived request to the GCM connection server.
+type ArchiveRecord struct {
+	Time       time.Time       `json:"time"`
+	Request    json.RawMessage `json:"request"`
+	StatusCode int             `json:"status_code"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	// Tags are the Tags of the message.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Archiver archives a sample of the requests sent to the GCM connection server
+// and their responses, for debugging delivery complaints.  Recipients and the
+// notification title, body and their localization args are replaced with
+// hashes before archiving, and so are the values of ScrubDataKeys.
+type Archiver struct {
+	// Store receives the archived records as JSON.
+	Store BlobStore
+	// SampleRate is the ratio of requests archived, from 0 to 1.
+	SampleRate float64
+	// Retention is how long records are kept.  Zero keeps them forever.
+	Retention time.Duration
+	// ScrubDataKeys lists the data keys whose values are personal data.
+	ScrubDataKeys []string
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+// retentionPurgeInterval is how often expired records are deleted.
+const retentionPurgeInterval = time.Hour
+
+func scrub(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// scrubNotificationKeys are the notification keys whose values are user
+// visible text, which may be personal data.
+var scrubNotificationKeys = []string{"title", "body", "title_loc_args", "body_loc_args"}
+
+// scrubRequest replaces the personal data in the JSON encoded request.
+func (a *Archiver) scrubRequest(request []byte) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(request, &fields); err != nil {
+		return nil, err
+	}
+	var targets rawTargets
+	json.Unmarshal(request, &targets)
+	if targets.To != "" && targets.To[0] != '/' { // topics are not personal data
+		fields["to"], _ = json.Marshal(scrub(targets.To))
+	}
+	if len(targets.RegistrationIDs) > 0 {
+		scrubbed := make([]string, len(targets.RegistrationIDs))
+		for i, regID := range targets.RegistrationIDs {
+			scrubbed[i] = scrub(regID)
+		}
+		fields["registration_ids"], _ = json.Marshal(scrubbed)
+	}
+	if raw, ok := fields["notification"]; ok {
+		var notification map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &notification); err == nil {
+			for _, key := range scrubNotificationKeys {
+				if v, ok := notification[key]; ok {
+					notification[key], _ = json.Marshal(scrub(string(v)))
+				}
+			}
+			fields["notification"], _ = json.Marshal(notification)
+		}
+	}
+	if raw, ok := fields["data"]; ok && len(a.ScrubDataKeys) > 0 {
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &data); err == nil {
+			for _, key := range a.ScrubDataKeys {
+				if v, ok := data[key]; ok {
+					data[key], _ = json.Marshal(scrub(string(v)))
+				}
+			}
+			fields["data"], _ = json.Marshal(data)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// scrubResponse replaces the canonical registration IDs in the JSON encoded
+// response.
+func scrubResponse(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	for i := range resp.Results {
+		if resp.Results[i].RegistrationID != "" {
+			resp.Results[i].RegistrationID = scrub(resp.Results[i].RegistrationID)
+		}
+	}
+	for i := range resp.FailedRegistrationIDs {
+		resp.FailedRegistrationIDs[i] = scrub(resp.FailedRegistrationIDs[i])
+	}
+	scrubbed, _ := json.Marshal(resp)
+	return scrubbed
+}
+
+func (a *Archiver) archive(sampled float64, request []byte, statusCode int, response []byte, tags map[string]string) error {
+	if sampled >= a.SampleRate {
+		return nil
+	}
+	now := time.Now()
+	scrubbed, err := a.scrubRequest(request)
+	if err != nil {
+		return err
+	}
+	record, err := json.Marshal(ArchiveRecord{now, scrubbed, statusCode, scrubResponse(response), tags})
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s-%s", now.UTC().Format("20060102T150405.000000000Z"), newTraceID()[:8])
+	if err := a.Store.Put(key, record); err != nil {
+		return err
+	}
+
+	if a.Retention > 0 {
+		a.mu.Lock()
+		purge := now.Sub(a.lastPurge) >= retentionPurgeInterval
+		if purge {
+			a.lastPurge = now
+		}
+		a.mu.Unlock()
+		if purge {
+			return a.Store.DeleteBefore(now.Add(-a.Retention))
+		}
+	}
+	return nil
+}
+
+// archive archives the request in the Sender's Archiver, if any.  Failures are
+// logged and do not affect the send.
+func (s *Sender) archive(ctx context.Context, request []byte, statusCode int, response []byte) {
+	if s.Archiver == nil {
+		return
+	}
+	if err := s.Archiver.archive(s.random().Float64(), request, statusCode, response, TagsFromContext(ctx)); err != nil {
+		log.Printf("failed to archive request: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package gcm
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendWithArchiver(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{MulticastID: 1, Success: 2, CanonicalIds: 1, Results: []result{{MessageID: "1", RegistrationID: "new"}, {MessageID: "2"}}}},
+	)
+	defer server.Close()
+	store := NewMemoryBlobStore()
+	s := NewSender("test-api-key")
+	s.Archiver = &Archiver{Store: store, SampleRate: 1, ScrubDataKeys: []string{"email"}}
+	_, err := s.SendMulticastNoRetry(&Message{Data: map[string]string{"email": "a@b.c", "k": "v"}}, twoRecipients)
+	assert.NoError(t, err)
+
+	keys := store.Keys()
+	if assert.Len(t, keys, 1) {
+		var record ArchiveRecord
+		assert.NoError(t, json.Unmarshal(store.Get(keys[0]), &record))
+		assert.Equal(t, 200, record.StatusCode)
+		assert.Equal(t, `{"data":{"email":"`+scrub(`"a@b.c"`)+`","k":"v"},"registration_ids":["`+scrub("1")+`","`+scrub("2")+`"]}`, string(record.Request))
+		assert.Equal(t, `{"multicast_id":1,"success":2,"canonical_ids":1,"results":[{"message_id":"1","registration_id":"`+scrub("new")+`"},{"message_id":"2"}]}`, string(record.Response))
+	}
+}
+
+func TestArchiverScrubsNotificationText(t *testing.T) {
+	a := &Archiver{}
+	scrubbed, err := a.scrubRequest([]byte(`{"to":"/topics/a","notification":{"title":"Hi Ann","body":"Your order shipped","body_loc_args":["Ann"],"icon":"box"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"notification":{"body":"`+scrub(`"Your order shipped"`)+`","body_loc_args":"`+scrub(`["Ann"]`)+`","icon":"box","title":"`+scrub(`"Hi Ann"`)+`"},"to":"/topics/a"}`, string(scrubbed))
+}
+
+// blockingBlobStore blocks the first Put until unblocked.
+type blockingBlobStore struct {
+	BlobStore
+	blocked int32
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingBlobStore) Put(key string, blob []byte) error {
+	if atomic.CompareAndSwapInt32(&b.blocked, 0, 1) {
+		close(b.started)
+		<-b.unblock
+	}
+	return b.BlobStore.Put(key, blob)
+}
+
+func TestArchiveOutsideRequestSlot(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 1, Results: successes(1)}},
+		&testResponse{response: &response{Success: 1, Results: successes(1)}},
+	)
+	defer server.Close()
+	store := &blockingBlobStore{BlobStore: NewMemoryBlobStore(), started: make(chan struct{}), unblock: make(chan struct{})}
+	s := NewSender("test-api-key")
+	s.MaxConcurrentRequests = 1
+	s.Archiver = &Archiver{Store: store, SampleRate: 1}
+	first := make(chan error)
+	go func() {
+		_, err := s.SendNoRetry(msg, "1")
+		first <- err
+	}()
+	// the second request goes through while the first one is being archived
+	<-store.started
+	second := make(chan error)
+	go func() {
+		_, err := s.SendNoRetry(msg, "2")
+		second <- err
+	}()
+	select {
+	case err := <-second:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("second request blocked by archiving")
+	}
+	close(store.unblock)
+	assert.NoError(t, <-first)
+}
+
+func TestArchiverSampling(t *testing.T) {
+	store := NewMemoryBlobStore()
+	a := &Archiver{Store: store, SampleRate: 0.1}
+	assert.NoError(t, a.archive(0.5, []byte(`{"to":"/topics/a"}`), 200, nil, nil))
+	assert.Empty(t, store.Keys())
+	assert.NoError(t, a.archive(0.05, []byte(`{"to":"/topics/a"}`), 200, nil, nil))
+	assert.Len(t, store.Keys(), 1)
+}
+
+func TestArchiverRetention(t *testing.T) {
+	store := NewMemoryBlobStore()
+	store.Put("old", []byte("{}"))
+	time.Sleep(10 * time.Millisecond)
+	a := &Archiver{Store: store, SampleRate: 1, Retention: 5 * time.Millisecond}
+	assert.NoError(t, a.archive(0, []byte(`{"to":"1"}`), 500, nil, nil))
+	assert.Len(t, store.Keys(), 1)
+	assert.NotContains(t, store.Keys(), "old")
+}
@@ -0,0 +1,127 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CanaryPolicy defines the canary tokens of a broadcast, e.g. of internal
+// test devices, which receive the broadcast LeadTime before the rest of the
+// audience, which is only sent to once Confirm approves.
+type CanaryPolicy struct {
+	// Tokens are the registration tokens of the canary devices.  They are
+	// removed from the rest of the audience.
+	Tokens []string
+	// LeadTime is how long to wait after sending to the canaries before
+	// calling Confirm, measured by the Time of the Sender.
+	LeadTime time.Duration
+	// Confirm, if set, is called with the result of sending to the canaries
+	// and must return nil for the broadcast to go on.  It may block, e.g.
+	// until an operator approves with a ManualGate.
+	Confirm func(*MulticastResult) error
+}
+
+// CanaryRejectedError is returned when a broadcast is stopped after its
+// canaries.
+type CanaryRejectedError struct {
+	Err error
+}
+
+func (e *CanaryRejectedError) Error() string {
+	return fmt.Sprintf("broadcast rejected after canaries: %v", e.Err)
+}
+
+// Unwrap returns the error of the confirmation gate.
+func (e *CanaryRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrGateRejected is returned by a ManualGate rejected without a reason.
+var ErrGateRejected = errors.New("rejected")
+
+// ManualGate is a confirmation gate opened by an operator.  Its Confirm method
+// can be used as the Confirm of a CanaryPolicy.
+type ManualGate struct {
+	once sync.Once
+	mu   sync.Mutex
+	done chan struct{}
+	err  error
+}
+
+func (g *ManualGate) init() {
+	g.once.Do(func() { g.done = make(chan struct{}) })
+}
+
+// Approve lets the waiting broadcast go on.
+func (g *ManualGate) Approve() {
+	g.decide(nil)
+}
+
+// Reject stops the waiting broadcast with reason, or ErrGateRejected if nil.
+func (g *ManualGate) Reject(reason error) {
+	if reason == nil {
+		reason = ErrGateRejected
+	}
+	g.decide(reason)
+}
+
+func (g *ManualGate) decide(err error) {
+	g.init()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.done:
+	default:
+		g.err = err
+		close(g.done)
+	}
+}
+
+// Confirm blocks until the gate is approved or rejected.
+func (g *ManualGate) Confirm(*MulticastResult) error {
+	g.init()
+	<-g.done
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+// sendCanaries sends to the canaries of policy and waits for confirmation.
+func (s *Sender) sendCanaries(msg *Message, policy *CanaryPolicy, retries int) (*MulticastResult, error) {
+	result, err := mergeMulticastBatches(policy.Tokens, func(batch []string) (*MulticastResult, error) {
+		return s.SendMulticastWithRetries(msg, batch, retries)
+	})
+	if err != nil {
+		return result, err
+	}
+	<-s.clock().After(policy.LeadTime)
+	if policy.Confirm != nil {
+		if perr := protect(s.PanicPolicy, "Confirm", func() { err = policy.Confirm(result) }); perr != nil {
+			err = perr
+		}
+		if err != nil {
+			return result, &CanaryRejectedError{err}
+		}
+	}
+	return result, nil
+}
+
+// withoutTokens returns regIDs without the given tokens.
+func withoutTokens(regIDs, tokens []string) []string {
+	if len(tokens) == 0 {
+		return regIDs
+	}
+	excluded := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		excluded[token] = true
+	}
+	rest := make([]string, 0, len(regIDs))
+	for _, regID := range regIDs {
+		if !excluded[regID] {
+			rest = append(rest, regID)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,66 @@
+package gcm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRampedWithCanaries(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 1, Results: successes(1)}},
+		&testResponse{response: &response{Success: 2, Results: successes(2)}},
+	)
+	defer server.Close()
+	var gate ManualGate
+	policy := RampPolicy{Canary: &CanaryPolicy{Tokens: []string{"canary"}, LeadTime: time.Millisecond, Confirm: gate.Confirm}}
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		gate.Approve()
+	}()
+	result, err := NewSender("test-api-key").SendRamped(msg, []string{"1", "canary", "2"}, policy, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Canary.Success)
+	assert.Equal(t, 2, result.Sent)
+}
+
+func TestSendRampedCanaryRejected(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &response{Failure: 1, Results: []result{{Err: ErrorMessageTooBig}}}})
+	defer server.Close()
+	reason := errors.New("canary crashed")
+	policy := RampPolicy{Canary: &CanaryPolicy{Tokens: []string{"canary"}, Confirm: func(res *MulticastResult) error {
+		if res.Failure > 0 {
+			return reason
+		}
+		return nil
+	}}}
+	result, err := NewSender("test-api-key").SendRamped(msg, []string{"1", "2"}, policy, 0)
+	assert.Equal(t, &CanaryRejectedError{reason}, err)
+	assert.Equal(t, 0, result.Sent)
+
+	var gate ManualGate
+	gate.Reject(nil)
+	gate.Approve()
+	assert.Equal(t, ErrGateRejected, gate.Confirm(nil))
+}
+
+func TestSendRampedCanaryLeadTimeOnSenderTime(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 1, Results: successes(1)}},
+		&testResponse{response: &response{Success: 1, Results: successes(1)}},
+	)
+	defer server.Close()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSender("test-api-key")
+	s.Time = &fakeTime{now: start}
+	var confirmed time.Time
+	policy := RampPolicy{Canary: &CanaryPolicy{Tokens: []string{"canary"}, LeadTime: time.Hour, Confirm: func(*MulticastResult) error {
+		confirmed = s.Time.Now()
+		return nil
+	}}}
+	_, err := s.SendRamped(msg, []string{"1", "canary"}, policy, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(time.Hour), confirmed)
+}
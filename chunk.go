@@ -0,0 +1,173 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Data keys of the chunking protocol.  A data payload exceeding MaxPayloadSize
+// is JSON-encoded and split into N messages, each carrying in its data:
+//
+//	push_chunk_id:    the ID shared by all chunks of the payload
+//	push_chunk_index: the 0-based index of the chunk
+//	push_chunk_count: N
+//	push_chunk_data:  the chunk of the JSON-encoded payload
+//
+// Clients concatenate push_chunk_data of chunks 0 to N-1 and decode the result
+// as a JSON object of strings to get the original data payload.  Chunks may
+// arrive in any order.  See Reassembler for a reference implementation.  The
+// keys avoid the "gcm" and "google" prefixes, which are reserved.
+const (
+	ChunkIDKey    = "push_chunk_id"
+	ChunkIndexKey = "push_chunk_index"
+	ChunkCountKey = "push_chunk_count"
+	ChunkDataKey  = "push_chunk_data"
+)
+
+// chunkOverhead reserves room for the chunk metadata and JSON escaping.
+const chunkOverhead = 512
+
+// minChunkBudget is the smallest room for a chunk of the payload: that of the
+// longest escaped code point, e.g. \u2028, so that every chunk advances.
+const minChunkBudget = len(`\u2028`)
+
+// ChunkMessage splits the data payload of msg into messages that each fit in
+// MaxPayloadSize, identified by id.  The chunks share the options of msg except
+// CollapseKey, which would make the chunks replace one another.  The
+// Notification, if any, is only attached to the last chunk.  A message whose
+// payload fits is returned as the only chunk, unchanged.
+func ChunkMessage(msg *Message, id string) ([]*Message, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	if !AnalyzePayload(msg).Exceeds() {
+		return []*Message{msg}, nil
+	}
+	if id == "" {
+		return nil, errors.New("missing chunk id")
+	}
+	payload, err := json.Marshal(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	budget := MaxPayloadSize - chunkOverhead - len(id)
+	if msg.Notification != nil {
+		n, _ := json.Marshal(msg.Notification)
+		budget -= len(n)
+	}
+	if budget < minChunkBudget {
+		return nil, errors.New("notification leaves no room for chunks")
+	}
+
+	var pieces []string
+	for rest := string(payload); len(rest) > 0; {
+		piece := truncateUTF8(rest, budget)
+		for AnalyzePayload(&Message{Data: map[string]string{ChunkDataKey: piece}}).Size > budget+len(ChunkDataKey)+8 {
+			// escaping made the piece too big, e.g. for quotes and control characters
+			piece = truncateUTF8(piece, len(piece)*3/4)
+		}
+		if piece == "" {
+			return nil, errors.New("notification leaves no room for chunks")
+		}
+		pieces = append(pieces, piece)
+		rest = rest[len(piece):]
+	}
+
+	chunks := make([]*Message, len(pieces))
+	for i, piece := range pieces {
+		chunk := *msg
+		chunk.CollapseKey = ""
+		if i < len(pieces)-1 {
+			chunk.Notification = nil
+		}
+		chunk.Data = map[string]string{
+			ChunkIDKey:    id,
+			ChunkIndexKey: strconv.Itoa(i),
+			ChunkCountKey: strconv.Itoa(len(pieces)),
+			ChunkDataKey:  piece,
+		}
+		chunks[i] = &chunk
+	}
+	return chunks, nil
+}
+
+// SendChunked sends a message whose data payload may exceed MaxPayloadSize as
+// chunks, identified by id, to a single recipient, a topic, or a device group.
+// It stops at the first error.
+func (s *Sender) SendChunked(msg *Message, to, id string, retries int) ([]*Result, error) {
+	chunks, err := ChunkMessage(msg, id)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Result, 0, len(chunks))
+	for _, chunk := range chunks {
+		result, err := s.SendWithRetries(chunk, to, retries)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Reassembler reassembles data payloads received in chunks.  It is the
+// reference implementation of the client side of the chunking protocol.
+//
+// Reassembler is safe for concurrent use.
+type Reassembler struct {
+	mu      sync.Mutex
+	pending map[string][]*string
+}
+
+// Add adds the data payload of a received message.  Once all chunks of a
+// payload are added, it returns the original payload and true.  Data without
+// chunk metadata is returned as is.
+func (r *Reassembler) Add(data map[string]string) (map[string]string, bool, error) {
+	id, ok := data[ChunkIDKey]
+	if !ok {
+		return data, true, nil
+	}
+	index, err := strconv.Atoi(data[ChunkIndexKey])
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %v", ChunkIndexKey, err)
+	}
+	count, err := strconv.Atoi(data[ChunkCountKey])
+	if err != nil || count <= 0 {
+		return nil, false, fmt.Errorf("invalid %s: %q", ChunkCountKey, data[ChunkCountKey])
+	}
+	if index < 0 || index >= count {
+		return nil, false, fmt.Errorf("chunk index %d out of range [0, %d)", index, count)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = make(map[string][]*string)
+	}
+	pieces := r.pending[id]
+	if pieces == nil {
+		pieces = make([]*string, count)
+		r.pending[id] = pieces
+	} else if len(pieces) != count {
+		return nil, false, fmt.Errorf("chunk count changed from %d to %d", len(pieces), count)
+	}
+	piece := data[ChunkDataKey]
+	pieces[index] = &piece
+
+	var payload []byte
+	for _, p := range pieces {
+		if p == nil {
+			return nil, false, nil
+		}
+		payload = append(payload, *p...)
+	}
+	delete(r.pending, id)
+	var original map[string]string
+	if err := json.Unmarshal(payload, &original); err != nil {
+		return nil, false, err
+	}
+	return original, true, nil
+}
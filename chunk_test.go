@@ -0,0 +1,79 @@
+package gcm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkMessageAndReassemble(t *testing.T) {
+	data := map[string]string{
+		"ascii":   strings.Repeat("a", 5000),
+		"unicode": strings.Repeat("€😀", 1000),
+		"quotes":  strings.Repeat(`"\`, 1000),
+	}
+	original := &Message{CollapseKey: "sync", Data: data, Notification: &Notification{Title: "t"}}
+	chunks, err := ChunkMessage(original, "c1")
+	assert.NoError(t, err)
+	assert.True(t, len(chunks) > 1)
+	for i, chunk := range chunks {
+		assert.False(t, AnalyzePayload(chunk).Exceeds())
+		for _, f := range Lint(chunk) {
+			assert.NotEqual(t, "reserved-key", f.Rule)
+		}
+		assert.Equal(t, "", chunk.CollapseKey)
+		assert.Equal(t, i == len(chunks)-1, chunk.Notification != nil)
+	}
+
+	r := &Reassembler{}
+	// feed the chunks in reverse order
+	for i := len(chunks) - 1; i > 0; i-- {
+		_, done, err := r.Add(chunks[i].Data)
+		assert.NoError(t, err)
+		assert.False(t, done)
+	}
+	reassembled, done, err := r.Add(chunks[0].Data)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, data, reassembled)
+}
+
+func TestChunkMessageWithinLimit(t *testing.T) {
+	chunks, err := ChunkMessage(msg, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []*Message{msg}, chunks)
+	r := &Reassembler{}
+	reassembled, done, err := r.Add(msg.Data)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, msg.Data, reassembled)
+}
+
+func TestReassemblerInvalidChunk(t *testing.T) {
+	r := &Reassembler{}
+	_, _, err := r.Add(map[string]string{ChunkIDKey: "c", ChunkIndexKey: "2", ChunkCountKey: "2"})
+	assert.EqualError(t, err, "chunk index 2 out of range [0, 2)")
+	_, _, err = r.Add(map[string]string{ChunkIDKey: "c", ChunkIndexKey: "0", ChunkCountKey: "x"})
+	assert.EqualError(t, err, `invalid push_chunk_count: "x"`)
+}
+
+func TestChunkMessageSmallBudget(t *testing.T) {
+	data := map[string]string{"k": strings.Repeat("é\"", 2000)}
+	for n := 3540; n < 3600; n++ {
+		m := &Message{Data: data, Notification: &Notification{Body: strings.Repeat("b", n)}}
+		chunks, err := ChunkMessage(m, "c1")
+		if err != nil {
+			assert.EqualError(t, err, "notification leaves no room for chunks")
+			continue
+		}
+		r := &Reassembler{}
+		var reassembled map[string]string
+		for _, chunk := range chunks {
+			assert.False(t, AnalyzePayload(chunk).Exceeds())
+			reassembled, _, err = r.Add(chunk.Data)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, data, reassembled)
+	}
+}
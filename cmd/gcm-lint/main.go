@@ -0,0 +1,71 @@
+// Command gcm-lint checks message JSON files, or YAML files named *.yaml or
+// *.yml, for common pitfalls, e.g. payloads that are too large, reserved data
+// keys or missing Android channel IDs.  It prints the findings of each file as
+// a JSON line and exits with status 1 if any finding is an error.
+//
+// Usage:
+//
+//	gcm-lint [-strict] file...
+//
+// With -strict, warnings also fail.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	gcm "github.com/wuman/go-gcm"
+)
+
+type report struct {
+	File     string        `json:"file"`
+	Findings []gcm.Finding `json:"findings"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func main() {
+	strict := flag.Bool("strict", false, "fail on warnings too")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gcm-lint [-strict] file...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	enc := json.NewEncoder(os.Stdout)
+	for _, name := range flag.Args() {
+		r := report{File: name, Findings: []gcm.Finding{}}
+		b, err := ioutil.ReadFile(name)
+		if err == nil {
+			var findings []gcm.Finding
+			switch filepath.Ext(name) {
+			case ".yaml", ".yml":
+				findings, err = gcm.LintYAML(b)
+			default:
+				findings, err = gcm.LintJSON(b)
+			}
+			r.Findings = append(r.Findings, findings...)
+		}
+		if err != nil {
+			r.Error = err.Error()
+			failed = true
+		}
+		for _, f := range r.Findings {
+			if f.Severity == gcm.SeverityError || *strict {
+				failed = true
+			}
+		}
+		enc.Encode(r)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,180 @@
+package gcm
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCoalesceWindow is the Window used by a Coalescer when none is set.
+const DefaultCoalesceWindow = 100 * time.Millisecond
+
+// Coalescer enqueues jobs into a Queue, coalescing the jobs of the same class
+// and identical message sent to single registration tokens within a short
+// window into multicast jobs, which can cut the number of requests by orders
+// of magnitude for event-driven fan-outs.
+//
+// Jobs sent to topics or to several registration IDs are enqueued right away.
+// A coalesced job carries its recipients in RegistrationIDs, so its outcome is
+// a MulticastResult rather than a Result.
+//
+// Enqueue gives no delivery guarantee for coalesced jobs: they are only added
+// to the Queue later, when their batch is.  A batch that the Queue rejects,
+// e.g. with ErrQueueFull or ErrQueueClosed, is reported to the OnDrop of the
+// Listener of the Queue with the error as the reason.
+//
+// Coalescer is safe for concurrent use.
+type Coalescer struct {
+	// Queue receives the jobs.
+	Queue *Queue
+	// Window is the max age of a batch, i.e. how long a job waits for others
+	// to coalesce with.  Zero means DefaultCoalesceWindow.
+	Window time.Duration
+	// MaxBatchSize is the max number of recipients of a batch, which is
+	// enqueued as soon as it is full.  Zero or more than MaxRegistrationIDs
+	// means MaxRegistrationIDs.
+	MaxBatchSize int
+	// Immediate, if set, reports whether a job must be enqueued right away
+	// rather than wait for others, e.g. for one-time passwords.  A job for
+	// which it panics is enqueued right away.
+	Immediate func(*Job) bool
+	// PanicPolicy decides what happens when Immediate panics.
+	PanicPolicy PanicPolicy
+
+	mu      sync.Mutex
+	batches map[string]*coalescedJob
+}
+
+type coalescedJob struct {
+	job   *Job
+	timer *time.Timer
+	// tokens is the set of the RegistrationIDs of job.
+	tokens map[string]bool
+}
+
+// Enqueue adds a job to the batch of identical jobs, which is enqueued once
+// Window has passed since its first job or it reaches MaxBatchSize.  A token
+// already in the batch is not added again, so that the device gets the message
+// once.  Jobs that cannot be coalesced or are Immediate are enqueued right
+// away.
+func (c *Coalescer) Enqueue(job *Job) error {
+	if job == nil || job.Message == nil || job.To == "" || strings.HasPrefix(job.To, TopicPrefix) {
+		return c.Queue.Enqueue(job)
+	}
+	if c.Immediate != nil {
+		immediate := true
+		protect(c.PanicPolicy, "Immediate", func() { immediate = c.Immediate(job) })
+		if immediate {
+			return c.Queue.Enqueue(job)
+		}
+	}
+	payload, err := json.Marshal(job.Message)
+	if err != nil {
+		return c.Queue.Enqueue(job)
+	}
+	tags, _ := json.Marshal(job.Message.Tags)
+	key := strconv.Itoa(int(job.Class)) + ":" + string(tags) + ":" + string(payload)
+
+	c.mu.Lock()
+	if c.batches == nil {
+		c.batches = make(map[string]*coalescedJob)
+	}
+	batch, ok := c.batches[key]
+	if !ok {
+		batch = &coalescedJob{job: &Job{Class: job.Class, Message: job.Message}, tokens: make(map[string]bool)}
+		c.batches[key] = batch
+		window := c.Window
+		if window <= 0 {
+			window = DefaultCoalesceWindow
+		}
+		batch.timer = time.AfterFunc(window, func() { c.flush(key, batch) })
+	}
+	if !batch.tokens[job.To] {
+		batch.tokens[job.To] = true
+		batch.job.RegistrationIDs = append(batch.job.RegistrationIDs, job.To)
+	}
+	full := len(batch.job.RegistrationIDs) >= c.maxBatchSize()
+	if full {
+		delete(c.batches, key)
+		batch.timer.Stop()
+	}
+	c.mu.Unlock()
+
+	if full {
+		return c.enqueue(batch.job)
+	}
+	return nil
+}
+
+func (c *Coalescer) maxBatchSize() int {
+	if c.MaxBatchSize <= 0 || c.MaxBatchSize > MaxRegistrationIDs {
+		return MaxRegistrationIDs
+	}
+	return c.MaxBatchSize
+}
+
+// flush enqueues the batch when its window has passed, unless it was already
+// enqueued.
+func (c *Coalescer) flush(key string, batch *coalescedJob) {
+	c.mu.Lock()
+	if c.batches[key] != batch {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.batches, key)
+	c.mu.Unlock()
+	c.enqueue(batch.job)
+}
+
+// enqueue enqueues a batch, reporting a failure to the OnDrop of the Listener
+// of the Queue, since the callers that added its jobs are long gone.
+func (c *Coalescer) enqueue(job *Job) error {
+	if len(job.RegistrationIDs) == 1 {
+		job.To, job.RegistrationIDs = job.RegistrationIDs[0], nil
+	}
+	err := c.Queue.Enqueue(job)
+	if err != nil {
+		log.Printf("failed to enqueue coalesced job: %v", err)
+		// a Queue dropping its oldest job has already reported the drop
+		if err != ErrQueueFull || c.Queue.fullPolicy != FullPolicyDropOldest {
+			c.Queue.notifyDrops([]*Job{job}, err)
+		}
+	}
+	return err
+}
+
+// Flush enqueues the pending batches right away.  If ctx is done before all
+// are enqueued, the others are left to wait for their window and Flush returns
+// ctx.Err().  Otherwise it returns the first error of the Queue.
+func (c *Coalescer) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.batches))
+	for key := range c.batches {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	var err error
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.mu.Lock()
+		batch, ok := c.batches[key]
+		if ok {
+			delete(c.batches, key)
+			batch.timer.Stop()
+		}
+		c.mu.Unlock()
+		if ok {
+			if qerr := c.enqueue(batch.job); err == nil {
+				err = qerr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package gcm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalescer(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	c := &Coalescer{Queue: q, Window: time.Hour}
+	for i := 0; i < MaxRegistrationIDs+2; i++ {
+		assert.NoError(t, c.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: fmt.Sprint(i)}))
+	}
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "a"}))
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "/topics/a"}))
+	assert.Equal(t, 2, q.Len()) // the full batch and the topic job
+	assert.NoError(t, c.Flush(context.Background()))
+	assert.Equal(t, 4, q.Len())
+
+	jobs := q.Pending()
+	assert.Equal(t, "a", jobs[0].To)
+	assert.Len(t, jobs[1].RegistrationIDs, MaxRegistrationIDs)
+	assert.Equal(t, "/topics/a", jobs[2].To)
+	assert.Equal(t, []string{"1000", "1001"}, jobs[3].RegistrationIDs)
+}
+
+func TestCoalescerDedupesTokens(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	c := &Coalescer{Queue: q, Window: time.Hour, MaxBatchSize: 2}
+	for _, to := range []string{"1", "1", "2"} {
+		assert.NoError(t, c.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: to}))
+	}
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.NoError(t, c.Flush(context.Background()))
+
+	jobs := q.Pending()
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, []string{"1", "2"}, jobs[0].RegistrationIDs, "the device gets the message once")
+		assert.Equal(t, "1", jobs[1].To, "a later batch may send it again")
+	}
+}
+
+func TestCoalescerWindow(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	c := &Coalescer{Queue: q, Window: 10 * time.Millisecond}
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "2"}))
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassReminder, Message: &Message{CollapseKey: "k"}, To: "3"}))
+	assert.Equal(t, 0, q.Len())
+	job, _ := q.Dequeue()
+	assert.NotNil(t, job)
+	job2, _ := q.Dequeue()
+	if job.To == "" {
+		job, job2 = job2, job
+	}
+	assert.Equal(t, "3", job.To)
+	assert.Equal(t, []string{"1", "2"}, job2.RegistrationIDs)
+}
+
+func TestCoalescerFlushControls(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	c := &Coalescer{
+		Queue:        q,
+		Window:       time.Hour,
+		MaxBatchSize: 2,
+		Immediate:    func(job *Job) bool { return job.Class == ClassTransactional },
+	}
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "otp"}))
+	assert.Equal(t, 1, q.Len())
+	for _, to := range []string{"1", "2", "3"} {
+		assert.NoError(t, c.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: to}))
+	}
+	assert.Equal(t, 2, q.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, c.Flush(ctx))
+	assert.Equal(t, 2, q.Len())
+	assert.NoError(t, c.Flush(context.Background()))
+	assert.Equal(t, 3, q.Len())
+	assert.Equal(t, "3", q.Pending()[2].To)
+}
+
+func TestCoalescerReportsRejectedBatches(t *testing.T) {
+	l := &recordingListener{}
+	q := NewQueue(QueueConfig{Capacity: 1, FullPolicy: FullPolicyReject, Listener: l})
+	c := &Coalescer{Queue: q, Window: 10 * time.Millisecond}
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "0"}))
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "2"}))
+	assert.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return len(l.events) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"enqueue 0", "drop : queue is full"}, l.events)
+}
@@ -0,0 +1,37 @@
+package gcm
+
+import "encoding/json"
+
+// Codec encodes the jobs persisted by durable stores, such as a WAL, so that
+// large deployments can choose a compact encoding, e.g. protobuf or msgpack,
+// for their stores.
+type Codec interface {
+	// Encode encodes job.
+	Encode(job *Job) ([]byte, error)
+	// Decode decodes a job encoded by Encode.
+	Decode(data []byte) (*Job, error)
+}
+
+// JSONCodec is a Codec encoding jobs as JSON.  It is the default Codec.
+type JSONCodec struct{}
+
+// Encode encodes job as JSON.
+func (JSONCodec) Encode(job *Job) ([]byte, error) {
+	return json.Marshal(job)
+}
+
+// Decode decodes a job from JSON.
+func (JSONCodec) Decode(data []byte) (*Job, error) {
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func codecOrDefault(c Codec) Codec {
+	if c == nil {
+		return JSONCodec{}
+	}
+	return c
+}
@@ -0,0 +1,18 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONCodec(t *testing.T) {
+	job := &Job{Class: ClassReminder, Message: msg, RegistrationIDs: twoRecipients}
+	b, err := JSONCodec{}.Encode(job)
+	assert.NoError(t, err)
+	decoded, err := JSONCodec{}.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, job, decoded)
+	_, err = JSONCodec{}.Decode([]byte("{"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package gcm
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConcurrency = 100
+	defaultBackoff        = 0.5
+)
+
+// ConcurrencyController adapts the number of simultaneous requests of a
+// Sender by additive increase, multiplicative decrease (AIMD): the limit grows
+// by about one per round of healthy requests, and shrinks by Backoff when a
+// request finds the GCM connection server overloaded, i.e. it fails with a
+// retryable error, has Unavailable results or takes longer than MaxLatency.
+// This replaces hand-tuned static limits for broadcasts.
+//
+// Its fields must be set before use.  ConcurrencyController is safe for
+// concurrent use.
+type ConcurrencyController struct {
+	// Min is the lowest limit, and the initial one.  Zero means 1.
+	Min int
+	// Max is the highest limit.  Zero means 100.
+	Max int
+	// MaxLatency is the request latency above which the server is considered
+	// overloaded.  Zero disables the check.
+	MaxLatency time.Duration
+	// Backoff is the factor in (0, 1) the limit is multiplied by on overload.
+	// Zero means 0.5.
+	Backoff float64
+
+	once         sync.Once
+	mu           sync.Mutex
+	cond         *sync.Cond
+	limit        float64
+	inflight     int
+	lastDecrease time.Time
+}
+
+func (c *ConcurrencyController) init() {
+	c.once.Do(func() {
+		c.cond = sync.NewCond(&c.mu)
+		c.limit = float64(c.min())
+	})
+}
+
+func (c *ConcurrencyController) min() int {
+	if c.Min < 1 {
+		return 1
+	}
+	return c.Min
+}
+
+func (c *ConcurrencyController) max() int {
+	if c.Max < 1 {
+		return defaultMaxConcurrency
+	}
+	return c.Max
+}
+
+// Limit returns the current limit.
+func (c *ConcurrencyController) Limit() int {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// acquire blocks until a request may start and returns a function that
+// reports whether it found the server overloaded when it is done.
+func (c *ConcurrencyController) acquire() func(overloaded bool) {
+	c.init()
+	c.mu.Lock()
+	for c.inflight >= int(c.limit) {
+		c.cond.Wait()
+	}
+	c.inflight++
+	c.mu.Unlock()
+	start := time.Now()
+	return func(overloaded bool) {
+		c.release(start, overloaded)
+	}
+}
+
+func (c *ConcurrencyController) release(start time.Time, overloaded bool) {
+	now := time.Now()
+	if c.MaxLatency > 0 && now.Sub(start) > c.MaxLatency {
+		overloaded = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if overloaded {
+		// requests started before the last decrease saw the old limit
+		if start.After(c.lastDecrease) {
+			backoff := c.Backoff
+			if backoff <= 0 || backoff >= 1 {
+				backoff = defaultBackoff
+			}
+			c.limit *= backoff
+			if min := float64(c.min()); c.limit < min {
+				c.limit = min
+			}
+			c.lastDecrease = now
+		}
+	} else {
+		c.limit += 1 / c.limit
+		if max := float64(c.max()); c.limit > max {
+			c.limit = max
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// overloaded reports whether the outcome of a request shows that the GCM
+// connection server is overloaded.
+func overloaded(resp *response, err error) bool {
+	if err != nil {
+		return isRetryable(err)
+	}
+	if resp != nil {
+		for _, res := range resp.Results {
+			if isUnavailable(res.Err) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package gcm
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyControllerAIMD(t *testing.T) {
+	c := &ConcurrencyController{Min: 2, Max: 4}
+	assert.Equal(t, 2, c.Limit())
+	for i := 0; i < 20; i++ {
+		c.acquire()(false)
+	}
+	assert.Equal(t, 4, c.Limit())
+
+	// requests in flight during an overload only decrease the limit once
+	var releases []func(bool)
+	for i := 0; i < 4; i++ {
+		releases = append(releases, c.acquire())
+	}
+	for _, release := range releases {
+		release(true)
+	}
+	assert.Equal(t, 2, c.Limit())
+	c.acquire()(true)
+	assert.Equal(t, 2, c.Limit())
+
+	c = &ConcurrencyController{MaxLatency: time.Nanosecond}
+	release := c.acquire()
+	time.Sleep(time.Millisecond)
+	release(false)
+	assert.Equal(t, 1, c.Limit())
+}
+
+func TestConcurrencyControllerBlocks(t *testing.T) {
+	c := &ConcurrencyController{Min: 1, Max: 1}
+	release := c.acquire()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	acquired := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		c.acquire()(false)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release(false)
+	wg.Wait()
+}
+
+func TestOverloaded(t *testing.T) {
+	assert.True(t, overloaded(nil, httpError{http.StatusServiceUnavailable, "503"}))
+	assert.False(t, overloaded(nil, httpError{http.StatusBadRequest, "400"}))
+	assert.False(t, overloaded(nil, errors.New("missing API key")))
+	assert.True(t, overloaded(&response{Results: []result{{Err: ErrorUnavailable}}}, nil))
+	assert.False(t, overloaded(&response{Results: []result{{MessageID: "id"}}}, nil))
+}
+
+func TestSenderConcurrency(t *testing.T) {
+	server := startTestServer(t, &testResponse{statusCode: http.StatusServiceUnavailable})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Concurrency = &ConcurrencyController{Min: 1}
+	for i := 0; i < 3; i++ {
+		s.Concurrency.acquire()(false)
+	}
+	limit := s.Concurrency.Limit()
+	assert.True(t, limit > 1)
+	_, err := s.SendNoRetry(msg, "regId")
+	assert.Error(t, err)
+	assert.True(t, s.Concurrency.Limit() < limit)
+}
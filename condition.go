@@ -0,0 +1,112 @@
+package gcm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxConditionTopics is the max number of topics in a condition expression.
+const MaxConditionTopics = 5
+
+// Condition is a boolean expression of topics that selects the devices a
+// message is sent to, e.g. 'dogs' in topics && ('cats' in topics || 'birds'
+// in topics).  Build conditions with InTopic, And and Or.
+type Condition struct {
+	op       string // "" for a single topic
+	operands []*Condition
+	topic    string
+}
+
+// InTopic returns the condition that matches devices subscribed to the named
+// topic.  The name may or may not have TopicPrefix.
+func InTopic(name string) *Condition {
+	return &Condition{topic: strings.TrimPrefix(name, TopicPrefix)}
+}
+
+// And returns the condition that matches devices matching all conds.
+func And(conds ...*Condition) *Condition {
+	return &Condition{op: "&&", operands: conds}
+}
+
+// Or returns the condition that matches devices matching any of conds.
+func Or(conds ...*Condition) *Condition {
+	return &Condition{op: "||", operands: conds}
+}
+
+// String returns the condition expression without validating it.
+func (c *Condition) String() string {
+	if c == nil {
+		return ""
+	}
+	if c.op == "" {
+		return fmt.Sprintf("'%s' in topics", c.topic)
+	}
+	exprs := make([]string, len(c.operands))
+	for i, operand := range c.operands {
+		exprs[i] = operand.String()
+		if operand != nil && operand.op != "" && operand.op != c.op && len(operand.operands) > 1 {
+			exprs[i] = "(" + exprs[i] + ")"
+		}
+	}
+	return strings.Join(exprs, " "+c.op+" ")
+}
+
+// Build validates the condition and returns its expression.
+func (c *Condition) Build() (string, error) {
+	n, err := c.validate()
+	if err != nil {
+		return "", err
+	}
+	if n > MaxConditionTopics {
+		return "", fmt.Errorf("condition has %d topics, at most %d are allowed", n, MaxConditionTopics)
+	}
+	return c.String(), nil
+}
+
+// validate returns the number of topics in the condition.
+func (c *Condition) validate() (int, error) {
+	if c == nil {
+		return 0, fmt.Errorf("condition cannot be nil")
+	}
+	if c.op == "" {
+		if err := validateTopic(TopicPrefix + c.topic); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	if len(c.operands) == 0 {
+		return 0, fmt.Errorf("%s requires at least one operand", c.op)
+	}
+	n := 0
+	for _, operand := range c.operands {
+		m, err := operand.validate()
+		if err != nil {
+			return 0, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// SendConditionNoRetry sends a message to the devices matching the condition
+// without retries.
+func (s *Sender) SendConditionNoRetry(msg *Message, condition *Condition) (*Result, error) {
+	return s.SendConditionWithRetries(msg, condition, 0)
+}
+
+// SendConditionWithRetries sends a message to the devices matching the
+// condition with retries.
+func (s *Sender) SendConditionWithRetries(msg *Message, condition *Condition, retries int) (*Result, error) {
+	expr, err := condition.Build()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnrecoverableErrors(s, expr, nil, msg, retries); err != nil {
+		return nil, err
+	}
+	rawMsg := &message{Message: *msg, condition: expr}
+	s.injectIDs(rawMsg)
+	result, err := s.sendWithRetries(rawMsg, retries)
+	s.sent(rawMsg, result, err)
+	return result, withUUID(rawMsg, err)
+}
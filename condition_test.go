@@ -0,0 +1,43 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionBuild(t *testing.T) {
+	params := []struct {
+		cond *Condition
+		expr string
+		err  string
+	}{
+		{InTopic("dogs"), "'dogs' in topics", ""},
+		{InTopic("/topics/dogs"), "'dogs' in topics", ""},
+		{And(InTopic("dogs"), InTopic("cats")), "'dogs' in topics && 'cats' in topics", ""},
+		{And(InTopic("dogs"), Or(InTopic("cats"), InTopic("birds"))), "'dogs' in topics && ('cats' in topics || 'birds' in topics)", ""},
+		{Or(Or(InTopic("a"), InTopic("b")), InTopic("c")), "'a' in topics || 'b' in topics || 'c' in topics", ""},
+		{And(InTopic("a"), InTopic("b"), InTopic("c"), InTopic("d"), InTopic("e"), InTopic("f")), "", "condition has 6 topics, at most 5 are allowed"},
+		{And(), "", "&& requires at least one operand"},
+		{Or(InTopic("it's")), "", `invalid topic "/topics/it's": topic name should match [a-zA-Z0-9-_.~%]+`},
+		{And(InTopic("a"), nil), "", "condition cannot be nil"},
+	}
+	for _, param := range params {
+		expr, err := param.cond.Build()
+		if param.err != "" {
+			assert.EqualError(t, err, param.err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, param.expr, expr)
+		}
+	}
+}
+
+func TestSendCondition(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &response{MessageID: 10}})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	result, err := s.SendConditionNoRetry(msg, And(InTopic("dogs"), InTopic("cats")))
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "10"}, *result)
+}
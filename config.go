@@ -0,0 +1,261 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config configures a Sender, so that deployments can tune it without
+// recompiling.  It is loaded from the environment with LoadConfigFromEnv, or
+// from JSON or YAML with LoadConfig, e.g.
+//
+//	endpoint: https://fcm.googleapis.com/fcm/send
+//	credentials:
+//	  api_key_file: /run/secrets/gcm-api-key
+//	timeout: 10s
+//	retry:
+//	  retries: 3
+//	  device_group: individually
+//	  topic_rate_backoff: 1s
+//	rate_limit:
+//	  requests_per_second: 100
+//	  max_concurrent_requests: 20
+//	  adaptive: true
+//	hooks: [metrics, telemetry]
+//	trace_id_key: trace_id
+type Config struct {
+	// Endpoint is the URL of the GCM connection server.  Empty means
+	// GCMEndpoint.
+	Endpoint    string            `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Credentials CredentialsConfig `json:"credentials" yaml:"credentials"`
+	// Timeout bounds each request.  Zero means no timeout.
+	Timeout   Duration        `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Retry     RetryConfig     `json:"retry" yaml:"retry"`
+	RateLimit RateLimitConfig `json:"rate_limit" yaml:"rate_limit"`
+	// QuietHours sets the QuietHours of a Queue.  It is applied with
+	// Queue.Reload.
+	QuietHours *QuietHoursConfig `json:"quiet_hours,omitempty" yaml:"quiet_hours,omitempty"`
+	// Hooks lists the optional features of the Sender to enable: "metrics"
+	// sets Metrics, "telemetry" sets Telemetry, "partial_result_errors" sets
+	// PartialResultErrors, and "retry_exhausted_errors" sets
+	// RetryExhaustedErrors.
+	Hooks []string `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// TraceIDKey sets the TraceIDKey of the Sender.
+	TraceIDKey string `json:"trace_id_key,omitempty" yaml:"trace_id_key,omitempty"`
+}
+
+// CredentialsConfig tells where the API keys come from: inline, from a file,
+// or from an environment variable, in that order of precedence.
+type CredentialsConfig struct {
+	APIKey              string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	APIKeyFile          string `json:"api_key_file,omitempty" yaml:"api_key_file,omitempty"`
+	APIKeyEnv           string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+	SecondaryAPIKey     string `json:"secondary_api_key,omitempty" yaml:"secondary_api_key,omitempty"`
+	SecondaryAPIKeyFile string `json:"secondary_api_key_file,omitempty" yaml:"secondary_api_key_file,omitempty"`
+	SecondaryAPIKeyEnv  string `json:"secondary_api_key_env,omitempty" yaml:"secondary_api_key_env,omitempty"`
+}
+
+// RetryConfig is the retry policy.
+type RetryConfig struct {
+	// Retries is the number of retries of each send, for the callers of the
+	// *WithRetries methods and the Dispatcher.  It is applied to a
+	// Dispatcher with Dispatcher.Reload.
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+	// DeviceGroup is the DeviceGroupRetry of the Sender: "none",
+	// "individually" or "group".  Empty means none.
+	DeviceGroup string `json:"device_group,omitempty" yaml:"device_group,omitempty"`
+	// TopicRateBackoff sets the TopicRateBackoff of the Sender.
+	TopicRateBackoff Duration `json:"topic_rate_backoff,omitempty" yaml:"topic_rate_backoff,omitempty"`
+}
+
+// RateLimitConfig limits the requests of the Sender.
+type RateLimitConfig struct {
+	// RequestsPerSecond sets the RateLimit of the Sender.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty" yaml:"requests_per_second,omitempty"`
+	// MaxConcurrentRequests sets the MaxConcurrentRequests of the Sender.
+	MaxConcurrentRequests int `json:"max_concurrent_requests,omitempty" yaml:"max_concurrent_requests,omitempty"`
+	// Adaptive gives the Sender a ConcurrencyController, bounded by
+	// MaxConcurrentRequests.
+	Adaptive bool `json:"adaptive,omitempty" yaml:"adaptive,omitempty"`
+	// Classes caps the jobs dequeued per second from a Queue, by class name,
+	// see ClassConfig.RateLimit.  It is applied with Queue.Reload.
+	Classes map[string]float64 `json:"classes,omitempty" yaml:"classes,omitempty"`
+}
+
+// Duration is a time.Duration encoded as a string such as "1m30s", or as a
+// number of seconds.
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a string or a number of seconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var seconds float64
+		if err := json.Unmarshal(b, &seconds); err != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalYAML decodes a string or a number of seconds.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode && value.Tag != "!!str" {
+		var seconds float64
+		if err := value.Decode(&seconds); err != nil {
+			return fmt.Errorf("invalid duration %s", value.Value)
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+var deviceGroupRetryPolicies = map[string]DeviceGroupRetryPolicy{
+	"":             DeviceGroupRetryNone,
+	"none":         DeviceGroupRetryNone,
+	"individually": DeviceGroupRetryIndividually,
+	"group":        DeviceGroupRetryGroup,
+}
+
+// LoadConfig reads a Config in JSON or YAML.  Unknown keys are rejected, so
+// that typos do not go unnoticed.
+func LoadConfig(r io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(config)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(config); err == io.EOF {
+			err = nil // empty document
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return config, nil
+}
+
+// LoadConfigFile reads a Config from a JSON or YAML file, see LoadConfig.
+func LoadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadConfig(f)
+}
+
+// NewSenderFromConfig instantiates a Sender from a JSON or YAML config file.
+func NewSenderFromConfig(path string) (*Sender, error) {
+	config, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return config.NewSender()
+}
+
+// readKey returns the inline key, or the key read from file or env.
+func readKey(key, file, env string) (string, error) {
+	switch {
+	case key != "":
+		return key, nil
+	case file != "":
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	case env != "":
+		if key = os.Getenv(env); key == "" {
+			return "", fmt.Errorf("environment variable %s is not set", env)
+		}
+		return key, nil
+	}
+	return "", nil
+}
+
+// NewSender instantiates a Sender as configured.
+func (c *Config) NewSender() (*Sender, error) {
+	creds := c.Credentials
+	apiKey, err := readKey(creds.APIKey, creds.APIKeyFile, creds.APIKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, errors.New("missing API key")
+	}
+	secondary, err := readKey(creds.SecondaryAPIKey, creds.SecondaryAPIKeyFile, creds.SecondaryAPIKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	policy, ok := deviceGroupRetryPolicies[c.Retry.DeviceGroup]
+	if !ok {
+		return nil, fmt.Errorf("unknown device group retry policy %q", c.Retry.DeviceGroup)
+	}
+
+	s := NewSenderWithHTTPClient(apiKey, &http.Client{Timeout: time.Duration(c.Timeout)})
+	s.Endpoint = c.Endpoint
+	s.SecondaryAPIKey = secondary
+	s.DeviceGroupRetry = policy
+	s.TopicRateBackoff = int(time.Duration(c.Retry.TopicRateBackoff) / time.Millisecond)
+	s.RateLimit = c.RateLimit.RequestsPerSecond
+	s.MaxConcurrentRequests = c.RateLimit.MaxConcurrentRequests
+	if c.RateLimit.Adaptive {
+		s.Concurrency = &ConcurrencyController{Max: c.RateLimit.MaxConcurrentRequests}
+	}
+	s.TraceIDKey = c.TraceIDKey
+	for _, hook := range c.Hooks {
+		switch hook {
+		case "metrics":
+			s.Metrics = &Metrics{}
+		case "telemetry":
+			s.Telemetry = &Telemetry{}
+		case "partial_result_errors":
+			s.PartialResultErrors = true
+		case "retry_exhausted_errors":
+			s.RetryExhaustedErrors = true
+		default:
+			return nil, fmt.Errorf("unknown hook %q", hook)
+		}
+	}
+	return s, nil
+}
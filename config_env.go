@@ -0,0 +1,131 @@
+package gcm
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadConfigFromEnv reads a Config from environment variables, for 12-factor
+// deployments.  Each variable may also be given with the FCM_ prefix instead
+// of GCM_, the GCM_ one taking precedence:
+//
+//	GCM_ENDPOINT                    Endpoint
+//	GCM_API_KEY                     Credentials.APIKey
+//	GCM_API_KEY_FILE                Credentials.APIKeyFile
+//	GCM_SECONDARY_API_KEY           Credentials.SecondaryAPIKey
+//	GCM_SECONDARY_API_KEY_FILE      Credentials.SecondaryAPIKeyFile
+//	GCM_TIMEOUT                     Timeout, e.g. 10s
+//	GCM_RETRIES                     Retry.Retries
+//	GCM_DEVICE_GROUP_RETRY          Retry.DeviceGroup
+//	GCM_TOPIC_RATE_BACKOFF          Retry.TopicRateBackoff, e.g. 1s
+//	GCM_RATE_LIMIT                  RateLimit.RequestsPerSecond
+//	GCM_MAX_CONCURRENT_REQUESTS     RateLimit.MaxConcurrentRequests
+//	GCM_ADAPTIVE_CONCURRENCY        RateLimit.Adaptive, e.g. true
+//	GCM_HOOKS                       Hooks, comma separated
+//	GCM_TRACE_ID_KEY                TraceIDKey
+//
+// Durations are either Go durations or numbers of seconds.
+func LoadConfigFromEnv() (*Config, error) {
+	e := &envLoader{}
+	config := &Config{
+		Endpoint: e.str("ENDPOINT"),
+		Credentials: CredentialsConfig{
+			APIKey:              e.str("API_KEY"),
+			APIKeyFile:          e.str("API_KEY_FILE"),
+			SecondaryAPIKey:     e.str("SECONDARY_API_KEY"),
+			SecondaryAPIKeyFile: e.str("SECONDARY_API_KEY_FILE"),
+		},
+		Timeout: e.duration("TIMEOUT"),
+		Retry: RetryConfig{
+			Retries:          e.int("RETRIES"),
+			DeviceGroup:      e.str("DEVICE_GROUP_RETRY"),
+			TopicRateBackoff: e.duration("TOPIC_RATE_BACKOFF"),
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond:     e.float("RATE_LIMIT"),
+			MaxConcurrentRequests: e.int("MAX_CONCURRENT_REQUESTS"),
+			Adaptive:              e.bool("ADAPTIVE_CONCURRENCY"),
+		},
+		TraceIDKey: e.str("TRACE_ID_KEY"),
+	}
+	if hooks := e.str("HOOKS"); hooks != "" {
+		for _, hook := range strings.Split(hooks, ",") {
+			if hook = strings.TrimSpace(hook); hook != "" {
+				config.Hooks = append(config.Hooks, hook)
+			}
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return config, nil
+}
+
+// envLoader reads GCM_ or FCM_ variables, keeping the first parse error.
+type envLoader struct {
+	err error
+}
+
+func (e *envLoader) lookup(name string) (string, string) {
+	for _, prefix := range []string{"GCM_", "FCM_"} {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			return prefix + name, strings.TrimSpace(v)
+		}
+	}
+	return "", ""
+}
+
+func (e *envLoader) str(name string) string {
+	_, v := e.lookup(name)
+	return v
+}
+
+func (e *envLoader) parse(name string, parse func(string) error) {
+	key, v := e.lookup(name)
+	if v == "" || e.err != nil {
+		return
+	}
+	if err := parse(v); err != nil {
+		e.err = fmt.Errorf("invalid %s %q: %v", key, v, err)
+	}
+}
+
+func (e *envLoader) int(name string) (n int) {
+	e.parse(name, func(v string) (err error) {
+		n, err = strconv.Atoi(v)
+		return err
+	})
+	return n
+}
+
+func (e *envLoader) float(name string) (f float64) {
+	e.parse(name, func(v string) (err error) {
+		f, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	return f
+}
+
+func (e *envLoader) bool(name string) (b bool) {
+	e.parse(name, func(v string) (err error) {
+		b, err = strconv.ParseBool(v)
+		return err
+	})
+	return b
+}
+
+func (e *envLoader) duration(name string) (d Duration) {
+	e.parse(name, func(v string) error {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			d = Duration(seconds * float64(time.Second))
+			return nil
+		}
+		parsed, err := time.ParseDuration(v)
+		d = Duration(parsed)
+		return err
+	})
+	return d
+}
@@ -0,0 +1,156 @@
+package gcm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `
+# GCM sender
+endpoint: "http://localhost:8080/send"  # local emulator
+credentials:
+  api_key_env: TEST_GCM_API_KEY
+  secondary_api_key: 'old key'
+timeout: 10s
+retry:
+  retries: 3
+  device_group: individually
+  topic_rate_backoff: 1.5
+rate_limit:
+  requests_per_second: 100
+  max_concurrent_requests: 20
+  adaptive: true
+hooks:
+  - metrics
+  - partial_result_errors
+trace_id_key: trace
+`
+
+func TestLoadConfigYAML(t *testing.T) {
+	config, err := LoadConfig(strings.NewReader(yamlConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{
+		Endpoint:    "http://localhost:8080/send",
+		Credentials: CredentialsConfig{APIKeyEnv: "TEST_GCM_API_KEY", SecondaryAPIKey: "old key"},
+		Timeout:     Duration(10 * time.Second),
+		Retry:       RetryConfig{Retries: 3, DeviceGroup: "individually", TopicRateBackoff: Duration(1500 * time.Millisecond)},
+		RateLimit:   RateLimitConfig{RequestsPerSecond: 100, MaxConcurrentRequests: 20, Adaptive: true},
+		Hooks:       []string{"metrics", "partial_result_errors"},
+		TraceIDKey:  "trace",
+	}, config)
+
+	os.Setenv("TEST_GCM_API_KEY", "key")
+	defer os.Unsetenv("TEST_GCM_API_KEY")
+	s, err := config.NewSender()
+	assert.NoError(t, err)
+	assert.Equal(t, "key", s.APIKey)
+	assert.Equal(t, "old key", s.SecondaryAPIKey)
+	assert.Equal(t, "http://localhost:8080/send", s.Endpoint)
+	assert.Equal(t, 10*time.Second, s.Client.Timeout)
+	assert.Equal(t, DeviceGroupRetryIndividually, s.DeviceGroupRetry)
+	assert.Equal(t, 1500, s.TopicRateBackoff)
+	assert.Equal(t, 100.0, s.RateLimit)
+	assert.Equal(t, 20, s.MaxConcurrentRequests)
+	assert.Equal(t, 20, s.Concurrency.Max)
+	assert.NotNil(t, s.Metrics)
+	assert.True(t, s.PartialResultErrors)
+	assert.Equal(t, "trace", s.TraceIDKey)
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	config, err := LoadConfig(strings.NewReader(`{"credentials": {"api_key": "key"}, "hooks": ["telemetry"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{Credentials: CredentialsConfig{APIKey: "key"}, Hooks: []string{"telemetry"}}, config)
+
+	_, err = LoadConfig(strings.NewReader(`{"api_key": "key"}`))
+	assert.EqualError(t, err, `invalid config: json: unknown field "api_key"`)
+}
+
+func TestLoadConfigYAMLScalars(t *testing.T) {
+	config, err := LoadConfig(strings.NewReader("credentials:\n  api_key: 0123\n  secondary_api_key: \"caf\\xe9\"\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, CredentialsConfig{APIKey: "0123", SecondaryAPIKey: "café"}, config.Credentials)
+
+	_, err = LoadConfig(strings.NewReader("api_key: key"))
+	assert.Error(t, err)
+	config, err = LoadConfig(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, config)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for _, config := range []string{
+		"credentials:\n  api_key: key\n    extra: true",
+		"timeout 10s",
+		"timeout: 10s\ntimeout: 20s",
+		"timeout: ten seconds",
+		"hooks: [metrics",
+	} {
+		_, err := LoadConfig(strings.NewReader(config))
+		assert.Error(t, err, config)
+	}
+}
+
+func TestNewSenderFromConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyFile, configFile := filepath.Join(dir, "key"), filepath.Join(dir, "gcm.yaml")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("secret\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte("credentials:\n  api_key_file: "+keyFile+"\n"), 0600))
+	s, err := NewSenderFromConfig(configFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", s.APIKey)
+
+	_, err = (&Config{}).NewSender()
+	assert.EqualError(t, err, "missing API key")
+	_, err = (&Config{Credentials: CredentialsConfig{APIKey: "key"}, Hooks: []string{"bogus"}}).NewSender()
+	assert.EqualError(t, err, `unknown hook "bogus"`)
+	_, err = (&Config{Credentials: CredentialsConfig{APIKey: "key"}, Retry: RetryConfig{DeviceGroup: "all"}}).NewSender()
+	assert.EqualError(t, err, `unknown device group retry policy "all"`)
+}
+
+func setenv(t *testing.T, env map[string]string) func() {
+	for k, v := range env {
+		assert.NoError(t, os.Setenv(k, v))
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	defer setenv(t, map[string]string{
+		"GCM_API_KEY":                 "key",
+		"FCM_API_KEY":                 "ignored",
+		"FCM_ENDPOINT":                "http://localhost:8080/send",
+		"GCM_TIMEOUT":                 "10s",
+		"GCM_RETRIES":                 "3",
+		"GCM_TOPIC_RATE_BACKOFF":      "1.5",
+		"GCM_MAX_CONCURRENT_REQUESTS": "20",
+		"GCM_ADAPTIVE_CONCURRENCY":    "true",
+		"GCM_HOOKS":                   "metrics, telemetry",
+	})()
+	config, err := LoadConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{
+		Endpoint:    "http://localhost:8080/send",
+		Credentials: CredentialsConfig{APIKey: "key"},
+		Timeout:     Duration(10 * time.Second),
+		Retry:       RetryConfig{Retries: 3, TopicRateBackoff: Duration(1500 * time.Millisecond)},
+		RateLimit:   RateLimitConfig{MaxConcurrentRequests: 20, Adaptive: true},
+		Hooks:       []string{"metrics", "telemetry"},
+	}, config)
+
+	defer setenv(t, map[string]string{"GCM_RETRIES": "three"})()
+	_, err = LoadConfigFromEnv()
+	assert.EqualError(t, err, `invalid GCM_RETRIES "three": strconv.Atoi: parsing "three": invalid syntax`)
+}
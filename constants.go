@@ -3,4 +3,7 @@ package gcm
 const (
 	// TopicPrefix is the prefix for topics.
 	TopicPrefix = "/topics/"
+	// MaxRegistrationIDs is the max number of registration IDs in a multicast
+	// message.
+	MaxRegistrationIDs = 1000
 )
@@ -0,0 +1,268 @@
+package gcm
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoDashboardSender = errors.New("the dashboard has no sender")
+
+// defaultDashboardRecent is the number of recent sends a Dashboard shows when
+// Recent is not set.
+const defaultDashboardRecent = 50
+
+// Dashboard is an http.Handler serving a self-contained HTML page for
+// monitoring a Dispatcher: its recent sends, the breakdown of their errors,
+// the depth of its Queue, the state of its load shedder and concurrency
+// controller, and a form sending dry-run test messages.
+//
+// Feed it the outcome of each job with Record, e.g. from the OnResult of the
+// Dispatcher.  Mount it under a prefix with http.StripPrefix.
+//
+// Dashboard is safe for concurrent use.
+type Dashboard struct {
+	Dispatcher *Dispatcher
+	// Recent is the number of recent sends shown.  Zero means 50.
+	Recent int
+
+	mu     sync.Mutex
+	recent []dashboardSend // ring of recent sends
+	next   int
+	errors map[string]int
+}
+
+type dashboardSend struct {
+	Time    time.Time
+	Class   string
+	To      string
+	Success int
+	Failure int
+	Error   string
+	Latency time.Duration
+}
+
+// Record records the outcome of a job.
+func (db *Dashboard) Record(jr *JobResult) {
+	send := dashboardSend{Time: time.Now(), Class: jr.Job.Class.String(), To: jr.Job.To, Latency: jr.Latency}
+	if len(jr.Job.RegistrationIDs) > 0 {
+		send.To = strings.Join(jr.Job.RegistrationIDs[:min(3, len(jr.Job.RegistrationIDs))], ", ")
+		if len(jr.Job.RegistrationIDs) > 3 {
+			send.To += ", ..."
+		}
+	}
+	var errCodes []string
+	switch {
+	case jr.Err != nil:
+		send.Failure, send.Error = 1, jr.Err.Error()
+		errCodes = append(errCodes, "send error")
+	case jr.Result != nil:
+		if jr.Result.Error != "" {
+			send.Failure, send.Error = 1, jr.Result.Error
+			errCodes = append(errCodes, jr.Result.Error)
+		} else {
+			send.Success = 1
+		}
+	case jr.MulticastResult != nil:
+		send.Success, send.Failure = jr.MulticastResult.Success, jr.MulticastResult.Failure
+		for _, res := range jr.MulticastResult.Results {
+			if res.Error != "" {
+				errCodes = append(errCodes, res.Error)
+			}
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	size := db.Recent
+	if size <= 0 {
+		size = defaultDashboardRecent
+	}
+	if len(db.recent) < size {
+		db.recent = append(db.recent, send)
+	} else {
+		db.recent[db.next] = send
+		db.next = (db.next + 1) % len(db.recent)
+	}
+	if db.errors == nil {
+		db.errors = make(map[string]int)
+	}
+	for _, errCode := range errCodes {
+		db.errors[errCode]++
+	}
+}
+
+type dashboardError struct {
+	Error string
+	Count int
+}
+
+type dashboardPage struct {
+	Now         time.Time
+	Recent      []dashboardSend
+	Errors      []dashboardError
+	Sender      *SenderStats
+	Queue       *QueueStats
+	Paused      bool
+	Shedding    bool
+	Concurrency int
+	DeadLetters int
+	Compose     *composeResult
+}
+
+type composeResult struct {
+	To     string
+	Result *Result
+	Err    error
+}
+
+func (db *Dashboard) page() *dashboardPage {
+	p := &dashboardPage{Now: time.Now()}
+	db.mu.Lock()
+	// most recent first
+	for i := len(db.recent) - 1; i >= 0; i-- {
+		p.Recent = append(p.Recent, db.recent[(db.next+i)%len(db.recent)])
+	}
+	for errCode, n := range db.errors {
+		p.Errors = append(p.Errors, dashboardError{errCode, n})
+	}
+	db.mu.Unlock()
+	sort.Slice(p.Errors, func(i, j int) bool {
+		if p.Errors[i].Count != p.Errors[j].Count {
+			return p.Errors[i].Count > p.Errors[j].Count
+		}
+		return p.Errors[i].Error < p.Errors[j].Error
+	})
+
+	d := db.Dispatcher
+	if d == nil {
+		return p
+	}
+	p.Paused = d.Paused()
+	if d.Sender != nil {
+		stats := d.Sender.Stats()
+		p.Sender = &stats
+		if d.Sender.Concurrency != nil {
+			p.Concurrency = d.Sender.Concurrency.Limit()
+		}
+	}
+	if d.Queue != nil {
+		stats := d.Queue.Stats()
+		p.Queue = &stats
+		if d.Queue.shedder != nil {
+			p.Shedding = d.Queue.shedder.Shedding()
+		}
+	}
+	if d.DeadLetters != nil {
+		p.DeadLetters = len(d.DeadLetters.List())
+	}
+	return p
+}
+
+// compose sends the dry-run test message of the form.
+func (db *Dashboard) compose(r *http.Request) *composeResult {
+	c := &composeResult{To: r.FormValue("to")}
+	if db.Dispatcher == nil || db.Dispatcher.Sender == nil {
+		c.Err = errNoDashboardSender
+		return c
+	}
+	msg := &Message{DryRun: true}
+	if title, body := r.FormValue("title"), r.FormValue("body"); title != "" || body != "" {
+		msg.Notification = &Notification{Title: title, Body: body}
+	}
+	for _, line := range strings.Split(r.FormValue("data"), "\n") {
+		if kv := strings.SplitN(strings.TrimSpace(line), "=", 2); len(kv) == 2 {
+			if msg.Data == nil {
+				msg.Data = make(map[string]string)
+			}
+			msg.Data[kv[0]] = kv[1]
+		}
+	}
+	c.Result, c.Err = db.Dispatcher.Sender.SendNoRetry(msg, c.To)
+	return c
+}
+
+// ServeHTTP serves the dashboard on GET, and sends the test message of the
+// compose form on POST.
+func (db *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "" {
+		http.NotFound(w, r)
+		return
+	}
+	p := db.page()
+	switch r.Method {
+	case "GET":
+	case "POST":
+		p.Compose = db.compose(r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	dashboardTemplate.Execute(w, p)
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>GCM dispatch</title>
+<style>
+body { font: 14px sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; }
+.bad { color: #b00; }
+.ok { color: #070; }
+textarea, input[type=text] { width: 30em; }
+</style>
+</head>
+<body>
+<h1>GCM dispatch</h1>
+<p>As of {{.Now.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>State</h2>
+<table>
+<tr><th>Workers</th><td>{{if .Paused}}<span class="bad">paused</span>{{else}}<span class="ok">running</span>{{end}}</td></tr>
+<tr><th>Load shedding</th><td>{{if .Shedding}}<span class="bad">shedding</span>{{else}}<span class="ok">off</span>{{end}}</td></tr>
+{{if .Concurrency}}<tr><th>Concurrency limit</th><td>{{.Concurrency}}</td></tr>{{end}}
+{{with .Queue}}<tr><th>Queue depth</th><td>{{.Depth}}{{range $class, $n := .ClassDepth}} ({{$class}}: {{$n}}){{end}}</td></tr>
+<tr><th>Dropped</th><td>{{.Dropped}}</td></tr>{{end}}
+<tr><th>Dead letters</th><td>{{.DeadLetters}}</td></tr>
+{{with .Sender}}<tr><th>Requests</th><td>{{.Requests}} ({{.Failures}} failed)</td></tr>{{end}}
+</table>
+
+<h2>Errors</h2>
+{{if .Errors}}<table>
+<tr><th>Error</th><th>Count</th></tr>
+{{range .Errors}}<tr><td>{{.Error}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+{{with .Sender}}{{if .LastErrors}}<table>
+<tr><th>Time</th><th>Request error</th></tr>
+{{range .LastErrors}}<tr><td>{{.Time.Format "15:04:05"}}</td><td class="bad">{{.Error}}</td></tr>
+{{end}}</table>{{end}}{{end}}
+
+<h2>Recent sends</h2>
+{{if .Recent}}<table>
+<tr><th>Time</th><th>Class</th><th>To</th><th>Success</th><th>Failure</th><th>Latency</th><th>Error</th></tr>
+{{range .Recent}}<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Class}}</td><td>{{.To}}</td><td>{{.Success}}</td><td>{{.Failure}}</td><td>{{.Latency}}</td><td class="bad">{{.Error}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+
+<h2>Compose test message</h2>
+<p>Test messages are sent as dry runs and never reach devices.</p>
+{{with .Compose}}<p>{{if .Err}}<span class="bad">Failed: {{.Err}}</span>{{else if .Result.Error}}<span class="bad">{{.To}}: {{.Result.Error}}</span>{{else}}<span class="ok">{{.To}}: accepted</span>{{end}}</p>{{end}}
+<form method="post">
+<p><label>To<br><input type="text" name="to" required></label></p>
+<p><label>Title<br><input type="text" name="title"></label></p>
+<p><label>Body<br><input type="text" name="body"></label></p>
+<p><label>Data (key=value per line)<br><textarea name="data" rows="4"></textarea></label></p>
+<p><input type="submit" value="Send dry run"></p>
+</form>
+</body>
+</html>
+`))
@@ -0,0 +1,52 @@
+package gcm
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboard(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "pending"}))
+	db := &Dashboard{Dispatcher: &Dispatcher{Queue: q, Sender: NewSender("test-api-key")}, Recent: 2}
+	db.Record(&JobResult{Job: &Job{Class: ClassTransactional, To: "first"}, Result: &Result{MessageID: "id"}})
+	db.Record(&JobResult{Job: &Job{Class: ClassTransactional, To: "second"}, Err: errors.New("boom")})
+	db.Record(&JobResult{
+		Job:             &Job{Class: ClassReminder, RegistrationIDs: []string{"a", "b"}},
+		MulticastResult: &MulticastResult{Success: 1, Failure: 1, Results: []Result{{MessageID: "id"}, {Error: ErrorNotRegistered}}},
+	})
+
+	w := httptest.NewRecorder()
+	db.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	page := w.Body.String()
+	// only the 2 most recent sends are kept
+	assert.False(t, strings.Contains(page, "first"))
+	assert.True(t, strings.Index(page, "a, b") < strings.Index(page, "second"))
+	assert.True(t, strings.Contains(page, "<td>NotRegistered</td><td>1</td>"))
+	assert.True(t, strings.Contains(page, "<td>send error</td><td>1</td>"))
+	assert.True(t, strings.Contains(page, "(marketing: 1)"))
+
+	form := url.Values{"to": {"regId"}, "title": {"<hi>"}, "data": {"k=v\nbad"}}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	db.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "regId: accepted"))
+
+	w = httptest.NewRecorder()
+	db.ServeHTTP(w, httptest.NewRequest("DELETE", "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = httptest.NewRecorder()
+	db.ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,108 @@
+package gcm
+
+import "errors"
+
+// DeviceGroupRetryPolicy defines how the members of a device group that failed
+// to receive a message are retried.
+type DeviceGroupRetryPolicy int
+
+const (
+	// DeviceGroupRetryNone considers a partially successful device group
+	// message successful and does not retry it.
+	DeviceGroupRetryNone DeviceGroupRetryPolicy = iota
+	// DeviceGroupRetryIndividually resends the message to the failed members
+	// as a multicast message.
+	DeviceGroupRetryIndividually
+	// DeviceGroupRetryGroup resends the message to the whole device group,
+	// which also delivers it again to the members that already received it.
+	DeviceGroupRetryGroup
+)
+
+// retryDeviceGroup retries the failed members of a device group message
+// according to the DeviceGroupRetry policy of the Sender, and reports the
+// members that received the message in RecoveredRegistrationIDs.  The retries
+// are part of the send of the message, so they do not notify the Listener or
+// the Telemetry again.  If they end on an error, it is returned in a
+// *PartialResultError along with the result when the Sender has
+// PartialResultErrors.
+func (s *Sender) retryDeviceGroup(rawMsg *message, result *Result, policy DeviceGroupRetryPolicy, retries, backoff int) (*Result, error) {
+	failed := result.FailedRegistrationIDs
+	var stillFailed []string
+	var lastErr error
+	switch policy {
+	case DeviceGroupRetryIndividually:
+		s.sleep(rawMsg, backoff)
+		members := *rawMsg
+		members.to, members.condition, members.registrationIds = "", "", failed
+		multicastResult, abortErr, err := s.multicastWithRetries(&members, retries-1)
+		if err != nil {
+			return s.partialResult(result, err)
+		}
+		for i, res := range multicastResult.Results {
+			if res.MessageID == "" {
+				stillFailed = append(stillFailed, failed[i])
+			}
+		}
+		lastErr = abortErr
+	case DeviceGroupRetryGroup:
+		stillFailed = failed
+		for ; retries > 0 && len(stillFailed) > 0; retries-- {
+			backoff = s.sleep(rawMsg, backoff)
+			res, err := s.send(rawMsg)
+			if err == nil && res.Error != "" {
+				err = errors.New(res.Error)
+			}
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			lastErr = nil
+			stillFailed = intersect(stillFailed, res.FailedRegistrationIDs)
+		}
+	default:
+		return result, nil
+	}
+
+	recovered := len(failed) - len(stillFailed)
+	if recovered == 0 {
+		return s.partialResult(result, lastErr)
+	}
+	retried := *result
+	retried.Success += recovered
+	retried.Failure -= recovered
+	retried.FailedRegistrationIDs = stillFailed
+	for _, regID := range failed {
+		if !contains(stillFailed, regID) {
+			retried.RecoveredRegistrationIDs = append(retried.RecoveredRegistrationIDs, regID)
+		}
+	}
+	return s.partialResult(&retried, lastErr)
+}
+
+// partialResult returns result with err, if any, in a *PartialResultError
+// when the Sender has PartialResultErrors.
+func (s *Sender) partialResult(result *Result, err error) (*Result, error) {
+	if err != nil && s.PartialResultErrors {
+		return result, &PartialResultError{err}
+	}
+	return result, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func intersect(a, b []string) []string {
+	var both []string
+	for _, item := range a {
+		if contains(b, item) {
+			both = append(both, item)
+		}
+	}
+	return both
+}
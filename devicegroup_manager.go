@@ -0,0 +1,248 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// NotificationKeyEndpoint defines the endpoint for managing device groups
+	// on the GCM connection server owned by Google.
+	NotificationKeyEndpoint = "https://android.googleapis.com/gcm/notification"
+	// FCMNotificationKeyEndpoint defines the endpoint for managing device
+	// groups on the FCM connection server by Firebase.
+	FCMNotificationKeyEndpoint = "https://fcm.googleapis.com/fcm/notification"
+)
+
+// DeviceGroupEndpoint by default points to the device group management
+// endpoint owned by Google, but can be otherwise set to a different URL if
+// needed (e.g. FCMNotificationKeyEndpoint).
+var DeviceGroupEndpoint = NotificationKeyEndpoint
+
+// DeviceGroupError is returned when the connection server rejects a device
+// group operation.
+type DeviceGroupError struct {
+	Operation  string
+	Name       string
+	StatusCode int
+	Reason     string
+}
+
+func (e *DeviceGroupError) Error() string {
+	return fmt.Sprintf("device group %s %q failed with %d: %s", e.Operation, e.Name, e.StatusCode, e.Reason)
+}
+
+// DeviceGroupManager creates device groups and manages their members.  Refer
+// to https://firebase.google.com/docs/cloud-messaging/android/device-group.
+type DeviceGroupManager struct {
+	// APIKey is the server key of the project.
+	APIKey string
+	// SenderID is the sender ID of the project, which owns the device groups.
+	SenderID string
+	// Client makes the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// KeyCache, if set, caches notification keys so that sends to a device
+	// group by name do not look up its notification key every time.
+	KeyCache *NotificationKeyCache
+}
+
+// NewDeviceGroupManager instantiates a DeviceGroupManager for the project
+// given its server key and sender ID.
+func NewDeviceGroupManager(apiKey, senderID string) *DeviceGroupManager {
+	return &DeviceGroupManager{APIKey: apiKey, SenderID: senderID}
+}
+
+type deviceGroupRequest struct {
+	Operation           string   `json:"operation"`
+	NotificationKeyName string   `json:"notification_key_name"`
+	NotificationKey     string   `json:"notification_key,omitempty"`
+	RegistrationIDs     []string `json:"registration_ids"`
+}
+
+type deviceGroupResponse struct {
+	NotificationKey string `json:"notification_key"`
+	Err             string `json:"error"`
+}
+
+// Create creates the named device group with the registration IDs and returns
+// its notification key.
+func (m *DeviceGroupManager) Create(name string, regIDs []string) (string, error) {
+	return m.modify(&deviceGroupRequest{"create", name, "", regIDs})
+}
+
+// Add adds the registration IDs to the device group with the name and
+// notification key.
+func (m *DeviceGroupManager) Add(name, key string, regIDs []string) error {
+	_, err := m.modify(&deviceGroupRequest{"add", name, key, regIDs})
+	return err
+}
+
+// Remove removes the registration IDs from the device group with the name and
+// notification key.  A device group without members is deleted.
+func (m *DeviceGroupManager) Remove(name, key string, regIDs []string) error {
+	_, err := m.modify(&deviceGroupRequest{"remove", name, key, regIDs})
+	if err == nil {
+		// the group may have been deleted
+		m.KeyCache.Invalidate(m.SenderID, name)
+	}
+	return err
+}
+
+// GetNotificationKey returns the notification key of the named device group,
+// from the KeyCache if possible.
+func (m *DeviceGroupManager) GetNotificationKey(name string) (string, error) {
+	if key, ok := m.KeyCache.Get(m.SenderID, name); ok {
+		return key, nil
+	}
+	u := DeviceGroupEndpoint + "?" + url.Values{"notification_key_name": {name}}.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	key, err := m.do(req, "get", name)
+	if err != nil {
+		return "", err
+	}
+	m.KeyCache.Put(m.SenderID, name, key)
+	return key, nil
+}
+
+// Send sends a message with retries to the named device group.
+func (m *DeviceGroupManager) Send(s *Sender, msg *Message, name string, retries int) (*Result, error) {
+	key, err := m.GetNotificationKey(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.SendWithRetries(msg, key, retries)
+}
+
+func (m *DeviceGroupManager) modify(r *deviceGroupRequest) (string, error) {
+	if len(r.RegistrationIDs) == 0 {
+		return "", errors.New("missing registration ids")
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", DeviceGroupEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	key, err := m.do(req, r.Operation, r.NotificationKeyName)
+	if err != nil {
+		return "", err
+	}
+	m.KeyCache.Put(m.SenderID, r.NotificationKeyName, key)
+	return key, nil
+}
+
+func (m *DeviceGroupManager) do(req *http.Request, op, name string) (string, error) {
+	if m.APIKey == "" {
+		return "", errors.New("missing API key")
+	}
+	if m.SenderID == "" {
+		return "", errors.New("missing sender ID")
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("key=%s", m.APIKey))
+	req.Header.Add("project_id", m.SenderID)
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var r deviceGroupResponse
+	json.Unmarshal(body, &r)
+	if resp.StatusCode != http.StatusOK {
+		reason := r.Err
+		if reason == "" {
+			reason = resp.Status
+		}
+		return "", &DeviceGroupError{op, name, resp.StatusCode, reason}
+	}
+	if r.NotificationKey == "" {
+		return "", fmt.Errorf("expected notification_key, but found: %s", body)
+	}
+	return r.NotificationKey, nil
+}
+
+// NotificationKeyCache caches the notification keys of device groups by sender
+// ID and key name for TTL.  A nil *NotificationKeyCache caches nothing.
+//
+// NotificationKeyCache is safe for concurrent use, and can be shared by the
+// DeviceGroupManagers of several projects.
+type NotificationKeyCache struct {
+	// TTL is how long a key is cached.  Zero means forever.
+	TTL time.Duration
+
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+type cachedKey struct {
+	key     string
+	expires time.Time
+}
+
+func cacheKey(senderID, name string) string {
+	return senderID + "/" + name
+}
+
+// Get returns the cached notification key of the named device group.
+func (c *NotificationKeyCache) Get(senderID, name string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k, ok := c.keys[cacheKey(senderID, name)]
+	if !ok || !k.expires.IsZero() && time.Now().After(k.expires) {
+		return "", false
+	}
+	return k.key, true
+}
+
+// Put caches the notification key of the named device group.
+func (c *NotificationKeyCache) Put(senderID, name, key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]cachedKey)
+	}
+	k := cachedKey{key: key}
+	if c.TTL > 0 {
+		k.expires = time.Now().Add(c.TTL)
+	}
+	c.keys[cacheKey(senderID, name)] = k
+}
+
+// Invalidate removes the notification key of the named device group from the
+// cache.
+func (c *NotificationKeyCache) Invalidate(senderID, name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.keys, cacheKey(senderID, name))
+}
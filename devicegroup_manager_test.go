@@ -0,0 +1,142 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deviceGroupServer fakes the device group management endpoint.
+type deviceGroupServer struct {
+	mu      sync.Mutex
+	groups  map[string][]string // members by notification key name
+	lookups int
+}
+
+func startDeviceGroupServer(t *testing.T) (*httptest.Server, *deviceGroupServer) {
+	dg := &deviceGroupServer{groups: make(map[string][]string)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dg.mu.Lock()
+		defer dg.mu.Unlock()
+		assert.Equal(t, "key=test-api-key", r.Header.Get("Authorization"))
+		assert.Equal(t, "sender", r.Header.Get("project_id"))
+		reply := func(status int, resp deviceGroupResponse) {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(resp)
+		}
+		if r.Method == "GET" {
+			dg.lookups++
+			name := r.URL.Query().Get("notification_key_name")
+			if _, ok := dg.groups[name]; !ok {
+				reply(http.StatusBadRequest, deviceGroupResponse{Err: "notification_key not found"})
+				return
+			}
+			reply(http.StatusOK, deviceGroupResponse{NotificationKey: "key-" + name})
+			return
+		}
+		var req deviceGroupRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		name := req.NotificationKeyName
+		members, exists := dg.groups[name]
+		switch {
+		case req.Operation == "create" && exists:
+			reply(http.StatusBadRequest, deviceGroupResponse{Err: "notification_key already exists"})
+			return
+		case req.Operation != "create" && (!exists || req.NotificationKey != "key-"+name):
+			reply(http.StatusBadRequest, deviceGroupResponse{Err: "notification_key not found"})
+			return
+		case req.Operation == "remove":
+			var left []string
+			for _, m := range members {
+				if !contains(req.RegistrationIDs, m) {
+					left = append(left, m)
+				}
+			}
+			members = left
+		default:
+			members = append(members, req.RegistrationIDs...)
+		}
+		if len(members) == 0 {
+			delete(dg.groups, name)
+		} else {
+			sort.Strings(members)
+			dg.groups[name] = members
+		}
+		reply(http.StatusOK, deviceGroupResponse{NotificationKey: "key-" + name})
+	}))
+	DeviceGroupEndpoint = server.URL
+	return server, dg
+}
+
+func TestDeviceGroupManager(t *testing.T) {
+	server, dg := startDeviceGroupServer(t)
+	defer server.Close()
+	m := NewDeviceGroupManager("test-api-key", "sender")
+
+	key, err := m.Create("group", []string{"1", "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "key-group", key)
+	assert.NoError(t, m.Add("group", key, []string{"3"}))
+	assert.NoError(t, m.Remove("group", key, []string{"1"}))
+	assert.Equal(t, []string{"2", "3"}, dg.groups["group"])
+
+	key, err = m.GetNotificationKey("group")
+	assert.NoError(t, err)
+	assert.Equal(t, "key-group", key)
+
+	_, err = m.Create("group", []string{"4"})
+	assert.EqualError(t, err, `device group create "group" failed with 400: notification_key already exists`)
+	_, err = m.GetNotificationKey("other")
+	assert.IsType(t, &DeviceGroupError{}, err)
+	assert.EqualError(t, m.Add("group", key, nil), "missing registration ids")
+}
+
+func TestDeviceGroupManagerKeyCache(t *testing.T) {
+	server, dg := startDeviceGroupServer(t)
+	defer server.Close()
+	m := NewDeviceGroupManager("test-api-key", "sender")
+	m.KeyCache = &NotificationKeyCache{TTL: time.Hour}
+	dg.groups["group"] = []string{"1"}
+
+	for i := 0; i < 3; i++ {
+		key, err := m.GetNotificationKey("group")
+		assert.NoError(t, err)
+		assert.Equal(t, "key-group", key)
+	}
+	assert.Equal(t, 1, dg.lookups)
+
+	// a removal may delete the group
+	assert.NoError(t, m.Remove("group", "key-group", []string{"1"}))
+	_, err := m.GetNotificationKey("group")
+	assert.Error(t, err)
+	assert.Equal(t, 2, dg.lookups)
+
+	// keys are cached per sender ID
+	m.KeyCache.Put("other-sender", "group", "other-key")
+	_, ok := m.KeyCache.Get("sender", "group")
+	assert.False(t, ok)
+
+	expired := &NotificationKeyCache{TTL: time.Nanosecond}
+	expired.Put("sender", "group", "key")
+	time.Sleep(time.Millisecond)
+	_, ok = expired.Get("sender", "group")
+	assert.False(t, ok)
+}
+
+func TestDeviceGroupManagerSend(t *testing.T) {
+	server, dg := startDeviceGroupServer(t)
+	defer server.Close()
+	gcmServer := startTestServer(t, &testResponse{response: &response{Success: 1}})
+	defer gcmServer.Close()
+	dg.groups["group"] = []string{"1"}
+	m := NewDeviceGroupManager("test-api-key", "sender")
+	result, err := m.Send(NewSender("test-api-key"), msg, "group", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Success)
+}
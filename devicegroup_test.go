@@ -0,0 +1,102 @@
+package gcm
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendDeviceGroupRetryIndividually(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{response: &response{MulticastID: 1, Success: 1, Failure: 1, Results: []result{{MessageID: "m1"}, {Err: ErrorNotRegistered}}}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key").WithRandSource(rand.NewSource(1))
+	s.DeviceGroupRetry = DeviceGroupRetryIndividually
+	result, err := s.SendWithRetries(msg, "group", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{
+		Success:                  2,
+		Failure:                  1,
+		FailedRegistrationIDs:    []string{"id2"},
+		RecoveredRegistrationIDs: []string{"id1"},
+	}, *result)
+}
+
+func TestSendDeviceGroupRetryGroup(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{response: &response{Success: 3}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.DeviceGroupRetry = DeviceGroupRetryGroup
+	result, err := s.SendWithRetries(msg, "group", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{
+		Success:                  3,
+		RecoveredRegistrationIDs: []string{"id1", "id2"},
+	}, *result)
+}
+
+func TestSendDeviceGroupRetryWithoutRetries(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &partialDeviceGroup})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.DeviceGroupRetry = DeviceGroupRetryGroup
+	result, err := s.SendWithRetries(msg, "group", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Success: 1, Failure: 2, FailedRegistrationIDs: []string{"id1", "id2"}}, *result)
+}
+
+func TestSendDeviceGroupRetryNotifiesOnce(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{response: &response{MulticastID: 1, Success: 2, Results: []result{{MessageID: "m1"}, {MessageID: "m2"}}}},
+	)
+	defer server.Close()
+	l := &recordingListener{}
+	s := NewSender("test-api-key")
+	s.DeviceGroupRetry = DeviceGroupRetryIndividually
+	s.Listener = l
+	_, err := s.SendWithRetries(msg, "group", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"attempt 1", "retry 2", "attempt 2", "success "}, l.events)
+}
+
+func TestSendDeviceGroupRetryError(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{statusCode: http.StatusBadRequest},
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{statusCode: http.StatusBadRequest},
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.DeviceGroupRetry = DeviceGroupRetryIndividually
+	partial := Result{Success: 1, Failure: 2, FailedRegistrationIDs: []string{"id1", "id2"}}
+
+	result, err := s.SendWithRetries(msg, "group", 1)
+	assert.NoError(t, err, "the error is only returned with PartialResultErrors")
+	assert.Equal(t, partial, *result)
+
+	s.PartialResultErrors = true
+	result, err = s.SendWithRetries(msg, "group", 1)
+	var partialErr *PartialResultError
+	assert.True(t, errors.As(err, &partialErr))
+	assert.EqualError(t, partialErr.Err, "400 error: 400 Bad Request")
+	assert.Equal(t, partial, *result)
+
+	s.DeviceGroupRetry = DeviceGroupRetryGroup
+	result, err = s.SendWithRetries(msg, "group", 2)
+	assert.True(t, errors.As(err, &partialErr))
+	assert.EqualError(t, partialErr.Err, "503 error: 503 Service Unavailable")
+	assert.Equal(t, partial, *result)
+}
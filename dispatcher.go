@@ -0,0 +1,275 @@
+package gcm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// JobResult is the outcome of a dispatched job.  Result is set for jobs sent to
+// a single recipient, MulticastResult for jobs sent to RegistrationIDs.
+type JobResult struct {
+	Job             *Job
+	Result          *Result
+	MulticastResult *MulticastResult
+	// Suppressed lists the recipients skipped by the PreferenceChecker of the
+	// Sender.  MulticastResult only covers the other RegistrationIDs, and
+	// neither result is set if all recipients were skipped.
+	Suppressed []string
+	Err        error
+	Latency    time.Duration
+}
+
+// Dispatcher sends the jobs of a Queue with a pool of workers.
+type Dispatcher struct {
+	// Queue is the source of jobs.
+	Queue *Queue
+	// Sender sends the jobs.
+	Sender *Sender
+	// Workers is the number of concurrent workers.  Zero means 1.
+	Workers int
+	// Retries is the number of retries for each job.
+	Retries int
+	// OnResult, if set, is called with the outcome of each job.
+	OnResult func(*JobResult)
+	// Results, if set, receives the outcome of each job, published for App.
+	Results *ResultBus
+	// App names the app the Sender sends for on Results.
+	App string
+	// PanicPolicy decides what happens when OnResult panics, so that a buggy
+	// callback cannot take down the workers.
+	PanicPolicy PanicPolicy
+	// DeadLetters, if set, keeps the jobs that failed with an error so that
+	// they can be inspected and retried later.
+	DeadLetters *DeadLetterQueue
+	// MaxFailures, if positive, makes the Dispatcher enqueue a job again when
+	// sending it fails with a retryable error or panics, until it has failed
+	// MaxFailures times.  A job that fails with a non-retryable error, or too
+	// many times, is poison and is quarantined in DeadLetters with its
+	// failure history instead of looping forever.  Zero means failed jobs are
+	// not enqueued again.
+	MaxFailures int
+
+	// Leaser, if set, makes the Dispatcher dispatch only while it holds the
+	// lease on Partition, so that dispatchers in several regions can share
+	// the same partition with failover.  While another owner holds the lease,
+	// Run keeps waiting for it even if the Queue is closed.
+	Leaser Leaser
+	// Partition is the name of the leased partition.
+	Partition string
+	// LeaseOwner identifies this Dispatcher to the Leaser.  Empty means the
+	// host name and process id.
+	LeaseOwner string
+	// LeaseTTL is how long a lease lasts without renewal.  Zero means 30s.
+	LeaseTTL time.Duration
+	// HighWatermark is the Queue depth above which Status reports the
+	// Dispatcher as Degraded.  Zero means the check is disabled.
+	HighWatermark int
+
+	pauseMu sync.Mutex
+	resumed chan struct{} // non-nil while paused
+
+	failMu   sync.Mutex
+	failures map[*Job][]Failure // failure history of jobs enqueued again
+
+	reloadMu        sync.RWMutex
+	reloadedRetries *int // set by Reload
+}
+
+// Run dispatches jobs until the Queue is closed and drained.  While the
+// Dispatcher is paused, Run does not return until it is resumed.
+func (d *Dispatcher) Run() {
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var gate *leaseGate
+	stop, released := make(chan struct{}), make(chan struct{})
+	if d.Leaser != nil {
+		gate = newLeaseGate()
+		go func() {
+			defer close(released)
+			d.holdLease(gate, stop)
+		}()
+	} else {
+		close(released)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				if gate != nil {
+					gate.wait(stop)
+				}
+				d.waitResumed()
+				job, ok := d.Queue.Dequeue()
+				if !ok {
+					return
+				}
+				if gate != nil {
+					// the lease may have been lost while waiting for a job
+					gate.wait(stop)
+				}
+				d.waitResumed()
+				d.dispatch(job)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-released
+}
+
+// sendJob sends job with retries to the recipients allowed by the
+// PreferenceChecker of the Sender, recovering from a panic of the Sender
+// according to policy, in which case it also reports that it panicked.
+func sendJob(s *Sender, job *Job, retries int, policy PanicPolicy) (*JobResult, bool) {
+	jr := &JobResult{Job: job}
+	start := time.Now()
+	panicErr := protect(policy, "Sender", func() {
+		var send *Job
+		send, jr.Suppressed, jr.Err = s.checkPreferences(job)
+		switch {
+		case send == nil:
+		case len(send.RegistrationIDs) > 0:
+			jr.MulticastResult, jr.Err = s.SendMulticastWithRetries(send.Message, send.RegistrationIDs, retries)
+		default:
+			jr.Result, jr.Err = s.SendWithRetries(send.Message, send.To, retries)
+		}
+	})
+	if panicErr != nil {
+		jr.Err = panicErr
+	}
+	jr.Latency = time.Since(start)
+	return jr, panicErr != nil
+}
+
+func (d *Dispatcher) dispatch(job *Job) {
+	jr, panicked := sendJob(d.Sender, job, d.retries(), d.PanicPolicy)
+
+	if shedder := d.Queue.shedder; shedder != nil {
+		shedder.Observe(jr.Latency, jr.serverFailed())
+	}
+	requeued := false
+	if jr.Err != nil {
+		requeued = d.failed(job, jr.Err, panicked, jr.delivered())
+	} else if d.MaxFailures > 0 {
+		d.failMu.Lock()
+		delete(d.failures, job)
+		d.failMu.Unlock()
+	}
+	if !requeued {
+		if err := d.Queue.Ack(job); err != nil {
+			log.Printf("failed to acknowledge job: %v", err)
+		}
+	}
+	if d.OnResult != nil {
+		protect(d.PanicPolicy, "OnResult", func() { d.OnResult(jr) })
+	}
+	if d.Results != nil {
+		d.Results.Publish(d.App, jr)
+	}
+}
+
+// failed handles a job whose sending failed with err, either enqueueing it
+// again or putting it in DeadLetters, and reports whether it was enqueued again.
+// A job delivered to some of its recipients is never enqueued again, since
+// they would get the message twice.
+func (d *Dispatcher) failed(job *Job, err error, panicked, delivered bool) bool {
+	if d.MaxFailures <= 0 {
+		if d.DeadLetters != nil {
+			d.DeadLetters.Add(job, err)
+		}
+		return false
+	}
+	d.failMu.Lock()
+	history := append(d.failures[job], Failure{Error: err.Error(), Time: time.Now()})
+	poison := len(history) >= d.MaxFailures || delivered || !panicked && !isRetryable(err)
+	if poison {
+		delete(d.failures, job)
+	} else {
+		if d.failures == nil {
+			d.failures = make(map[*Job][]Failure)
+		}
+		d.failures[job] = history
+	}
+	d.failMu.Unlock()
+
+	if !poison {
+		d.Queue.requeue(job)
+		return true
+	}
+	if d.DeadLetters != nil {
+		d.DeadLetters.Quarantine(job, history)
+	}
+	return false
+}
+
+// Retryable reports whether sending a message may succeed later after failing
+// with err, e.g. for services wrapping the Sender to tell their clients.
+func Retryable(err error) bool {
+	return isRetryable(err)
+}
+
+// isRetryable reports whether sending a message may succeed later after
+// failing with err.
+func isRetryable(err error) bool {
+	var prefErr *PreferenceError
+	if errors.As(err, &prefErr) {
+		return true
+	}
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		return httpErr.statusCode >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// delivered reports whether the message of the job reached some of its
+// recipients, e.g. before an error ended the retries of a multicast.
+func (jr *JobResult) delivered() bool {
+	if jr.Result != nil {
+		return jr.Result.MessageID != "" || jr.Result.Success > 0
+	}
+	return jr.MulticastResult != nil && jr.MulticastResult.Success > 0
+}
+
+// serverFailed reports whether the job failed because of the GCM connection
+// server rather than because of the message or its recipients.
+func (jr *JobResult) serverFailed() bool {
+	if jr.Err != nil {
+		var httpErr httpError
+		if errors.As(jr.Err, &httpErr) {
+			return httpErr.statusCode >= http.StatusInternalServerError
+		}
+		// e.g. validation errors are the caller's, not the server's
+		var urlErr *url.Error
+		var netErr net.Error
+		return errors.As(jr.Err, &urlErr) || errors.As(jr.Err, &netErr) && netErr.Timeout() ||
+			errors.Is(jr.Err, context.DeadlineExceeded)
+	}
+	if jr.Result != nil {
+		return isUnavailable(jr.Result.Error)
+	}
+	if jr.MulticastResult != nil {
+		for _, res := range jr.MulticastResult.Results {
+			if isUnavailable(res.Error) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isUnavailable(errCode string) bool {
+	return errCode == ErrorUnavailable || errCode == ErrorInternalServerError
+}
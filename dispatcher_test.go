@@ -0,0 +1,165 @@
+package gcm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcherRun(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &response{MulticastID: 1, Success: 2, Results: []result{{MessageID: "id1"}, {MessageID: "id2"}}}},
+	)
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "regId"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, RegistrationIDs: twoRecipients}))
+	q.Close()
+
+	var mu sync.Mutex
+	var results []*JobResult
+	d := &Dispatcher{
+		Queue:  q,
+		Sender: NewSender("test-api-key"),
+		OnResult: func(jr *JobResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, jr)
+		},
+	}
+	d.Run()
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, Result{MessageID: "id"}, *results[0].Result)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, 2, results[1].MulticastResult.Success)
+}
+
+func TestDispatcherQuarantinesNonRetryable(t *testing.T) {
+	server := startTestServer(t, &testResponse{statusCode: http.StatusBadRequest, body: "bad"})
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	job := &Job{Class: ClassTransactional, Message: msg, To: "regId"}
+	assert.NoError(t, q.Enqueue(job))
+	q.Close()
+
+	d := &Dispatcher{Queue: q, Sender: NewSender("test-api-key"), MaxFailures: 3, DeadLetters: &DeadLetterQueue{}}
+	d.Run()
+	letters := d.DeadLetters.List()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, job, letters[0].Job)
+	assert.Len(t, letters[0].History, 1)
+}
+
+func TestDispatcherQuarantinesPoison(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	poison := &Job{Class: ClassTransactional, Message: &Message{Data: map[string]string{"poison": "1"}}, To: "regId"}
+	retried := &Job{Class: ClassTransactional, Message: msg, To: "regId"}
+	assert.NoError(t, q.Enqueue(poison))
+	assert.NoError(t, q.Enqueue(retried))
+	q.Close()
+
+	s := NewSender("test-api-key")
+	s.PanicPolicy = PanicPropagate
+	s.Signer = RequestSignerFunc(func(req *http.Request, body []byte) error {
+		if strings.Contains(string(body), "poison") {
+			panic("boom")
+		}
+		return nil
+	})
+	var mu sync.Mutex
+	var errs []error
+	d := &Dispatcher{
+		Queue:       q,
+		Sender:      s,
+		MaxFailures: 3,
+		PanicPolicy: PanicCount,
+		DeadLetters: &DeadLetterQueue{},
+		OnResult: func(jr *JobResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			if jr.Job == retried {
+				errs = append(errs, jr.Err)
+			}
+		},
+	}
+	d.Run()
+	letters := d.DeadLetters.List()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, poison, letters[0].Job)
+	assert.Len(t, letters[0].History, 3)
+	assert.Equal(t, "panic in Sender: boom", letters[0].Error)
+	assert.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.NoError(t, errs[1])
+}
+
+// partialResultSender returns a Sender whose multicasts end with partial
+// results and a retryable *url.Error after the first attempt.
+func partialResultSender() *Sender {
+	s := NewSender("test-api-key")
+	s.PartialResultErrors = true
+	attempts := 0
+	s.Signer = RequestSignerFunc(func(req *http.Request, body []byte) error {
+		if attempts++; attempts > 1 {
+			return &url.Error{Op: "Post", URL: req.URL.String(), Err: errors.New("connection reset")}
+		}
+		return nil
+	})
+	return s
+}
+
+func TestDispatcherDoesNotRequeuePartialResults(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &partialMulticast})
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	job := &Job{Class: ClassTransactional, Message: msg, RegistrationIDs: twoRecipients}
+	assert.NoError(t, q.Enqueue(job))
+	q.Close()
+
+	var results []*JobResult
+	d := &Dispatcher{
+		Queue:       q,
+		Sender:      partialResultSender(),
+		Retries:     1,
+		MaxFailures: 3,
+		DeadLetters: &DeadLetterQueue{},
+		OnResult:    func(jr *JobResult) { results = append(results, jr) },
+	}
+	d.Run()
+	assert.Len(t, results, 1)
+	assert.True(t, Retryable(results[0].Err))
+	letters := d.DeadLetters.List()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, job, letters[0].Job)
+	assert.Len(t, letters[0].History, 1)
+}
+
+func TestJobResultServerFailed(t *testing.T) {
+	for err, expected := range map[error]bool{
+		httpError{http.StatusServiceUnavailable, "503"}:                    true,
+		&RetryExhaustedError{Err: httpError{http.StatusBadGateway, "502"}}: true,
+		&url.Error{Op: "Post", Err: errors.New("connection reset")}:        true,
+		context.DeadlineExceeded:                                           true,
+		httpError{http.StatusBadRequest, "400"}:                            false,
+		errors.New("message cannot be nil"):                                false,
+		&PreferenceError{errors.New("unavailable")}:                        false,
+		&TopicError{Name: "/topics/gcm", Reason: "reserved"}:               false,
+	} {
+		assert.Equal(t, expected, (&JobResult{Err: err}).serverFailed(), err.Error())
+	}
+	assert.True(t, (&JobResult{Result: &Result{Error: ErrorUnavailable}}).serverFailed())
+	assert.False(t, (&JobResult{Result: &Result{Error: ErrorNotRegistered}}).serverFailed())
+}
@@ -0,0 +1,43 @@
+package gcm
+
+import "errors"
+
+// DryRunDiff is a registration token for which two dry runs of a message got
+// different outcomes.
+type DryRunDiff struct {
+	RegistrationID string
+	Before         Result
+	After          Result
+}
+
+// DryRunCompare sends the message as a dry run to the registration IDs with
+// both Senders, retrying with retries, and returns the registration IDs whose
+// errors or canonical registration IDs differ.  It helps validating a change of
+// configuration, e.g. a migration from GCM to FCM, without disturbing users.
+func DryRunCompare(before, after *Sender, msg *Message, regIDs []string, retries int) ([]DryRunDiff, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	dryRun := *msg
+	dryRun.DryRun = true
+
+	var diffs []DryRunDiff
+	err := forEachBatch(regIDs, MaxRegistrationIDs, func(batch []string) error {
+		beforeResult, err := before.SendMulticastWithRetries(&dryRun, batch, retries)
+		if err != nil {
+			return err
+		}
+		afterResult, err := after.SendMulticastWithRetries(&dryRun, batch, retries)
+		if err != nil {
+			return err
+		}
+		for i, regID := range batch {
+			b, a := beforeResult.Results[i], afterResult.Results[i]
+			if b.Error != a.Error || b.CanonicalRegistrationID != a.CanonicalRegistrationID {
+				diffs = append(diffs, DryRunDiff{regID, b, a})
+			}
+		}
+		return nil
+	})
+	return diffs, err
+}
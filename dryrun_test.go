@@ -0,0 +1,20 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunCompare(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 2, Results: []result{{MessageID: "fake"}, {MessageID: "fake"}}}},
+		&testResponse{response: &response{Success: 1, Failure: 1, Results: []result{{MessageID: "fake"}, {Err: ErrorMismatchSenderID}}}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	diffs, err := DryRunCompare(s, s, msg, twoRecipients, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []DryRunDiff{{"2", Result{MessageID: "fake"}, Result{Error: ErrorMismatchSenderID}}}, diffs)
+	assert.False(t, msg.DryRun)
+}
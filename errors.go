@@ -15,3 +15,158 @@ const (
 	ErrorDeviceMessageRateExceeded = "DeviceMessageRateExceeded"
 	ErrorTopicsMessageRateExceeded = "TopicsMessageRateExceeded"
 )
+
+// ErrorCode is a protocol independent error code.  The error strings of the
+// legacy HTTP protocol and the canonical error codes of the HTTP v1 API that
+// mean the same thing map to the same ErrorCode, so that application logic
+// survives a protocol migration unchanged.
+type ErrorCode int
+
+const (
+	// ErrorCodeNone means no error.
+	ErrorCodeNone ErrorCode = iota
+	// ErrorCodeUnknown is any error not listed below.
+	ErrorCodeUnknown
+	ErrorCodeMissingRegistration
+	ErrorCodeInvalidRegistration
+	// ErrorCodeUnregistered: NotRegistered, UNREGISTERED.
+	ErrorCodeUnregistered
+	ErrorCodeInvalidPackageName
+	// ErrorCodeSenderIDMismatch: MismatchSenderId, SENDER_ID_MISMATCH.
+	ErrorCodeSenderIDMismatch
+	ErrorCodeMessageTooBig
+	ErrorCodeInvalidDataKey
+	ErrorCodeInvalidTTL
+	// ErrorCodeInvalidArgument: INVALID_ARGUMENT.
+	ErrorCodeInvalidArgument
+	// ErrorCodeUnavailable: Unavailable, UNAVAILABLE.
+	ErrorCodeUnavailable
+	// ErrorCodeInternal: InternalServerError, INTERNAL.
+	ErrorCodeInternal
+	// ErrorCodeQuotaExceeded: DeviceMessageRateExceeded,
+	// TopicsMessageRateExceeded, QUOTA_EXCEEDED.
+	ErrorCodeQuotaExceeded
+	// ErrorCodeThirdPartyAuth: THIRD_PARTY_AUTH_ERROR.
+	ErrorCodeThirdPartyAuth
+)
+
+var errorCodes = map[string]ErrorCode{
+	"":                             ErrorCodeNone,
+	ErrorMissingRegistration:       ErrorCodeMissingRegistration,
+	ErrorInvalidRegistration:       ErrorCodeInvalidRegistration,
+	ErrorNotRegistered:             ErrorCodeUnregistered,
+	ErrorInvalidPackageName:        ErrorCodeInvalidPackageName,
+	ErrorMismatchSenderID:          ErrorCodeSenderIDMismatch,
+	ErrorMessageTooBig:             ErrorCodeMessageTooBig,
+	ErrorInvalidDataKey:            ErrorCodeInvalidDataKey,
+	ErrorInvalidTTL:                ErrorCodeInvalidTTL,
+	ErrorUnavailable:               ErrorCodeUnavailable,
+	ErrorInternalServerError:       ErrorCodeInternal,
+	ErrorDeviceMessageRateExceeded: ErrorCodeQuotaExceeded,
+	ErrorTopicsMessageRateExceeded: ErrorCodeQuotaExceeded,
+	// HTTP v1 API, refer to https://firebase.google.com/docs/reference/fcm/rest/v1/ErrorCode.
+	"UNSPECIFIED_ERROR":      ErrorCodeUnknown,
+	"INVALID_ARGUMENT":       ErrorCodeInvalidArgument,
+	"UNREGISTERED":           ErrorCodeUnregistered,
+	"SENDER_ID_MISMATCH":     ErrorCodeSenderIDMismatch,
+	"QUOTA_EXCEEDED":         ErrorCodeQuotaExceeded,
+	"UNAVAILABLE":            ErrorCodeUnavailable,
+	"INTERNAL":               ErrorCodeInternal,
+	"THIRD_PARTY_AUTH_ERROR": ErrorCodeThirdPartyAuth,
+}
+
+var errorCodeNames = []string{
+	"None",
+	"Unknown",
+	"MissingRegistration",
+	"InvalidRegistration",
+	"Unregistered",
+	"InvalidPackageName",
+	"SenderIDMismatch",
+	"MessageTooBig",
+	"InvalidDataKey",
+	"InvalidTTL",
+	"InvalidArgument",
+	"Unavailable",
+	"Internal",
+	"QuotaExceeded",
+	"ThirdPartyAuth",
+}
+
+// ParseErrorCode maps a legacy error string or an HTTP v1 error code to an
+// ErrorCode.
+func ParseErrorCode(s string) ErrorCode {
+	if code, ok := errorCodes[s]; ok {
+		return code
+	}
+	return ErrorCodeUnknown
+}
+
+func (c ErrorCode) String() string {
+	if c >= 0 && int(c) < len(errorCodeNames) {
+		return errorCodeNames[c]
+	}
+	return "Unknown"
+}
+
+// ErrorCode returns the protocol independent code of the result's error.
+func (r *Result) ErrorCode() ErrorCode {
+	return ParseErrorCode(r.Error)
+}
+
+// Action is a coarse category of what to do about an error.
+type Action int
+
+const (
+	// ActionNone means there is no error to act on.
+	ActionNone Action = iota
+	// ActionRemoveToken means the registration token is no longer valid and
+	// should be removed.
+	ActionRemoveToken
+	// ActionRetryLater means the message can be sent again later, with
+	// exponential backoff.
+	ActionRetryLater
+	// ActionFixPayload means the message is invalid and must be fixed before
+	// it is sent again.
+	ActionFixPayload
+	// ActionFixConfig means the sender is misconfigured, e.g. with the wrong
+	// API key or package name.
+	ActionFixConfig
+	// ActionFatal means the error is not understood and should not be retried.
+	ActionFatal
+)
+
+var actionNames = []string{"None", "RemoveToken", "RetryLater", "FixPayload", "FixConfig", "Fatal"}
+
+func (a Action) String() string {
+	if a >= 0 && int(a) < len(actionNames) {
+		return actionNames[a]
+	}
+	return "Fatal"
+}
+
+var errorCodeActions = map[ErrorCode]Action{
+	ErrorCodeNone:                ActionNone,
+	ErrorCodeMissingRegistration: ActionFixPayload,
+	ErrorCodeInvalidRegistration: ActionRemoveToken,
+	ErrorCodeUnregistered:        ActionRemoveToken,
+	ErrorCodeInvalidPackageName:  ActionFixConfig,
+	ErrorCodeSenderIDMismatch:    ActionFixConfig,
+	ErrorCodeMessageTooBig:       ActionFixPayload,
+	ErrorCodeInvalidDataKey:      ActionFixPayload,
+	ErrorCodeInvalidTTL:          ActionFixPayload,
+	ErrorCodeInvalidArgument:     ActionFixPayload,
+	ErrorCodeUnavailable:         ActionRetryLater,
+	ErrorCodeInternal:            ActionRetryLater,
+	ErrorCodeQuotaExceeded:       ActionRetryLater,
+	ErrorCodeThirdPartyAuth:      ActionFixConfig,
+}
+
+// Classify maps a legacy error string or an HTTP v1 error code to the Action
+// to take about it.
+func Classify(errCode string) Action {
+	if action, ok := errorCodeActions[ParseErrorCode(errCode)]; ok {
+		return action
+	}
+	return ActionFatal
+}
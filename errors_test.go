@@ -0,0 +1,55 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrorCode(t *testing.T) {
+	params := []struct {
+		s    string
+		code ErrorCode
+	}{
+		{"", ErrorCodeNone},
+		{ErrorNotRegistered, ErrorCodeUnregistered},
+		{"UNREGISTERED", ErrorCodeUnregistered},
+		{ErrorMismatchSenderID, ErrorCodeSenderIDMismatch},
+		{"SENDER_ID_MISMATCH", ErrorCodeSenderIDMismatch},
+		{ErrorTopicsMessageRateExceeded, ErrorCodeQuotaExceeded},
+		{ErrorDeviceMessageRateExceeded, ErrorCodeQuotaExceeded},
+		{"QUOTA_EXCEEDED", ErrorCodeQuotaExceeded},
+		{ErrorInternalServerError, ErrorCodeInternal},
+		{"INTERNAL", ErrorCodeInternal},
+		{"SomethingNew", ErrorCodeUnknown},
+	}
+	for _, param := range params {
+		assert.Equal(t, param.code, ParseErrorCode(param.s), param.s)
+	}
+	assert.Equal(t, ErrorCodeUnregistered, (&Result{Error: ErrorNotRegistered}).ErrorCode())
+	assert.Equal(t, "SenderIDMismatch", ErrorCodeSenderIDMismatch.String())
+	assert.Equal(t, "ThirdPartyAuth", ErrorCodeThirdPartyAuth.String())
+}
+
+func TestClassify(t *testing.T) {
+	params := []struct {
+		s      string
+		action Action
+	}{
+		{"", ActionNone},
+		{ErrorNotRegistered, ActionRemoveToken},
+		{ErrorInvalidRegistration, ActionRemoveToken},
+		{"UNREGISTERED", ActionRemoveToken},
+		{ErrorUnavailable, ActionRetryLater},
+		{ErrorDeviceMessageRateExceeded, ActionRetryLater},
+		{ErrorMessageTooBig, ActionFixPayload},
+		{"INVALID_ARGUMENT", ActionFixPayload},
+		{ErrorMismatchSenderID, ActionFixConfig},
+		{"THIRD_PARTY_AUTH_ERROR", ActionFixConfig},
+		{"SomethingNew", ActionFatal},
+	}
+	for _, param := range params {
+		assert.Equal(t, param.action, Classify(param.s), param.s)
+	}
+	assert.Equal(t, "RemoveToken", ActionRemoveToken.String())
+}
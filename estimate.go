@@ -0,0 +1,105 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultBroadcastLatency is the request latency assumed by EstimateBroadcast
+// when the BroadcastConfig does not set one.
+const DefaultBroadcastLatency = 100 * time.Millisecond
+
+// estimatedTokenSize is the assumed size in bytes of a registration token in
+// a request, including JSON quoting and separator.
+const estimatedTokenSize = 166
+
+// BroadcastConfig describes how a broadcast is sent, for capacity planning.
+type BroadcastConfig struct {
+	// Shards is the number of shards sent in parallel, as with
+	// SendMulticastSharded.  Zero means 1.
+	Shards int
+	// BatchSize is the number of recipients per request.  Zero or more than
+	// MaxRegistrationIDs means MaxRegistrationIDs.
+	BatchSize int
+	// Latency is the expected latency of a request.  Zero means
+	// DefaultBroadcastLatency.
+	Latency time.Duration
+}
+
+// BroadcastEstimate is the projected cost of a broadcast.
+type BroadcastEstimate struct {
+	// Requests is the number of requests to the GCM connection server.
+	Requests int
+	// Bytes is the approximate number of bytes sent.
+	Bytes int64
+	// Duration is how long sending all requests takes.
+	Duration time.Duration
+	// PeakQPS is the max number of requests per second.
+	PeakQPS float64
+}
+
+// EstimateBroadcast estimates the cost of broadcasting a payload of
+// payloadSize bytes to audienceSize registration tokens, at up to rateLimit
+// requests per second, with the default BroadcastConfig.  Zero rateLimit means
+// unlimited.
+func EstimateBroadcast(audienceSize, payloadSize int, rateLimit float64) (*BroadcastEstimate, error) {
+	return BroadcastConfig{}.EstimateBroadcast(audienceSize, payloadSize, rateLimit)
+}
+
+// EstimateBroadcast estimates the cost of broadcasting a payload of
+// payloadSize bytes to audienceSize registration tokens, at up to rateLimit
+// requests per second, with the config.  Zero rateLimit means unlimited.
+func (c BroadcastConfig) EstimateBroadcast(audienceSize, payloadSize int, rateLimit float64) (*BroadcastEstimate, error) {
+	if audienceSize < 0 {
+		return nil, errors.New("audience size cannot be negative")
+	}
+	if payloadSize > MaxPayloadSize {
+		return nil, fmt.Errorf("payload of %d bytes exceeds %d bytes", payloadSize, MaxPayloadSize)
+	}
+	shards, batch, latency := c.Shards, c.BatchSize, c.Latency
+	if shards < 1 {
+		shards = 1
+	}
+	if batch <= 0 || batch > MaxRegistrationIDs {
+		batch = MaxRegistrationIDs
+	}
+	if latency <= 0 {
+		latency = DefaultBroadcastLatency
+	}
+
+	// tokens are spread evenly over the shards, each batched on its own
+	perShard := (audienceSize + shards - 1) / shards
+	requestsPerShard := (perShard + batch - 1) / batch
+	requests := 0
+	for i, left := 0, audienceSize; i < shards && left > 0; i++ {
+		n := perShard
+		if left < n {
+			n = left
+		}
+		requests += (n + batch - 1) / batch
+		left -= n
+	}
+	active := shards
+	if requests < active {
+		active = requests
+	}
+
+	est := &BroadcastEstimate{
+		Requests: requests,
+		Bytes:    int64(requests)*int64(payloadSize) + int64(audienceSize)*estimatedTokenSize,
+	}
+	if requests == 0 {
+		return est, nil
+	}
+	est.PeakQPS = float64(active) / latency.Seconds()
+	est.Duration = time.Duration(requestsPerShard) * latency
+	if rateLimit > 0 && rateLimit < est.PeakQPS {
+		est.PeakQPS = rateLimit
+		if d := time.Duration(math.Ceil(float64(requests) / rateLimit * float64(time.Second))); d > est.Duration {
+			est.Duration = d
+		}
+	}
+	return est, nil
+}
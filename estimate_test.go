@@ -0,0 +1,36 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateBroadcast(t *testing.T) {
+	est, err := EstimateBroadcast(2500, 1000, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, est.Requests)
+	assert.Equal(t, int64(3*1000+2500*estimatedTokenSize), est.Bytes)
+	assert.Equal(t, 300*time.Millisecond, est.Duration)
+	assert.Equal(t, 10.0, est.PeakQPS)
+
+	config := BroadcastConfig{Shards: 4, BatchSize: 500, Latency: time.Second}
+	est, err = config.EstimateBroadcast(1000000, 100, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2000, est.Requests)
+	assert.Equal(t, 500*time.Second, est.Duration)
+	assert.Equal(t, 4.0, est.PeakQPS)
+
+	est, err = config.EstimateBroadcast(1000000, 100, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000*time.Second, est.Duration)
+	assert.Equal(t, 2.0, est.PeakQPS)
+
+	est, err = EstimateBroadcast(0, 100, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &BroadcastEstimate{}, est)
+
+	_, err = EstimateBroadcast(1, MaxPayloadSize+1, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package gcm
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrJobShed is the reason given to EventListener.OnDrop for jobs dropped by a
+// LoadShedder.
+var ErrJobShed = errors.New("job shed under load")
+
+// EventListener is notified of the lifecycle events of queued jobs and sent
+// messages, so that metrics, auditing and the like can be built on top of
+// Queue and Sender.  Listeners are called synchronously and must be safe for
+// concurrent use.  Embed NopEventListener to implement only some of the
+// methods.
+type EventListener interface {
+	// OnEnqueue is called when a job is added to a Queue.
+	OnEnqueue(job *Job)
+	// OnDrop is called when a Queue drops a job, with ErrQueueFull or
+	// ErrJobShed as the reason, or when it rejects a batch of a Coalescer,
+	// with the error of Enqueue as the reason.
+	OnDrop(job *Job, reason error)
+	// OnAttempt is called before each request made to send a message,
+	// starting with attempt 1.
+	OnAttempt(msg *Message, attempt int)
+	// OnRetryScheduled is called when the next attempt to send a message is
+	// made after delay.
+	OnRetryScheduled(msg *Message, attempt int, delay time.Duration)
+	// OnSuccess is called when a message is sent, with a result per
+	// recipient.  Results may still hold errors for individual recipients.
+	OnSuccess(msg *Message, results []Result)
+	// OnFailure is called when sending a message fails with an error.
+	OnFailure(msg *Message, err error)
+}
+
+// NopEventListener is an EventListener that does nothing.
+type NopEventListener struct{}
+
+func (NopEventListener) OnEnqueue(job *Job)                                              {}
+func (NopEventListener) OnDrop(job *Job, reason error)                                   {}
+func (NopEventListener) OnAttempt(msg *Message, attempt int)                             {}
+func (NopEventListener) OnRetryScheduled(msg *Message, attempt int, delay time.Duration) {}
+func (NopEventListener) OnSuccess(msg *Message, results []Result)                        {}
+func (NopEventListener) OnFailure(msg *Message, err error)                               {}
+
+// MultiEventListener returns an EventListener that notifies each of the
+// listeners in order.
+func MultiEventListener(listeners ...EventListener) EventListener {
+	return multiEventListener(listeners)
+}
+
+type multiEventListener []EventListener
+
+func (m multiEventListener) OnEnqueue(job *Job) {
+	for _, l := range m {
+		l.OnEnqueue(job)
+	}
+}
+
+func (m multiEventListener) OnDrop(job *Job, reason error) {
+	for _, l := range m {
+		l.OnDrop(job, reason)
+	}
+}
+
+func (m multiEventListener) OnAttempt(msg *Message, attempt int) {
+	for _, l := range m {
+		l.OnAttempt(msg, attempt)
+	}
+}
+
+func (m multiEventListener) OnRetryScheduled(msg *Message, attempt int, delay time.Duration) {
+	for _, l := range m {
+		l.OnRetryScheduled(msg, attempt, delay)
+	}
+}
+
+func (m multiEventListener) OnSuccess(msg *Message, results []Result) {
+	for _, l := range m {
+		l.OnSuccess(msg, results)
+	}
+}
+
+func (m multiEventListener) OnFailure(msg *Message, err error) {
+	for _, l := range m {
+		l.OnFailure(msg, err)
+	}
+}
+
+// attempted notifies the Sender's Listener of an attempt to send the message.
+func (s *Sender) attempted(m *message) {
+	m.attempts++
+	if s.Listener != nil {
+		protect(s.PanicPolicy, "OnAttempt", func() { s.Listener.OnAttempt(&m.Message, m.attempts) })
+	}
+}
+
+// done notifies the Sender's Listener of the outcome of sending the message.
+func (s *Sender) done(m *message, err error, results ...Result) {
+	if s.Listener == nil {
+		return
+	}
+	if err != nil {
+		protect(s.PanicPolicy, "OnFailure", func() { s.Listener.OnFailure(&m.Message, err) })
+	} else {
+		protect(s.PanicPolicy, "OnSuccess", func() { s.Listener.OnSuccess(&m.Message, results) })
+	}
+}
+
+func (s *Sender) sent(m *message, result *Result, err error) {
+	if err != nil {
+		s.done(m, err)
+	} else {
+		s.done(m, nil, *result)
+	}
+}
+
+func (q *Queue) notifyDrops(jobs []*Job, reason error) {
+	if q.listener == nil {
+		return
+	}
+	for _, job := range jobs {
+		protect(q.panicPolicy, "OnDrop", func() { q.listener.OnDrop(job, reason) })
+	}
+}
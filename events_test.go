@@ -0,0 +1,73 @@
+package gcm
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingListener struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *recordingListener) record(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingListener) OnEnqueue(job *Job) { l.record("enqueue %s", job.To) }
+func (l *recordingListener) OnDrop(job *Job, reason error) {
+	l.record("drop %s: %v", job.To, reason)
+}
+func (l *recordingListener) OnAttempt(msg *Message, attempt int) { l.record("attempt %d", attempt) }
+func (l *recordingListener) OnRetryScheduled(msg *Message, attempt int, delay time.Duration) {
+	l.record("retry %d", attempt)
+}
+func (l *recordingListener) OnSuccess(msg *Message, results []Result) {
+	l.record("success %s", results[0].MessageID)
+}
+func (l *recordingListener) OnFailure(msg *Message, err error) { l.record("failure %v", err) }
+
+func TestSenderListener(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{response: &success},
+		&testResponse{statusCode: http.StatusBadRequest},
+	)
+	defer server.Close()
+	l := &recordingListener{}
+	s := NewSender("test-api-key")
+	s.Listener = MultiEventListener(NopEventListener{}, l)
+	_, err := s.SendWithRetries(msg, "regId", 1)
+	assert.NoError(t, err)
+	_, err = s.SendMulticastNoRetry(msg, []string{"regId"})
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"attempt 1",
+		"retry 2",
+		"attempt 2",
+		"success id",
+		"attempt 1",
+		"failure 400 error: 400 Bad Request",
+	}, l.events)
+}
+
+func TestQueueListener(t *testing.T) {
+	l := &recordingListener{}
+	q := NewQueue(QueueConfig{Capacity: 1, FullPolicy: FullPolicyDropOldest, Listener: l})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "2"}))
+	assert.Equal(t, ErrQueueFull, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "3"}))
+	assert.Equal(t, []string{
+		"enqueue 1",
+		"enqueue 2",
+		"drop 1: queue is full",
+		"drop 3: queue is full",
+	}, l.events)
+}
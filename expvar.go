@@ -0,0 +1,28 @@
+package gcm
+
+import "expvar"
+
+// DefaultExpvarPrefix is the prefix used by PublishExpvar when none is given.
+const DefaultExpvarPrefix = "gcm"
+
+// PublishExpvar publishes the statistics of the Sender and the Queue via expvar
+// as "<prefix>.sender" and "<prefix>.queue", which makes them visible at
+// /debug/vars.  Either may be nil.  An empty prefix means DefaultExpvarPrefix.
+//
+// Like expvar.Publish, it panics if a name is already published, so call it
+// once per prefix.
+func PublishExpvar(prefix string, s *Sender, q *Queue) {
+	if prefix == "" {
+		prefix = DefaultExpvarPrefix
+	}
+	if s != nil {
+		expvar.Publish(prefix+".sender", expvar.Func(func() interface{} {
+			return s.Stats()
+		}))
+	}
+	if q != nil {
+		expvar.Publish(prefix+".queue", expvar.Func(func() interface{} {
+			return q.Stats()
+		}))
+	}
+}
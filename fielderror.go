@@ -0,0 +1,111 @@
+package gcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxErrorBodySize is the max number of bytes read from the body of an error
+// response.
+const maxErrorBodySize = 64 << 10
+
+// FieldError describes a message field likely rejected by the connection
+// server.
+type FieldError struct {
+	Field       string
+	Description string
+}
+
+func (e FieldError) String() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Description)
+}
+
+// BadRequestError is returned when the connection server rejects a message
+// with 400.  Fields lists the message fields the error description mentions,
+// if any can be told.
+type BadRequestError struct {
+	httpError
+	Body   string
+	Fields []FieldError
+}
+
+func (e *BadRequestError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.httpError.Error()
+	}
+	fields := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		fields[i] = f.String()
+	}
+	return fmt.Sprintf("%s: %s", e.httpError.Error(), strings.Join(fields, "; "))
+}
+
+// Unwrap returns the underlying HTTP error.
+func (e *BadRequestError) Unwrap() error {
+	return e.httpError
+}
+
+var quotedName = regexp.MustCompile(`"([a-z_]+)"`)
+
+var (
+	messageFieldsOnce sync.Once
+	messageFields     map[string]bool
+)
+
+// isMessageField reports whether name is the JSON name of a field of a
+// message or its notification.
+func isMessageField(name string) bool {
+	messageFieldsOnce.Do(func() {
+		messageFields = map[string]bool{"to": true, "registration_ids": true, "condition": true}
+		for _, t := range []reflect.Type{reflect.TypeOf(Message{}), reflect.TypeOf(Notification{})} {
+			for i := 0; i < t.NumField(); i++ {
+				if tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+					messageFields[tag] = true
+				}
+			}
+		}
+	})
+	return messageFields[name]
+}
+
+// parseFieldErrors maps the error description in the body of a 400 response
+// to the message fields it mentions.  Both the HTTP v1 JSON error format with
+// field violations and the plain text descriptions of the legacy protocol,
+// e.g. `Field "time_to_live" must be a JSON number: abc`, are understood.
+func parseFieldErrors(body []byte) []FieldError {
+	var v1 struct {
+		Error struct {
+			Details []struct {
+				FieldViolations []struct {
+					Field       string `json:"field"`
+					Description string `json:"description"`
+				} `json:"fieldViolations"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &v1) == nil {
+		var fields []FieldError
+		for _, d := range v1.Error.Details {
+			for _, v := range d.FieldViolations {
+				fields = append(fields, FieldError{v.Field, v.Description})
+			}
+		}
+		return fields
+	}
+
+	var fields []FieldError
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		for _, m := range quotedName.FindAllStringSubmatch(line, -1) {
+			if isMessageField(m[1]) {
+				fields = append(fields, FieldError{m[1], line})
+				break
+			}
+		}
+	}
+	return fields
+}
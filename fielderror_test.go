@@ -0,0 +1,37 @@
+package gcm
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFieldErrors(t *testing.T) {
+	params := []struct {
+		body   string
+		fields []FieldError
+	}{
+		{"", nil},
+		{"JSON_PARSING_ERROR: Unexpected character (x) at position 0.", nil},
+		{`Field "time_to_live" must be a JSON number: abc`, []FieldError{{"time_to_live", `Field "time_to_live" must be a JSON number: abc`}}},
+		{"Invalid value (urgent) for \"priority\"\nField \"unknown\" is bad", []FieldError{{"priority", `Invalid value (urgent) for "priority"`}}},
+		{`{"error":{"code":400,"details":[{"fieldViolations":[{"field":"message.android.ttl","description":"Invalid duration"}]}]}}`, []FieldError{{"message.android.ttl", "Invalid duration"}}},
+	}
+	for _, param := range params {
+		assert.Equal(t, param.fields, parseFieldErrors([]byte(param.body)), param.body)
+	}
+}
+
+func TestSendWithBadRequest(t *testing.T) {
+	server := startTestServer(t, &testResponse{statusCode: http.StatusBadRequest, body: `Field "time_to_live" must be a JSON number: abc`})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	_, err := s.SendNoRetry(msg, "regId")
+	assert.EqualError(t, err, `400 error: 400 Bad Request: time_to_live: Field "time_to_live" must be a JSON number: abc`)
+	if assert.IsType(t, &BadRequestError{}, err) {
+		badRequest := err.(*BadRequestError)
+		assert.Equal(t, `Field "time_to_live" must be a JSON number: abc`, badRequest.Body)
+		assert.Len(t, badRequest.Fields, 1)
+	}
+}
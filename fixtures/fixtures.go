@@ -0,0 +1,260 @@
+// Package fixtures generates deterministic GCM messages and connection server
+// responses for property-based and fuzz testing of services built on go-gcm.
+// Generators with the same seed generate the same fixtures, so that failures
+// can be reproduced.
+//
+// Valid fixtures pass gcm.Lint without errors.  Adversarial fixtures are still
+// well-formed JSON but stress their consumers: payloads above the size limit,
+// multi-byte and invalid Unicode, nested quotes, control characters, reserved
+// data keys and out of range options.
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	gcm "github.com/wuman/go-gcm"
+)
+
+// AdversarialStrings lists strings that commonly break encoders, parsers and
+// storage of payloads.
+var AdversarialStrings = []string{
+	"",
+	" ",
+	`"`,
+	`\"`,
+	`{"nested": "{\"quoted\": \"\\\"deep\\\"\"}"}`,
+	"'; DROP TABLE tokens; --",
+	"<script>alert(1)</script>",
+	"line\nbreak\r\ttab",
+	"\x00\x01\x1f\x7f",
+	"\u200b\u200e\u202e\ufeff",
+	"Ünïcödé façade naïve",
+	"日本語のテキスト",
+	"עברית ومرحبا",
+	"👩‍👩‍👧‍👦🏳️‍🌈👍🏽",
+	"e\u0301\u0301\u0301",
+	"\xff\xfe invalid UTF-8",
+	"%s%d%n{0}${x}",
+	strings.Repeat("A", 1024),
+}
+
+var (
+	words   = strings.Fields("order shipped delivered reminder payment received new message from your friend sale ends tonight weather alert flight delayed gate changed")
+	titles  = []string{"Order update", "New message", "Reminder", "Payment received", "Flight update", "Weather alert"}
+	sounds  = []string{"", "default", "chime.caf"}
+	appIDs  = []string{"", "com.example.app", "com.example.app.debug"}
+	errCode = []string{gcm.ErrorNotRegistered, gcm.ErrorInvalidRegistration, gcm.ErrorUnavailable, gcm.ErrorInternalServerError, gcm.ErrorMismatchSenderID, gcm.ErrorDeviceMessageRateExceeded}
+)
+
+const tokenAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// Generator generates fixtures.  It is not safe for concurrent use.
+type Generator struct {
+	rand *rand.Rand
+}
+
+// New returns a Generator seeded with seed.
+func New(seed int64) *Generator {
+	return &Generator{rand.New(rand.NewSource(seed))}
+}
+
+func (g *Generator) pick(list []string) string {
+	return list[g.rand.Intn(len(list))]
+}
+
+func (g *Generator) chars(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[g.rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+func (g *Generator) sentence(n int) string {
+	s := make([]string, n)
+	for i := range s {
+		s[i] = g.pick(words)
+	}
+	return strings.Join(s, " ")
+}
+
+// Token returns a registration token shaped like the ones issued by FCM.
+func (g *Generator) Token() string {
+	return g.chars(tokenAlphabet, 11) + ":APA91b" + g.chars(tokenAlphabet, 134)
+}
+
+// Tokens returns n distinct registration tokens.
+func (g *Generator) Tokens(n int) []string {
+	tokens := make([]string, n)
+	for i := range tokens {
+		tokens[i] = g.Token()
+	}
+	return tokens
+}
+
+// Message returns a valid message with a data payload, a notification payload,
+// or both.
+func (g *Generator) Message() *gcm.Message {
+	msg := &gcm.Message{
+		RestrictedPackageName: g.pick(appIDs),
+		ContentAvailable:      g.rand.Intn(4) == 0,
+	}
+	if g.rand.Intn(2) == 0 {
+		msg.CollapseKey = g.pick(words)
+	}
+	if g.rand.Intn(2) == 0 {
+		msg.TimeToLive = g.rand.Intn(2419200)
+	}
+	if g.rand.Intn(2) == 0 {
+		msg.Priority = gcm.PriorityHigh
+	}
+	kind := g.rand.Intn(3)
+	if kind != 1 {
+		msg.Data = make(map[string]string)
+		for i := g.rand.Intn(6) + 1; i > 0; i-- {
+			msg.Data[fmt.Sprintf("%s_%d", g.pick(words), i)] = g.sentence(g.rand.Intn(8) + 1)
+		}
+	}
+	if kind != 0 {
+		msg.Notification = &gcm.Notification{
+			Title:            g.pick(titles),
+			Body:             g.sentence(g.rand.Intn(12) + 3),
+			Sound:            g.pick(sounds),
+			AndroidChannelID: "default",
+		}
+	}
+	return msg
+}
+
+// AdversarialMessage returns a message stressing its consumers, see the
+// package documentation.  It may not be accepted by the connection server.
+func (g *Generator) AdversarialMessage() *gcm.Message {
+	msg := g.Message()
+	if msg.Data == nil {
+		msg.Data = make(map[string]string)
+	}
+	switch g.rand.Intn(6) {
+	case 0: // huge payload
+		msg.Data["blob"] = g.chars(tokenAlphabet, gcm.MaxPayloadSize+g.rand.Intn(gcm.MaxPayloadSize))
+	case 1: // many keys
+		for i := 0; i < 500; i++ {
+			msg.Data[fmt.Sprintf("k%d", i)] = g.pick(AdversarialStrings)
+		}
+	case 2: // reserved keys
+		msg.Data[g.pick([]string{"from", "message_type", "google.sent_time", "gcm.notification.title"})] = g.pick(AdversarialStrings)
+	case 3: // out of range options
+		msg.TimeToLive = g.pick2(-1, 2419201)
+		msg.Priority = gcm.Priority(g.rand.Intn(10) + 3)
+	}
+	// adversarial strings everywhere
+	for i := g.rand.Intn(4) + 1; i > 0; i-- {
+		msg.Data[g.pick(AdversarialStrings)] = g.pick(AdversarialStrings)
+	}
+	if msg.Notification != nil {
+		msg.Notification.Title = g.pick(AdversarialStrings)
+		msg.Notification.Body = g.pick(AdversarialStrings)
+	}
+	return msg
+}
+
+func (g *Generator) pick2(a, b int) int {
+	if g.rand.Intn(2) == 0 {
+		return a
+	}
+	return b
+}
+
+// Response is a response of the connection server, as sent on the wire.
+type Response struct {
+	MulticastID           int64    `json:"multicast_id,omitempty"`
+	Success               int      `json:"success"`
+	Failure               int      `json:"failure"`
+	CanonicalIds          int      `json:"canonical_ids"`
+	Results               []Result `json:"results,omitempty"`
+	MessageID             int64    `json:"message_id,omitempty"`
+	Error                 string   `json:"error,omitempty"`
+	FailedRegistrationIDs []string `json:"failed_registration_ids,omitempty"`
+}
+
+// Result is the result of a registration token in a Response.
+type Result struct {
+	MessageID      string `json:"message_id,omitempty"`
+	RegistrationID string `json:"registration_id,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// JSON returns the JSON encoding of the response.
+func (r *Response) JSON() []byte {
+	b, _ := json.Marshal(r)
+	return b
+}
+
+// Response returns the response to a message sent to n registration tokens,
+// with about failureRate of them failing with an error and some of the others
+// having a canonical registration token.
+func (g *Generator) Response(n int, failureRate float64) *Response {
+	resp := &Response{MulticastID: g.rand.Int63(), Results: make([]Result, n)}
+	for i := range resp.Results {
+		res := &resp.Results[i]
+		switch {
+		case g.rand.Float64() < failureRate:
+			res.Error = g.pick(errCode)
+			resp.Failure++
+		default:
+			res.MessageID = fmt.Sprintf("0:%d%%%016x", g.rand.Int63(), g.rand.Int63())
+			if g.rand.Intn(20) == 0 {
+				res.RegistrationID = g.Token()
+				resp.CanonicalIds++
+			}
+			resp.Success++
+		}
+	}
+	return resp
+}
+
+// TopicResponse returns the response to a topic message, failing with
+// probability failureRate.
+func (g *Generator) TopicResponse(failureRate float64) *Response {
+	if g.rand.Float64() < failureRate {
+		return &Response{Error: g.pick([]string{gcm.ErrorTopicsMessageRateExceeded, gcm.ErrorInternalServerError})}
+	}
+	return &Response{MessageID: g.rand.Int63()}
+}
+
+// GroupResponse returns the response to a device group message sent to
+// members, with about failureRate of them failing.
+func (g *Generator) GroupResponse(members []string, failureRate float64) *Response {
+	resp := &Response{}
+	for _, member := range members {
+		if g.rand.Float64() < failureRate {
+			resp.Failure++
+			resp.FailedRegistrationIDs = append(resp.FailedRegistrationIDs, member)
+		} else {
+			resp.Success++
+		}
+	}
+	return resp
+}
+
+// AdversarialResponse returns a response body that a robust client must
+// reject or handle without crashing: malformed, truncated, mistyped or with
+// a result count not matching the n registration tokens.
+func (g *Generator) AdversarialResponse(n int) []byte {
+	valid := g.Response(n, 0.5).JSON()
+	switch g.rand.Intn(6) {
+	case 0:
+		return valid[:g.rand.Intn(len(valid))]
+	case 1:
+		return g.Response(n+1+g.rand.Intn(3), 0.5).JSON()
+	case 2:
+		return []byte(`{"success": "1", "failure": null, "results": {}}`)
+	case 3:
+		return []byte("<html><body>502 Bad Gateway</body></html>")
+	case 4:
+		return []byte(`{"results": [{"message_id": ` + fmt.Sprintf("%q", g.pick(AdversarialStrings)) + `}]}`)
+	}
+	return []byte{}
+}
@@ -0,0 +1,91 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gcm "github.com/wuman/go-gcm"
+)
+
+func TestDeterministic(t *testing.T) {
+	a, b := New(42), New(42)
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, a.Message(), b.Message())
+		assert.Equal(t, a.AdversarialMessage(), b.AdversarialMessage())
+		assert.Equal(t, a.Response(5, 0.3), b.Response(5, 0.3))
+	}
+	assert.NotEqual(t, New(1).Tokens(3), New(2).Tokens(3))
+}
+
+func TestValidMessages(t *testing.T) {
+	g := New(1)
+	for i := 0; i < 200; i++ {
+		msg := g.Message()
+		for _, f := range gcm.Lint(msg) {
+			assert.NotEqual(t, gcm.SeverityError, f.Severity, f.Message)
+		}
+	}
+	assert.Len(t, g.Token(), 152)
+}
+
+func TestAdversarialMessages(t *testing.T) {
+	g := New(1)
+	for i := 0; i < 200; i++ {
+		b, err := json.Marshal(g.AdversarialMessage())
+		if err != nil {
+			// out of range priorities do not encode
+			continue
+		}
+		_, err = gcm.LintJSON(b)
+		assert.NoError(t, err)
+	}
+}
+
+func TestResponses(t *testing.T) {
+	g := New(1)
+	tokens := g.Tokens(10)
+	resp := g.Response(len(tokens), 0.5)
+	assert.Len(t, resp.Results, 10)
+	assert.Equal(t, 10, resp.Success+resp.Failure)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp.JSON())
+	}))
+	defer server.Close()
+	s := gcm.NewSender("test-api-key")
+	s.Endpoint = server.URL
+	result, err := s.SendMulticastNoRetry(g.Message(), tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.Success, result.Success)
+	assert.Equal(t, resp.CanonicalIds, result.CanonicalIds)
+	for i, res := range result.Results {
+		assert.Equal(t, resp.Results[i].Error, res.Error)
+	}
+
+	group := g.GroupResponse(tokens[:4], 0.5)
+	assert.Equal(t, group.Failure, len(group.FailedRegistrationIDs))
+	topic := g.TopicResponse(0)
+	assert.NotEqual(t, int64(0), topic.MessageID)
+}
+
+func TestAdversarialResponses(t *testing.T) {
+	g := New(1)
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer server.Close()
+	s := gcm.NewSender("test-api-key")
+	s.Endpoint = server.URL
+	tokens := g.Tokens(3)
+	for i := 0; i < 50; i++ {
+		body = g.AdversarialResponse(len(tokens))
+		// must not panic
+		s.SendMulticastNoRetry(g.Message(), tokens)
+	}
+}
@@ -0,0 +1,92 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// The fuzz targets below run their seeds with go test, and fuzz with e.g.
+//
+//	go test -fuzz FuzzMessageUnmarshalJSON
+
+func FuzzMessageUnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"to":"1","data":{"k":"v"},"priority":"high","time_to_live":60}`))
+	f.Add([]byte(`{"registration_ids":["1","2"],"notification":{"title":"t","body_loc_args":["a"]}}`))
+	f.Add([]byte(`{"condition":"'a' in topics","priority":"normal","dry_run":true}`))
+	f.Add([]byte(`{"data":{"\u0000":"\"\\\""},"priority":5}`))
+	f.Add([]byte(`{"to":null,"data":[],"notification":"x"}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var m message
+		if err := json.Unmarshal(b, &m); err != nil {
+			return
+		}
+		// whatever was accepted must encode, and encode the same once decoded
+		encoded, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("failed to marshal %s: %v", b, err)
+		}
+		var decoded message
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("failed to unmarshal %s: %v", encoded, err)
+		}
+		reencoded, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("round trip changed %s to %s", encoded, reencoded)
+		}
+		Lint(&m.Message)
+	})
+}
+
+func FuzzPriorityUnmarshalJSON(f *testing.F) {
+	for _, seed := range []string{`"high"`, `"normal"`, `"HIGH"`, `10`, `null`, `""`, `"high\u0000"`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var p Priority
+		if err := p.UnmarshalJSON(b); err != nil {
+			if p != 0 {
+				t.Fatalf("priority set to %d despite error %v", p, err)
+			}
+			return
+		}
+		encoded, err := p.MarshalJSON()
+		if err != nil {
+			t.Fatalf("failed to marshal priority %d parsed from %s: %v", p, b, err)
+		}
+		var decoded Priority
+		if err := decoded.UnmarshalJSON(encoded); err != nil || decoded != p {
+			t.Fatalf("round trip changed %d to %d: %v", p, decoded, err)
+		}
+	})
+}
+
+func FuzzResponse(f *testing.F) {
+	f.Add([]byte(`{"multicast_id":1,"success":1,"failure":1,"results":[{"message_id":"1"},{"error":"Unavailable"}]}`))
+	f.Add([]byte(`{"message_id":123}`))
+	f.Add([]byte(`{"error":"TopicsMessageRateExceeded"}`))
+	f.Add([]byte(`{"success":1,"failure":2,"failed_registration_ids":["a","b"]}`))
+	f.Add([]byte(`{"results":[]}`))
+	f.Add([]byte(`{"results":null,"success":-1}`))
+	f.Add([]byte(`{"error":{"details":[{"fieldViolations":[{"field":"message.token"}]}]}}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		parseFieldErrors(b)
+		resp := new(response)
+		if err := json.Unmarshal(b, resp); err != nil {
+			return
+		}
+		// none of the conversions of the sender may panic
+		newResult(resp, false)
+		newResult(resp, true)
+		if resp.checkResultCount(len(resp.Results)) != nil {
+			t.Fatalf("result count of %d results does not match itself", len(resp.Results))
+		}
+		result := newMulticastResult(resp)
+		if len(result.Results) != len(resp.Results) {
+			t.Fatalf("got %d results, expected %d", len(result.Results), len(resp.Results))
+		}
+	})
+}
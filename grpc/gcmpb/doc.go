@@ -0,0 +1,6 @@
+// Package gcmpb holds the protocol buffers of the gRPC service of package
+// gcmgrpc.  The Go code is generated from gcm.proto with protoc-gen-go and
+// protoc-gen-go-grpc by go generate, which runs buf with buf.gen.yaml.
+package gcmpb
+
+//go:generate buf generate
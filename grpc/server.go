@@ -0,0 +1,179 @@
+// Package gcmgrpc exposes a gcm.Sender as a gRPC push microservice, so that
+// services in other languages share the same dispatch layer.
+//
+// It is a separate module, depending on google.golang.org/grpc, so that
+// package gcm keeps no external dependencies.  Package gcmpb is generated
+// from gcm.proto with go generate ./gcmpb.
+package gcmgrpc
+
+import (
+	"context"
+	"time"
+
+	gcm "github.com/wuman/go-gcm"
+	"github.com/wuman/go-gcm/grpc/gcmpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultStatusInterval is how often GetStatus checks the status for changes
+// when the request does not say.
+const defaultStatusInterval = 10 * time.Second
+
+// Server implements the gcm.v1.Sender service.
+type Server struct {
+	gcmpb.UnimplementedSenderServer
+	Sender *gcm.Sender
+	// Dispatcher reports the status streamed by GetStatus.  Nil means
+	// GetStatus is unimplemented.
+	Dispatcher *gcm.Dispatcher
+}
+
+// Register registers the service of s with srv.
+func (s *Server) Register(srv *grpc.Server) {
+	gcmpb.RegisterSenderServer(srv, s)
+}
+
+// Send sends a downstream message with the Sender.
+func (s *Server) Send(ctx context.Context, req *gcmpb.SendRequest) (*gcmpb.Result, error) {
+	if req.Message == nil {
+		return nil, status.Error(codes.InvalidArgument, "message cannot be nil")
+	}
+	result, err := s.Sender.SendWithRetries(fromMessage(req.Message), req.To, int(req.Retries))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toResult(result), nil
+}
+
+// SendMulticast sends a multicast message with the Sender.
+func (s *Server) SendMulticast(ctx context.Context, req *gcmpb.SendMulticastRequest) (*gcmpb.MulticastResult, error) {
+	if req.Message == nil {
+		return nil, status.Error(codes.InvalidArgument, "message cannot be nil")
+	}
+	result, err := s.Sender.SendMulticastWithRetries(fromMessage(req.Message), req.RegistrationIds, int(req.Retries))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toMulticastResult(result), nil
+}
+
+// GetStatus streams the status of the Dispatcher until the client goes away.
+func (s *Server) GetStatus(req *gcmpb.GetStatusRequest, stream gcmpb.Sender_GetStatusServer) error {
+	if s.Dispatcher == nil {
+		return status.Error(codes.Unimplemented, "no dispatcher")
+	}
+	interval := time.Duration(req.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultStatusInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last *gcmpb.Status
+	for {
+		st := toStatus(s.Dispatcher.Status())
+		if last == nil || !sameStatus(st, last) {
+			if err := stream.Send(st); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// statusError converts an error of the Sender to a gRPC status, telling
+// clients whether to retry.
+func statusError(err error) error {
+	if gcm.Retryable(err) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func fromMessage(m *gcmpb.Message) *gcm.Message {
+	msg := &gcm.Message{
+		CollapseKey:           m.CollapseKey,
+		DelayWhileIdle:        m.DelayWhileIdle,
+		RestrictedPackageName: m.RestrictedPackageName,
+		DryRun:                m.DryRun,
+		ContentAvailable:      m.ContentAvailable,
+		Priority:              gcm.Priority(m.Priority),
+		DirectBootOK:          m.DirectBootOk,
+		Data:                  m.Data,
+		Tags:                  m.Tags,
+	}
+	if m.TimeToLive != nil {
+		msg.TimeToLive, msg.TimeToLiveSet = int(*m.TimeToLive), true
+	}
+	if n := m.Notification; n != nil {
+		msg.Notification = &gcm.Notification{
+			Title:            n.Title,
+			Body:             n.Body,
+			Sound:            n.Sound,
+			ClickAction:      n.ClickAction,
+			BodyLocKey:       n.BodyLocKey,
+			BodyLocArgs:      n.BodyLocArgs,
+			TitleLocKey:      n.TitleLocKey,
+			TitleLocArgs:     n.TitleLocArgs,
+			Icon:             n.Icon,
+			Tag:              n.Tag,
+			Color:            n.Color,
+			AndroidChannelID: n.AndroidChannelId,
+			Badge:            n.Badge,
+		}
+	}
+	return msg
+}
+
+func toResult(r *gcm.Result) *gcmpb.Result {
+	return &gcmpb.Result{
+		MessageId:                r.MessageID,
+		CanonicalRegistrationId:  r.CanonicalRegistrationID,
+		Error:                    r.Error,
+		Success:                  int32(r.Success),
+		Failure:                  int32(r.Failure),
+		FailedRegistrationIds:    r.FailedRegistrationIDs,
+		RecoveredRegistrationIds: r.RecoveredRegistrationIDs,
+		TraceId:                  r.TraceID,
+		Uuid:                     r.UUID,
+	}
+}
+
+func toMulticastResult(r *gcm.MulticastResult) *gcmpb.MulticastResult {
+	result := &gcmpb.MulticastResult{
+		Success:           int32(r.Success),
+		Failure:           int32(r.Failure),
+		CanonicalIds:      int32(r.CanonicalIds),
+		MulticastId:       r.MulticastID,
+		RetryMulticastIds: r.RetryMulticastIDs,
+		BatchMulticastIds: r.BatchMulticastIDs,
+		TraceId:           r.TraceID,
+		Uuid:              r.UUID,
+	}
+	for i := range r.Results {
+		result.Results = append(result.Results, toResult(&r.Results[i]))
+	}
+	return result
+}
+
+func toStatus(st gcm.HealthStatus) *gcmpb.Status {
+	return &gcmpb.Status{Level: gcmpb.Status_Level(st.Level), Reasons: st.Reasons}
+}
+
+func sameStatus(a, b *gcmpb.Status) bool {
+	if a.Level != b.Level || len(a.Reasons) != len(b.Reasons) {
+		return false
+	}
+	for i := range a.Reasons {
+		if a.Reasons[i] != b.Reasons[i] {
+			return false
+		}
+	}
+	return true
+}
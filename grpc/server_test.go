@@ -0,0 +1,145 @@
+package gcmgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gcm "github.com/wuman/go-gcm"
+	"github.com/wuman/go-gcm/grpc/gcmpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// startServer serves s in memory and returns a client of it.
+func startServer(t *testing.T, s *Server) gcmpb.SenderClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	s.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gcmpb.NewSenderClient(conn)
+}
+
+// startGCM starts a fake connection server answering with handler.
+func startGCM(t *testing.T, handler http.HandlerFunc) *gcm.Sender {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	s := gcm.NewSender("test-api-key")
+	s.Endpoint = server.URL
+	return s
+}
+
+func TestSend(t *testing.T) {
+	var sent map[string]interface{}
+	sender := startGCM(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"success":1,"results":[{"message_id":"id"}]}`))
+	})
+	client := startServer(t, &Server{Sender: sender})
+
+	result, err := client.Send(context.Background(), &gcmpb.SendRequest{
+		Message: &gcmpb.Message{
+			Priority:     gcmpb.Priority_PRIORITY_HIGH,
+			TimeToLive:   proto.Int32(0),
+			Data:         map[string]string{"k": "v"},
+			Notification: &gcmpb.Notification{Title: "title", AndroidChannelId: "news"},
+		},
+		To: "regId",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "id", result.MessageId)
+	assert.Equal(t, "regId", sent["to"])
+	assert.Equal(t, "high", sent["priority"])
+	assert.Equal(t, float64(0), sent["time_to_live"], "an explicit zero TTL is sent")
+	assert.Equal(t, map[string]interface{}{"k": "v"}, sent["data"])
+	assert.Equal(t, map[string]interface{}{"title": "title", "android_channel_id": "news"}, sent["notification"])
+
+	_, err = client.Send(context.Background(), &gcmpb.SendRequest{To: "regId"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSendMulticast(t *testing.T) {
+	sender := startGCM(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"multicast_id":7,"success":1,"failure":1,"results":[{"message_id":"id"},{"error":"NotRegistered"}]}`))
+	})
+	client := startServer(t, &Server{Sender: sender})
+
+	result, err := client.SendMulticast(context.Background(), &gcmpb.SendMulticastRequest{
+		Message:         &gcmpb.Message{Data: map[string]string{"k": "v"}},
+		RegistrationIds: []string{"1", "2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), result.MulticastId)
+	assert.Equal(t, int32(1), result.Success)
+	assert.Equal(t, int32(1), result.Failure)
+	assert.Len(t, result.Results, 2)
+	assert.Equal(t, "id", result.Results[0].MessageId)
+	assert.Equal(t, gcm.ErrorNotRegistered, result.Results[1].Error)
+}
+
+func TestSendErrorStatus(t *testing.T) {
+	code := http.StatusServiceUnavailable
+	sender := startGCM(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	})
+	client := startServer(t, &Server{Sender: sender})
+	req := &gcmpb.SendRequest{Message: &gcmpb.Message{}, To: "regId"}
+
+	_, err := client.Send(context.Background(), req)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "retryable")
+	code = http.StatusUnauthorized
+	_, err = client.Send(context.Background(), req)
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.Equal(t, codes.Unknown, status.Code(statusError(errors.New("boom"))))
+}
+
+func TestGetStatus(t *testing.T) {
+	d := &gcm.Dispatcher{}
+	client := startServer(t, &Server{Sender: gcm.NewSender("test-api-key"), Dispatcher: d})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.GetStatus(ctx, &gcmpb.GetStatusRequest{IntervalSeconds: 1})
+	assert.NoError(t, err)
+	st, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, gcmpb.Status_HEALTHY, st.Level)
+
+	d.Pause()
+	st, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, gcmpb.Status_DEGRADED, st.Level)
+	assert.Equal(t, []string{"paused"}, st.Reasons)
+
+	stream, err = startServer(t, &Server{}).GetStatus(ctx, &gcmpb.GetStatusRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestSameStatus(t *testing.T) {
+	a := &gcmpb.Status{Level: gcmpb.Status_DEGRADED, Reasons: []string{"paused"}}
+	assert.True(t, sameStatus(a, &gcmpb.Status{Level: gcmpb.Status_DEGRADED, Reasons: []string{"paused"}}))
+	assert.False(t, sameStatus(a, &gcmpb.Status{Level: gcmpb.Status_UNHEALTHY, Reasons: []string{"paused"}}))
+	assert.False(t, sameStatus(a, &gcmpb.Status{Level: gcmpb.Status_DEGRADED}))
+	assert.False(t, sameStatus(a, &gcmpb.Status{Level: gcmpb.Status_DEGRADED, Reasons: []string{"load shedding"}}))
+}
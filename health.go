@@ -0,0 +1,83 @@
+package gcm
+
+import "fmt"
+
+// HealthLevel is the overall health of a Dispatcher.
+type HealthLevel int
+
+const (
+	// Healthy means messages are flowing normally.
+	Healthy HealthLevel = iota
+	// Degraded means messages are flowing, but slower or only partly, e.g.
+	// while less urgent classes are shed.
+	Degraded
+	// Unhealthy means messages are not flowing and intervention is needed.
+	Unhealthy
+)
+
+var healthLevelNames = map[HealthLevel]string{
+	Healthy:   "healthy",
+	Degraded:  "degraded",
+	Unhealthy: "unhealthy",
+}
+
+func (l HealthLevel) String() string {
+	if name, ok := healthLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("HealthLevel(%d)", int(l))
+}
+
+// MarshalText encodes the level as its name.
+func (l HealthLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// HealthStatus is the health of a Dispatcher with the reasons it is not
+// Healthy.
+type HealthStatus struct {
+	Level   HealthLevel `json:"level"`
+	Reasons []string    `json:"reasons,omitempty"`
+}
+
+func (st *HealthStatus) report(level HealthLevel, reason string, args ...interface{}) {
+	if level > st.Level {
+		st.Level = level
+	}
+	st.Reasons = append(st.Reasons, fmt.Sprintf(reason, args...))
+}
+
+// authFailing reports whether the last request of the Sender was rejected
+// with 401, i.e. its API keys are not accepted anymore.
+func (s *Sender) authFailing() bool {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	return s.stats.authFailures > 0
+}
+
+// Status returns the health of the Dispatcher, e.g. to feed the readiness
+// probe of an orchestrator or an alert.  It is Unhealthy while the API keys of
+// the Sender are rejected or the Queue is full, and Degraded while the Queue
+// is above HighWatermark, its LoadShedder is shedding, or the Dispatcher is
+// paused.
+func (d *Dispatcher) Status() HealthStatus {
+	var st HealthStatus
+	if d.Sender != nil && d.Sender.authFailing() {
+		st.report(Unhealthy, "authentication failing")
+	}
+	if q := d.Queue; q != nil {
+		depth := q.Stats().Depth
+		if q.capacity > 0 && depth >= q.capacity {
+			st.report(Unhealthy, "queue full (%d jobs)", depth)
+		} else if d.HighWatermark > 0 && depth > d.HighWatermark {
+			st.report(Degraded, "queue above watermark (%d > %d jobs)", depth, d.HighWatermark)
+		}
+		if q.shedder != nil && q.shedder.Shedding() {
+			st.report(Degraded, "load shedding")
+		}
+	}
+	if d.Paused() {
+		st.report(Degraded, "paused")
+	}
+	return st
+}
@@ -0,0 +1,46 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcherStatus(t *testing.T) {
+	shedder := &LoadShedder{MaxErrorRate: 0.5, MinSamples: 1}
+	q := NewQueue(QueueConfig{Capacity: 3, Shedder: shedder})
+	d := &Dispatcher{Queue: q, Sender: NewSender("test-api-key"), HighWatermark: 1}
+	assert.Equal(t, HealthStatus{Level: Healthy}, d.Status())
+
+	q.Enqueue(&Job{Class: ClassTransactional, To: "1", Message: msg})
+	q.Enqueue(&Job{Class: ClassTransactional, To: "2", Message: msg})
+	shedder.Observe(time.Millisecond, true)
+	d.Pause()
+	assert.Equal(t, HealthStatus{Degraded, []string{"queue above watermark (2 > 1 jobs)", "load shedding", "paused"}}, d.Status())
+	d.Resume()
+
+	q.Enqueue(&Job{Class: ClassTransactional, To: "3", Message: msg})
+	status := d.Status()
+	assert.Equal(t, Unhealthy, status.Level)
+	assert.Contains(t, status.Reasons, "queue full (3 jobs)")
+
+	b, err := json.Marshal(status)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"level":"unhealthy"`)
+}
+
+func TestDispatcherStatusAuthFailing(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{statusCode: http.StatusUnauthorized},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	d := &Dispatcher{Sender: NewSender("test-api-key")}
+	d.Sender.SendNoRetry(msg, "1")
+	assert.Equal(t, HealthStatus{Unhealthy, []string{"authentication failing"}}, d.Status())
+	d.Sender.SendNoRetry(msg, "1")
+	assert.Equal(t, Healthy, d.Status().Level)
+}
@@ -0,0 +1,335 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HMSEndpoint is the endpoint of the Huawei Push Kit server.
+	HMSEndpoint = "https://push-api.cloud.huawei.com"
+	// HMSTokenEndpoint is the endpoint of the Huawei OAuth server.
+	HMSTokenEndpoint = "https://oauth-login.cloud.huawei.com/oauth2/v3/token"
+
+	// hmsMaxRecipients is the max number of tokens per Push Kit request.
+	hmsMaxRecipients = 1000
+)
+
+// Push Kit result codes.
+const (
+	hmsSuccess        = "80000000"
+	hmsPartialSuccess = "80100000"
+	hmsTokenExpired   = "80200003"
+	hmsAllTokensBad   = "80300007"
+	hmsTooBig         = "80300008"
+	hmsInternalError  = "81000001"
+)
+
+// HMSTransport is the reference Transport adapter for Huawei Push Kit, for
+// devices without Google Play services.  It authenticates with the OAuth
+// client credentials of the app, and reports invalid tokens as
+// ErrorInvalidRegistration.
+//
+// HMSTransport is safe for concurrent use.
+type HMSTransport struct {
+	// AppID is the ID of the app, which also is its OAuth client ID.
+	AppID string
+	// AppSecret is the OAuth client secret of the app.
+	AppSecret string
+	// Endpoint is the Push Kit server.  Empty means HMSEndpoint.
+	Endpoint string
+	// TokenEndpoint is the OAuth server.  Empty means HMSTokenEndpoint.
+	TokenEndpoint string
+	// Client sends the requests.  Nil means http.DefaultClient.
+	Client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type hmsMessage struct {
+	Data         string           `json:"data,omitempty"`
+	Notification *hmsNotification `json:"notification,omitempty"`
+	Android      hmsAndroid       `json:"android"`
+	Token        []string         `json:"token,omitempty"`
+}
+
+type hmsNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type hmsAndroid struct {
+	CollapseKey  int                     `json:"collapse_key,omitempty"`
+	Urgency      string                  `json:"urgency,omitempty"`
+	TTL          string                  `json:"ttl,omitempty"`
+	Notification *hmsAndroidNotification `json:"notification,omitempty"`
+}
+
+// hmsAndroidNotification holds the Android notification options without a
+// GCM equivalent, see Notification.
+type hmsAndroidNotification struct {
+	ClickAction       hmsClickAction `json:"click_action"`
+	Importance        string         `json:"importance,omitempty"`
+	DefaultSound      bool           `json:"default_sound,omitempty"`
+	UseDefaultVibrate bool           `json:"use_default_vibrate,omitempty"`
+	VibrateConfig     []string       `json:"vibrate_config,omitempty"`
+	UseDefaultLight   bool           `json:"use_default_light,omitempty"`
+}
+
+type hmsClickAction struct {
+	Type int `json:"type"`
+}
+
+// hmsImportance maps the notification priorities to Push Kit importances.
+var hmsImportance = map[NotificationPriority]string{
+	NotificationPriorityMin:     "LOW",
+	NotificationPriorityLow:     "LOW",
+	NotificationPriorityDefault: "NORMAL",
+	NotificationPriorityHigh:    "HIGH",
+	NotificationPriorityMax:     "HIGH",
+}
+
+// maxVibrateTimings is the max number of VibrateTimings.
+const maxVibrateTimings = 10
+
+// androidNotification converts the Android notification options of n, if
+// any.
+func androidNotification(n *Notification) (*hmsAndroidNotification, error) {
+	if n.NotificationPriority == "" && !n.DefaultSound && !n.DefaultVibrateTimings && len(n.VibrateTimings) == 0 && !n.DefaultLightSettings {
+		return nil, nil
+	}
+	// click action 3 opens the app, as GCM does
+	an := &hmsAndroidNotification{
+		ClickAction:       hmsClickAction{Type: 3},
+		DefaultSound:      n.DefaultSound,
+		UseDefaultVibrate: n.DefaultVibrateTimings,
+		UseDefaultLight:   n.DefaultLightSettings,
+	}
+	if n.NotificationPriority != "" {
+		importance, ok := hmsImportance[n.NotificationPriority]
+		if !ok {
+			return nil, fmt.Errorf("unknown notification priority %q", n.NotificationPriority)
+		}
+		an.Importance = importance
+	}
+	if len(n.VibrateTimings) > maxVibrateTimings {
+		return nil, fmt.Errorf("at most %d vibrate timings, got %d", maxVibrateTimings, len(n.VibrateTimings))
+	}
+	for _, d := range n.VibrateTimings {
+		if d <= 0 || d > time.Minute {
+			return nil, fmt.Errorf("vibrate timing should be positive and at most a minute, got %v", d)
+		}
+		an.VibrateConfig = append(an.VibrateConfig, strconv.FormatFloat(d.Seconds(), 'f', -1, 64)+"s")
+	}
+	return an, nil
+}
+
+type hmsResponse struct {
+	Code      string `json:"code"`
+	Msg       string `json:"msg"`
+	RequestID string `json:"requestId"`
+}
+
+// Name returns "hms".
+func (t *HMSTransport) Name() string {
+	return "hms"
+}
+
+// Capabilities returns the capabilities of Push Kit.
+func (t *HMSTransport) Capabilities() Capabilities {
+	return Capabilities{Notification: true, Data: true, MaxRecipients: hmsMaxRecipients, MaxPayloadSize: MaxPayloadSize}
+}
+
+func (t *HMSTransport) message(msg *Message) (*hmsMessage, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	m := &hmsMessage{}
+	if len(msg.Data) > 0 {
+		data, err := json.Marshal(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		m.Data = string(data)
+	}
+	if n := msg.Notification; n != nil {
+		m.Notification = &hmsNotification{Title: n.Title, Body: n.Body}
+		an, err := androidNotification(n)
+		if err != nil {
+			return nil, err
+		}
+		m.Android.Notification = an
+	}
+	if msg.Priority == PriorityHigh {
+		m.Android.Urgency = "HIGH"
+	} else if msg.Priority == PriorityNormal {
+		m.Android.Urgency = "NORMAL"
+	}
+	if msg.TimeToLive > 0 || msg.TimeToLiveSet {
+		m.Android.TTL = strconv.Itoa(msg.TimeToLive) + "s"
+	}
+	if msg.CollapseKey != "" {
+		// Push Kit only takes numeric collapse keys
+		key, err := strconv.Atoi(msg.CollapseKey)
+		if err != nil || key < -1 || key > 100 {
+			return nil, fmt.Errorf("collapse key must be a number from -1 to 100 for HMS, got %q", msg.CollapseKey)
+		}
+		m.Android.CollapseKey = key
+	}
+	return m, nil
+}
+
+// Convert returns the JSON encoding of the Push Kit message, without tokens.
+func (t *HMSTransport) Convert(msg *Message) ([]byte, error) {
+	m, err := t.message(msg)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// accessToken returns the current OAuth access token, requesting a new one
+// when needed.
+func (t *HMSTransport) accessToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+	endpoint := t.TokenEndpoint
+	if endpoint == "" {
+		endpoint = HMSTokenEndpoint
+	}
+	resp, err := t.client().PostForm(endpoint, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.AppID},
+		"client_secret": {t.AppSecret},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", httpError{statusCode: resp.StatusCode, status: resp.Status}
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	// renew a minute early
+	t.token, t.expires = token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn-60)*time.Second)
+	return t.token, nil
+}
+
+func (t *HMSTransport) invalidateToken(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+func (t *HMSTransport) client() *http.Client {
+	if t.Client == nil {
+		return http.DefaultClient
+	}
+	return t.Client
+}
+
+// Push sends a message to Push Kit tokens.
+func (t *HMSTransport) Push(msg *Message, tokens []string) ([]Result, error) {
+	if len(tokens) == 0 || len(tokens) > hmsMaxRecipients {
+		return nil, fmt.Errorf("number of tokens must be from 1 to %d, got %d", hmsMaxRecipients, len(tokens))
+	}
+	m, err := t.message(msg)
+	if err != nil {
+		return nil, err
+	}
+	m.Token = tokens
+	body, err := json.Marshal(map[string]interface{}{"validate_only": msg.DryRun, "message": m})
+	if err != nil {
+		return nil, err
+	}
+	token, err := t.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = HMSEndpoint
+	}
+	req, err := http.NewRequest("POST", endpoint+"/v1/"+url.PathEscape(t.AppID)+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var hmsResp hmsResponse
+	if err := json.Unmarshal(respBody, &hmsResp); err != nil {
+		return nil, httpError{statusCode: resp.StatusCode, status: resp.Status}
+	}
+	return t.results(tokens, token, &hmsResp)
+}
+
+// results maps a Push Kit response to the results of the tokens.
+func (t *HMSTransport) results(tokens []string, accessToken string, resp *hmsResponse) ([]Result, error) {
+	results := make([]Result, len(tokens))
+	fill := func(res Result) {
+		for i := range results {
+			results[i] = res
+		}
+	}
+	switch resp.Code {
+	case hmsSuccess:
+		fill(Result{MessageID: resp.RequestID})
+	case hmsPartialSuccess:
+		// msg is a JSON object listing the invalid tokens
+		var partial struct {
+			IllegalTokens []string `json:"illegal_tokens"`
+		}
+		json.Unmarshal([]byte(resp.Msg), &partial)
+		illegal := tokenSet(partial.IllegalTokens)
+		for i, token := range tokens {
+			if illegal[token] {
+				results[i] = Result{Error: ErrorInvalidRegistration}
+			} else {
+				results[i] = Result{MessageID: resp.RequestID}
+			}
+		}
+	case hmsAllTokensBad:
+		fill(Result{Error: ErrorInvalidRegistration})
+	case hmsTooBig:
+		fill(Result{Error: ErrorMessageTooBig})
+	case hmsInternalError:
+		fill(Result{Error: ErrorUnavailable})
+	case hmsTokenExpired:
+		t.invalidateToken(accessToken)
+		return nil, errors.New("access token of HMS expired")
+	default:
+		return nil, fmt.Errorf("hms error %s: %s", resp.Code, strings.TrimSpace(resp.Msg))
+	}
+	return results, nil
+}
@@ -0,0 +1,102 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMSTransportConvert(t *testing.T) {
+	tr := &HMSTransport{}
+	payload, err := tr.Convert(&Message{
+		CollapseKey:  "3",
+		TimeToLive:   60,
+		Priority:     PriorityHigh,
+		Data:         map[string]string{"k": "v"},
+		Notification: &Notification{Title: "title", Body: "body", Icon: "ignored"},
+	})
+	assert.NoError(t, err)
+	assertJSON(t, `{"data":"{\"k\":\"v\"}","notification":{"title":"title","body":"body"},"android":{"collapse_key":3,"urgency":"HIGH","ttl":"60s"}}`, payload)
+
+	_, err = tr.Convert(&Message{CollapseKey: "score"})
+	assert.NotNil(t, err)
+}
+
+func TestHMSTransportConvertAndroidNotification(t *testing.T) {
+	tr := &HMSTransport{}
+	payload, err := tr.Convert(&Message{Notification: &Notification{
+		Title:                "title",
+		NotificationPriority: NotificationPriorityMax,
+		DefaultSound:         true,
+		VibrateTimings:       []time.Duration{time.Second, 1500 * time.Millisecond},
+		DefaultLightSettings: true,
+	}})
+	assert.NoError(t, err)
+	assertJSON(t, `{"notification":{"title":"title"},"android":{"notification":{"click_action":{"type":3},"importance":"HIGH","default_sound":true,"vibrate_config":["1s","1.5s"],"use_default_light":true}}}`, payload)
+
+	_, err = tr.Convert(&Message{Notification: &Notification{NotificationPriority: "PRIORITY_URGENT"}})
+	assert.NotNil(t, err)
+	_, err = tr.Convert(&Message{Notification: &Notification{VibrateTimings: []time.Duration{2 * time.Minute}}})
+	assert.NotNil(t, err)
+	_, err = tr.Convert(&Message{Notification: &Notification{VibrateTimings: make([]time.Duration, 11)}})
+	assert.NotNil(t, err)
+
+	// GCM has no equivalent, so they are not sent to it
+	b, err := json.Marshal(&Notification{Title: "title", NotificationPriority: NotificationPriorityLow, DefaultSound: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"title":"title"}`, string(b))
+}
+
+func TestHMSTransportPush(t *testing.T) {
+	tokenRequests := 0
+	responses := []hmsResponse{
+		{Code: hmsSuccess, RequestID: "req1"},
+		{Code: hmsPartialSuccess, Msg: `{"success":1,"failure":1,"illegal_tokens":["bad"]}`, RequestID: "req2"},
+		{Code: hmsTokenExpired, Msg: "token expired"},
+		{Code: hmsAllTokensBad},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+			assert.Equal(t, "app", r.FormValue("client_id"))
+			assert.Equal(t, "secret", r.FormValue("client_secret"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access", "expires_in": 3600})
+			return
+		}
+		assert.Equal(t, "/v1/app/messages:send", r.URL.Path)
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		var body struct {
+			Message hmsMessage `json:"message"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NotEmpty(t, body.Message.Token)
+		json.NewEncoder(w).Encode(responses[0])
+		responses = responses[1:]
+	}))
+	defer server.Close()
+	tr := &HMSTransport{AppID: "app", AppSecret: "secret", Endpoint: server.URL, TokenEndpoint: server.URL + "/token"}
+
+	results, err := tr.Push(msg, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{MessageID: "req1"}, {MessageID: "req1"}}, results)
+
+	results, err = tr.Push(msg, []string{"good", "bad"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{MessageID: "req2"}, {Error: ErrorInvalidRegistration}}, results)
+	assert.Equal(t, 1, tokenRequests)
+
+	_, err = tr.Push(msg, []string{"a"})
+	assert.NotNil(t, err)
+	results, err = tr.Push(msg, []string{"a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{Error: ErrorInvalidRegistration}}, results)
+	assert.Equal(t, 2, tokenRequests)
+
+	_, err = tr.Push(msg, nil)
+	assert.NotNil(t, err)
+}
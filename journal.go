@@ -0,0 +1,146 @@
+package gcm
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Journal events.
+const (
+	JournalAttempt = "attempt"
+	JournalRetry   = "retry"
+	JournalSuccess = "success"
+	JournalFailure = "failure"
+)
+
+// JournalEntry is an entry of the attempt journal of a message.
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	// Message identifies the message within the journal.
+	Message string `json:"message"`
+	// Event is one of JournalAttempt, JournalRetry, JournalSuccess and
+	// JournalFailure.
+	Event string `json:"event"`
+	// Attempt is the number of the attempt, counting from 1.
+	Attempt int `json:"attempt,omitempty"`
+	// Backoff is the delay before the retry of a JournalRetry.
+	Backoff time.Duration `json:"backoff,omitempty"`
+	// Error is the error of a JournalFailure, or the errors of the results
+	// of a JournalSuccess, separated by commas.
+	Error string            `json:"error,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+// JournalWriter is an EventListener that writes the attempts of each message
+// sent, with their timestamps, errors and backoffs, as JSON lines, for
+// postmortems of delivery incidents.  Read the journal with ReadJournal.
+//
+// JournalWriter is safe for concurrent use.
+type JournalWriter struct {
+	NopEventListener
+
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+	nextID int64
+	ids    map[*Message]string
+	err    error
+}
+
+// NewJournalWriter instantiates a JournalWriter writing to w.
+func NewJournalWriter(w io.Writer) *JournalWriter {
+	return &JournalWriter{w: w, prefix: newTraceID()[:8], ids: make(map[*Message]string)}
+}
+
+// Err returns the first error writing the journal, if any.
+func (j *JournalWriter) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// OnAttempt journals an attempt.
+func (j *JournalWriter) OnAttempt(msg *Message, attempt int) {
+	j.write(msg, JournalEntry{Event: JournalAttempt, Attempt: attempt}, false)
+}
+
+// OnRetryScheduled journals a retry with its backoff.
+func (j *JournalWriter) OnRetryScheduled(msg *Message, attempt int, delay time.Duration) {
+	j.write(msg, JournalEntry{Event: JournalRetry, Attempt: attempt, Backoff: delay}, false)
+}
+
+// OnSuccess journals the results of a message.
+func (j *JournalWriter) OnSuccess(msg *Message, results []Result) {
+	var errs []byte
+	for _, res := range results {
+		if res.Error != "" {
+			if len(errs) > 0 {
+				errs = append(errs, ',')
+			}
+			errs = append(errs, res.Error...)
+		}
+	}
+	j.write(msg, JournalEntry{Event: JournalSuccess, Error: string(errs)}, true)
+}
+
+// OnFailure journals the failure of a message.
+func (j *JournalWriter) OnFailure(msg *Message, err error) {
+	j.write(msg, JournalEntry{Event: JournalFailure, Error: err.Error()}, true)
+}
+
+// write journals entry for msg, forgetting msg if last.
+func (j *JournalWriter) write(msg *Message, entry JournalEntry, last bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.ids[msg]
+	if !ok {
+		j.nextID++
+		id = j.prefix + "-" + strconv.FormatInt(j.nextID, 10)
+		j.ids[msg] = id
+	}
+	if last {
+		delete(j.ids, msg)
+	}
+	entry.Time, entry.Message, entry.Tags = time.Now(), id, msg.Tags
+	b, err := json.Marshal(entry)
+	if err == nil {
+		_, err = j.w.Write(append(b, '\n'))
+	}
+	if err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// ReadJournal reads the entries of a journal written by a JournalWriter that
+// match filter, or all entries if filter is nil.
+func ReadJournal(r io.Reader, filter func(*JournalEntry) bool) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, err
+		}
+		if filter == nil || filter(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// GroupJournal groups journal entries by message, keeping their order.
+func GroupJournal(entries []JournalEntry) map[string][]JournalEntry {
+	groups := make(map[string][]JournalEntry)
+	for _, entry := range entries {
+		groups[entry.Message] = append(groups[entry.Message], entry)
+	}
+	return groups
+}
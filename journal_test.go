@@ -0,0 +1,46 @@
+package gcm
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{response: &success},
+		&testResponse{statusCode: http.StatusBadRequest},
+	)
+	defer server.Close()
+	var buf bytes.Buffer
+	journal := NewJournalWriter(&buf)
+	s := NewSender("test-api-key")
+	s.Listener = journal
+	_, err := s.SendWithRetries(&Message{Tags: map[string]string{"campaign": "c"}}, "regId", 1)
+	assert.NoError(t, err)
+	_, err = s.SendNoRetry(msg, "regId")
+	assert.Error(t, err)
+	assert.NoError(t, journal.Err())
+
+	entries, err := ReadJournal(bytes.NewReader(buf.Bytes()), nil)
+	assert.NoError(t, err)
+	var events []string
+	for _, entry := range entries {
+		events = append(events, entry.Event)
+	}
+	assert.Equal(t, []string{JournalAttempt, JournalRetry, JournalAttempt, JournalSuccess, JournalAttempt, JournalFailure}, events)
+	assert.True(t, entries[1].Backoff > 0)
+	assert.Equal(t, map[string]string{"campaign": "c"}, entries[0].Tags)
+	assert.Equal(t, "400 error: 400 Bad Request", entries[5].Error)
+
+	groups := GroupJournal(entries)
+	assert.Len(t, groups, 2)
+	assert.Len(t, groups[entries[0].Message], 4)
+
+	failures, err := ReadJournal(bytes.NewReader(buf.Bytes()), func(e *JournalEntry) bool { return e.Event == JournalFailure })
+	assert.NoError(t, err)
+	assert.Equal(t, entries[5:], failures)
+}
@@ -0,0 +1,228 @@
+package gcm
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// KeyIDKey is the data key holding the ID of the key a data payload was signed
+// with, so that verifiers can pick the matching key after a rotation.
+const KeyIDKey = "push_key_id"
+
+// ErrKeyNotFound is returned when a KeyProvider has no key with the given ID.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyProvider provides keys by ID, one of which is current, so that keys can be
+// rotated without redeploying.
+type KeyProvider interface {
+	// Key returns the key with the given ID.
+	Key(id string) ([]byte, error)
+	// Current returns the ID and the key to use for new signatures.
+	Current() (string, []byte, error)
+	// Rotate adds a key and makes it current.  Previous keys are kept so that
+	// payloads signed with them can still be verified.
+	Rotate(id string, key []byte) error
+}
+
+var keyIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func validateKeyID(id string) error {
+	if !keyIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+	return nil
+}
+
+// NewMemoryKeyProvider instantiates an in-memory KeyProvider without keys.
+func NewMemoryKeyProvider() KeyProvider {
+	return &memoryKeyProvider{keys: make(map[string][]byte)}
+}
+
+type memoryKeyProvider struct {
+	mu      sync.Mutex
+	keys    map[string][]byte
+	current string
+}
+
+func (m *memoryKeyProvider) Key(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte(nil), key...), nil
+}
+
+func (m *memoryKeyProvider) Current() (string, []byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current == "" {
+		return "", nil, ErrKeyNotFound
+	}
+	return m.current, append([]byte(nil), m.keys[m.current]...), nil
+}
+
+func (m *memoryKeyProvider) Rotate(id string, key []byte) error {
+	if err := validateKeyID(id); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[id] = append([]byte(nil), key...)
+	m.current = id
+	return nil
+}
+
+// FileKeyProvider is a KeyProvider keeping each key in a file named <id>.key in
+// Dir, and the ID of the current key in a file named current.  Files are read
+// on every call, so keys rotated by another process are picked up.
+type FileKeyProvider struct {
+	Dir string
+}
+
+// Key returns the key with the given ID, or ErrKeyNotFound.
+func (f *FileKeyProvider) Key(id string) ([]byte, error) {
+	if err := validateKeyID(id); err != nil {
+		return nil, err
+	}
+	key, err := ioutil.ReadFile(filepath.Join(f.Dir, id+".key"))
+	if os.IsNotExist(err) {
+		return nil, ErrKeyNotFound
+	}
+	return key, err
+}
+
+// Current returns the ID and the key used for signing, or ErrKeyNotFound.
+func (f *FileKeyProvider) Current() (string, []byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(f.Dir, "current"))
+	if os.IsNotExist(err) {
+		return "", nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	id := strings.TrimSpace(string(b))
+	key, err := f.Key(id)
+	return id, key, err
+}
+
+// Rotate stores the key under id and makes it the current key.
+func (f *FileKeyProvider) Rotate(id string, key []byte) error {
+	if err := validateKeyID(id); err != nil {
+		return err
+	}
+	if err := writeFileAtomically(filepath.Join(f.Dir, id+".key"), key); err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath.Join(f.Dir, "current"), []byte(id+"\n"))
+}
+
+func writeFileAtomically(name string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// KeyedHMACSigner signs and verifies data payloads with HMAC-SHA256 using the
+// keys of a KeyProvider.  SignData records the ID of the current key under
+// KeyIDKey, and VerifyData verifies with the key recorded there.
+type KeyedHMACSigner struct {
+	Keys KeyProvider
+}
+
+// SignPayload signs the payload with the current key.
+func (s KeyedHMACSigner) SignPayload(payload []byte) ([]byte, error) {
+	_, signer, err := s.currentSigner()
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignPayload(payload)
+}
+
+// VerifyPayload verifies the payload with the current key.
+func (s KeyedHMACSigner) VerifyPayload(payload, sig []byte) bool {
+	_, signer, err := s.currentSigner()
+	return err == nil && signer.(HMACSigner).VerifyPayload(payload, sig)
+}
+
+func (s KeyedHMACSigner) currentSigner() (string, PayloadSigner, error) {
+	id, key, err := s.Keys.Current()
+	if err != nil {
+		return "", nil, err
+	}
+	return id, HMACSigner{key}, nil
+}
+
+func (s KeyedHMACSigner) verifierFor(id string) (PayloadVerifier, error) {
+	key, err := s.Keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return HMACSigner{key}, nil
+}
+
+// KeyedEd25519Signer signs data payloads with Ed25519 using the private keys,
+// or their seeds, of a KeyProvider.  SignData records the ID of the current
+// key under KeyIDKey.
+type KeyedEd25519Signer struct {
+	Keys KeyProvider
+}
+
+// SignPayload signs the payload with the current key.
+func (s KeyedEd25519Signer) SignPayload(payload []byte) ([]byte, error) {
+	_, signer, err := s.currentSigner()
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignPayload(payload)
+}
+
+func (s KeyedEd25519Signer) currentSigner() (string, PayloadSigner, error) {
+	id, key, err := s.Keys.Current()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(key) == ed25519.SeedSize {
+		key = ed25519.NewKeyFromSeed(key)
+	}
+	return id, Ed25519Signer{key}, nil
+}
+
+// KeyedEd25519Verifier verifies data payloads signed by a KeyedEd25519Signer
+// using the public keys of a KeyProvider, with the key recorded under
+// KeyIDKey.
+type KeyedEd25519Verifier struct {
+	Keys KeyProvider
+}
+
+// VerifyPayload verifies the payload with the current key.
+func (v KeyedEd25519Verifier) VerifyPayload(payload, sig []byte) bool {
+	_, key, err := v.Keys.Current()
+	return err == nil && Ed25519Verifier{key}.VerifyPayload(payload, sig)
+}
+
+func (v KeyedEd25519Verifier) verifierFor(id string) (PayloadVerifier, error) {
+	key, err := v.Keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return Ed25519Verifier{key}, nil
+}
@@ -0,0 +1,97 @@
+package gcm
+
+import (
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeyProvider(t *testing.T, keys KeyProvider) {
+	_, _, err := keys.Current()
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.NoError(t, keys.Rotate("k1", []byte("secret1")))
+	assert.NoError(t, keys.Rotate("k2", []byte("secret2")))
+	id, key, err := keys.Current()
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", id)
+	assert.Equal(t, []byte("secret2"), key)
+	key, err = keys.Key("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret1"), key)
+	key[0] = 'x'
+	key, _ = keys.Key("k1")
+	assert.Equal(t, []byte("secret1"), key)
+	_, err = keys.Key("k3")
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.EqualError(t, keys.Rotate("../k", nil), `invalid key id "../k"`)
+}
+
+func TestMemoryKeyProvider(t *testing.T) {
+	testKeyProvider(t, NewMemoryKeyProvider())
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	testKeyProvider(t, &FileKeyProvider{dir})
+}
+
+func TestKeyedHMACSigner(t *testing.T) {
+	keys := NewMemoryKeyProvider()
+	signer := KeyedHMACSigner{keys}
+	_, err := SignData(data, signer)
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	assert.NoError(t, keys.Rotate("k1", []byte("secret1")))
+	signed, err := SignData(data, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", signed[KeyIDKey])
+
+	// payloads signed before a rotation still verify
+	assert.NoError(t, keys.Rotate("k2", []byte("secret2")))
+	assert.NoError(t, VerifyData(signed, signer))
+	signed[KeyIDKey] = "k2"
+	assert.Equal(t, ErrInvalidSignature, VerifyData(signed, signer))
+	signed[KeyIDKey] = "k3"
+	assert.Equal(t, ErrInvalidSignature, VerifyData(signed, signer))
+
+	signed, err = SignData(data, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", signed[KeyIDKey])
+	assert.NoError(t, VerifyData(signed, HMACSigner{[]byte("secret2")}))
+}
+
+func TestKeyedEd25519Signer(t *testing.T) {
+	privateKeys, publicKeys := NewMemoryKeyProvider(), NewMemoryKeyProvider()
+	signer, verifier := KeyedEd25519Signer{privateKeys}, KeyedEd25519Verifier{publicKeys}
+	_, err := SignData(data, signer)
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	pub1, priv1, _ := ed25519.GenerateKey(nil)
+	assert.NoError(t, privateKeys.Rotate("k1", priv1))
+	assert.NoError(t, publicKeys.Rotate("k1", pub1))
+	signed, err := SignData(data, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", signed[KeyIDKey])
+	for _, f := range Lint(&Message{Data: signed}) {
+		assert.NotEqual(t, "reserved-key", f.Rule)
+	}
+
+	// payloads signed before a rotation still verify
+	pub2, priv2, _ := ed25519.GenerateKey(nil)
+	assert.NoError(t, privateKeys.Rotate("k2", priv2.Seed()))
+	assert.NoError(t, publicKeys.Rotate("k2", pub2))
+	assert.NoError(t, VerifyData(signed, verifier))
+	signed[KeyIDKey] = "k2"
+	assert.Equal(t, ErrInvalidSignature, VerifyData(signed, verifier))
+
+	signed, err = SignData(data, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", signed[KeyIDKey])
+	assert.NoError(t, VerifyData(signed, verifier))
+	assert.NoError(t, VerifyData(signed, Ed25519Verifier{pub2}))
+}
@@ -0,0 +1,119 @@
+package gcm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TargetType defines the type of the recipients of a message, which have very
+// different server-side costs.
+type TargetType int
+
+const (
+	// TargetToken defines messages to registration tokens.
+	TargetToken TargetType = iota
+	// TargetTopic defines messages to a topic or a condition.
+	TargetTopic
+	// TargetGroup defines messages to a device group.
+	TargetGroup
+	numTargetTypes
+)
+
+var targetTypeNames = []string{"token", "topic", "group"}
+
+func (t TargetType) String() string {
+	if t >= 0 && t < numTargetTypes {
+		return targetTypeNames[t]
+	}
+	return fmt.Sprintf("TargetType(%d)", int(t))
+}
+
+// targetType returns the type of the message's recipients.  Device groups can
+// only be told apart from registration tokens by the response.
+func (m *message) targetType(resp *response) TargetType {
+	if m.condition != "" || strings.HasPrefix(m.to, TopicPrefix) {
+		return TargetTopic
+	}
+	if m.to != "" && resp != nil && resp.Results == nil {
+		return TargetGroup
+	}
+	return TargetToken
+}
+
+// LatencyBuckets are the upper bounds of the buckets of a LatencyHistogram.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram is a distribution of request latencies.
+type LatencyHistogram struct {
+	// Buckets counts the latencies up to each of LatencyBuckets, with an
+	// extra bucket for the longer ones.  Counts are not cumulative.
+	Buckets []int64 `json:"buckets"`
+	// Count is the number of latencies.
+	Count int64 `json:"count"`
+	// Sum is the sum of the latencies.
+	Sum time.Duration `json:"sum_ns"`
+}
+
+func (h *LatencyHistogram) observe(d time.Duration) {
+	if h.Buckets == nil {
+		h.Buckets = make([]int64, len(LatencyBuckets)+1)
+	}
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.Buckets[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Quantile estimates the q-quantile (0 to 1) of the latencies as the upper
+// bound of the bucket it falls in.  Latencies beyond the last bucket are
+// reported as the max of LatencyBuckets.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var n int64
+	for i, c := range h.Buckets {
+		n += c
+		if n >= rank && i < len(LatencyBuckets) {
+			return LatencyBuckets[i]
+		}
+	}
+	return LatencyBuckets[len(LatencyBuckets)-1]
+}
+
+// Latency returns the distribution of the latencies of the requests made by
+// the Sender for the given type of recipients.
+func (s *Sender) Latency(t TargetType) LatencyHistogram {
+	st := &s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if t < 0 || t >= numTargetTypes {
+		return LatencyHistogram{}
+	}
+	return st.latencies[t].copy()
+}
+
+func (h LatencyHistogram) copy() LatencyHistogram {
+	h.Buckets = append([]int64(nil), h.Buckets...)
+	return h
+}
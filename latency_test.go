@@ -0,0 +1,45 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderLatency(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &response{MessageID: 1}},
+		&testResponse{response: &response{Success: 1}},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	for _, to := range []string{"regId", topic, "notificationKey"} {
+		_, err := s.SendNoRetry(msg, to)
+		assert.NoError(t, err)
+	}
+	_, err := s.SendMulticastNoRetry(msg, []string{"regId"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(2), s.Latency(TargetToken).Count)
+	assert.Equal(t, int64(1), s.Latency(TargetTopic).Count)
+	assert.Equal(t, int64(1), s.Latency(TargetGroup).Count)
+	assert.Len(t, s.Latency(TargetGroup).Buckets, len(LatencyBuckets)+1)
+	assert.Len(t, s.Stats().Latency, 3)
+	assert.Equal(t, LatencyHistogram{}, s.Latency(TargetType(5)))
+}
+
+func TestLatencyHistogramQuantile(t *testing.T) {
+	var h LatencyHistogram
+	assert.Equal(t, time.Duration(0), h.Quantile(0.5))
+	for _, d := range []time.Duration{time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, time.Minute} {
+		h.observe(d)
+	}
+	assert.Equal(t, int64(4), h.Count)
+	assert.Equal(t, 5*time.Millisecond, h.Quantile(0))
+	assert.Equal(t, 25*time.Millisecond, h.Quantile(0.5))
+	assert.Equal(t, 50*time.Millisecond, h.Quantile(0.75))
+	assert.Equal(t, 10*time.Second, h.Quantile(1))
+}
@@ -0,0 +1,127 @@
+package gcm
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Leaser coordinates dispatchers running in several processes or regions so
+// that only one of them dispatches a given partition at a time.  A lease that
+// is not renewed before its TTL expires can be acquired by another owner,
+// which provides failover.
+type Leaser interface {
+	// Acquire acquires or renews the lease on partition for owner, valid for
+	// ttl, and reports whether owner holds it.
+	Acquire(partition, owner string, ttl time.Duration) (bool, error)
+	// Release gives up the lease on partition if held by owner.
+	Release(partition, owner string) error
+}
+
+// NewMemoryLeaser instantiates an in-memory Leaser, which only coordinates
+// dispatchers within the same process.  It is mostly useful for tests.
+func NewMemoryLeaser() Leaser {
+	return &memoryLeaser{leases: make(map[string]memoryLease)}
+}
+
+type memoryLeaser struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	owner   string
+	expires time.Time
+}
+
+func (m *memoryLeaser) Acquire(partition, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if lease, ok := m.leases[partition]; ok && lease.owner != owner && now.Before(lease.expires) {
+		return false, nil
+	}
+	m.leases[partition] = memoryLease{owner, now.Add(ttl)}
+	return true, nil
+}
+
+func (m *memoryLeaser) Release(partition, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lease, ok := m.leases[partition]; ok && lease.owner == owner {
+		delete(m.leases, partition)
+	}
+	return nil
+}
+
+const defaultLeaseTTL = 30 * time.Second
+
+func defaultLeaseOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// leaseGate tracks whether a dispatcher currently holds its lease.
+type leaseGate struct {
+	mu      sync.Mutex
+	held    bool
+	changed chan struct{}
+}
+
+func newLeaseGate() *leaseGate {
+	return &leaseGate{changed: make(chan struct{})}
+}
+
+func (g *leaseGate) set(held bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.held != held {
+		g.held = held
+		close(g.changed)
+		g.changed = make(chan struct{})
+	}
+}
+
+// wait blocks until the lease is held or stop is closed, and reports whether
+// the lease is held.
+func (g *leaseGate) wait(stop <-chan struct{}) bool {
+	for {
+		g.mu.Lock()
+		held, changed := g.held, g.changed
+		g.mu.Unlock()
+		if held {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// holdLease keeps acquiring and renewing the lease on the dispatcher's
+// partition until stop is closed, then releases it.
+func (d *Dispatcher) holdLease(gate *leaseGate, stop <-chan struct{}) {
+	ttl, owner := d.LeaseTTL, d.LeaseOwner
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	if owner == "" {
+		owner = defaultLeaseOwner()
+	}
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		held, err := d.Leaser.Acquire(d.Partition, owner, ttl)
+		gate.set(held && err == nil)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			gate.set(false)
+			d.Leaser.Release(d.Partition, owner)
+			return
+		}
+	}
+}
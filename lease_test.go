@@ -0,0 +1,58 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLeaser(t *testing.T) {
+	l := NewMemoryLeaser()
+	held, err := l.Acquire("p", "a", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, held)
+	held, _ = l.Acquire("p", "b", time.Minute)
+	assert.False(t, held)
+	held, _ = l.Acquire("p", "a", time.Minute)
+	assert.True(t, held, "renewal")
+	assert.NoError(t, l.Release("p", "a"))
+	held, _ = l.Acquire("p", "b", time.Minute)
+	assert.True(t, held)
+}
+
+func TestMemoryLeaserExpiry(t *testing.T) {
+	l := NewMemoryLeaser()
+	l.Acquire("p", "a", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	held, _ := l.Acquire("p", "b", time.Minute)
+	assert.True(t, held)
+}
+
+func TestDispatcherWaitsForLease(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	leaser := NewMemoryLeaser()
+	leaser.Acquire("p", "other-region", 50*time.Millisecond)
+
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "regId"}))
+	q.Close()
+	var dispatchedAt time.Time
+	d := &Dispatcher{
+		Queue:      q,
+		Sender:     NewSender("test-api-key"),
+		Leaser:     leaser,
+		Partition:  "p",
+		LeaseOwner: "this-region",
+		LeaseTTL:   15 * time.Millisecond,
+		OnResult:   func(*JobResult) { dispatchedAt = time.Now() },
+	}
+	start := time.Now()
+	d.Run()
+	assert.True(t, dispatchedAt.Sub(start) >= 40*time.Millisecond)
+
+	// the lease is released once the dispatcher is done
+	held, _ := leaser.Acquire("p", "other-region", time.Minute)
+	assert.True(t, held)
+}
@@ -0,0 +1,59 @@
+package gcm
+
+import "time"
+
+// rateLimiter is a token bucket allowing rate events per second with bursts
+// of up to one second worth of events.
+type rateLimiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{rate: rate, tokens: burst(rate)}
+}
+
+func burst(rate float64) float64 {
+	if rate < 1 {
+		return 1
+	}
+	return rate
+}
+
+func (l *rateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if max := burst(l.rate); l.tokens > max {
+			l.tokens = max
+		}
+	}
+	l.last = now
+}
+
+// allow reports whether an event may happen at now without consuming it.  If
+// not, it also returns how long to wait until it may.
+func (l *rateLimiter) allow(now time.Time) (bool, time.Duration) {
+	l.refill(now)
+	if l.tokens >= 1 {
+		return true, 0
+	}
+	return false, time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// take consumes one event.  It must only be called after allow returned true.
+func (l *rateLimiter) take() {
+	l.tokens--
+}
+
+// wait blocks until an event may happen and consumes it.
+func (l *rateLimiter) wait() {
+	for {
+		ok, w := l.allow(time.Now())
+		if ok {
+			l.take()
+			return
+		}
+		time.Sleep(w)
+	}
+}
@@ -0,0 +1,125 @@
+package gcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity defines how serious a lint Finding is.
+type Severity string
+
+const (
+	// SeverityError defines findings that make the message fail or misbehave.
+	SeverityError Severity = "error"
+	// SeverityWarning defines findings that are likely mistakes.
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a pitfall found in a message by Lint.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// maxTimeToLive is the max TimeToLive in seconds, i.e. 4 weeks.
+const maxTimeToLive = 2419200
+
+// Lint checks a message for common pitfalls, e.g. in notification templates
+// checked in CI.
+func Lint(msg *Message) []Finding {
+	var findings []Finding
+	add := func(rule string, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{rule, severity, fmt.Sprintf(format, args...)})
+	}
+	if msg == nil {
+		add("empty-message", SeverityError, "message cannot be nil")
+		return findings
+	}
+
+	if report := AnalyzePayload(msg); report.Exceeds() {
+		add("payload-size", SeverityError, "payload of %d bytes exceeds %d bytes", report.Size, MaxPayloadSize)
+	}
+	for k := range msg.Data {
+		if isReservedDataKey(k) {
+			add("reserved-key", SeverityError, "data key %q is reserved", k)
+		}
+	}
+	if msg.TimeToLive < 0 || msg.TimeToLive > maxTimeToLive {
+		add("time-to-live", SeverityError, "time_to_live %d is not between 0 and %d", msg.TimeToLive, maxTimeToLive)
+	}
+	if msg.Priority != 0 && msg.Priority != PriorityNormal && msg.Priority != PriorityHigh {
+		add("priority", SeverityError, "priority %d is neither normal nor high", msg.Priority)
+	}
+	if msg.ContentAvailable && msg.Priority == PriorityHigh && msg.Notification == nil {
+		// APNs only accepts background notifications at priority 5
+		add("background-priority", SeverityWarning, "content_available only message with high priority may be throttled or dropped on iOS, use normal priority")
+	}
+	if n := msg.Notification; n != nil {
+		if strings.TrimSpace(n.Title) == "" && n.TitleLocKey == "" {
+			add("missing-title", SeverityWarning, "notification has no title, which Android requires")
+		}
+		if n.AndroidChannelID == "" {
+			add("missing-channel-id", SeverityWarning, "notification has no android_channel_id, so Android 8.0+ uses the default channel")
+		}
+		if n.Badge != "" {
+			if _, err := strconv.Atoi(n.Badge); err != nil {
+				add("badge", SeverityError, "badge %q is not a number", n.Badge)
+			}
+		}
+	}
+	return findings
+}
+
+// isReservedDataKey reports whether the data key is reserved by the connection
+// server.
+func isReservedDataKey(k string) bool {
+	switch k {
+	case "from", "notification", "message_type":
+		return true
+	}
+	return strings.HasPrefix(k, "google") || strings.HasPrefix(k, "gcm")
+}
+
+// LintJSON checks a message in JSON, as sent to the connection server, for
+// common pitfalls, including mistyped fields.
+func LintJSON(b []byte) ([]Finding, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	if n, ok := m["notification"].(map[string]interface{}); ok {
+		if badge, ok := n["badge"]; ok {
+			if _, isString := badge.(string); !isString {
+				findings = append(findings, Finding{"badge", SeverityError, fmt.Sprintf("badge should be a string, got %v", badge)})
+				// check the rest of the message without the badge
+				delete(n, "badge")
+			}
+		}
+	}
+	b, _ = json.Marshal(m)
+	var msg Message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+	return append(findings, Lint(&msg)...), nil
+}
+
+// LintYAML checks a message in YAML, with the fields of the JSON sent to the
+// connection server, for common pitfalls, see LintJSON.
+func LintYAML(b []byte) ([]Finding, error) {
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return LintJSON(b)
+}
@@ -0,0 +1,58 @@
+package gcm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	assert.Empty(t, Lint(&Message{Data: data, Notification: &Notification{Title: "t", AndroidChannelID: "c", Badge: "1"}}))
+	assert.Equal(t, []Finding{{"empty-message", SeverityError, "message cannot be nil"}}, Lint(nil))
+
+	findings := Lint(&Message{
+		Data:         map[string]string{"from": "x", "k": strings.Repeat("v", MaxPayloadSize)},
+		TimeToLive:   maxTimeToLive + 1,
+		Notification: &Notification{Badge: "one"},
+	})
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule)
+	}
+	assert.Equal(t, []string{"payload-size", "reserved-key", "time-to-live", "missing-title", "missing-channel-id", "badge"}, rules)
+
+	assert.Equal(t, []Finding{
+		{"background-priority", SeverityWarning, "content_available only message with high priority may be throttled or dropped on iOS, use normal priority"},
+	}, Lint(&Message{ContentAvailable: true, Priority: PriorityHigh}))
+	assert.Empty(t, Lint(&Message{ContentAvailable: true, Priority: PriorityNormal}))
+	assert.Equal(t, []Finding{
+		{"missing-title", SeverityWarning, "notification has no title, which Android requires"},
+	}, Lint(&Message{ContentAvailable: true, Priority: PriorityHigh, Notification: &Notification{Title: " ", AndroidChannelID: "c"}}))
+}
+
+func TestLintJSON(t *testing.T) {
+	findings, err := LintJSON([]byte(`{"to":"regId","data":{"google.x":"1"},"notification":{"title":"t","android_channel_id":"c","badge":3}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{"badge", SeverityError, "badge should be a string, got 3"},
+		{"reserved-key", SeverityError, `data key "google.x" is reserved`},
+	}, findings)
+
+	_, err = LintJSON([]byte(`{`))
+	assert.Error(t, err)
+	_, err = LintJSON([]byte(`{"priority":"urgent"}`))
+	assert.EqualError(t, err, "priority should be either normal or high, got urgent")
+}
+
+func TestLintYAML(t *testing.T) {
+	findings, err := LintYAML([]byte("to: regId\ndata:\n  google.x: \"1\"\nnotification:\n  title: t\n  android_channel_id: c\n  badge: 3\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{"badge", SeverityError, "badge should be a string, got 3"},
+		{"reserved-key", SeverityError, `data key "google.x" is reserved`},
+	}, findings)
+
+	_, err = LintYAML([]byte("data: [1"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+package gcm
+
+import (
+	"sync"
+	"time"
+)
+
+// ShedPolicy defines what happens to jobs that are shed.
+type ShedPolicy int
+
+const (
+	// ShedDrop removes shed jobs from the queue.
+	ShedDrop ShedPolicy = iota
+	// ShedDefer keeps shed jobs queued until shedding stops.
+	ShedDefer
+)
+
+const (
+	defaultShedWindow     = 30 * time.Second
+	defaultShedMinSamples = 10
+)
+
+// LoadShedder decides when a Queue should stop dispatching less urgent jobs,
+// based on the error rate and latency of recent sends, so that critical
+// messages keep flowing during an incident.
+//
+// Its fields must be set before use.  LoadShedder is safe for concurrent use.
+type LoadShedder struct {
+	// MaxErrorRate is the ratio of failed sends (0 to 1) above which shedding
+	// starts.  Zero disables the check.
+	MaxErrorRate float64
+	// MaxLatency is the average send latency above which shedding starts.
+	// Zero disables the check.
+	MaxLatency time.Duration
+	// Window is how far back sends are taken into account.  Zero means 30s.
+	Window time.Duration
+	// MinSamples is the number of sends in Window required before shedding
+	// can start.  Zero means 10.
+	MinSamples int
+	// MinClass is the least urgent class still dispatched while shedding.
+	// Zero means ClassTransactional.
+	MinClass Class
+	// Policy decides whether shed jobs are dropped or deferred.
+	Policy ShedPolicy
+
+	mu       sync.Mutex
+	outcomes []shedOutcome
+	shed     map[Class]int64
+}
+
+type shedOutcome struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// Observe records the outcome of a send.
+func (l *LoadShedder) Observe(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.prune(now)
+	l.outcomes = append(l.outcomes, shedOutcome{now, latency, failed})
+}
+
+func (l *LoadShedder) prune(now time.Time) {
+	window := l.Window
+	if window <= 0 {
+		window = defaultShedWindow
+	}
+	i := 0
+	for i < len(l.outcomes) && now.Sub(l.outcomes[i].at) > window {
+		i++
+	}
+	l.outcomes = l.outcomes[i:]
+}
+
+// Shedding reports whether the recent sends exceed the thresholds.
+func (l *LoadShedder) Shedding() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(time.Now())
+	minSamples := l.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultShedMinSamples
+	}
+	n := len(l.outcomes)
+	if n == 0 || n < minSamples {
+		return false
+	}
+	failed, latency := 0, time.Duration(0)
+	for _, o := range l.outcomes {
+		if o.failed {
+			failed++
+		}
+		latency += o.latency
+	}
+	if l.MaxErrorRate > 0 && float64(failed)/float64(n) > l.MaxErrorRate {
+		return true
+	}
+	return l.MaxLatency > 0 && latency/time.Duration(n) > l.MaxLatency
+}
+
+// sheds reports whether jobs of the given class are shed while shedding.
+func (l *LoadShedder) sheds(c Class) bool {
+	minClass := l.MinClass
+	if minClass == 0 {
+		minClass = ClassTransactional
+	}
+	return c > minClass
+}
+
+func (l *LoadShedder) recordShed(c Class, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.shed == nil {
+		l.shed = make(map[Class]int64)
+	}
+	l.shed[c] += int64(n)
+}
+
+// ShedCount returns the number of jobs of the given class dropped by ShedDrop.
+func (l *LoadShedder) ShedCount(c Class) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shed[c]
+}
@@ -0,0 +1,60 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedderThresholds(t *testing.T) {
+	l := &LoadShedder{MaxErrorRate: 0.5, MaxLatency: time.Second, MinSamples: 2}
+	l.Observe(time.Millisecond, true)
+	assert.False(t, l.Shedding(), "not enough samples")
+	l.Observe(time.Millisecond, true)
+	assert.True(t, l.Shedding(), "error rate exceeded")
+
+	l = &LoadShedder{MaxLatency: time.Second, MinSamples: 2}
+	l.Observe(3*time.Second, false)
+	l.Observe(0, false)
+	assert.True(t, l.Shedding(), "latency exceeded")
+	l.Observe(0, false)
+	assert.False(t, l.Shedding())
+}
+
+func TestLoadShedderWindow(t *testing.T) {
+	l := &LoadShedder{MaxErrorRate: 0.5, MinSamples: 1, Window: 10 * time.Millisecond}
+	l.Observe(0, true)
+	assert.True(t, l.Shedding())
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, l.Shedding())
+}
+
+func TestQueueShedDrop(t *testing.T) {
+	l := &LoadShedder{MaxErrorRate: 0.5, MinSamples: 1}
+	q := NewQueue(QueueConfig{Shedder: l})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "1"}))
+	l.Observe(0, true)
+	q.Close()
+	assert.Equal(t, []Class{ClassTransactional}, dequeueClasses(t, q, 1))
+	_, ok := q.Dequeue()
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), l.ShedCount(ClassMarketing))
+	assert.Equal(t, int64(1), l.ShedCount(ClassReminder))
+	assert.Equal(t, int64(0), l.ShedCount(ClassTransactional))
+}
+
+func TestQueueShedDefer(t *testing.T) {
+	l := &LoadShedder{MaxErrorRate: 0.5, MinSamples: 1, MinClass: ClassReminder, Policy: ShedDefer, Window: 50 * time.Millisecond}
+	q := NewQueue(QueueConfig{Shedder: l})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	l.Observe(0, true)
+	assert.Equal(t, []Class{ClassReminder}, dequeueClasses(t, q, 1))
+	assert.Equal(t, 1, q.ClassLen(ClassMarketing))
+	// the deferred job is dispatched once the failure leaves the window
+	assert.Equal(t, []Class{ClassMarketing}, dequeueClasses(t, q, 1))
+	assert.Equal(t, int64(0), l.ShedCount(ClassMarketing))
+}
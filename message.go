@@ -3,6 +3,7 @@ package gcm
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // Priority defines the priority of the message.
@@ -21,16 +22,67 @@ const (
 // Refer to https://goo.gl/ot271K.
 type Message struct {
 	// Options
-	CollapseKey           string   `json:"collapse_key,omitempty"`
-	DelayWhileIdle        bool     `json:"delay_while_idle,omitempty"`
-	TimeToLive            int      `json:"time_to_live,omitempty"`
+	CollapseKey    string `json:"collapse_key,omitempty"`
+	DelayWhileIdle bool   `json:"delay_while_idle,omitempty"`
+	TimeToLive     int    `json:"time_to_live,omitempty"`
+	// TimeToLiveSet sends TimeToLive even when it is 0, i.e. the message is
+	// delivered right away or dropped.  Without it a 0 TimeToLive is left out
+	// and the connection server applies its default of 4 weeks.
+	TimeToLiveSet         bool     `json:"-"`
 	RestrictedPackageName string   `json:"restricted_package_name,omitempty"`
 	DryRun                bool     `json:"dry_run,omitempty"`
 	ContentAvailable      bool     `json:"content_available,omitempty"`
 	Priority              Priority `json:"priority,omitempty"`
+	// DirectBootOK lets the message be delivered to an app while the device
+	// is in direct boot mode, before it is unlocked.
+	DirectBootOK bool `json:"direct_boot_ok,omitempty"`
 	// Payload
 	Data         map[string]string `json:"data,omitempty"`
 	Notification *Notification     `json:"notification,omitempty"`
+	// Tags is opaque caller metadata, e.g. the campaign of the message.  Tags
+	// are never sent to the GCM connection server, but are carried along to
+	// queues, hooks, results and archives to attribute outcomes.
+	Tags map[string]string `json:"-"`
+	// WebPush holds the web push options, only used through ToWebPush, as
+	// the GCM connection server has no equivalent.
+	WebPush *WebPushConfig `json:"-"`
+}
+
+// plainMessage has the fields of Message without its JSON methods.
+type plainMessage Message
+
+// ttlJSON is the time_to_live of m to encode, if any.
+func (m *Message) ttlJSON() *int {
+	if m.TimeToLive == 0 && !m.TimeToLiveSet {
+		return nil
+	}
+	ttl := m.TimeToLive
+	return &ttl
+}
+
+// MarshalJSON marshals Message to json.
+func (m Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		plainMessage
+		TimeToLive *int `json:"time_to_live,omitempty"`
+	}{plainMessage(m), m.ttlJSON()})
+}
+
+// UnmarshalJSON unmarshals Message from json, setting TimeToLiveSet when
+// time_to_live is present.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		plainMessage
+		TimeToLive *int `json:"time_to_live"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*m = Message(aux.plainMessage)
+	if aux.TimeToLive != nil {
+		m.TimeToLive, m.TimeToLiveSet = *aux.TimeToLive, true
+	}
+	return nil
 }
 
 type message struct {
@@ -38,20 +90,33 @@ type message struct {
 	// Targets
 	to              string
 	registrationIds []string
+	condition       string
+	// trace ID injected into Data, if any
+	traceID string
+	// UUID injected into Data, if any
+	uuid string
+	// number of requests made so far to send the message
+	attempts int
 }
 
 func (m *message) UnmarshalJSON(data []byte) error {
 	var aux struct {
 		To              string   `json:"to,omitempty"`
 		RegistrationIDs []string `json:"registration_ids,omitempty"`
-		Message
+		Condition       string   `json:"condition,omitempty"`
+		plainMessage
+		TimeToLive *int `json:"time_to_live"`
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
 	m.to = aux.To
 	m.registrationIds = aux.RegistrationIDs
-	m.Message = aux.Message
+	m.condition = aux.Condition
+	m.Message = Message(aux.plainMessage)
+	if aux.TimeToLive != nil {
+		m.TimeToLive, m.TimeToLiveSet = *aux.TimeToLive, true
+	}
 	return nil
 }
 
@@ -87,13 +152,17 @@ func (p Priority) MarshalJSON() ([]byte, error) {
 
 func (m message) MarshalJSON() ([]byte, error) {
 	aux := struct {
-		Message
+		plainMessage
+		TimeToLive      *int     `json:"time_to_live,omitempty"`
 		To              string   `json:"to,omitempty"`
 		RegistrationIDs []string `json:"registration_ids,omitempty"`
+		Condition       string   `json:"condition,omitempty"`
 	}{
-		Message:         m.Message,
+		plainMessage:    plainMessage(m.Message),
+		TimeToLive:      m.ttlJSON(),
 		To:              m.to,
 		RegistrationIDs: m.registrationIds,
+		Condition:       m.condition,
 	}
 	return json.Marshal(aux)
 }
@@ -109,9 +178,62 @@ type Notification struct {
 	TitleLocKey  string   `json:"title_loc_key,omitempty"`
 	TitleLocArgs []string `json:"title_loc_args,omitempty"`
 	// Android only
-	Icon  string `json:"icon,omitempty"`
-	Tag   string `json:"tag,omitempty"`
-	Color string `json:"color,omitempty"`
+	Icon             string `json:"icon,omitempty"`
+	Tag              string `json:"tag,omitempty"`
+	Color            string `json:"color,omitempty"`
+	AndroidChannelID string `json:"android_channel_id,omitempty"`
+	// NotificationPriority, DefaultSound, DefaultVibrateTimings,
+	// VibrateTimings and DefaultLightSettings are only sent through
+	// HMSTransport, as the GCM connection server has no equivalent.
+	NotificationPriority  NotificationPriority `json:"-"`
+	DefaultSound          bool                 `json:"-"`
+	DefaultVibrateTimings bool                 `json:"-"`
+	// VibrateTimings alternates the durations of vibration and pause, at most
+	// 10 of at most a minute each.
+	VibrateTimings       []time.Duration `json:"-"`
+	DefaultLightSettings bool            `json:"-"`
 	// iOS only
 	Badge string `json:"badge,omitempty"`
+	// InterruptionLevel and RelevanceScore are only sent through APNs, see
+	// ToAPNs, as the GCM connection server has no equivalent.
+	InterruptionLevel InterruptionLevel `json:"-"`
+	// RelevanceScore, from 0 to 1, ranks the notification in the summary of
+	// iOS 15+.  Zero leaves it out.
+	RelevanceScore float64 `json:"-"`
 }
+
+// NotificationPriority defines how much an Android notification interrupts
+// the user, on Android 7.1 and lower, or within its channel.
+type NotificationPriority string
+
+const (
+	// NotificationPriorityMin shows the notification only in the shade.
+	NotificationPriorityMin NotificationPriority = "PRIORITY_MIN"
+	// NotificationPriorityLow shows the notification without sound.
+	NotificationPriorityLow NotificationPriority = "PRIORITY_LOW"
+	// NotificationPriorityDefault is the default.
+	NotificationPriorityDefault NotificationPriority = "PRIORITY_DEFAULT"
+	// NotificationPriorityHigh may show the notification as a heads-up.
+	NotificationPriorityHigh NotificationPriority = "PRIORITY_HIGH"
+	// NotificationPriorityMax is for urgent notifications, e.g. calls.
+	NotificationPriorityMax NotificationPriority = "PRIORITY_MAX"
+)
+
+// InterruptionLevel defines when iOS 15+ presents a notification, e.g. during
+// Focus.
+type InterruptionLevel string
+
+const (
+	// InterruptionLevelPassive adds the notification to the list without
+	// lighting up the screen or playing a sound.
+	InterruptionLevelPassive InterruptionLevel = "passive"
+	// InterruptionLevelActive presents the notification immediately.  It is
+	// the default.
+	InterruptionLevelActive InterruptionLevel = "active"
+	// InterruptionLevelTimeSensitive presents the notification immediately,
+	// even during Focus.
+	InterruptionLevelTimeSensitive InterruptionLevel = "time-sensitive"
+	// InterruptionLevelCritical presents the notification immediately, even
+	// when muted.  It requires an entitlement from Apple.
+	InterruptionLevelCritical InterruptionLevel = "critical"
+)
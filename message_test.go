@@ -18,8 +18,12 @@ func TestMessageMarshalUnmarshal(t *testing.T) {
 	params := []param{
 		// success cases
 		{`{"registration_ids":["1","2"]}`, &message{registrationIds: []string{"1", "2"}}, nil},
+		{`{"condition":"'a' in topics"}`, &message{condition: "'a' in topics"}, nil},
 		{`{"priority":"normal"}`, &message{Message: Message{Priority: PriorityNormal}}, nil},
 		{`{"priority":"high"}`, &message{Message: Message{Priority: PriorityHigh}}, nil},
+		{`{"direct_boot_ok":true}`, &message{Message: Message{DirectBootOK: true}}, nil},
+		{`{"time_to_live":60}`, &message{Message: Message{TimeToLive: 60, TimeToLiveSet: true}}, nil},
+		{`{"time_to_live":0}`, &message{Message: Message{TimeToLiveSet: true}}, nil},
 		{`{"data":{"k":"v"}}`, &message{Message: Message{Data: map[string]string{"k": "v"}}}, nil},
 		{`{"notification":{"title":"test"}}`, &message{Message: Message{Notification: &Notification{Title: "test"}}}, nil},
 		// unmarshal failure cases
@@ -51,3 +55,35 @@ func TestMessageMarshalUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestMessageDirectBootOK(t *testing.T) {
+	b, err := json.Marshal(message{Message: Message{DirectBootOK: true}, to: "regId"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"direct_boot_ok":true,"to":"regId"}`, string(b))
+	b, err = json.Marshal(message{to: "regId"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"to":"regId"}`, string(b))
+}
+
+func TestMessageTimeToLiveZero(t *testing.T) {
+	b, err := json.Marshal(Message{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(b), "unset TimeToLive is left out")
+	b, err = json.Marshal(Message{TimeToLive: 60})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"time_to_live":60}`, string(b))
+	b, err = json.Marshal(&Message{TimeToLiveSet: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"time_to_live":0}`, string(b))
+
+	var m Message
+	assert.NoError(t, json.Unmarshal([]byte(`{"time_to_live":0,"collapse_key":"k"}`), &m))
+	assert.Equal(t, Message{CollapseKey: "k", TimeToLiveSet: true}, m)
+	m = Message{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"collapse_key":"k"}`), &m))
+	assert.False(t, m.TimeToLiveSet)
+
+	hm, err := (&HMSTransport{}).message(&Message{TimeToLiveSet: true, Data: map[string]string{"k": "v"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "0s", hm.Android.TTL)
+}
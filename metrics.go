@@ -0,0 +1,420 @@
+package gcm
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types.
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+// MetricDesc describes a metric exported by Metrics.
+type MetricDesc struct {
+	// Name is the name of the metric family.  Counters are exposed with a
+	// _total suffix, histograms with _bucket, _count and _sum series.
+	Name   string
+	Type   string
+	Help   string
+	Labels []string
+}
+
+// MetricDescs is the registry of the metrics exported by Metrics.  Names and
+// labels are a stable contract: they are only ever added to, never renamed or
+// removed, so that dashboards and alerts keep working across versions.
+//
+// The labels are:
+//
+//	app          the RestrictedPackageName of the message, or empty
+//	target_type  the TargetType of the message: token, topic or group
+//	error_code   "ok", a GCM error code such as NotRegistered, or for
+//	             requests and probes "http_<status>" or "transport"
+//	class        the Class of queued jobs
+var MetricDescs = []MetricDesc{
+	{"gcm_requests", MetricCounter, "Requests made to the GCM connection server.", []string{"app", "target_type", "error_code"}},
+	{"gcm_results", MetricCounter, "Per recipient results of the requests.", []string{"app", "target_type", "error_code"}},
+	{"gcm_request_duration_seconds", MetricHistogram, "Latency of the requests.", []string{"app", "target_type"}},
+	{"gcm_queue_depth", MetricGauge, "Pending jobs of the Queue.", []string{"class"}},
+	{"gcm_probes", MetricCounter, "Probes sent by a Prober.", []string{"error_code"}},
+	{"gcm_probe_duration_seconds", MetricHistogram, "End-to-end latency of the probes of a Prober.", nil},
+}
+
+// exemplar links an observation to the trace of the message it is about.
+type exemplar struct {
+	traceID string
+	value   float64
+	time    time.Time
+}
+
+type counterSeries struct {
+	labels   []string
+	value    float64
+	exemplar *exemplar
+}
+
+type histogramSeries struct {
+	labels    []string
+	buckets   []uint64 // not cumulative, with an extra bucket for +Inf
+	exemplars []*exemplar
+	count     uint64
+	sum       float64
+}
+
+// Metrics collects the metrics of a Sender or a Prober, described by
+// MetricDescs, and serves them in the OpenMetrics text format, e.g. for
+// Prometheus.  When the Sender has a TraceIDKey, the latency buckets and the
+// error series carry the trace ID of their latest observation as an exemplar,
+// linking them to the traces of the messages.
+//
+// Metrics is safe for concurrent use.
+type Metrics struct {
+	// Queue, if set, has its depth exported.
+	Queue *Queue
+
+	mu         sync.Mutex
+	counters   map[string]map[string]*counterSeries   // by family and labels
+	histograms map[string]map[string]*histogramSeries // by family and labels
+}
+
+func seriesKey(labels []string) string {
+	return strings.Join(labels, "\xff")
+}
+
+func (m *Metrics) add(family string, labels []string, n float64, ex *exemplar) {
+	if m.counters == nil {
+		m.counters = make(map[string]map[string]*counterSeries)
+	}
+	series := m.counters[family]
+	if series == nil {
+		series = make(map[string]*counterSeries)
+		m.counters[family] = series
+	}
+	key := seriesKey(labels)
+	c := series[key]
+	if c == nil {
+		c = &counterSeries{labels: labels}
+		series[key] = c
+	}
+	c.value += n
+	if ex != nil {
+		c.exemplar = ex
+	}
+}
+
+func (m *Metrics) observeLatency(family string, labels []string, d time.Duration, ex *exemplar) {
+	if m.histograms == nil {
+		m.histograms = make(map[string]map[string]*histogramSeries)
+	}
+	series := m.histograms[family]
+	if series == nil {
+		series = make(map[string]*histogramSeries)
+		m.histograms[family] = series
+	}
+	key := seriesKey(labels)
+	h := series[key]
+	if h == nil {
+		h = &histogramSeries{
+			labels:    labels,
+			buckets:   make([]uint64, len(LatencyBuckets)+1),
+			exemplars: make([]*exemplar, len(LatencyBuckets)+1),
+		}
+		series[key] = h
+	}
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.buckets[i]++
+	h.count++
+	h.sum += d.Seconds()
+	if ex != nil {
+		h.exemplars[i] = ex
+	}
+}
+
+// requestErrorCode returns the error_code label of a failed request.
+func requestErrorCode(err error) string {
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		return "http_" + strconv.Itoa(httpErr.statusCode)
+	}
+	return "transport"
+}
+
+// observeProbe records a probe of a Prober.
+func (m *Metrics) observeProbe(probe ProbeResult) {
+	if m == nil {
+		return
+	}
+	code := "ok"
+	switch {
+	case probe.Result != nil && probe.Result.Error != "":
+		code = probe.Result.Error
+	case probe.Err != nil:
+		code = requestErrorCode(probe.Err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observeLatency("gcm_probe_duration_seconds", nil, probe.Latency, nil)
+	m.add("gcm_probes", []string{code}, 1, nil)
+}
+
+// observe records a request made for msg.
+func (m *Metrics) observe(msg *message, t TargetType, latency time.Duration, resp *response, err error) {
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	newExemplar := func(value float64) *exemplar {
+		if msg.traceID == "" {
+			return nil
+		}
+		return &exemplar{msg.traceID, value, now}
+	}
+	app, target := msg.RestrictedPackageName, t.String()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observeLatency("gcm_request_duration_seconds", []string{app, target}, latency, newExemplar(latency.Seconds()))
+	if err != nil {
+		m.add("gcm_requests", []string{app, target, requestErrorCode(err)}, 1, newExemplar(1))
+		return
+	}
+	m.add("gcm_requests", []string{app, target, "ok"}, 1, nil)
+	result := func(errCode string, n int) {
+		if n == 0 {
+			return
+		}
+		if errCode == "" {
+			m.add("gcm_results", []string{app, target, "ok"}, float64(n), nil)
+		} else {
+			m.add("gcm_results", []string{app, target, errCode}, float64(n), newExemplar(float64(n)))
+		}
+	}
+	switch {
+	case resp == nil:
+	case len(resp.Results) > 0:
+		for _, res := range resp.Results {
+			result(res.Err, 1)
+		}
+	case t == TargetGroup:
+		result("", resp.Success)
+		result("GroupFailure", resp.Failure)
+	default:
+		result(resp.Err, 1)
+	}
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	write := func(name, value string) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value))
+		b.WriteByte('"')
+	}
+	for i, name := range names {
+		write(name, values[i])
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		write(extra[i], extra[i+1])
+	}
+	if b.Len() == 1 {
+		return ""
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatExemplar(ex *exemplar) string {
+	if ex == nil {
+		return ""
+	}
+	return fmt.Sprintf(` # {trace_id="%s"} %s %.3f`, ex.traceID, formatFloat(ex.value), float64(ex.time.UnixNano())/1e9)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// WriteTo writes the metrics in the OpenMetrics text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var depths map[string]int
+	if m.Queue != nil {
+		depths = m.Queue.Stats().ClassDepth
+	}
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	m.mu.Lock()
+	for _, desc := range MetricDescs {
+		fmt.Fprintf(cw, "# TYPE %s %s\n# HELP %s %s\n", desc.Name, desc.Type, desc.Name, desc.Help)
+		switch desc.Type {
+		case MetricCounter:
+			for _, c := range sortedCounters(m.counters[desc.Name]) {
+				fmt.Fprintf(cw, "%s_total%s %s%s\n", desc.Name, formatLabels(desc.Labels, c.labels), formatFloat(c.value), formatExemplar(c.exemplar))
+			}
+		case MetricHistogram:
+			for _, h := range sortedHistograms(m.histograms[desc.Name]) {
+				var cumulative uint64
+				for i, n := range h.buckets {
+					cumulative += n
+					le := "+Inf"
+					if i < len(LatencyBuckets) {
+						le = formatFloat(LatencyBuckets[i].Seconds())
+					}
+					fmt.Fprintf(cw, "%s_bucket%s %d%s\n", desc.Name, formatLabels(desc.Labels, h.labels, "le", le), cumulative, formatExemplar(h.exemplars[i]))
+				}
+				fmt.Fprintf(cw, "%s_count%s %d\n", desc.Name, formatLabels(desc.Labels, h.labels), h.count)
+				fmt.Fprintf(cw, "%s_sum%s %s\n", desc.Name, formatLabels(desc.Labels, h.labels), formatFloat(h.sum))
+			}
+		case MetricGauge:
+			classes := make([]string, 0, len(depths))
+			for class := range depths {
+				classes = append(classes, class)
+			}
+			sort.Strings(classes)
+			for _, class := range classes {
+				fmt.Fprintf(cw, "%s%s %d\n", desc.Name, formatLabels(desc.Labels, []string{class}), depths[class])
+			}
+		}
+	}
+	m.mu.Unlock()
+	io.WriteString(cw, "# EOF\n")
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func sortedCounters(series map[string]*counterSeries) []*counterSeries {
+	sorted := make([]*counterSeries, 0, len(series))
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sorted = append(sorted, series[key])
+	}
+	return sorted
+}
+
+func sortedHistograms(series map[string]*histogramSeries) []*histogramSeries {
+	sorted := make([]*histogramSeries, 0, len(series))
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sorted = append(sorted, series[key])
+	}
+	return sorted
+}
+
+// ServeHTTP serves the metrics in the OpenMetrics text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// GrafanaDashboard generates a starter Grafana dashboard, as JSON, with a panel
+// per metric of MetricDescs queried from the named Prometheus data source,
+// and a variable selecting the app.
+func GrafanaDashboard(datasource string) ([]byte, error) {
+	ds := map[string]string{"type": "prometheus", "uid": datasource}
+	var panels []map[string]interface{}
+	for i, desc := range MetricDescs {
+		filter := ""
+		if contains(desc.Labels, "app") {
+			filter = `{app=~"$app"}`
+		}
+		// the app is selected by the variable
+		var by []string
+		for _, label := range desc.Labels {
+			if label != "app" {
+				by = append(by, label)
+			}
+		}
+		var expr, unit string
+		exemplars := false
+		switch desc.Type {
+		case MetricCounter:
+			expr = fmt.Sprintf("sum by (%s) (rate(%s_total%s[$__rate_interval]))", strings.Join(by, ", "), desc.Name, filter)
+			unit = "reqps"
+			exemplars = true
+		case MetricHistogram:
+			expr = fmt.Sprintf("histogram_quantile(0.99, sum by (%s) (rate(%s_bucket%s[$__rate_interval])))", strings.Join(append([]string{"le"}, by...), ", "), desc.Name, filter)
+			unit = "s"
+			exemplars = true
+		case MetricGauge:
+			expr = fmt.Sprintf("sum by (%s) (%s%s)", strings.Join(desc.Labels, ", "), desc.Name, filter)
+			unit = "short"
+		}
+		panels = append(panels, map[string]interface{}{
+			"id":          i + 1,
+			"type":        "timeseries",
+			"title":       desc.Name,
+			"description": desc.Help,
+			"datasource":  ds,
+			"gridPos":     map[string]int{"x": (i % 2) * 12, "y": (i / 2) * 8, "w": 12, "h": 8},
+			"fieldConfig": map[string]interface{}{"defaults": map[string]string{"unit": unit}},
+			"targets": []map[string]interface{}{{
+				"refId":      "A",
+				"datasource": ds,
+				"expr":       expr,
+				"exemplar":   exemplars,
+			}},
+		})
+	}
+	dashboard := map[string]interface{}{
+		"title":         "GCM",
+		"schemaVersion": 39,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{{
+				"name":       "app",
+				"type":       "query",
+				"datasource": ds,
+				"query":      "label_values(gcm_requests_total, app)",
+				"includeAll": true,
+				"multi":      true,
+				"allValue":   ".*",
+			}},
+		},
+		"panels": panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
@@ -0,0 +1,76 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &partialMulticast},
+		&testResponse{statusCode: http.StatusBadRequest},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Metrics = &Metrics{}
+	s.TraceIDKey = "trace"
+	s.TraceIDGenerator = func() string { return "t1" }
+
+	_, err := s.SendNoRetry(&Message{Data: data, RestrictedPackageName: "com.example"}, "1")
+	assert.NoError(t, err)
+	_, err = s.SendMulticastNoRetry(msg, twoRecipients)
+	assert.NoError(t, err)
+	_, err = s.SendNoRetry(msg, "1")
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	_, err = s.Metrics.WriteTo(&buf)
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE gcm_requests counter\n")
+	assert.Contains(t, out, `gcm_requests_total{app="com.example",target_type="token",error_code="ok"} 1`+"\n")
+	assert.Contains(t, out, `gcm_requests_total{app="",target_type="token",error_code="ok"} 1`+"\n")
+	assert.Contains(t, out, `gcm_requests_total{app="",target_type="token",error_code="http_400"} 1 # {trace_id="t1"} 1 `)
+	assert.Contains(t, out, `gcm_results_total{app="",target_type="token",error_code="ok"} 1`+"\n")
+	assert.Contains(t, out, `gcm_results_total{app="",target_type="token",error_code="Unavailable"} 1 # {trace_id="t1"} 1 `)
+	assert.Contains(t, out, `gcm_request_duration_seconds_bucket{app="",target_type="token",le="+Inf"} 2`)
+	assert.Contains(t, out, `gcm_request_duration_seconds_count{app="com.example",target_type="token"} 1`+"\n")
+	assert.Contains(t, out, `# {trace_id="t1"}`)
+	assert.True(t, strings.HasSuffix(out, "# EOF\n"))
+}
+
+func TestMetricsQueueDepth(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, To: "1", Message: msg}))
+	m := &Metrics{Queue: q}
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	assert.Contains(t, buf.String(), `gcm_queue_depth{class="reminder"} 1`+"\n")
+}
+
+func TestGrafanaDashboard(t *testing.T) {
+	b, err := GrafanaDashboard("prom")
+	assert.NoError(t, err)
+	var dashboard struct {
+		Panels []struct {
+			Title   string
+			Targets []struct {
+				Expr     string
+				Exemplar bool
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(b, &dashboard))
+	assert.Len(t, dashboard.Panels, len(MetricDescs))
+	for i, desc := range MetricDescs {
+		assert.Equal(t, desc.Name, dashboard.Panels[i].Title)
+		assert.Contains(t, dashboard.Panels[i].Targets[0].Expr, desc.Name)
+	}
+	assert.True(t, dashboard.Panels[2].Targets[0].Exemplar)
+}
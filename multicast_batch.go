@@ -0,0 +1,80 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// sendMulticastBatches sends to registration IDs exceeding MaxRegistrationIDs
+// with send in consecutive batches if the Sender has SplitMulticast, and
+// merges their results.  The MulticastID is that of the first batch, and the
+// multicast IDs of the other batches are in BatchMulticastIDs.
+//
+// A batch whose error comes with a result for each of its registration IDs,
+// e.g. a *RetryExhaustedError, was sent, so the other batches are sent too,
+// and the error of the first such batch is returned with the merged results.
+// If a batch fails without results after others were sent, their results, and
+// any partial results of the failed batch, are returned with a
+// *PartialResultError rather than thrown away, so that the registration IDs
+// past the returned Results can be sent again without sending to the others
+// twice.
+func (s *Sender) sendMulticastBatches(regIDs []string, send func(batch []string) (*MulticastResult, error)) (*MulticastResult, error) {
+	if !s.SplitMulticast {
+		return nil, fmt.Errorf("%d registration IDs exceed the limit of %d per request, set SplitMulticast to send them in batches", len(regIDs), MaxRegistrationIDs)
+	}
+	return mergeMulticastBatches(regIDs, send)
+}
+
+// mergeMulticastBatches is sendMulticastBatches regardless of SplitMulticast.
+func mergeMulticastBatches(regIDs []string, send func(batch []string) (*MulticastResult, error)) (*MulticastResult, error) {
+	merged := &MulticastResult{Results: make([]Result, 0, len(regIDs))}
+	first := true
+	var batchErr error
+	err := forEachBatch(regIDs, MaxRegistrationIDs, func(batch []string) error {
+		result, err := send(batch)
+		if result != nil {
+			if first {
+				merged.MulticastID, merged.TraceID, merged.UUID = result.MulticastID, result.TraceID, result.UUID
+			} else if result.MulticastID != 0 {
+				merged.BatchMulticastIDs = append(merged.BatchMulticastIDs, result.MulticastID)
+			}
+			merged.Success += result.Success
+			merged.Failure += result.Failure
+			merged.CanonicalIds += result.CanonicalIds
+			merged.Results = append(merged.Results, result.Results...)
+			merged.RetryMulticastIDs = append(merged.RetryMulticastIDs, result.RetryMulticastIDs...)
+		}
+		first = false
+		if err != nil && result != nil && len(result.Results) == len(batch) {
+			if batchErr == nil {
+				batchErr = err
+			}
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		if len(merged.Results) == 0 {
+			return nil, err
+		}
+		var partialErr *PartialResultError
+		if errors.As(err, &partialErr) {
+			err = partialErr.Err
+		}
+		return merged, &PartialResultError{err}
+	}
+	return merged, batchErr
+}
+
+// forEachBatch calls f with the registration IDs in consecutive batches of at
+// most size, until done or f returns an error, which is returned.
+func forEachBatch(regIDs []string, size int, f func(batch []string) error) error {
+	for len(regIDs) > 0 {
+		batch := regIDs[:min(size, len(regIDs))]
+		regIDs = regIDs[len(batch):]
+		if err := f(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendMulticastBatches(t *testing.T) {
+	var batches []int
+	failBatch := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m message
+		json.NewDecoder(r.Body).Decode(&m)
+		batches = append(batches, len(m.registrationIds))
+		if len(batches) == failBatch {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := response{MulticastID: int64(len(batches)), Success: len(m.registrationIds)}
+		for i := range m.registrationIds {
+			resp.Results = append(resp.Results, result{MessageID: fmt.Sprint(i)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Endpoint = server.URL
+	regIDs := make([]string, 2*MaxRegistrationIDs+500)
+	for i := range regIDs {
+		regIDs[i] = fmt.Sprint("regId", i)
+	}
+
+	_, err := s.SendMulticastNoRetry(msg, regIDs)
+	assert.EqualError(t, err, "2500 registration IDs exceed the limit of 1000 per request, set SplitMulticast to send them in batches")
+	_, err = s.SendMulticastWithRetries(msg, regIDs, 1)
+	assert.Error(t, err)
+	assert.Empty(t, batches, "nothing is sent")
+
+	s.SplitMulticast = true
+	result, err := s.SendMulticastWithRetries(msg, regIDs, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1000, 1000, 500}, batches)
+	assert.Equal(t, 2500, result.Success)
+	assert.Len(t, result.Results, 2500)
+	assert.Equal(t, "0", result.Results[MaxRegistrationIDs].MessageID)
+	assert.Equal(t, "499", result.Results[2499].MessageID)
+	assert.Equal(t, int64(1), result.MulticastID)
+	assert.Equal(t, []int64{2, 3}, result.BatchMulticastIDs)
+	assert.Empty(t, result.RetryMulticastIDs)
+
+	result, err = s.SendMulticastNoRetry(msg, regIDs[:MaxRegistrationIDs+1])
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1000, 1000, 500, 1000, 1}, batches)
+	assert.Equal(t, "0", result.Results[MaxRegistrationIDs].MessageID)
+
+	// the results of the batches sent before a failed one are kept
+	batches, failBatch = nil, 3
+	result, err = s.SendMulticastNoRetry(msg, regIDs)
+	var partialErr *PartialResultError
+	assert.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, []int{1000, 1000, 500}, batches)
+	assert.Equal(t, 2000, result.Success)
+	assert.Len(t, result.Results, 2000)
+	assert.Equal(t, []int64{2}, result.BatchMulticastIDs)
+
+	batches, failBatch = nil, 1
+	result, err = s.SendMulticastNoRetry(msg, regIDs)
+	assert.Error(t, err)
+	assert.Nil(t, result, "nothing was sent")
+	assert.Equal(t, []int{1000}, batches)
+}
+
+func TestSendMulticastBatchesAfterRetryExhausted(t *testing.T) {
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m message
+		json.NewDecoder(r.Body).Decode(&m)
+		batches = append(batches, len(m.registrationIds))
+		resp := response{MulticastID: int64(len(batches))}
+		for i, regID := range m.registrationIds {
+			if regID == "regId0" {
+				resp.Failure++
+				resp.Results = append(resp.Results, result{Err: ErrorUnavailable})
+			} else {
+				resp.Success++
+				resp.Results = append(resp.Results, result{MessageID: fmt.Sprint(i)})
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Endpoint = server.URL
+	s.SplitMulticast, s.RetryExhaustedErrors = true, true
+	s.Time = &fakeTime{now: time.Now()}
+	regIDs := make([]string, 2*MaxRegistrationIDs+500)
+	for i := range regIDs {
+		regIDs[i] = fmt.Sprint("regId", i)
+	}
+
+	result, err := s.SendMulticastWithRetries(msg, regIDs, 1)
+	var exhausted *RetryExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Equal(t, []int{1000, 1, 1000, 500}, batches, "the batches after the exhausted one are sent")
+	assert.Len(t, result.Results, 2500)
+	assert.Equal(t, 2499, result.Success)
+	assert.Equal(t, ErrorUnavailable, result.Results[0].Error)
+}
+
+func TestSendMulticastBatchesShareIDs(t *testing.T) {
+	ids := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m message
+		json.NewDecoder(r.Body).Decode(&m)
+		ids[m.Data["uuid"]+"/"+m.Data["trace"]] = true
+		resp := response{Success: len(m.registrationIds), Results: successes(len(m.registrationIds))}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Endpoint = server.URL
+	s.SplitMulticast = true
+	s.MessageUUIDKey, s.TraceIDKey = "uuid", "trace"
+	regIDs := make([]string, 2*MaxRegistrationIDs+500)
+	for i := range regIDs {
+		regIDs[i] = fmt.Sprint("regId", i)
+	}
+
+	result, err := s.SendMulticastNoRetry(msg, regIDs)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{result.UUID + "/" + result.TraceID: true}, ids, "one send, one UUID and trace ID")
+	assert.NotEmpty(t, result.UUID)
+	assert.Equal(t, map[string]string{"k": "v"}, msg.Data, "the caller's message is untouched")
+}
+
+func TestForEachBatch(t *testing.T) {
+	var sizes []int
+	err := forEachBatch([]string{"1", "2", "3", "4", "5"}, 2, func(batch []string) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+
+	stop := errors.New("stop")
+	sizes = nil
+	err = forEachBatch([]string{"1", "2", "3", "4", "5"}, 2, func(batch []string) error {
+		sizes = append(sizes, len(batch))
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []int{2}, sizes)
+}
@@ -0,0 +1,115 @@
+package gcm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// OpenAPISpec generates the OpenAPI 3 document of the API served by
+// APIHandler, as JSON, with the schemas derived from the Go types so that it
+// stays in sync with them.
+func OpenAPISpec() ([]byte, error) {
+	schemas := make(map[string]interface{})
+	ref := func(v interface{}) interface{} {
+		return schemaOf(reflect.TypeOf(v), schemas)
+	}
+	content := func(schema interface{}) map[string]interface{} {
+		return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+	}
+	send := func(summary string, req, resp interface{}) map[string]interface{} {
+		return map[string]interface{}{"post": map[string]interface{}{
+			"summary":     summary,
+			"requestBody": map[string]interface{}{"required": true, "content": content(ref(req))},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "sent", "content": content(ref(resp))},
+				"400": map[string]interface{}{"description": "invalid request"},
+				"502": map[string]interface{}{"description": "rejected by the connection server"},
+				"503": map[string]interface{}{"description": "unavailable, the request may be retried"},
+			},
+		}}
+	}
+	paths := map[string]interface{}{
+		"/messages":  send("Send a message to a registration token, topic or device group", SendRequest{}, SendResponse{}),
+		"/multicast": send("Send a message to registration tokens", MulticastRequest{}, MulticastResponse{}),
+		"/results/{id}": map[string]interface{}{"get": map[string]interface{}{
+			"summary": "Get the response to a send again",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": map[string]string{"type": "string"}},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "the response to the send", "content": content(map[string]interface{}{
+					"oneOf": []interface{}{ref(SendResponse{}), ref(MulticastResponse{})},
+				})},
+				"404": map[string]interface{}{"description": "unknown or expired id"},
+			},
+		}},
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]string{"title": "GCM push API", "version": "1"},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}, "", "  ")
+}
+
+var priorityType = reflect.TypeOf(Priority(0))
+
+// schemaOf returns the schema of values of t encoded as JSON, adding the
+// schemas of structs to schemas and referring to them.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == priorityType {
+		return map[string]interface{}{"type": "string", "enum": []string{"normal", "high"}}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]string{"type": "string"}
+	case reflect.Bool:
+		return map[string]string{"type": "boolean"}
+	case reflect.Int64:
+		return map[string]string{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int32:
+		return map[string]string{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]string{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		schema := map[string]interface{}{"type": "object"}
+		// added before its fields, in case a field refers back to it
+		schemas[t.Name()] = schema
+		properties := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if f.PkgPath != "" || tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, opts = tag[:i], tag[i:]
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = schemaOf(f.Type, schemas)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,117 @@
+package gcm
+
+import (
+	"log"
+	"time"
+)
+
+// OutboxEntry is a job claimed from an Outbox.
+type OutboxEntry struct {
+	ID  string
+	Job *Job
+}
+
+// Outbox is a durable store of jobs shared by several OutboxRelay replicas
+// with at-least-once semantics: a claimed entry that is not acknowledged in
+// time, e.g. because its replica crashed, can be claimed again.
+type Outbox interface {
+	// Add stores a job.
+	Add(job *Job) error
+	// Claim claims up to n entries for the caller.  It returns no entries if
+	// none are available.
+	Claim(n int) ([]OutboxEntry, error)
+	// Ack acknowledges that the claimed entries are done with, removing them.
+	Ack(ids ...string) error
+}
+
+// DefaultOutboxBatchSize is the BatchSize used by an OutboxRelay when none is
+// set.
+const DefaultOutboxBatchSize = 100
+
+// defaultPollInterval is the PollInterval used by an OutboxRelay when none is
+// set.
+const defaultPollInterval = time.Second
+
+// OutboxRelay sends the jobs of an Outbox.  A job is acknowledged once sent, or
+// once it failed with an error that retrying cannot fix or after reaching some
+// of its recipients; jobs that failed with a retryable error before reaching
+// any recipient are left to be claimed again.
+type OutboxRelay struct {
+	// Outbox is the source of jobs.
+	Outbox Outbox
+	// Sender sends the jobs.
+	Sender *Sender
+	// Retries is the number of retries for each job.
+	Retries int
+	// BatchSize is the max number of entries claimed at once.  Zero means
+	// DefaultOutboxBatchSize.
+	BatchSize int
+	// PollInterval is how long Run waits before claiming again when the
+	// Outbox is empty or failing.  Zero means 1s.
+	PollInterval time.Duration
+	// OnResult, if set, is called with the outcome of each job.
+	OnResult func(*JobResult)
+	// PanicPolicy decides what happens when the Sender or OnResult panics.
+	PanicPolicy PanicPolicy
+	// DeadLetters, if set, keeps the jobs that were given up on.
+	DeadLetters *DeadLetterQueue
+}
+
+// Relay claims a batch of entries, sends their jobs and acknowledges them.  It
+// returns the number of claimed entries.
+func (r *OutboxRelay) Relay() (int, error) {
+	n := r.BatchSize
+	if n <= 0 {
+		n = DefaultOutboxBatchSize
+	}
+	entries, err := r.Outbox.Claim(n)
+	if err != nil {
+		return 0, err
+	}
+	var done []string
+	for _, entry := range entries {
+		jr, panicked := sendJob(r.Sender, entry.Job, r.Retries, r.PanicPolicy)
+		if jr.Err == nil || jr.delivered() || !panicked && !isRetryable(jr.Err) {
+			done = append(done, entry.ID)
+			if jr.Err != nil && r.DeadLetters != nil {
+				r.DeadLetters.Add(entry.Job, jr.Err)
+			}
+		}
+		if r.OnResult != nil {
+			protect(r.PanicPolicy, "OnResult", func() { r.OnResult(jr) })
+		}
+	}
+	if len(done) > 0 {
+		if err := r.Outbox.Ack(done...); err != nil {
+			return len(entries), err
+		}
+	}
+	return len(entries), nil
+}
+
+// Run relays jobs until done is closed.
+func (r *OutboxRelay) Run(done <-chan struct{}) {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		n, err := r.Relay()
+		if err != nil {
+			log.Printf("failed to relay outbox: %v", err)
+		}
+		if err == nil && n > 0 {
+			continue
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
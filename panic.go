@@ -0,0 +1,51 @@
+package gcm
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// PanicPolicy defines what happens when a user-provided hook, such as a
+// callback or an EventListener, panics.
+type PanicPolicy int
+
+const (
+	// PanicLog recovers from the panic, logs it with its stack trace and
+	// counts it in RecoveredPanics.
+	PanicLog PanicPolicy = iota
+	// PanicCount recovers from the panic and only counts it in
+	// RecoveredPanics.
+	PanicCount
+	// PanicPropagate lets the panic propagate, which crashes the program
+	// unless the caller recovers from it.
+	PanicPropagate
+)
+
+var recoveredPanics int64
+
+// RecoveredPanics returns the number of panics in hooks recovered from so far.
+func RecoveredPanics() int64 {
+	return atomic.LoadInt64(&recoveredPanics)
+}
+
+// protect calls the named hook f, recovering from a panic according to policy,
+// in which case it returns an error describing the panic.
+func protect(policy PanicPolicy, hook string, f func()) (err error) {
+	if policy == PanicPropagate {
+		f()
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddInt64(&recoveredPanics, 1)
+			err = fmt.Errorf("panic in %s: %v", hook, r)
+			if policy == PanicLog {
+				log.Printf("%v\n%s", err, debug.Stack())
+			}
+		}
+	}()
+	f()
+	return nil
+}
@@ -0,0 +1,109 @@
+package gcm
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtect(t *testing.T) {
+	before := RecoveredPanics()
+	assert.NoError(t, protect(PanicLog, "hook", func() {}))
+	assert.EqualError(t, protect(PanicLog, "hook", func() { panic("boom") }), "panic in hook: boom")
+	assert.EqualError(t, protect(PanicCount, "hook", func() { panic("boom") }), "panic in hook: boom")
+	assert.Equal(t, before+2, RecoveredPanics())
+	assert.Panics(t, func() { protect(PanicPropagate, "hook", func() { panic("boom") }) })
+	assert.Equal(t, before+2, RecoveredPanics())
+}
+
+func TestSendWithPanickingHooks(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success}, &testResponse{response: &success})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.PanicPolicy = PanicCount
+	s.OnAPIKeyUsed = func(bool) { panic("boom") }
+	s.TraceIDKey = "trace_id"
+	s.TraceIDGenerator = func() string { panic("boom") }
+	s.Listener = panickingListener{}
+	result, err := s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.Len(t, result.TraceID, 32)
+
+	s.Signer = RequestSignerFunc(func(*http.Request, []byte) error { panic("boom") })
+	_, err = s.SendNoRetry(msg, "regId")
+	assert.EqualError(t, err, "panic in Signer: boom")
+}
+
+func TestDispatcherWithPanickingOnResult(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success}, &testResponse{response: &success})
+	defer server.Close()
+	q := NewQueue(QueueConfig{Listener: panickingListener{}, PanicPolicy: PanicCount})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "2"}))
+	q.Close()
+	dispatched := 0
+	d := &Dispatcher{Queue: q, Sender: NewSender("test-api-key"), PanicPolicy: PanicCount}
+	d.OnResult = func(*JobResult) {
+		dispatched++
+		panic("boom")
+	}
+	d.Run()
+	assert.Equal(t, 2, dispatched)
+}
+
+func TestConfigWatcherWithPanickingOnReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcm.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("retry:\n  device_group: group\n"), 0600))
+	s := NewSender("test-api-key")
+	w := &ConfigWatcher{Path: path, Sender: s, PanicPolicy: PanicCount, OnReload: func(*Config, error) { panic("boom") }}
+	ok, err := w.Check()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, DeviceGroupRetryGroup, s.policy().DeviceGroupRetry)
+}
+
+func TestProberWithPanickingOnProbe(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.PanicPolicy = PanicCount
+	p := &Prober{Sender: s, To: "regId", OnProbe: func(ProbeResult) { panic("boom") }}
+	assert.NoError(t, p.Probe().Err)
+}
+
+func TestCoalescerWithPanickingImmediate(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	c := &Coalescer{Queue: q, Window: time.Hour, PanicPolicy: PanicCount, Immediate: func(*Job) bool { panic("boom") }}
+	assert.NoError(t, c.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "otp"}))
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestAPNsFallbackWithPanickingDegraded(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.PanicPolicy = PanicCount
+	f := &APNsFallback{Sender: s, Degraded: func() bool { panic("boom") }}
+	result, err := f.Send(msg, "regId", "device-token", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", result.MessageID)
+}
+
+type panickingListener struct{}
+
+func (panickingListener) OnEnqueue(job *Job)                  { panic("boom") }
+func (panickingListener) OnDrop(job *Job, reason error)       { panic("boom") }
+func (panickingListener) OnAttempt(msg *Message, attempt int) { panic("boom") }
+func (panickingListener) OnRetryScheduled(msg *Message, attempt int, delay time.Duration) {
+	panic("boom")
+}
+func (panickingListener) OnSuccess(msg *Message, results []Result) { panic("boom") }
+func (panickingListener) OnFailure(msg *Message, err error)        { panic("boom") }
@@ -0,0 +1,193 @@
+package gcm
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxPayloadSize is the max size in bytes of the payload of a message.
+const MaxPayloadSize = 4096
+
+// KeySize is the number of bytes a data key and its value take in the
+// JSON-encoded payload.
+type KeySize struct {
+	Key  string
+	Size int
+}
+
+// PayloadReport describes the size of the payload of a message.
+type PayloadReport struct {
+	// Size is the size in bytes of the JSON-encoded data and notification.
+	Size int
+	// Keys lists the data keys from the largest to the smallest.
+	Keys []KeySize
+}
+
+// Exceeds reports whether the payload exceeds MaxPayloadSize.
+func (r PayloadReport) Exceeds() bool {
+	return r.Size > MaxPayloadSize
+}
+
+// AnalyzePayload reports the payload size of the message and which data keys
+// consume the most bytes.
+func AnalyzePayload(msg *Message) PayloadReport {
+	var report PayloadReport
+	if msg == nil {
+		return report
+	}
+	if len(msg.Data) > 0 {
+		b, _ := json.Marshal(msg.Data)
+		report.Size += len(b)
+	}
+	if msg.Notification != nil {
+		b, _ := json.Marshal(msg.Notification)
+		report.Size += len(b)
+	}
+	for k, v := range msg.Data {
+		report.Keys = append(report.Keys, KeySize{k, keySize(k, v)})
+	}
+	sort.Slice(report.Keys, func(i, j int) bool {
+		if report.Keys[i].Size != report.Keys[j].Size {
+			return report.Keys[i].Size > report.Keys[j].Size
+		}
+		return report.Keys[i].Key < report.Keys[j].Key
+	})
+	return report
+}
+
+// keySize returns the bytes taken by "k":"v", including the separator.
+func keySize(k, v string) int {
+	kb, _ := json.Marshal(k)
+	vb, _ := json.Marshal(v)
+	return len(kb) + len(vb) + 2
+}
+
+// TrimRules defines how to trim the data payload of a message exceeding
+// MaxPayloadSize.
+type TrimRules struct {
+	// OptionalKeys are data keys that are dropped, in order, until the payload
+	// fits.
+	OptionalKeys []string
+	// MaxValueLength, if positive, is the length in bytes that data values are
+	// truncated to, starting from the largest, until the payload fits.
+	MaxValueLength int
+}
+
+// Trim returns the message with its data payload trimmed by the rules if the
+// payload exceeds MaxPayloadSize, along with the report of the result.  The
+// returned payload may still exceed MaxPayloadSize if the rules do not free up
+// enough bytes.  The given message is never modified.
+func (r *TrimRules) Trim(msg *Message) (*Message, PayloadReport) {
+	report := AnalyzePayload(msg)
+	if r == nil || !report.Exceeds() {
+		return msg, report
+	}
+	trimmed := *msg
+	trimmed.Data = make(map[string]string, len(msg.Data))
+	for k, v := range msg.Data {
+		trimmed.Data[k] = v
+	}
+
+	size := report.Size
+	for _, key := range r.OptionalKeys {
+		if size <= MaxPayloadSize {
+			break
+		}
+		if v, ok := trimmed.Data[key]; ok {
+			size -= keySize(key, v)
+			delete(trimmed.Data, key)
+		}
+	}
+	if r.MaxValueLength > 0 {
+		for _, ks := range report.Keys {
+			if size <= MaxPayloadSize {
+				break
+			}
+			v, ok := trimmed.Data[ks.Key]
+			if !ok || len(v) <= r.MaxValueLength {
+				continue
+			}
+			truncated := truncateUTF8(v, r.MaxValueLength)
+			size -= keySize(ks.Key, v) - keySize(ks.Key, truncated)
+			trimmed.Data[ks.Key] = truncated
+		}
+	}
+	return &trimmed, AnalyzePayload(&trimmed)
+}
+
+// truncateUTF8 truncates s to at most n bytes without splitting a code point.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// zeroWidthJoiner joins emoji into a single glyph, e.g. family emoji.
+const zeroWidthJoiner = "\u200d"
+
+// TruncateBytes truncates s to at most n bytes of UTF-8, e.g. to fit a title
+// or a body in a byte limit.  Unlike slicing s, it never splits a code point,
+// so emoji and other astral-plane characters are either kept whole or
+// dropped, and it drops a zero width joiner left dangling at the end.
+func TruncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.TrimRight(truncateUTF8(s, n), zeroWidthJoiner)
+}
+
+// EncodedLen returns the number of bytes s takes in the JSON-encoded payload,
+// without its quotes.  This is what counts towards MaxPayloadSize, rather than
+// the number of runes: an emoji takes 4 bytes, and characters such as '<' are
+// escaped into 6.
+func EncodedLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		n += encodedRuneLen(r, size)
+		i += size
+	}
+	return n
+}
+
+// TruncateEncoded truncates s so that its EncodedLen is at most n, without
+// splitting a code point, like TruncateBytes.
+func TruncateEncoded(s string, n int) string {
+	total := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		total += encodedRuneLen(r, size)
+		if total > n {
+			return strings.TrimRight(s[:i], zeroWidthJoiner)
+		}
+		i += size
+	}
+	return s
+}
+
+// invalidUTF8Len is the bytes encoding/json encodes an invalid UTF-8 byte
+// into, either U+FFFD as is or escaped depending on the Go version.
+var invalidUTF8Len = func() int {
+	b, _ := json.Marshal("\xff")
+	return len(b) - 2
+}()
+
+// encodedRuneLen returns the bytes encoding/json encodes the rune r, decoded
+// from size bytes, into.
+func encodedRuneLen(r rune, size int) int {
+	switch {
+	case r == utf8.RuneError && size == 1: // invalid UTF-8 becomes U+FFFD
+		return invalidUTF8Len
+	case r == '"' || r == '\\' || r == '\b' || r == '\f' || r == '\n' || r == '\r' || r == '\t':
+		return 2
+	case r < 0x20 || r == '<' || r == '>' || r == '&' || r == '\u2028' || r == '\u2029':
+		return 6
+	}
+	return size
+}
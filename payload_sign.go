@@ -0,0 +1,148 @@
+package gcm
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// SignatureKey is the data key reserved for the signature of the data payload.
+// Like any data key, it must not start with "gcm" or "google", which the
+// connection server reserves.
+const SignatureKey = "push_signature"
+
+var (
+	// ErrMissingSignature is returned when verifying a data payload without
+	// a signature.
+	ErrMissingSignature = errors.New("missing payload signature")
+	// ErrInvalidSignature is returned when the signature of a data payload
+	// does not match it.
+	ErrInvalidSignature = errors.New("invalid payload signature")
+)
+
+// PayloadSigner signs data payloads so that clients can detect spoofed or
+// tampered messages.
+type PayloadSigner interface {
+	SignPayload(payload []byte) ([]byte, error)
+}
+
+// PayloadVerifier verifies the signatures of data payloads.
+type PayloadVerifier interface {
+	VerifyPayload(payload, sig []byte) bool
+}
+
+// HMACSigner signs and verifies data payloads with HMAC-SHA256.
+type HMACSigner struct {
+	Key []byte
+}
+
+// SignPayload returns the HMAC-SHA256 of the payload.
+func (s HMACSigner) SignPayload(payload []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// VerifyPayload reports whether sig is the HMAC-SHA256 of the payload.
+func (s HMACSigner) VerifyPayload(payload, sig []byte) bool {
+	expected, _ := s.SignPayload(payload)
+	return hmac.Equal(expected, sig)
+}
+
+// Ed25519Signer signs data payloads with Ed25519.
+type Ed25519Signer struct {
+	PrivateKey ed25519.PrivateKey
+}
+
+// SignPayload returns the Ed25519 signature of the payload.
+func (s Ed25519Signer) SignPayload(payload []byte) ([]byte, error) {
+	if len(s.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid Ed25519 private key")
+	}
+	return ed25519.Sign(s.PrivateKey, payload), nil
+}
+
+// Ed25519Verifier verifies data payloads signed with Ed25519.
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+// VerifyPayload reports whether sig is a valid Ed25519 signature of the payload.
+func (v Ed25519Verifier) VerifyPayload(payload, sig []byte) bool {
+	return len(v.PublicKey) == ed25519.PublicKeySize && ed25519.Verify(v.PublicKey, payload, sig)
+}
+
+// CanonicalPayload returns the bytes of a data payload that are signed: a JSON
+// object of the entries other than SignatureKey with keys in sorted order and
+// without HTML escaping.
+func CanonicalPayload(data map[string]string) []byte {
+	unsigned := make(map[string]string, len(data))
+	for k, v := range data {
+		if k != SignatureKey {
+			unsigned[k] = v
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(unsigned)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// keyedSigner is a PayloadSigner signing with one of several keys.
+type keyedSigner interface {
+	currentSigner() (string, PayloadSigner, error)
+}
+
+// keyedVerifier is a PayloadVerifier verifying with one of several keys.
+type keyedVerifier interface {
+	verifierFor(id string) (PayloadVerifier, error)
+}
+
+// SignData returns a copy of the data payload with its base64 signature under
+// SignatureKey.  For a KeyedHMACSigner or a KeyedEd25519Signer, the ID of the
+// signing key is added under KeyIDKey and signed too.
+func SignData(data map[string]string, signer PayloadSigner) (map[string]string, error) {
+	signed := make(map[string]string, len(data)+2)
+	for k, v := range data {
+		signed[k] = v
+	}
+	if ks, ok := signer.(keyedSigner); ok {
+		id, s, err := ks.currentSigner()
+		if err != nil {
+			return nil, err
+		}
+		signed[KeyIDKey] = id
+		signer = s
+	}
+	sig, err := signer.SignPayload(CanonicalPayload(signed))
+	if err != nil {
+		return nil, err
+	}
+	signed[SignatureKey] = base64.StdEncoding.EncodeToString(sig)
+	return signed, nil
+}
+
+// VerifyData verifies the signature under SignatureKey of a data payload.
+func VerifyData(data map[string]string, verifier PayloadVerifier) error {
+	encoded, ok := data[SignatureKey]
+	if !ok {
+		return ErrMissingSignature
+	}
+	if kv, ok := verifier.(keyedVerifier); ok {
+		v, err := kv.verifierFor(data[KeyIDKey])
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		verifier = v
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !verifier.VerifyPayload(CanonicalPayload(data), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
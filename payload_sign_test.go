@@ -0,0 +1,64 @@
+package gcm
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalPayload(t *testing.T) {
+	data := map[string]string{"b": "<2>", "a": "1", SignatureKey: "sig"}
+	assert.Equal(t, `{"a":"1","b":"<2>"}`, string(CanonicalPayload(data)))
+}
+
+func TestSignVerifyData(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	params := []struct {
+		signer   PayloadSigner
+		verifier PayloadVerifier
+	}{
+		{HMACSigner{[]byte("secret")}, HMACSigner{[]byte("secret")}},
+		{Ed25519Signer{priv}, Ed25519Verifier{pub}},
+	}
+	for _, param := range params {
+		signed, err := SignData(data, param.signer)
+		assert.NoError(t, err)
+		assert.NotContains(t, data, SignatureKey)
+		assert.NoError(t, VerifyData(signed, param.verifier))
+
+		signed["k"] = "tampered"
+		assert.Equal(t, ErrInvalidSignature, VerifyData(signed, param.verifier))
+		assert.Equal(t, ErrMissingSignature, VerifyData(data, param.verifier))
+	}
+	assert.Equal(t, ErrInvalidSignature, VerifyData(map[string]string{SignatureKey: "!"}, HMACSigner{}))
+	_, err = SignData(data, Ed25519Signer{})
+	assert.EqualError(t, err, "invalid Ed25519 private key")
+}
+
+func TestSendWithPayloadSigner(t *testing.T) {
+	var sent message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		json.NewEncoder(w).Encode(success)
+	}))
+	defer server.Close()
+	GCMEndpoint = server.URL
+	s := NewSender("test-api-key")
+	s.PayloadSigner = HMACSigner{[]byte("secret")}
+	_, err := s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyData(sent.Data, HMACSigner{[]byte("secret")}))
+}
+
+func TestLintSignedMessage(t *testing.T) {
+	signed, err := SignData(data, HMACSigner{[]byte("secret")})
+	assert.NoError(t, err)
+	for _, f := range Lint(&Message{Data: signed}) {
+		assert.NotEqual(t, "reserved-key", f.Rule)
+	}
+}
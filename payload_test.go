@@ -0,0 +1,85 @@
+package gcm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzePayload(t *testing.T) {
+	report := AnalyzePayload(&Message{
+		Data:         map[string]string{"a": "1", "big": "12345"},
+		Notification: &Notification{Title: "t"},
+	})
+	// {"a":"1","big":"12345"} + {"title":"t"}
+	assert.Equal(t, 23+13, report.Size)
+	assert.Equal(t, []KeySize{{"big", 14}, {"a", 8}}, report.Keys)
+	assert.False(t, report.Exceeds())
+	assert.Equal(t, PayloadReport{}, AnalyzePayload(nil))
+}
+
+func TestTrimRules(t *testing.T) {
+	original := &Message{Data: map[string]string{
+		"id":    "42",
+		"debug": strings.Repeat("d", 1000),
+		"body":  strings.Repeat("b", 5000),
+	}}
+	rules := &TrimRules{OptionalKeys: []string{"debug"}, MaxValueLength: 2000}
+
+	trimmed, report := rules.Trim(original)
+	assert.False(t, report.Exceeds())
+	assert.Len(t, trimmed.Data, 2)
+	assert.Equal(t, "42", trimmed.Data["id"])
+	assert.Equal(t, 2000, len(trimmed.Data["body"]))
+	assert.Len(t, original.Data, 3, "original is not modified")
+
+	small := &Message{Data: map[string]string{"debug": "x"}}
+	trimmed, _ = rules.Trim(small)
+	assert.True(t, trimmed == small, "payloads within the limit are not trimmed")
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "ab", truncateUTF8("abc", 2))
+	assert.Equal(t, "a", truncateUTF8("a€", 3))
+	assert.Equal(t, "a€", truncateUTF8("a€", 4))
+}
+
+func TestEmojiPayload(t *testing.T) {
+	// astral-plane characters survive encoding as is, not as surrogate pairs
+	text := "Hi 😀 👨\u200d👩\u200d👧 𝄞"
+	b, err := json.Marshal(message{Message: Message{Data: map[string]string{"k": text}, Notification: &Notification{Title: text}}, to: "regId"})
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(b), text))
+	var m message
+	assert.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, text, m.Data["k"])
+	assert.Equal(t, text, m.Notification.Title)
+}
+
+func TestEncodedLen(t *testing.T) {
+	for _, s := range []string{"", "abc", "😀", "é", "<a & b>", "\"\\\n\r\t\b\f\x01", "\u2028", "\xff", "👨\u200d👩"} {
+		b, _ := json.Marshal(s)
+		assert.Equal(t, len(b)-2, EncodedLen(s), s)
+	}
+	assert.Equal(t, 4, EncodedLen("😀"), "bytes, not runes")
+}
+
+func TestTruncateBytes(t *testing.T) {
+	assert.Equal(t, "ab", TruncateBytes("ab", 5))
+	assert.Equal(t, "a", TruncateBytes("a😀", 4), "the emoji is not split")
+	assert.Equal(t, "a😀", TruncateBytes("a😀b", 5))
+	assert.Equal(t, "👨", TruncateBytes("👨\u200d👩", 8), "no dangling joiner")
+	assert.Equal(t, "", TruncateBytes("😀", 3))
+
+	assert.Equal(t, "a", TruncateEncoded("a<b", 6))
+	assert.Equal(t, "a<", TruncateEncoded("a<b", 7))
+	assert.Equal(t, "a😀", TruncateEncoded("a😀<", 8))
+	for n := 0; n < 20; n++ {
+		s := TruncateEncoded("x😀<\"👨\u200d👩", n)
+		assert.True(t, EncodedLen(s) <= n)
+		assert.True(t, utf8.ValidString(s))
+	}
+}
@@ -0,0 +1,167 @@
+package gcm
+
+import "strings"
+
+// CLDR plural categories.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// pluralRules maps languages to the CLDR cardinal plural rule of integers.
+// Languages not listed use the rule of English.
+var pluralRules = map[string]func(n int64) string{
+	"ar": pluralArabic,
+	"be": pluralEastSlavic,
+	"bn": pluralZeroOne,
+	"bs": pluralSerboCroatian,
+	"cs": pluralCzech,
+	"fa": pluralZeroOne,
+	"fr": pluralZeroOne,
+	"he": pluralHebrew,
+	"hi": pluralZeroOne,
+	"hr": pluralSerboCroatian,
+	"id": pluralNone,
+	"ja": pluralNone,
+	"km": pluralNone,
+	"ko": pluralNone,
+	"lo": pluralNone,
+	"ms": pluralNone,
+	"my": pluralNone,
+	"pl": pluralPolish,
+	"pt": pluralZeroOne,
+	"ro": pluralRomanian,
+	"ru": pluralEastSlavic,
+	"sk": pluralCzech,
+	"sr": pluralSerboCroatian,
+	"th": pluralNone,
+	"uk": pluralEastSlavic,
+	"vi": pluralNone,
+	"zh": pluralNone,
+}
+
+// PluralCategory returns the CLDR plural category of the integer n in the
+// language of locale, e.g. "en", "pt-BR" or "ru_RU": one of PluralZero,
+// PluralOne, PluralTwo, PluralFew, PluralMany and PluralOther.
+func PluralCategory(locale string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	if locale == "pt-pt" {
+		return pluralEnglish(n)
+	}
+	lang := locale
+	if i := strings.Index(lang, "-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if rule, ok := pluralRules[lang]; ok {
+		return rule(n)
+	}
+	return pluralEnglish(n)
+}
+
+func pluralNone(n int64) string {
+	return PluralOther
+}
+
+func pluralEnglish(n int64) string {
+	if n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+func pluralZeroOne(n int64) string {
+	if n == 0 || n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+func pluralEastSlavic(n int64) string {
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+func pluralPolish(n int64) string {
+	switch mod10, mod100 := n%10, n%100; {
+	case n == 1:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+func pluralSerboCroatian(n int64) string {
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralOther
+	}
+}
+
+func pluralCzech(n int64) string {
+	switch {
+	case n == 1:
+		return PluralOne
+	case n >= 2 && n <= 4:
+		return PluralFew
+	default:
+		return PluralOther
+	}
+}
+
+func pluralRomanian(n int64) string {
+	switch mod100 := n % 100; {
+	case n == 1:
+		return PluralOne
+	case n == 0 || mod100 >= 2 && mod100 <= 19:
+		return PluralFew
+	default:
+		return PluralOther
+	}
+}
+
+func pluralHebrew(n int64) string {
+	switch n {
+	case 1:
+		return PluralOne
+	case 2:
+		return PluralTwo
+	default:
+		return PluralOther
+	}
+}
+
+func pluralArabic(n int64) string {
+	switch mod100 := n % 100; {
+	case n == 0:
+		return PluralZero
+	case n == 1:
+		return PluralOne
+	case n == 2:
+		return PluralTwo
+	case mod100 >= 3 && mod100 <= 10:
+		return PluralFew
+	case mod100 >= 11:
+		return PluralMany
+	default:
+		return PluralOther
+	}
+}
@@ -0,0 +1,51 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluralCategory(t *testing.T) {
+	params := []struct {
+		locale   string
+		n        int64
+		category string
+	}{
+		{"en", 1, PluralOne},
+		{"en", 0, PluralOther},
+		{"en-US", 2, PluralOther},
+		{"xx", 1, PluralOne},
+		{"fr", 0, PluralOne},
+		{"fr_FR", 2, PluralOther},
+		{"pt-BR", 0, PluralOne},
+		{"pt-PT", 0, PluralOther},
+		{"ja", 1, PluralOther},
+		{"ru", 1, PluralOne},
+		{"ru", 21, PluralOne},
+		{"ru", 11, PluralMany},
+		{"ru", 3, PluralFew},
+		{"ru", 13, PluralMany},
+		{"ru", 25, PluralMany},
+		{"uk", -22, PluralFew},
+		{"pl", 1, PluralOne},
+		{"pl", 21, PluralMany},
+		{"pl", 22, PluralFew},
+		{"cs", 4, PluralFew},
+		{"cs", 5, PluralOther},
+		{"hr", 21, PluralOne},
+		{"hr", 5, PluralOther},
+		{"ro", 0, PluralFew},
+		{"ro", 119, PluralFew},
+		{"ro", 20, PluralOther},
+		{"he", 2, PluralTwo},
+		{"ar", 0, PluralZero},
+		{"ar", 2, PluralTwo},
+		{"ar", 103, PluralFew},
+		{"ar", 111, PluralMany},
+		{"ar", 100, PluralOther},
+	}
+	for _, param := range params {
+		assert.Equal(t, param.category, PluralCategory(param.locale, param.n), param.locale)
+	}
+}
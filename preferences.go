@@ -0,0 +1,73 @@
+package gcm
+
+import "fmt"
+
+// PreferenceChecker enforces the notification preferences of users, e.g.
+// that a user opted out of a category of messages or muted a channel, as
+// told by the Tags of the message.
+type PreferenceChecker interface {
+	// Allowed returns the recipients of job, among the given To or
+	// RegistrationIDs, that accept its message.
+	Allowed(job *Job, recipients []string) ([]string, error)
+}
+
+// PreferenceCheckerFunc adapts a function to a PreferenceChecker.
+type PreferenceCheckerFunc func(job *Job, recipients []string) ([]string, error)
+
+// Allowed calls f(job, recipients).
+func (f PreferenceCheckerFunc) Allowed(job *Job, recipients []string) ([]string, error) {
+	return f(job, recipients)
+}
+
+// PreferenceError is returned when the PreferenceChecker fails.  Jobs failing
+// with a PreferenceError are retryable.
+type PreferenceError struct {
+	Err error
+}
+
+func (e *PreferenceError) Error() string {
+	return fmt.Sprintf("failed to check preferences: %v", e.Err)
+}
+
+// Unwrap returns the error of the PreferenceChecker.
+func (e *PreferenceError) Unwrap() error {
+	return e.Err
+}
+
+// checkPreferences returns job restricted to the recipients allowed by the
+// Sender's PreferenceChecker, if any, or nil if none is, along with the
+// suppressed recipients.
+func (s *Sender) checkPreferences(job *Job) (*Job, []string, error) {
+	if s.Preferences == nil {
+		return job, nil, nil
+	}
+	recipients := job.RegistrationIDs
+	if len(recipients) == 0 {
+		recipients = []string{job.To}
+	}
+	allowed, err := s.Preferences.Allowed(job, recipients)
+	if err != nil {
+		return nil, nil, &PreferenceError{err}
+	}
+	ok := make(map[string]bool, len(allowed))
+	for _, recipient := range allowed {
+		ok[recipient] = true
+	}
+	var kept, suppressed []string
+	for _, recipient := range recipients {
+		if ok[recipient] {
+			kept = append(kept, recipient)
+		} else {
+			suppressed = append(suppressed, recipient)
+		}
+	}
+	switch {
+	case len(suppressed) == 0:
+		return job, nil, nil
+	case len(kept) == 0:
+		return nil, suppressed, nil
+	}
+	filtered := *job
+	filtered.RegistrationIDs = kept
+	return &filtered, suppressed, nil
+}
@@ -0,0 +1,75 @@
+package gcm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mutedChecker allows every recipient except the muted ones.
+func mutedChecker(muted ...string) PreferenceChecker {
+	return PreferenceCheckerFunc(func(job *Job, recipients []string) ([]string, error) {
+		var allowed []string
+		for _, recipient := range recipients {
+			if !contains(muted, recipient) {
+				allowed = append(allowed, recipient)
+			}
+		}
+		return allowed, nil
+	})
+}
+
+func TestCheckPreferences(t *testing.T) {
+	s := NewSender("test-api-key")
+	job := &Job{Message: msg, RegistrationIDs: []string{"a", "b", "c"}}
+	send, suppressed, err := s.checkPreferences(job)
+	assert.Nil(t, err)
+	assert.Equal(t, job, send)
+	assert.Nil(t, suppressed)
+
+	s.Preferences = mutedChecker("b")
+	send, suppressed, err = s.checkPreferences(job)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "c"}, send.RegistrationIDs)
+	assert.Equal(t, []string{"b"}, suppressed)
+	assert.Equal(t, []string{"a", "b", "c"}, job.RegistrationIDs)
+
+	send, suppressed, err = s.checkPreferences(&Job{Message: msg, To: "b"})
+	assert.Nil(t, err)
+	assert.Nil(t, send)
+	assert.Equal(t, []string{"b"}, suppressed)
+
+	failure := errors.New("preferences unavailable")
+	s.Preferences = PreferenceCheckerFunc(func(*Job, []string) ([]string, error) { return nil, failure })
+	_, _, err = s.checkPreferences(job)
+	var prefErr *PreferenceError
+	assert.True(t, errors.As(err, &prefErr))
+	assert.Equal(t, failure, prefErr.Err)
+	assert.True(t, isRetryable(err))
+}
+
+func TestDispatcherSkipsMutedRecipients(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{MulticastID: 1, Success: 1, Results: []result{{MessageID: "id1"}}}},
+	)
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "muted"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, RegistrationIDs: []string{"muted", "regId"}}))
+	q.Close()
+
+	var results []*JobResult
+	sender := NewSender("test-api-key")
+	sender.Preferences = mutedChecker("muted")
+	d := &Dispatcher{Queue: q, Sender: sender, OnResult: func(jr *JobResult) { results = append(results, jr) }}
+	d.Run()
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Nil(t, results[0].Result)
+	assert.Equal(t, []string{"muted"}, results[0].Suppressed)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, []string{"muted"}, results[1].Suppressed)
+	assert.Equal(t, 1, results[1].MulticastResult.Success)
+	assert.Len(t, results[1].MulticastResult.Results, 1)
+}
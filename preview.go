@@ -0,0 +1,153 @@
+package gcm
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Platform names a client platform, e.g. for Token.Platform.
+type Platform string
+
+const (
+	PlatformAndroid Platform = "android"
+	PlatformIOS     Platform = "ios"
+)
+
+// Preview describes how a notification appears on a platform.
+type Preview struct {
+	Platform Platform
+	Title    string
+	Body     string
+	// Notes lists how the other fields of the notification show up, or why
+	// they do not.
+	Notes []string
+}
+
+// PreviewRenderer renders previews of notifications for review before they
+// are sent.
+type PreviewRenderer struct {
+	// Strings maps the localization keys used by notifications to their
+	// format strings in the language to preview, with Android (%1$s) or iOS
+	// (%@, %1$@) placeholders for the arguments.
+	Strings map[string]string
+}
+
+var locPlaceholder = regexp.MustCompile(`%(?:(\d+)\$)?[s@]`)
+
+// localize formats the string of the localization key with the arguments.  It
+// returns false if the key is unknown.
+func (r *PreviewRenderer) localize(key string, args []string) (string, bool) {
+	format, ok := r.Strings[key]
+	if !ok {
+		return "", false
+	}
+	next := 0
+	return locPlaceholder.ReplaceAllStringFunc(format, func(p string) string {
+		i := next
+		if m := locPlaceholder.FindStringSubmatch(p); m[1] != "" {
+			n, _ := strconv.Atoi(m[1])
+			i = n - 1
+		} else {
+			next++
+		}
+		if i < 0 || i >= len(args) {
+			return p
+		}
+		return args[i]
+	}), true
+}
+
+// Preview renders the notification as it appears on the platform.
+func (r *PreviewRenderer) Preview(n *Notification, platform Platform) Preview {
+	p := Preview{Platform: platform}
+	if n == nil {
+		p.Notes = append(p.Notes, "no notification: data messages are not displayed")
+		return p
+	}
+	p.Title, p.Body = n.Title, n.Body
+	if n.TitleLocKey != "" {
+		if s, ok := r.localize(n.TitleLocKey, n.TitleLocArgs); ok {
+			p.Title = s
+		} else {
+			p.Notes = append(p.Notes, fmt.Sprintf("title_loc_key %q has no string to preview", n.TitleLocKey))
+		}
+	}
+	if n.BodyLocKey != "" {
+		if s, ok := r.localize(n.BodyLocKey, n.BodyLocArgs); ok {
+			p.Body = s
+		} else {
+			p.Notes = append(p.Notes, fmt.Sprintf("body_loc_key %q has no string to preview", n.BodyLocKey))
+		}
+	}
+
+	switch platform {
+	case PlatformAndroid:
+		if p.Title == "" {
+			p.Notes = append(p.Notes, "no title: Android shows the app name")
+		}
+		if n.Icon == "" {
+			p.Notes = append(p.Notes, "icon: default app icon")
+		} else {
+			p.Notes = append(p.Notes, "icon: drawable "+n.Icon)
+		}
+		if n.Color != "" {
+			p.Notes = append(p.Notes, "color: "+n.Color)
+		}
+		if n.AndroidChannelID != "" {
+			p.Notes = append(p.Notes, "channel: "+n.AndroidChannelID)
+		}
+		if n.Tag != "" {
+			p.Notes = append(p.Notes, fmt.Sprintf("tag: replaces a shown notification tagged %q", n.Tag))
+		}
+		if n.Badge != "" {
+			p.Notes = append(p.Notes, "badge: ignored on Android")
+		}
+	case PlatformIOS:
+		if n.Badge != "" {
+			p.Notes = append(p.Notes, "badge: "+n.Badge)
+		}
+		if n.Icon != "" || n.Color != "" || n.AndroidChannelID != "" || n.Tag != "" {
+			p.Notes = append(p.Notes, "icon, color, channel and tag: ignored on iOS")
+		}
+	}
+	if n.Sound != "" {
+		p.Notes = append(p.Notes, "sound: "+n.Sound)
+	}
+	if n.ClickAction != "" {
+		p.Notes = append(p.Notes, "click action: "+n.ClickAction)
+	}
+	return p
+}
+
+// String returns the preview as text.
+func (p Preview) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n%s\n%s\n", p.Platform, p.Title, p.Body)
+	for _, note := range p.Notes {
+		fmt.Fprintf(&b, "- %s\n", note)
+	}
+	return b.String()
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<div class="gcm-preview gcm-preview-{{.Platform}}">
+<div class="gcm-preview-title">{{.Title}}</div>
+<div class="gcm-preview-body">{{.Body}}</div>
+{{- if .Notes}}
+<ul class="gcm-preview-notes">
+{{- range .Notes}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</div>
+`))
+
+// HTML returns the preview as an HTML fragment, with its text escaped.
+func (p Preview) HTML() string {
+	var b strings.Builder
+	previewTemplate.Execute(&b, p)
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewRenderer(t *testing.T) {
+	r := &PreviewRenderer{Strings: map[string]string{
+		"order_title": "Order %1$s shipped",
+		"order_body":  "%2$s items via %1$s",
+		"ios_body":    "Hi %@, %@ is here",
+	}}
+	n := &Notification{
+		TitleLocKey:  "order_title",
+		TitleLocArgs: []string{"#42"},
+		BodyLocKey:   "order_body",
+		BodyLocArgs:  []string{"UPS", "3"},
+		Color:        "#ff0000",
+		Badge:        "1",
+	}
+
+	p := r.Preview(n, PlatformAndroid)
+	assert.Equal(t, "Order #42 shipped", p.Title)
+	assert.Equal(t, "3 items via UPS", p.Body)
+	assert.Equal(t, "[android]\nOrder #42 shipped\n3 items via UPS\n- icon: default app icon\n- color: #ff0000\n- badge: ignored on Android\n", p.String())
+
+	p = r.Preview(&Notification{Title: "<b>Hi</b>", BodyLocKey: "ios_body", BodyLocArgs: []string{"Ann", "Bob"}, Badge: "1"}, PlatformIOS)
+	assert.Equal(t, "Hi Ann, Bob is here", p.Body)
+	assert.Equal(t, `<div class="gcm-preview gcm-preview-ios">
+<div class="gcm-preview-title">&lt;b&gt;Hi&lt;/b&gt;</div>
+<div class="gcm-preview-body">Hi Ann, Bob is here</div>
+<ul class="gcm-preview-notes">
+<li>badge: 1</li>
+</ul>
+</div>
+`, p.HTML())
+
+	p = r.Preview(&Notification{Title: "t", BodyLocKey: "missing"}, PlatformIOS)
+	assert.Equal(t, []string{`body_loc_key "missing" has no string to preview`}, p.Notes)
+	assert.Equal(t, []string{"no notification: data messages are not displayed"}, r.Preview(nil, PlatformAndroid).Notes)
+}
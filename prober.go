@@ -0,0 +1,94 @@
+package gcm
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// defaultProbeInterval is how often a Prober sends a probe when Interval is
+// not set.
+const defaultProbeInterval = time.Minute
+
+// ProbeResult is the outcome of a probe.
+type ProbeResult struct {
+	// Time is when the probe was sent.
+	Time time.Time
+	// Latency is how long the request took, including its failure.
+	Latency time.Duration
+	Result  *Result
+	// Err is the error of the request, or of its result.
+	Err error
+}
+
+// Prober periodically sends a message and records the latency and outcome of
+// the sends in Metrics, as gcm_probes and gcm_probe_duration_seconds, to
+// alert on FCM being unavailable before it shows in the real traffic.  The
+// probes are not retried.
+type Prober struct {
+	Sender *Sender
+	// To is the recipient of the probes, e.g. a registration token or an
+	// internal topic nobody subscribes to.
+	To string
+	// Message is the probe.  Nil means a data message with the send time.
+	Message *Message
+	// Live sends the probes for real.  If not set, they are dry runs, which
+	// still go through FCM but are not delivered.
+	Live bool
+	// Interval is how often a probe is sent.  Zero means 1m.
+	Interval time.Duration
+	// Metrics, if set, collects the probe metrics.
+	Metrics *Metrics
+	// OnProbe, if set, is called with the result of each probe.  If not,
+	// failures are logged.  A panic in OnProbe is handled according to the
+	// PanicPolicy of the Sender.
+	OnProbe func(ProbeResult)
+}
+
+func (p *Prober) message(now time.Time) *Message {
+	var msg Message
+	if p.Message != nil {
+		msg = *p.Message
+	} else {
+		msg.Data = map[string]string{"probe": now.UTC().Format(time.RFC3339)}
+	}
+	msg.DryRun = !p.Live
+	return &msg
+}
+
+// Probe sends a probe and records its outcome.
+func (p *Prober) Probe() ProbeResult {
+	probe := ProbeResult{Time: p.Sender.clock().Now()}
+	start := time.Now()
+	probe.Result, probe.Err = p.Sender.SendNoRetry(p.message(probe.Time), p.To)
+	probe.Latency = time.Since(start)
+	if probe.Err == nil && probe.Result.Error != "" {
+		probe.Err = errors.New(probe.Result.Error)
+	}
+	p.Metrics.observeProbe(probe)
+	if p.OnProbe != nil {
+		protect(p.Sender.PanicPolicy, "OnProbe", func() { p.OnProbe(probe) })
+	} else if probe.Err != nil {
+		log.Printf("probe to %s failed: %v", p.To, probe.Err)
+	}
+	return probe
+}
+
+// Run sends a probe every Interval until done is closed.  The first one is
+// sent right away.
+func (p *Prober) Run(done <-chan struct{}) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Probe()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
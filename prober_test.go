@@ -0,0 +1,63 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProber(t *testing.T) {
+	responses := []*testResponse{
+		{response: &success},
+		{response: &fail},
+		{statusCode: http.StatusServiceUnavailable},
+	}
+	var sent []message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m message
+		json.NewDecoder(r.Body).Decode(&m)
+		resp := responses[len(sent)]
+		sent = append(sent, m)
+		if resp.statusCode != 0 {
+			w.WriteHeader(resp.statusCode)
+			return
+		}
+		json.NewEncoder(w).Encode(resp.response)
+	}))
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Endpoint = server.URL
+	var probes []ProbeResult
+	p := &Prober{Sender: s, To: "/topics/probe", Metrics: &Metrics{}, OnProbe: func(r ProbeResult) {
+		probes = append(probes, r)
+	}}
+
+	assert.NoError(t, p.Probe().Err)
+	assert.EqualError(t, p.Probe().Err, ErrorUnavailable)
+	assert.Error(t, p.Probe().Err, "probes are not retried")
+	assert.Len(t, sent, 3)
+	assert.Len(t, probes, 3)
+	assert.True(t, sent[0].DryRun)
+	assert.Equal(t, "/topics/probe", sent[0].to)
+	assert.NotEmpty(t, sent[0].Data["probe"])
+
+	var buf bytes.Buffer
+	p.Metrics.WriteTo(&buf)
+	out := buf.String()
+	assert.Contains(t, out, `gcm_probes_total{error_code="ok"} 1`+"\n")
+	assert.Contains(t, out, `gcm_probes_total{error_code="Unavailable"} 1`+"\n")
+	assert.Contains(t, out, `gcm_probes_total{error_code="http_503"} 1`+"\n")
+	assert.Contains(t, out, "gcm_probe_duration_seconds_count 3\n")
+	assert.NotContains(t, out, "gcm_request_duration_seconds_count", "the requests are not in the probe metrics")
+
+	p.Live = true
+	p.Message = &Message{Data: map[string]string{"canary": "1"}}
+	responses = append(responses, &testResponse{response: &success})
+	p.Probe()
+	assert.False(t, sent[3].DryRun)
+	assert.Equal(t, "1", sent[3].Data["canary"])
+}
@@ -0,0 +1,34 @@
+package gcm
+
+// SenderProfile defines product-wide defaults for notification payloads.  The
+// defaults are merged into every outgoing Notification for the fields the
+// Notification leaves empty; messages without a Notification are left alone.
+// Messages sent with SendRawJSON are sent as is.
+type SenderProfile struct {
+	Sound string
+	// Android only
+	Icon             string
+	Color            string
+	AndroidChannelID string
+}
+
+// apply returns a copy of n with the profile defaults merged in.
+func (p *SenderProfile) apply(n *Notification) *Notification {
+	if p == nil || n == nil {
+		return n
+	}
+	merged := *n
+	if merged.Sound == "" {
+		merged.Sound = p.Sound
+	}
+	if merged.Icon == "" {
+		merged.Icon = p.Icon
+	}
+	if merged.Color == "" {
+		merged.Color = p.Color
+	}
+	if merged.AndroidChannelID == "" {
+		merged.AndroidChannelID = p.AndroidChannelID
+	}
+	return &merged
+}
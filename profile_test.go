@@ -0,0 +1,45 @@
+package gcm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderProfileApply(t *testing.T) {
+	p := &SenderProfile{Sound: "chime", Icon: "ic_logo", Color: "#ff0000", AndroidChannelID: "default"}
+	n := &Notification{Title: "hi", Color: "#00ff00"}
+	assert.Equal(t, &Notification{
+		Title:            "hi",
+		Sound:            "chime",
+		Icon:             "ic_logo",
+		Color:            "#00ff00",
+		AndroidChannelID: "default",
+	}, p.apply(n))
+	assert.Equal(t, &Notification{Title: "hi", Color: "#00ff00"}, n, "original is not modified")
+	assert.Nil(t, p.apply(nil))
+	var nilProfile *SenderProfile
+	assert.Equal(t, n, nilProfile.apply(n))
+}
+
+func TestSendWithProfile(t *testing.T) {
+	var sent message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &sent)
+		respBytes, _ := json.Marshal(success)
+		w.Write(respBytes)
+	}))
+	defer server.Close()
+	GCMEndpoint = server.URL
+
+	s := NewSender("test-api-key")
+	s.Profile = &SenderProfile{Sound: "chime"}
+	_, err := s.SendNoRetry(&Message{Notification: &Notification{Title: "hi"}}, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, &Notification{Title: "hi", Sound: "chime"}, sent.Notification)
+}
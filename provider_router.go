@@ -0,0 +1,166 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RouteRule routes the tokens matching all of its conditions to a provider.
+type RouteRule struct {
+	// Prefix, if set, must be a prefix of the registration ID of the token.
+	Prefix string
+	// Platform, if set, must be the platform of the token.
+	Platform string
+	// Labels lists the labels the token must have.
+	Labels map[string]string
+	// Provider names the Transport of the matching tokens.
+	Provider string
+}
+
+func (rule *RouteRule) matches(token Token) bool {
+	if !strings.HasPrefix(token.RegistrationID, rule.Prefix) {
+		return false
+	}
+	if rule.Platform != "" && token.Platform != rule.Platform {
+		return false
+	}
+	for k, v := range rule.Labels {
+		if token.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// NoProviderError is the error of a token no provider was found for.
+type NoProviderError struct {
+	Token    string
+	Provider string
+}
+
+func (e *NoProviderError) Error() string {
+	if e.Provider != "" {
+		return fmt.Sprintf("no transport for provider %q of token %s", e.Provider, e.Token)
+	}
+	return fmt.Sprintf("no provider for token %s", e.Token)
+}
+
+// PushResult is the provider-agnostic result of a token.
+type PushResult struct {
+	Token    string
+	Provider string
+	// Result is the result reported by the provider, with its errors
+	// mapped to GCM error codes.
+	Result
+	// Err is the error of the Transport if the token could not be sent to,
+	// in which case Result is empty.
+	Err error
+}
+
+// RoutedResult is the result of ProviderRouter.Send.
+type RoutedResult struct {
+	Success int
+	Failure int
+	// Results has the result of each token, in order.
+	Results []PushResult
+}
+
+// ProviderRouter sends messages to tokens of several providers, picking the
+// Transport of each token by its rules, so that a single call can reach
+// Android, iOS and vendor devices alike.
+//
+// ProviderRouter is safe for concurrent use as long as its fields are not
+// modified once it is in use.
+type ProviderRouter struct {
+	// Transports maps provider names to their Transports.
+	Transports map[string]Transport
+	// Rules are tried in order, the first matching rule picking the
+	// provider.
+	Rules []RouteRule
+	// Default, if set, is the provider of the tokens no rule matches.
+	Default string
+}
+
+// Route returns the provider name and Transport of a token.
+func (r *ProviderRouter) Route(token Token) (string, Transport, error) {
+	provider := r.Default
+	for i := range r.Rules {
+		if r.Rules[i].matches(token) {
+			provider = r.Rules[i].Provider
+			break
+		}
+	}
+	if provider == "" {
+		return "", nil, &NoProviderError{Token: token.RegistrationID}
+	}
+	t, ok := r.Transports[provider]
+	if !ok {
+		return "", nil, &NoProviderError{token.RegistrationID, provider}
+	}
+	return provider, t, nil
+}
+
+// Send sends a message to tokens of any provider, in batches of at most the
+// MaxRecipients of each Transport.  The failures of a Transport and the
+// tokens without a provider are reported in the results rather than returned.
+func (r *ProviderRouter) Send(msg *Message, tokens []Token) (*RoutedResult, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("missing tokens")
+	}
+	result := &RoutedResult{Results: make([]PushResult, len(tokens))}
+	// indexes of the tokens of each provider, in order of appearance
+	var providers []string
+	indexes := make(map[string][]int)
+	for i, token := range tokens {
+		result.Results[i].Token = token.RegistrationID
+		provider, _, err := r.Route(token)
+		if err != nil {
+			result.Results[i].Err = err
+			continue
+		}
+		result.Results[i].Provider = provider
+		if _, ok := indexes[provider]; !ok {
+			providers = append(providers, provider)
+		}
+		indexes[provider] = append(indexes[provider], i)
+	}
+
+	for _, provider := range providers {
+		t := r.Transports[provider]
+		batchSize := t.Capabilities().MaxRecipients
+		for idx := indexes[provider]; len(idx) > 0; {
+			batch := idx
+			if batchSize > 0 && len(batch) > batchSize {
+				batch = batch[:batchSize]
+			}
+			idx = idx[len(batch):]
+			values := make([]string, len(batch))
+			for j, i := range batch {
+				values[j] = tokens[i].RegistrationID
+			}
+			results, err := t.Push(msg, values)
+			for j, i := range batch {
+				if j < len(results) {
+					result.Results[i].Result = results[j]
+				} else if err != nil {
+					result.Results[i].Err = err
+				} else {
+					result.Results[i].Err = errors.New("missing result")
+				}
+			}
+		}
+	}
+
+	for _, res := range result.Results {
+		if res.Err == nil && res.MessageID != "" {
+			result.Success++
+		} else {
+			result.Failure++
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+package gcm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTransport answers every token with a message ID made of its name,
+// except for the tokens in errors.
+type fakeTransport struct {
+	name    string
+	max     int
+	errors  map[string]string
+	err     error
+	batches [][]string
+}
+
+func (t *fakeTransport) Name() string                         { return t.name }
+func (t *fakeTransport) Capabilities() Capabilities           { return Capabilities{MaxRecipients: t.max} }
+func (t *fakeTransport) Convert(msg *Message) ([]byte, error) { return nil, nil }
+
+func (t *fakeTransport) Push(msg *Message, tokens []string) ([]Result, error) {
+	t.batches = append(t.batches, tokens)
+	if t.err != nil {
+		return nil, t.err
+	}
+	results := make([]Result, len(tokens))
+	for i, token := range tokens {
+		if errCode, ok := t.errors[token]; ok {
+			results[i].Error = errCode
+		} else {
+			results[i].MessageID = t.name
+		}
+	}
+	return results, nil
+}
+
+func TestProviderRouterRoute(t *testing.T) {
+	fcm, apns := &fakeTransport{name: "fcm"}, &fakeTransport{name: "apns"}
+	r := &ProviderRouter{
+		Transports: map[string]Transport{"fcm": fcm, "apns": apns},
+		Rules: []RouteRule{
+			{Platform: "ios", Provider: "apns"},
+			{Prefix: "hms:", Provider: "hms"},
+		},
+	}
+	provider, tr, err := r.Route(Token{RegistrationID: "t", Platform: "ios"})
+	assert.NoError(t, err)
+	assert.Equal(t, "apns", provider)
+	assert.Equal(t, apns, tr)
+
+	_, _, err = r.Route(Token{RegistrationID: "t"})
+	assert.Equal(t, &NoProviderError{Token: "t"}, err)
+	_, _, err = r.Route(Token{RegistrationID: "hms:t"})
+	assert.Equal(t, &NoProviderError{"hms:t", "hms"}, err)
+
+	r.Default = "fcm"
+	provider, _, err = r.Route(Token{RegistrationID: "t"})
+	assert.NoError(t, err)
+	assert.Equal(t, "fcm", provider)
+	r.Rules = append([]RouteRule{{Labels: map[string]string{"vendor": "huawei"}, Provider: "apns"}}, r.Rules...)
+	provider, _, err = r.Route(Token{RegistrationID: "t", Labels: map[string]string{"vendor": "huawei"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "apns", provider)
+}
+
+func TestProviderRouterSend(t *testing.T) {
+	fcm := &fakeTransport{name: "fcm", max: 2, errors: map[string]string{"dead": ErrorNotRegistered}}
+	apns := &fakeTransport{name: "apns", max: 1}
+	down := errors.New("down")
+	hms := &fakeTransport{name: "hms", err: down}
+	r := &ProviderRouter{
+		Transports: map[string]Transport{"fcm": fcm, "apns": apns, "hms": hms},
+		Rules: []RouteRule{
+			{Platform: "ios", Provider: "apns"},
+			{Prefix: "hms:", Provider: "hms"},
+			{Prefix: "x:", Provider: "unknown"},
+		},
+		Default: "fcm",
+	}
+	_, err := r.Send(msg, nil)
+	assert.NotNil(t, err)
+
+	result, err := r.Send(msg, []Token{
+		{RegistrationID: "a"}, {RegistrationID: "i1", Platform: "ios"}, {RegistrationID: "dead"}, {RegistrationID: "hms:1"},
+		{RegistrationID: "b"}, {RegistrationID: "i2", Platform: "ios"}, {RegistrationID: "x:1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Success)
+	assert.Equal(t, 3, result.Failure)
+	assert.Equal(t, [][]string{{"a", "dead"}, {"b"}}, fcm.batches)
+	assert.Equal(t, [][]string{{"i1"}, {"i2"}}, apns.batches)
+	assert.Equal(t, PushResult{Token: "a", Provider: "fcm", Result: Result{MessageID: "fcm"}}, result.Results[0])
+	assert.Equal(t, PushResult{Token: "i1", Provider: "apns", Result: Result{MessageID: "apns"}}, result.Results[1])
+	assert.Equal(t, ErrorNotRegistered, result.Results[2].Error)
+	assert.Equal(t, down, result.Results[3].Err)
+	assert.Equal(t, "hms", result.Results[3].Provider)
+	assert.Equal(t, &NoProviderError{"x:1", "unknown"}, result.Results[6].Err)
+}
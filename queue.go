@@ -0,0 +1,565 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Class defines the class of a queued message, which decides how urgently it
+// is dequeued relative to messages of other classes.
+type Class int
+
+const (
+	// ClassTransactional defines messages the user is actively waiting for,
+	// e.g. one-time passwords and security alerts.
+	ClassTransactional Class = iota + 1
+	// ClassReminder defines messages triggered by the user's own activity.
+	ClassReminder
+	// ClassMarketing defines bulk promotional messages.
+	ClassMarketing
+)
+
+var classNames = map[Class]string{
+	ClassTransactional: "transactional",
+	ClassReminder:      "reminder",
+	ClassMarketing:     "marketing",
+}
+
+func (c Class) String() string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Class(%d)", int(c))
+}
+
+// ClassConfig configures how jobs of a Class are dequeued.
+type ClassConfig struct {
+	// Weight is the relative share of dequeues given to the class while jobs
+	// of several classes are pending.  Weights below 1 are treated as 1.
+	Weight int
+	// RateLimit caps the number of jobs dequeued per second for the class.
+	// Zero means unlimited.
+	RateLimit float64
+}
+
+// DefaultClassConfigs defines the class configuration used when a QueueConfig
+// does not specify one.
+var DefaultClassConfigs = map[Class]ClassConfig{
+	ClassTransactional: {Weight: 16},
+	ClassReminder:      {Weight: 4},
+	ClassMarketing:     {Weight: 1},
+}
+
+// FullPolicy defines what Enqueue does when a Queue is at capacity.
+type FullPolicy int
+
+const (
+	// FullPolicyBlock blocks Enqueue until a job is dequeued.
+	FullPolicyBlock FullPolicy = iota
+	// FullPolicyReject makes Enqueue return ErrQueueFull.
+	FullPolicyReject
+	// FullPolicyDropOldest drops the oldest job of the least urgent class to
+	// make room.  If that class is more urgent than the new job, the new job is
+	// dropped instead and Enqueue returns ErrQueueFull.
+	FullPolicyDropOldest
+)
+
+// QueueConfig configures a Queue.
+type QueueConfig struct {
+	// Classes configures each class; nil means DefaultClassConfigs.
+	Classes map[Class]ClassConfig
+	// Capacity is the max number of pending jobs.  Zero means unbounded.
+	Capacity int
+	// FullPolicy decides what Enqueue does when the Queue is at Capacity.
+	FullPolicy FullPolicy
+	// Shedder, if set, sheds less urgent jobs while sends are failing or slow.
+	Shedder *LoadShedder
+	// Listener, if set, is notified of enqueued and dropped jobs.
+	Listener EventListener
+	// PanicPolicy decides what happens when Listener panics.
+	PanicPolicy PanicPolicy
+	// WAL, if set, logs the jobs until they are acknowledged with Ack, and
+	// the jobs it left unacknowledged are replayed into the new Queue.
+	WAL *WAL
+	// QuietHours, if set, holds back jobs of its classes during its window.
+	QuietHours *QuietHours
+}
+
+// Job is a message waiting in a Queue for delivery.  Either To or
+// RegistrationIDs specifies the recipient(s).
+type Job struct {
+	Class           Class    `json:"class"`
+	Message         *Message `json:"message"`
+	To              string   `json:"to,omitempty"`
+	RegistrationIDs []string `json:"registration_ids,omitempty"`
+}
+
+// Queue is an in-memory queue of jobs with priority classes.  Jobs of the same
+// class are dequeued in FIFO order, while classes share dequeues by weighted
+// round-robin, with ties going to the more urgent class, so that a marketing
+// blast can never starve transactional messages.
+//
+// Queue is safe for concurrent use.
+type Queue struct {
+	mu          sync.Mutex
+	classes     []*queueClass // ordered from most to least urgent
+	capacity    int
+	fullPolicy  FullPolicy
+	shedder     *LoadShedder
+	listener    EventListener
+	panicPolicy PanicPolicy
+	wal         *WAL
+	quietHours  *QuietHours
+	shed        []*Job // jobs shed since the last notification of the listener
+	notify      chan struct{}
+	notFull     chan struct{} // closed whenever the Queue is below capacity
+	done        chan struct{}
+	closed      bool
+	dropped     int64
+	dequeues    []time.Time // ring of recent dequeue times for drain estimates
+	dequeueIdx  int
+}
+
+type queueClass struct {
+	class   Class
+	weight  int
+	current int
+	limiter *rateLimiter
+	jobs    []*Job
+}
+
+var (
+	// ErrQueueClosed is returned when enqueueing to a closed Queue.
+	ErrQueueClosed = errors.New("queue is closed")
+	// ErrQueueFull is returned when enqueueing to a full Queue whose policy
+	// does not allow the job in.
+	ErrQueueFull = errors.New("queue is full")
+)
+
+// shedRecheckInterval is how often Dequeue checks whether shedding stopped
+// while only deferred jobs are pending.
+const shedRecheckInterval = 100 * time.Millisecond
+
+// drainWindow is the number of recent dequeues used to estimate drain time.
+const drainWindow = 128
+
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+// NewQueue instantiates a Queue given the config.
+func NewQueue(config QueueConfig) *Queue {
+	configs := config.Classes
+	if configs == nil {
+		configs = DefaultClassConfigs
+	}
+	q := &Queue{
+		capacity:    config.Capacity,
+		fullPolicy:  config.FullPolicy,
+		shedder:     config.Shedder,
+		listener:    config.Listener,
+		panicPolicy: config.PanicPolicy,
+		wal:         config.WAL,
+		quietHours:  config.QuietHours,
+		notify:      make(chan struct{}, 1),
+		notFull:     closedChan,
+		done:        make(chan struct{}),
+		dequeues:    make([]time.Time, 0, drainWindow),
+	}
+	for _, class := range []Class{ClassTransactional, ClassReminder, ClassMarketing} {
+		cfg := configs[class]
+		qc := &queueClass{class: class, weight: cfg.Weight}
+		if qc.weight < 1 {
+			qc.weight = 1
+		}
+		if cfg.RateLimit > 0 {
+			qc.limiter = newRateLimiter(cfg.RateLimit)
+		}
+		q.classes = append(q.classes, qc)
+	}
+	if q.wal != nil {
+		for _, job := range q.wal.replay() {
+			if qc := q.class(job.Class); qc != nil {
+				q.push(qc, job)
+			}
+		}
+	}
+	return q
+}
+
+func (q *Queue) class(c Class) *queueClass {
+	for _, qc := range q.classes {
+		if qc.class == c {
+			return qc
+		}
+	}
+	return nil
+}
+
+// Enqueue adds a job to the end of its class.  If the Queue is full, the
+// outcome depends on its FullPolicy.
+func (q *Queue) Enqueue(job *Job) error {
+	if job == nil {
+		return errors.New("job cannot be nil")
+	}
+	if job.Message == nil {
+		return errors.New("message cannot be nil")
+	}
+	if job.To == "" && len(job.RegistrationIDs) == 0 {
+		return errors.New("missing recipient(s)")
+	}
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return ErrQueueClosed
+		}
+		qc := q.class(job.Class)
+		if qc == nil {
+			q.mu.Unlock()
+			return fmt.Errorf("unknown class: %v", job.Class)
+		}
+		if !q.full() {
+			if err := q.log(job); err != nil {
+				q.mu.Unlock()
+				return err
+			}
+			q.push(qc, job)
+			q.mu.Unlock()
+			q.enqueued(job)
+			return nil
+		}
+		switch q.fullPolicy {
+		case FullPolicyReject:
+			q.mu.Unlock()
+			return ErrQueueFull
+		case FullPolicyDropOldest:
+			if err := q.log(job); err != nil {
+				q.mu.Unlock()
+				return err
+			}
+			dropped := q.dropOldest(qc, job)
+			q.mu.Unlock()
+			q.ackDropped([]*Job{dropped})
+			if dropped != job {
+				q.enqueued(job)
+			}
+			q.notifyDrops([]*Job{dropped}, ErrQueueFull)
+			if dropped == job {
+				return ErrQueueFull
+			}
+			return nil
+		}
+		notFull := q.notFull
+		q.mu.Unlock()
+		select {
+		case <-notFull:
+		case <-q.done:
+		}
+	}
+}
+
+// requeue adds a dequeued job back to the end of its class.  Unlike Enqueue,
+// it ignores capacity, since the job had a place in the Queue, and accepts
+// jobs after Close, since Dequeue keeps returning them until drained.
+func (q *Queue) requeue(job *Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.push(q.class(job.Class), job)
+}
+
+// log logs job to the WAL, if any.
+func (q *Queue) log(job *Job) error {
+	if q.wal == nil {
+		return nil
+	}
+	return q.wal.append(job)
+}
+
+// Ack acknowledges that a dequeued job is done with, whether it was sent or
+// given up on, so that the WAL, if any, does not replay it.
+func (q *Queue) Ack(job *Job) error {
+	if q.wal == nil {
+		return nil
+	}
+	return q.wal.ack(job)
+}
+
+func (q *Queue) ackDropped(jobs []*Job) {
+	for _, job := range jobs {
+		if err := q.Ack(job); err != nil {
+			log.Printf("failed to acknowledge dropped job: %v", err)
+		}
+	}
+}
+
+func (q *Queue) full() bool {
+	return q.capacity > 0 && q.len() >= q.capacity
+}
+
+func (q *Queue) push(qc *queueClass, job *Job) {
+	qc.jobs = append(qc.jobs, job)
+	if q.full() {
+		// keep the channel waiters hold, unless it is closed already
+		select {
+		case <-q.notFull:
+			q.notFull = make(chan struct{})
+		default:
+		}
+	}
+	q.signal()
+}
+
+func (q *Queue) enqueued(job *Job) {
+	if q.listener != nil {
+		protect(q.panicPolicy, "OnEnqueue", func() { q.listener.OnEnqueue(job) })
+	}
+}
+
+// dropOldest makes room for job by dropping the oldest job of the least urgent
+// class, unless that class is more urgent than job, and returns the dropped job.
+func (q *Queue) dropOldest(qc *queueClass, job *Job) *Job {
+	for i := len(q.classes) - 1; i >= 0; i-- {
+		victim := q.classes[i]
+		if len(victim.jobs) == 0 {
+			continue
+		}
+		if victim.class < qc.class {
+			break
+		}
+		dropped := victim.jobs[0]
+		victim.jobs[0] = nil
+		victim.jobs = victim.jobs[1:]
+		q.dropped++
+		q.push(qc, job)
+		return dropped
+	}
+	q.dropped++
+	return job
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Dequeue removes and returns the next job, blocking until one is available
+// and its class is within its rate limit.  It returns false once the Queue is
+// closed and all remaining jobs have been dequeued.
+func (q *Queue) Dequeue() (*Job, bool) {
+	for {
+		q.mu.Lock()
+		job, wait := q.next(time.Now())
+		pending := q.len()
+		closed := q.closed
+		if job != nil && pending > 0 {
+			q.signal() // wake up another consumer
+		}
+		shed := q.shed
+		q.shed = nil
+		q.mu.Unlock()
+		q.ackDropped(shed)
+		q.notifyDrops(shed, ErrJobShed)
+
+		if job != nil {
+			return job, true
+		}
+		if closed && pending == 0 {
+			return nil, false
+		}
+
+		if closed {
+			// only rate limits are holding back the remaining jobs
+			time.Sleep(wait)
+			continue
+		}
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-q.notify:
+		case <-timeout:
+		case <-q.done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// next picks the next job by smooth weighted round-robin among the classes
+// that have pending jobs, are within their rate limits and are neither shed
+// nor in their quiet hours.  If no job can be dequeued yet because of rate
+// limits, deferral or quiet hours, it returns how long to wait.
+func (q *Queue) next(now time.Time) (*Job, time.Duration) {
+	var selected *queueClass
+	var wait time.Duration
+	total := 0
+	shedding := q.shedder != nil && q.shedder.Shedding()
+	for _, qc := range q.classes {
+		if len(qc.jobs) == 0 {
+			continue
+		}
+		if held, w := q.quietHours.holds(qc.class, now); held {
+			if wait == 0 || w < wait {
+				wait = w
+			}
+			continue
+		}
+		if shedding && q.shedder.sheds(qc.class) {
+			if q.shedder.Policy == ShedDefer {
+				if wait == 0 || shedRecheckInterval < wait {
+					wait = shedRecheckInterval
+				}
+			} else {
+				q.shedder.recordShed(qc.class, len(qc.jobs))
+				if q.listener != nil || q.wal != nil {
+					q.shed = append(q.shed, qc.jobs...)
+				}
+				for i := range qc.jobs {
+					qc.jobs[i] = nil
+				}
+				qc.jobs = qc.jobs[:0]
+				q.releaseSpace()
+			}
+			continue
+		}
+		if qc.limiter != nil {
+			if ok, w := qc.limiter.allow(now); !ok {
+				if wait == 0 || w < wait {
+					wait = w
+				}
+				continue
+			}
+		}
+		qc.current += qc.weight
+		total += qc.weight
+		if selected == nil || qc.current > selected.current {
+			selected = qc
+		}
+	}
+	if selected == nil {
+		return nil, wait
+	}
+	selected.current -= total
+	if selected.limiter != nil {
+		selected.limiter.take()
+	}
+	job := selected.jobs[0]
+	selected.jobs[0] = nil
+	selected.jobs = selected.jobs[1:]
+	q.recordDequeue(now)
+	q.releaseSpace()
+	return job, 0
+}
+
+func (q *Queue) recordDequeue(now time.Time) {
+	if len(q.dequeues) < drainWindow {
+		q.dequeues = append(q.dequeues, now)
+	} else {
+		q.dequeues[q.dequeueIdx] = now
+		q.dequeueIdx = (q.dequeueIdx + 1) % drainWindow
+	}
+}
+
+func (q *Queue) releaseSpace() {
+	select {
+	case <-q.notFull:
+	default:
+		if !q.full() {
+			close(q.notFull)
+		}
+	}
+}
+
+func (q *Queue) len() int {
+	n := 0
+	for _, qc := range q.classes {
+		n += len(qc.jobs)
+	}
+	return n
+}
+
+// Len returns the number of pending jobs.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.len()
+}
+
+// ClassLen returns the number of pending jobs of the given class.
+func (q *Queue) ClassLen(c Class) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if qc := q.class(c); qc != nil {
+		return len(qc.jobs)
+	}
+	return 0
+}
+
+// Pending returns the pending jobs, most urgent class first and in FIFO order
+// within each class.
+func (q *Queue) Pending() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]*Job, 0, q.len())
+	for _, qc := range q.classes {
+		jobs = append(jobs, qc.jobs...)
+	}
+	return jobs
+}
+
+// Dropped returns the number of jobs dropped by FullPolicyDropOldest.
+func (q *Queue) Dropped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// DrainTime estimates how long it takes to dequeue all pending jobs at the
+// rate of the recent dequeues.  It returns false if there are not enough
+// recent dequeues to tell.
+func (q *Queue) DrainTime() (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.dequeues)
+	if n < 2 {
+		return 0, false
+	}
+	oldest := q.dequeues[q.dequeueIdx]
+	elapsed := time.Since(oldest)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	rate := float64(n-1) / elapsed.Seconds()
+	return time.Duration(float64(q.len()) / rate * float64(time.Second)), true
+}
+
+// NotFull returns a channel that is closed once the Queue is below capacity.
+// Callers can select on it to wait for room without blocking in Enqueue.
+func (q *Queue) NotFull() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.notFull
+}
+
+// Close stops the Queue from accepting new jobs.  Pending jobs can still be
+// dequeued.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+}
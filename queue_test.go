@@ -0,0 +1,181 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dequeueClasses(t *testing.T, q *Queue, n int) []Class {
+	classes := make([]Class, 0, n)
+	for i := 0; i < n; i++ {
+		job, ok := q.Dequeue()
+		if !assert.True(t, ok) {
+			break
+		}
+		classes = append(classes, job.Class)
+	}
+	return classes
+}
+
+func TestQueueWeightedDequeue(t *testing.T) {
+	q := NewQueue(QueueConfig{Classes: map[Class]ClassConfig{
+		ClassTransactional: {Weight: 2},
+		ClassReminder:      {Weight: 1},
+		ClassMarketing:     {Weight: 1},
+	}})
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "1"}))
+	}
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	assert.Equal(t, 9, q.Len())
+	assert.Equal(t, []Class{
+		ClassTransactional, ClassReminder, ClassMarketing, ClassTransactional,
+		ClassTransactional, ClassMarketing, ClassTransactional, ClassMarketing,
+		ClassMarketing,
+	}, dequeueClasses(t, q, 9))
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestQueueRateLimit(t *testing.T) {
+	q := NewQueue(QueueConfig{Classes: map[Class]ClassConfig{
+		ClassTransactional: {Weight: 1},
+		ClassReminder:      {Weight: 1},
+		ClassMarketing:     {Weight: 100, RateLimit: 1},
+	}})
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "1"}))
+	}
+	assert.Equal(t, []Class{
+		ClassMarketing, ClassTransactional, ClassTransactional, ClassTransactional,
+	}, dequeueClasses(t, q, 4))
+
+	start := time.Now()
+	assert.Equal(t, []Class{ClassMarketing}, dequeueClasses(t, q, 1))
+	assert.True(t, time.Since(start) > 500*time.Millisecond)
+}
+
+func TestQueueClose(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	q.Close()
+	assert.Equal(t, ErrQueueClosed, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	job, ok := q.Dequeue()
+	assert.True(t, ok)
+	assert.Equal(t, ClassReminder, job.Class)
+	_, ok = q.Dequeue()
+	assert.False(t, ok)
+}
+
+func TestQueueDequeueBlocksUntilEnqueue(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"})
+	}()
+	job, ok := q.Dequeue()
+	assert.True(t, ok)
+	assert.Equal(t, ClassMarketing, job.Class)
+}
+
+func TestQueueEnqueueInvalidJob(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	assert.EqualError(t, q.Enqueue(nil), "job cannot be nil")
+	assert.EqualError(t, q.Enqueue(&Job{Class: ClassMarketing, To: "1"}), "message cannot be nil")
+	assert.EqualError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg}), "missing recipient(s)")
+	assert.EqualError(t, q.Enqueue(&Job{Message: msg, To: "1"}), "unknown class: Class(0)")
+}
+
+func TestQueueFullPolicyReject(t *testing.T) {
+	q := NewQueue(QueueConfig{Capacity: 1, FullPolicy: FullPolicyReject})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.Equal(t, ErrQueueFull, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "1"}))
+	select {
+	case <-q.NotFull():
+		t.Fatal("queue should be full")
+	default:
+	}
+	q.Dequeue()
+	select {
+	case <-q.NotFull():
+	default:
+		t.Fatal("queue should not be full")
+	}
+}
+
+func TestQueueFullPolicyDropOldest(t *testing.T) {
+	q := NewQueue(QueueConfig{Capacity: 2, FullPolicy: FullPolicyDropOldest})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "old"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "new"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "otp"}))
+	assert.Equal(t, 1, q.ClassLen(ClassMarketing))
+	assert.Equal(t, int64(1), q.Dropped())
+
+	// a less urgent job never displaces a more urgent one
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "otp2"}))
+	assert.Equal(t, ErrQueueFull, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "promo"}))
+	assert.Equal(t, int64(3), q.Dropped())
+	assert.Equal(t, 2, q.ClassLen(ClassTransactional))
+}
+
+func TestQueueFullPolicyBlock(t *testing.T) {
+	q := NewQueue(QueueConfig{Capacity: 1})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	enqueued := make(chan error)
+	go func() {
+		enqueued <- q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "2"})
+	}()
+	select {
+	case <-enqueued:
+		t.Fatal("enqueue should block while the queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+	job, _ := q.Dequeue()
+	assert.Equal(t, "1", job.To)
+	assert.NoError(t, <-enqueued)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestQueueRequeueWhileEnqueueBlocked(t *testing.T) {
+	q := NewQueue(QueueConfig{Capacity: 1})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	requeued, _ := q.Dequeue()
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "2"}))
+	enqueued := make(chan error)
+	go func() {
+		enqueued <- q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "3"})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.requeue(requeued)
+	q.Dequeue()
+	q.Dequeue()
+	select {
+	case err := <-enqueued:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("enqueue should unblock once the queue is drained")
+	}
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestQueueDrainTime(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	_, ok := q.DrainTime()
+	assert.False(t, ok)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	}
+	q.Dequeue()
+	time.Sleep(10 * time.Millisecond)
+	q.Dequeue()
+	d, ok := q.DrainTime()
+	assert.True(t, ok)
+	assert.True(t, d > 0 && d < time.Second)
+}
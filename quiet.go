@@ -0,0 +1,93 @@
+package gcm
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours holds back the jobs of some classes in a Queue during a daily
+// window, e.g. marketing messages at night.  The jobs stay queued and are
+// dequeued once the window ends.
+type QuietHours struct {
+	// Start and End are the wall clock times of day the window starts and
+	// ends, as offsets from midnight.  The window spans midnight if End is
+	// before Start, and is empty if they are equal.
+	Start time.Duration
+	End   time.Duration
+	// Location is the time zone of Start and End.  Nil means UTC.
+	Location *time.Location
+	// Classes are the classes held back.
+	Classes []Class
+}
+
+// holds reports whether jobs of class are held back at now, and if so for
+// how long.
+func (h *QuietHours) holds(class Class, now time.Time) (bool, time.Duration) {
+	if h == nil || h.Start == h.End {
+		return false, 0
+	}
+	held := false
+	for _, c := range h.Classes {
+		held = held || c == class
+	}
+	if !held {
+		return false, 0
+	}
+	loc := h.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second + time.Duration(now.Nanosecond())
+	switch {
+	case h.Start < h.End && offset >= h.Start && offset < h.End:
+		return true, h.End - offset
+	case h.Start > h.End && offset >= h.Start:
+		return true, 24*time.Hour - offset + h.End
+	case h.Start > h.End && offset < h.End:
+		return true, h.End - offset
+	}
+	return false, 0
+}
+
+// QuietHoursConfig configures the QuietHours of a Queue.
+type QuietHoursConfig struct {
+	// Start and End are times of day such as "22:00".
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+	// TimeZone is an IANA time zone such as "Europe/Paris".  Empty means UTC.
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone,omitempty"`
+	// Classes are the names of the classes held back.
+	Classes []string `json:"classes,omitempty" yaml:"classes,omitempty"`
+}
+
+// QuietHours returns the QuietHours of the config.
+func (c *QuietHoursConfig) QuietHours() (*QuietHours, error) {
+	h := &QuietHours{}
+	for _, t := range []struct {
+		value string
+		dst   *time.Duration
+	}{{c.Start, &h.Start}, {c.End, &h.End}} {
+		parsed, err := time.Parse("15:04", t.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours time %q", t.value)
+		}
+		*t.dst = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	if c.TimeZone != "" {
+		loc, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours time zone %q", c.TimeZone)
+		}
+		h.Location = loc
+	}
+	for _, name := range c.Classes {
+		class, ok := classByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown class %q", name)
+		}
+		h.Classes = append(h.Classes, class)
+	}
+	return h, nil
+}
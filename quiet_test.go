@@ -0,0 +1,50 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHours(t *testing.T) {
+	night := &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour, Classes: []Class{ClassMarketing}}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+	held, wait := night.holds(ClassMarketing, at(23, 0))
+	assert.True(t, held)
+	assert.Equal(t, 8*time.Hour, wait)
+	held, wait = night.holds(ClassMarketing, at(6, 30))
+	assert.True(t, held)
+	assert.Equal(t, 30*time.Minute, wait)
+	held, _ = night.holds(ClassMarketing, at(7, 0))
+	assert.False(t, held)
+	held, _ = night.holds(ClassTransactional, at(23, 0))
+	assert.False(t, held, "class not held back")
+
+	lunch := &QuietHours{Start: 12 * time.Hour, End: 13 * time.Hour, Classes: []Class{ClassReminder}}
+	held, wait = lunch.holds(ClassReminder, at(12, 15))
+	assert.True(t, held)
+	assert.Equal(t, 45*time.Minute, wait)
+	held, _ = lunch.holds(ClassReminder, at(13, 15))
+	assert.False(t, held)
+
+	held, _ = (&QuietHours{Start: time.Hour, End: time.Hour, Classes: []Class{ClassReminder}}).holds(ClassReminder, at(1, 0))
+	assert.False(t, held, "empty window")
+	held, _ = (*QuietHours)(nil).holds(ClassReminder, at(1, 0))
+	assert.False(t, held)
+}
+
+func TestQuietHoursConfig(t *testing.T) {
+	h, err := (&QuietHoursConfig{Start: "22:30", End: "07:00", TimeZone: "UTC", Classes: []string{"marketing"}}).QuietHours()
+	assert.NoError(t, err)
+	assert.Equal(t, &QuietHours{Start: 22*time.Hour + 30*time.Minute, End: 7 * time.Hour, Location: time.UTC, Classes: []Class{ClassMarketing}}, h)
+
+	_, err = (&QuietHoursConfig{Start: "10pm", End: "07:00"}).QuietHours()
+	assert.EqualError(t, err, `invalid quiet hours time "10pm"`)
+	_, err = (&QuietHoursConfig{Start: "22:00", End: "07:00", TimeZone: "Mars/Olympus"}).QuietHours()
+	assert.EqualError(t, err, `invalid quiet hours time zone "Mars/Olympus"`)
+	_, err = (&QuietHoursConfig{Start: "22:00", End: "07:00", Classes: []string{"promo"}}).QuietHours()
+	assert.EqualError(t, err, `unknown class "promo"`)
+}
@@ -0,0 +1,179 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RampStage is a stage of a RampPolicy.
+type RampStage struct {
+	// Fraction is the fraction (0 to 1) of the audience reached by the end of
+	// the stage.
+	Fraction float64
+	// Soak is how long to wait after the stage before starting the next one,
+	// so that client-side problems can surface.  It is measured by the Time
+	// of the Sender.
+	Soak time.Duration
+}
+
+// RampPolicy defines how a broadcast warms up, so that a payload bug is
+// caught after reaching a small slice of the audience instead of everyone.
+type RampPolicy struct {
+	// Stages are the stages of the ramp, with increasing fractions.  The last
+	// stage should reach the whole audience; it is added otherwise.
+	Stages []RampStage
+	// MaxErrorRate is the ratio (0 to 1) of recipients of a stage failing
+	// with errors other than stale registration tokens above which the
+	// broadcast is aborted.  Zero means no limit.
+	MaxErrorRate float64
+	// Abort aborts the broadcast within a stage when the failure ratio of the
+	// recent recipients gets too high.
+	Abort AbortPolicy
+	// Canary, if set, sends to canary devices before the first stage.
+	Canary *CanaryPolicy
+}
+
+// DefaultRampPolicy ramps up to 1%, then 10%, then 100% of the audience with a
+// minute of soak in between, aborting above a 5% error rate.
+var DefaultRampPolicy = RampPolicy{
+	Stages: []RampStage{
+		{Fraction: 0.01, Soak: time.Minute},
+		{Fraction: 0.1, Soak: time.Minute},
+		{Fraction: 1},
+	},
+	MaxErrorRate: 0.05,
+}
+
+// RampStageResult is the outcome of a stage of a ramped broadcast.
+type RampStageResult struct {
+	Recipients int
+	// Failures is the number of recipients that failed with errors other
+	// than stale registration tokens.
+	Failures  int
+	ErrorRate float64
+}
+
+// RampResult is the result of SendRamped.
+type RampResult struct {
+	// MulticastResult aggregates the results of the stages, with Results for
+	// the first Sent registration IDs.  MulticastID, RetryMulticastIDs and
+	// BatchMulticastIDs are not set.
+	MulticastResult
+	// Sent is the number of registration IDs sent to, not counting canaries.
+	Sent   int
+	Stages []RampStageResult
+	// Canary is the result of sending to the canaries, if any.
+	Canary *MulticastResult
+}
+
+// RampAbortedError is returned by SendRamped when a stage exceeds the
+// MaxErrorRate of the policy.
+type RampAbortedError struct {
+	Stage     int
+	ErrorRate float64
+}
+
+func (e *RampAbortedError) Error() string {
+	return fmt.Sprintf("broadcast aborted after stage %d with error rate %.3f", e.Stage, e.ErrorRate)
+}
+
+// SendRamped sends a multicast message with retries to the registration IDs in
+// the stages of policy, in order, aborting with a RampAbortedError when a
+// stage exceeds the MaxErrorRate of the policy, or with a BroadcastAbortedError as soon as
+// the recent recipients exceed the MaxFailureRate of its Abort policy.  If
+// the policy has canaries, they are sent to first, and the stages only start
+// once confirmed.  The result covers the stages sent so far,
+// also when an error is returned.
+func (s *Sender) SendRamped(msg *Message, regIDs []string, policy RampPolicy, retries int) (*RampResult, error) {
+	if len(regIDs) == 0 {
+		return nil, errors.New("missing registration ids")
+	}
+	// the stages are checked in batches of at most MaxRegistrationIDs
+	if err := checkUnrecoverableErrors(s, "", regIDs[:1], msg, retries); err != nil {
+		return nil, err
+	}
+	stages := policy.Stages
+	if len(stages) == 0 || stages[len(stages)-1].Fraction < 1 {
+		stages = append(append([]RampStage(nil), stages...), RampStage{Fraction: 1})
+	}
+
+	result := &RampResult{}
+	if policy.Canary != nil {
+		regIDs = withoutTokens(regIDs, policy.Canary.Tokens)
+		canary, err := s.sendCanaries(msg, policy.Canary, retries)
+		result.Canary = canary
+		if err != nil {
+			return result, err
+		}
+	}
+	monitor := newAbortMonitor(policy.Abort)
+	for i, stage := range stages {
+		end := int(stage.Fraction * float64(len(regIDs)))
+		if end < 1 {
+			end = 1
+		}
+		if end > len(regIDs) {
+			end = len(regIDs)
+		}
+		if end <= result.Sent {
+			continue
+		}
+		stageResult, err := s.sendRampStage(msg, regIDs[result.Sent:end], retries, result, monitor)
+		result.Stages = append(result.Stages, stageResult)
+		if aborted, ok := err.(*BroadcastAbortedError); ok {
+			report := aborted.Report
+			report.Sent, report.Remaining = result.Sent, regIDs[result.Sent:]
+			if policy.Abort.OnAbort != nil {
+				protect(s.PanicPolicy, "OnAbort", func() { policy.Abort.OnAbort(report) })
+			}
+		}
+		if err != nil {
+			return result, err
+		}
+		if policy.MaxErrorRate > 0 && stageResult.ErrorRate > policy.MaxErrorRate {
+			return result, &RampAbortedError{Stage: i, ErrorRate: stageResult.ErrorRate}
+		}
+		if result.Sent < len(regIDs) {
+			<-s.clock().After(stage.Soak)
+		}
+	}
+	return result, nil
+}
+
+// sendRampStage sends to the registration IDs of a stage in batches, adding
+// the results to result, until done or monitor reports an abort, returned as a
+// *BroadcastAbortedError.
+func (s *Sender) sendRampStage(msg *Message, regIDs []string, retries int, result *RampResult, monitor *abortMonitor) (RampStageResult, error) {
+	stage := RampStageResult{}
+	err := forEachBatch(regIDs, MaxRegistrationIDs, func(batch []string) error {
+		res, err := s.SendMulticastWithRetries(msg, batch, retries)
+		if res == nil {
+			return err
+		}
+		// a result that comes with an error, e.g. a *RetryExhaustedError,
+		// was sent, so it is not left to Remaining
+		result.Sent += len(res.Results)
+		result.Success += res.Success
+		result.Failure += res.Failure
+		result.CanonicalIds += res.CanonicalIds
+		result.Results = append(result.Results, res.Results...)
+		stage.Recipients += len(res.Results)
+		for _, r := range res.Results {
+			if isFailure(r.Error) {
+				stage.Failures++
+			}
+		}
+		if stage.Recipients > 0 {
+			stage.ErrorRate = float64(stage.Failures) / float64(stage.Recipients)
+		}
+		if err != nil {
+			return err
+		}
+		if report := monitor.observe(res.Results); report != nil {
+			return &BroadcastAbortedError{report}
+		}
+		return nil
+	})
+	return stage, err
+}
@@ -0,0 +1,125 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func successes(n int) []result {
+	results := make([]result, n)
+	for i := range results {
+		results[i].MessageID = "id"
+	}
+	return results
+}
+
+func hundredRegIDs() []string {
+	regIDs := make([]string, 100)
+	for i := range regIDs {
+		regIDs[i] = fmt.Sprint(i)
+	}
+	return regIDs
+}
+
+var testRampPolicy = RampPolicy{
+	Stages:       []RampStage{{Fraction: 0.01, Soak: time.Millisecond}, {Fraction: 0.1}},
+	MaxErrorRate: 0.2,
+}
+
+func TestSendRamped(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 1, Results: []result{{MessageID: "id"}}}},
+		&testResponse{response: &response{Success: 8, Failure: 1, Results: append(successes(8), result{Err: ErrorNotRegistered})}},
+		&testResponse{response: &response{Success: 90, Results: successes(90)}},
+	)
+	defer server.Close()
+	result, err := NewSender("test-api-key").SendRamped(msg, hundredRegIDs(), testRampPolicy, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, result.Sent)
+	assert.Len(t, result.Results, 100)
+	assert.Equal(t, 99, result.Success)
+	assert.Equal(t, []RampStageResult{{Recipients: 1}, {Recipients: 9}, {Recipients: 90}}, result.Stages)
+}
+
+func TestSendRampedAborts(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 1, Results: []result{{MessageID: "id"}}}},
+		&testResponse{response: &response{Failure: 9, Results: []result{
+			{Err: ErrorInvalidDataKey}, {Err: ErrorInvalidDataKey}, {Err: ErrorInvalidDataKey}, {}, {}, {}, {}, {}, {},
+		}}},
+	)
+	defer server.Close()
+	result, err := NewSender("test-api-key").SendRamped(msg, hundredRegIDs(), testRampPolicy, 0)
+	assert.Equal(t, &RampAbortedError{Stage: 1, ErrorRate: 1.0 / 3}, err)
+	assert.Equal(t, 10, result.Sent)
+}
+
+func TestSendRampedAbortsOnFailureRate(t *testing.T) {
+	failures := make([]result, 1000)
+	for i := range failures {
+		failures[i].Err = ErrorMessageTooBig
+	}
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 10, Results: successes(10)}},
+		&testResponse{response: &response{Failure: 1000, Results: failures}},
+	)
+	defer server.Close()
+	regIDs := make([]string, 10000)
+	for i := range regIDs {
+		regIDs[i] = fmt.Sprint(i)
+	}
+	var reported *AbortReport
+	policy := RampPolicy{
+		Stages:       []RampStage{{Fraction: 0.001}},
+		MaxErrorRate: 1,
+		Abort: AbortPolicy{MaxFailureRate: 0.5, OnAbort: func(r *AbortReport) {
+			reported = r
+		}},
+	}
+	result, err := NewSender("test-api-key").SendRamped(msg, regIDs, policy, 0)
+	aborted, ok := err.(*BroadcastAbortedError)
+	if assert.True(t, ok) {
+		assert.Equal(t, reported, aborted.Report)
+		assert.Equal(t, 1010, reported.Sent)
+		assert.Len(t, reported.Remaining, 8990)
+		assert.Equal(t, 1000, reported.Samples)
+		assert.Equal(t, 1.0, reported.FailureRate)
+		assert.Equal(t, map[string]int{ErrorMessageTooBig: 1000}, reported.Errors)
+	}
+	assert.Equal(t, 1010, result.Sent)
+}
+
+func TestSendRampedSoaksOnSenderTime(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Failure: 1, Results: []result{{Err: ErrorInvalidDataKey}}}},
+		&testResponse{response: &response{Success: 99, Results: successes(99)}},
+	)
+	defer server.Close()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSender("test-api-key")
+	s.Time = &fakeTime{now: start}
+	policy := RampPolicy{Stages: []RampStage{{Fraction: 0.01, Soak: time.Hour}}}
+	result, err := s.SendRamped(msg, hundredRegIDs(), policy, 0)
+	assert.NoError(t, err, "zero MaxErrorRate means no limit")
+	assert.Equal(t, 100, result.Sent)
+	assert.Equal(t, start.Add(time.Hour), s.Time.Now())
+}
+
+func TestSendRampedKeepsResultsOfFailedBatch(t *testing.T) {
+	unavailable := &testResponse{response: &response{Failure: 1, Results: []result{{Err: ErrorUnavailable}}}}
+	server := startTestServer(t, unavailable, unavailable)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.RetryExhaustedErrors = true
+	s.Time = &fakeTime{now: time.Now()}
+	result, err := s.SendRamped(msg, hundredRegIDs(), testRampPolicy, 1)
+	var exhausted *RetryExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Equal(t, 1, result.Sent, "the recipient was sent to")
+	assert.Equal(t, []Result{{Error: ErrorUnavailable}}, result.Results)
+	assert.Equal(t, []RampStageResult{{Recipients: 1, Failures: 1, ErrorRate: 1}}, result.Stages)
+}
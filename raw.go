@@ -0,0 +1,71 @@
+package gcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// rawTargets holds the recipients of a raw JSON message.
+type rawTargets struct {
+	To              string   `json:"to"`
+	RegistrationIDs []string `json:"registration_ids"`
+	Condition       string   `json:"condition"`
+}
+
+func parseRawTargets(body []byte) (*rawTargets, error) {
+	targets := new(rawTargets)
+	if err := json.Unmarshal(body, targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// SendRawJSON sends a JSON encoded downstream message as is, without retries.
+// It is an escape hatch for fields that Message does not model.  The message
+// must specify its recipient with "to" or "condition"; use
+// SendMulticastRawJSON for messages with "registration_ids".
+func (s *Sender) SendRawJSON(ctx context.Context, body []byte) (*Result, error) {
+	if s.APIKey == "" {
+		return nil, errors.New("missing API key")
+	}
+	targets, err := parseRawTargets(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets.RegistrationIDs) > 0 {
+		return nil, errors.New("use SendMulticastRawJSON for messages with registration_ids")
+	}
+	if targets.To == "" && targets.Condition == "" {
+		return nil, errors.New("missing recipient(s)")
+	}
+	resp, err := s.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(resp, strings.HasPrefix(targets.To, TopicPrefix) || targets.Condition != "")
+}
+
+// SendMulticastRawJSON sends a JSON encoded multicast message as is, without
+// retries.  The message must specify its recipients with "registration_ids".
+func (s *Sender) SendMulticastRawJSON(ctx context.Context, body []byte) (*MulticastResult, error) {
+	if s.APIKey == "" {
+		return nil, errors.New("missing API key")
+	}
+	targets, err := parseRawTargets(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets.RegistrationIDs) == 0 {
+		return nil, errors.New("missing recipient(s)")
+	}
+	resp, err := s.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.checkResultCount(len(targets.RegistrationIDs)); err != nil {
+		return nil, err
+	}
+	return newMulticastResult(resp), nil
+}
@@ -0,0 +1,60 @@
+package gcm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRawJSON(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &response{MessageID: 10}},
+		&testResponse{response: &partialMulticast},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	ctx := context.Background()
+
+	result, err := s.SendRawJSON(ctx, []byte(`{"to":"regId","data":{"k":"v"},"new_field":true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "id"}, *result)
+
+	result, err = s.SendRawJSON(ctx, []byte(`{"to":"/topics/news"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "10"}, *result)
+
+	multicastResult, err := s.SendMulticastRawJSON(ctx, []byte(`{"registration_ids":["1","2"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, MulticastResult{
+		MulticastID: 1,
+		Success:     1,
+		Failure:     1,
+		Results:     []Result{{MessageID: "id1"}, {Error: ErrorUnavailable}},
+	}, *multicastResult)
+}
+
+func TestSendRawJSONWithInvalidBody(t *testing.T) {
+	s := NewSender("test-api-key")
+	ctx := context.Background()
+	_, err := s.SendRawJSON(ctx, []byte(`{"data":{}}`))
+	assert.EqualError(t, err, "missing recipient(s)")
+	_, err = s.SendRawJSON(ctx, []byte(`{"registration_ids":["1"]}`))
+	assert.EqualError(t, err, "use SendMulticastRawJSON for messages with registration_ids")
+	_, err = s.SendMulticastRawJSON(ctx, []byte(`{"to":"1"}`))
+	assert.EqualError(t, err, "missing recipient(s)")
+	_, err = s.SendRawJSON(ctx, []byte(`not json`))
+	assert.Error(t, err)
+	_, err = NewSender("").SendRawJSON(ctx, []byte(`{"to":"1"}`))
+	assert.EqualError(t, err, "missing API key")
+}
+
+func TestSendRawJSONWithCanceledContext(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewSender("test-api-key").SendRawJSON(ctx, []byte(`{"to":"1"}`))
+	assert.Error(t, err)
+}
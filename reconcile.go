@@ -0,0 +1,148 @@
+package gcm
+
+import (
+	"net/http"
+	"sync"
+)
+
+// DefaultReconcileBatchSize is the number of registration IDs added to or
+// removed from a device group per request when a GroupReconciler does not
+// specify one.
+const DefaultReconcileBatchSize = 20
+
+// TokenStore provides the registration tokens of the devices of each user.
+type TokenStore interface {
+	// Tokens returns the registration tokens of the user's devices.
+	Tokens(user string) ([]string, error)
+}
+
+// MembershipStore records the members of each device group as last applied,
+// since the connection server offers no way to list them.
+type MembershipStore interface {
+	// Members returns the recorded members of the named device group.
+	Members(group string) ([]string, error)
+	// SetMembers records the members of the named device group.
+	SetMembers(group string, members []string) error
+}
+
+// NewMemoryMembershipStore instantiates an in-memory MembershipStore.
+func NewMemoryMembershipStore() MembershipStore {
+	return &memoryMembershipStore{members: make(map[string][]string)}
+}
+
+type memoryMembershipStore struct {
+	mu      sync.Mutex
+	members map[string][]string
+}
+
+func (m *memoryMembershipStore) Members(group string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.members[group]...), nil
+}
+
+func (m *memoryMembershipStore) SetMembers(group string, members []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(members) == 0 {
+		delete(m.members, group)
+	} else {
+		m.members[group] = append([]string(nil), members...)
+	}
+	return nil
+}
+
+// GroupReconciler keeps the device group of each user, named after the user,
+// consistent with the user's devices in a TokenStore.
+type GroupReconciler struct {
+	Manager *DeviceGroupManager
+	Tokens  TokenStore
+	Members MembershipStore
+	// BatchSize is the max number of registration IDs per add or remove
+	// request.  Zero means DefaultReconcileBatchSize.
+	BatchSize int
+}
+
+// ReconcileResult lists the registration IDs added to and removed from a
+// device group.
+type ReconcileResult struct {
+	Added   []string
+	Removed []string
+}
+
+// Reconcile adds the user's tokens missing from the user's device group and
+// removes the members that are no longer the user's tokens, creating the group
+// if needed.  Progress is recorded in Members after each batch, so a failed
+// reconciliation resumes where it stopped.
+func (r *GroupReconciler) Reconcile(user string) (*ReconcileResult, error) {
+	desired, err := r.Tokens.Tokens(user)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := r.Members.Members(user)
+	if err != nil {
+		return nil, err
+	}
+	key, err := r.Manager.GetNotificationKey(user)
+	if isNotificationKeyNotFound(err) {
+		// the group does not exist (anymore)
+		key, actual, err = "", nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	toAdd, toRemove := difference(desired, actual), difference(actual, desired)
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultReconcileBatchSize
+	}
+	// add before removing, since removing the last member deletes the group
+	for len(toAdd) > 0 {
+		batch := toAdd[:min(batchSize, len(toAdd))]
+		if key == "" {
+			key, err = r.Manager.Create(user, batch)
+		} else {
+			err = r.Manager.Add(user, key, batch)
+		}
+		if err != nil {
+			return result, err
+		}
+		toAdd = toAdd[len(batch):]
+		actual = append(actual, batch...)
+		result.Added = append(result.Added, batch...)
+		if err := r.Members.SetMembers(user, actual); err != nil {
+			return result, err
+		}
+	}
+	for len(toRemove) > 0 {
+		batch := toRemove[:min(batchSize, len(toRemove))]
+		if err := r.Manager.Remove(user, key, batch); err != nil {
+			return result, err
+		}
+		toRemove = toRemove[len(batch):]
+		actual = difference(actual, batch)
+		result.Removed = append(result.Removed, batch...)
+		if err := r.Members.SetMembers(user, actual); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func isNotificationKeyNotFound(err error) bool {
+	dgErr, ok := err.(*DeviceGroupError)
+	return ok && dgErr.StatusCode == http.StatusBadRequest && dgErr.Reason == "notification_key not found"
+}
+
+// difference returns the elements of a that are not in b.
+func difference(a, b []string) []string {
+	var diff []string
+	for _, x := range a {
+		if !contains(b, x) {
+			diff = append(diff, x)
+		}
+	}
+	return diff
+}
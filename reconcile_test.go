@@ -0,0 +1,48 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenMap map[string][]string
+
+func (m tokenMap) Tokens(user string) ([]string, error) {
+	return m[user], nil
+}
+
+func TestGroupReconciler(t *testing.T) {
+	server, dg := startDeviceGroupServer(t)
+	defer server.Close()
+	tokens := tokenMap{"alice": {"1", "2", "3"}}
+	r := &GroupReconciler{
+		Manager:   NewDeviceGroupManager("test-api-key", "sender"),
+		Tokens:    tokens,
+		Members:   NewMemoryMembershipStore(),
+		BatchSize: 2,
+	}
+
+	result, err := r.Reconcile("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &ReconcileResult{Added: []string{"1", "2", "3"}}, result)
+	assert.Equal(t, []string{"1", "2", "3"}, dg.groups["alice"])
+
+	tokens["alice"] = []string{"3", "4"}
+	result, err = r.Reconcile("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &ReconcileResult{Added: []string{"4"}, Removed: []string{"1", "2"}}, result)
+	assert.Equal(t, []string{"3", "4"}, dg.groups["alice"])
+	members, _ := r.Members.Members("alice")
+	assert.Equal(t, []string{"3", "4"}, members)
+
+	result, err = r.Reconcile("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &ReconcileResult{}, result)
+
+	// the group was deleted behind the reconciler's back
+	delete(dg.groups, "alice")
+	result, err = r.Reconcile("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &ReconcileResult{Added: []string{"3", "4"}}, result)
+}
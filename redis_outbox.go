@@ -0,0 +1,208 @@
+package gcm
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisClient runs Redis commands.  It lets RedisOutbox work with any Redis
+// client library; e.g. with go-redis:
+//
+//	gcm.RedisClientFunc(func(args ...interface{}) (interface{}, error) {
+//		return rdb.Do(ctx, args...).Result()
+//	})
+//
+// Replies are expected as returned by such libraries: arrays as
+// []interface{}, bulk strings as string or []byte and nil replies as nil.
+type RedisClient interface {
+	Do(args ...interface{}) (interface{}, error)
+}
+
+// RedisClientFunc is a func that implements RedisClient.
+type RedisClientFunc func(args ...interface{}) (interface{}, error)
+
+// Do calls f(args...).
+func (f RedisClientFunc) Do(args ...interface{}) (interface{}, error) {
+	return f(args...)
+}
+
+// DefaultRedisClaimIdle is the ClaimIdle used by a RedisOutbox when none is
+// set.
+const DefaultRedisClaimIdle = time.Minute
+
+// RedisOutbox is an Outbox on a Redis stream read by a consumer group, so that
+// OutboxRelay replicas, each with its own Consumer name, share the jobs.  It
+// requires Redis 6.2 or later.
+//
+// RedisOutbox is safe for concurrent use.
+type RedisOutbox struct {
+	// Client runs the Redis commands.
+	Client RedisClient
+	// Stream is the key of the stream.
+	Stream string
+	// Group is the name of the consumer group, which is created if needed.
+	Group string
+	// Consumer is the name of this replica in the consumer group.
+	Consumer string
+	// ClaimIdle is how long an entry claimed by a consumer stays unacknowledged
+	// before other consumers can claim it.  Zero means DefaultRedisClaimIdle.
+	ClaimIdle time.Duration
+	// Codec encodes the jobs.  Nil means JSONCodec.
+	Codec Codec
+
+	mu      sync.Mutex
+	created bool
+	cursor  string // where the next XAUTOCLAIM resumes scanning pending entries
+}
+
+// Add adds a job to the stream.
+func (o *RedisOutbox) Add(job *Job) error {
+	data, err := codecOrDefault(o.Codec).Encode(job)
+	if err != nil {
+		return err
+	}
+	_, err = o.Client.Do("XADD", o.Stream, "*", "job", data)
+	return err
+}
+
+// Claim claims up to n entries, first among those left unacknowledged by
+// other consumers for ClaimIdle, then among the new ones.  Unacknowledged
+// entries are scanned from where the previous Claim stopped, so that entries
+// still in progress do not hide idle ones behind them.
+func (o *RedisOutbox) Claim(n int) ([]OutboxEntry, error) {
+	if err := o.createGroup(); err != nil {
+		return nil, err
+	}
+	idle := o.ClaimIdle
+	if idle <= 0 {
+		idle = DefaultRedisClaimIdle
+	}
+	o.mu.Lock()
+	cursor := o.cursor
+	o.mu.Unlock()
+	if cursor == "" {
+		cursor = "0-0"
+	}
+	reply, err := o.Client.Do("XAUTOCLAIM", o.Stream, o.Group, o.Consumer, int64(idle/time.Millisecond), cursor, "COUNT", n)
+	if err != nil {
+		return nil, err
+	}
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) < 2 {
+		return nil, fmt.Errorf("unexpected XAUTOCLAIM reply: %v", reply)
+	}
+	next, ok := redisString(parts[0])
+	if !ok {
+		return nil, fmt.Errorf("unexpected XAUTOCLAIM cursor: %v", parts[0])
+	}
+	o.mu.Lock()
+	o.cursor = next // 0-0 once the scan wrapped around
+	o.mu.Unlock()
+	entries, err := o.entries(parts[1])
+	if err != nil || len(entries) >= n {
+		return entries, err
+	}
+
+	reply, err = o.Client.Do("XREADGROUP", "GROUP", o.Group, o.Consumer, "COUNT", n-len(entries), "STREAMS", o.Stream, ">")
+	if err != nil || reply == nil {
+		return entries, err
+	}
+	streams, ok := reply.([]interface{})
+	if !ok || len(streams) != 1 {
+		return entries, fmt.Errorf("unexpected XREADGROUP reply: %v", reply)
+	}
+	stream, ok := streams[0].([]interface{})
+	if !ok || len(stream) != 2 {
+		return entries, fmt.Errorf("unexpected XREADGROUP reply: %v", reply)
+	}
+	more, err := o.entries(stream[1])
+	return append(entries, more...), err
+}
+
+// Ack acknowledges and deletes the entries.
+func (o *RedisOutbox) Ack(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{"XACK", o.Stream, o.Group}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := o.Client.Do(args...); err != nil {
+		return err
+	}
+	args = append([]interface{}{"XDEL", o.Stream}, args[3:]...)
+	_, err := o.Client.Do(args...)
+	return err
+}
+
+func (o *RedisOutbox) createGroup() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.created {
+		return nil
+	}
+	_, err := o.Client.Do("XGROUP", "CREATE", o.Stream, o.Group, "0", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	o.created = true
+	return nil
+}
+
+// entries decodes the stream entries of a reply.  Entries deleted while
+// pending, which have no fields, and entries that cannot be decoded are
+// acknowledged right away.
+func (o *RedisOutbox) entries(reply interface{}) ([]OutboxEntry, error) {
+	items, ok := reply.([]interface{})
+	if !ok && reply != nil {
+		return nil, fmt.Errorf("unexpected stream entries: %v", reply)
+	}
+	var entries []OutboxEntry
+	var deleted []string
+	for _, item := range items {
+		entry, ok := item.([]interface{})
+		if !ok || len(entry) != 2 {
+			return entries, fmt.Errorf("unexpected stream entry: %v", item)
+		}
+		id, ok := redisString(entry[0])
+		if !ok {
+			return entries, fmt.Errorf("unexpected stream entry id: %v", entry[0])
+		}
+		fields, _ := entry[1].([]interface{})
+		var data string
+		for i := 0; i+1 < len(fields); i += 2 {
+			if name, _ := redisString(fields[i]); name == "job" {
+				data, _ = redisString(fields[i+1])
+			}
+		}
+		if data == "" {
+			deleted = append(deleted, id)
+			continue
+		}
+		job, err := codecOrDefault(o.Codec).Decode([]byte(data))
+		if err != nil {
+			log.Printf("dropping stream entry %s that failed to decode: %v", id, err)
+			deleted = append(deleted, id)
+			continue
+		}
+		entries = append(entries, OutboxEntry{ID: id, Job: job})
+	}
+	if len(deleted) > 0 {
+		return entries, o.Ack(deleted...)
+	}
+	return entries, nil
+}
+
+func redisString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,187 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis implements the stream commands used by RedisOutbox for a single
+// stream and consumer group.
+type fakeRedis struct {
+	mu      sync.Mutex
+	seq     int
+	group   bool
+	ids     []string
+	fields  map[string][]interface{}
+	pending map[string]string // id to consumer
+	claimed map[string]time.Time
+	lastID  int // index of the last entry delivered to the group
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{fields: map[string][]interface{}{}, pending: map[string]string{}, claimed: map[string]time.Time{}}
+}
+
+func (r *fakeRedis) Do(args ...interface{}) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := func(id string) interface{} {
+		return []interface{}{id, r.fields[id]}
+	}
+	switch args[0] {
+	case "XGROUP":
+		if r.group {
+			return nil, errors.New("BUSYGROUP Consumer Group name already exists")
+		}
+		r.group = true
+		return "OK", nil
+	case "XADD":
+		r.seq++
+		id := fmt.Sprintf("%d-0", r.seq)
+		r.ids = append(r.ids, id)
+		r.fields[id] = []interface{}{args[3], args[4]}
+		return id, nil
+	case "XAUTOCLAIM":
+		// like Redis, scan up to count pending entries from the cursor
+		consumer, idle, count := args[3].(string), time.Duration(args[4].(int64))*time.Millisecond, args[7].(int)
+		var start int
+		fmt.Sscanf(args[5].(string), "%d-0", &start)
+		var entries []interface{}
+		next, scanned := "0-0", 0
+		for _, id := range r.ids {
+			var seq int
+			fmt.Sscanf(id, "%d-0", &seq)
+			if _, ok := r.pending[id]; !ok || seq < start {
+				continue
+			}
+			if scanned == count {
+				next = id
+				break
+			}
+			scanned++
+			if time.Since(r.claimed[id]) >= idle {
+				r.pending[id], r.claimed[id] = consumer, time.Now()
+				entries = append(entries, entry(id))
+			}
+		}
+		return []interface{}{next, entries, []interface{}{}}, nil
+	case "XREADGROUP":
+		consumer, count := args[3].(string), args[5].(int)
+		var entries []interface{}
+		for ; r.lastID < len(r.ids) && len(entries) < count; r.lastID++ {
+			id := r.ids[r.lastID]
+			r.pending[id], r.claimed[id] = consumer, time.Now()
+			entries = append(entries, entry(id))
+		}
+		if len(entries) == 0 {
+			return nil, nil
+		}
+		return []interface{}{[]interface{}{args[7], entries}}, nil
+	case "XACK":
+		for _, id := range args[3:] {
+			delete(r.pending, id.(string))
+		}
+		return int64(len(args) - 3), nil
+	case "XDEL":
+		for _, id := range args[2:] {
+			delete(r.fields, id.(string))
+		}
+		return int64(len(args) - 2), nil
+	}
+	return nil, fmt.Errorf("unknown command %v", args[0])
+}
+
+func TestRedisOutbox(t *testing.T) {
+	redis := newFakeRedis()
+	a := &RedisOutbox{Client: redis, Stream: "jobs", Group: "relays", Consumer: "a", ClaimIdle: time.Millisecond}
+	b := &RedisOutbox{Client: redis, Stream: "jobs", Group: "relays", Consumer: "b", ClaimIdle: time.Hour}
+	for _, to := range []string{"1", "2", "3"} {
+		assert.NoError(t, a.Add(&Job{Class: ClassReminder, Message: msg, To: to}))
+	}
+
+	entries, err := a.Claim(2)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "1", entries[0].Job.To)
+	assert.NoError(t, a.Ack(entries[0].ID))
+
+	// b gets the new entry, but not the one a left unacknowledged
+	entries, err = b.Claim(10)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "3", entries[0].Job.To)
+
+	// a takes back its own entry and b's once they have been idle long enough
+	time.Sleep(2 * time.Millisecond)
+	entries, err = a.Claim(10)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "2", entries[0].Job.To)
+	assert.Equal(t, "3", entries[1].Job.To)
+}
+
+func TestRedisOutboxClaimResumesScan(t *testing.T) {
+	redis := newFakeRedis()
+	a := &RedisOutbox{Client: redis, Stream: "jobs", Group: "relays", Consumer: "a", ClaimIdle: time.Millisecond}
+	b := &RedisOutbox{Client: redis, Stream: "jobs", Group: "relays", Consumer: "b", ClaimIdle: time.Hour}
+	for _, to := range []string{"1", "2", "3"} {
+		assert.NoError(t, b.Add(&Job{Class: ClassReminder, Message: msg, To: to}))
+	}
+	entries, err := b.Claim(3)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	time.Sleep(2 * time.Millisecond)
+	redis.claimed["1-0"] = time.Now() // still in progress
+
+	// the first scan only reaches the entry in progress
+	entries, err = a.Claim(1)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	for _, to := range []string{"2", "3"} {
+		entries, err = a.Claim(1)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, to, entries[0].Job.To)
+		}
+	}
+}
+
+func TestOutboxRelay(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{statusCode: 503},
+		&testResponse{statusCode: 400},
+	)
+	defer server.Close()
+	redis := newFakeRedis()
+	outbox := &RedisOutbox{Client: redis, Stream: "jobs", Group: "relays", Consumer: "a", ClaimIdle: time.Hour}
+	for _, to := range []string{"1", "2", "3"} {
+		assert.NoError(t, outbox.Add(&Job{Class: ClassReminder, Message: msg, To: to}))
+	}
+	relay := &OutboxRelay{Outbox: outbox, Sender: NewSender("test-api-key"), DeadLetters: &DeadLetterQueue{}}
+	n, err := relay.Relay()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	// only the job that failed with 503 is left to be claimed again
+	assert.Equal(t, map[string]string{"2-0": "a"}, redis.pending)
+	assert.Len(t, relay.DeadLetters.List(), 1)
+}
+
+func TestOutboxRelayAcksPartialResults(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &partialMulticast})
+	defer server.Close()
+	redis := newFakeRedis()
+	outbox := &RedisOutbox{Client: redis, Stream: "jobs", Group: "relays", Consumer: "a", ClaimIdle: time.Hour}
+	assert.NoError(t, outbox.Add(&Job{Class: ClassReminder, Message: msg, RegistrationIDs: twoRecipients}))
+	relay := &OutboxRelay{Outbox: outbox, Sender: partialResultSender(), Retries: 1, DeadLetters: &DeadLetterQueue{}}
+	n, err := relay.Relay()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, redis.pending)
+	assert.Len(t, relay.DeadLetters.List(), 1)
+}
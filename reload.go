@@ -0,0 +1,213 @@
+package gcm
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultReloadInterval is how often a ConfigWatcher checks its file when
+// Interval is not set.
+const defaultReloadInterval = 5 * time.Second
+
+// senderPolicy holds the settings of a Sender that Reload changes while it is
+// in use.
+type senderPolicy struct {
+	DeviceGroupRetry DeviceGroupRetryPolicy
+	TopicRateBackoff int
+}
+
+func (s *Sender) policy() senderPolicy {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	if s.reloaded != nil {
+		return *s.reloaded
+	}
+	return senderPolicy{s.DeviceGroupRetry, s.TopicRateBackoff}
+}
+
+// Reload applies the rate limit and retry policy of a config to the Sender
+// while it is in use, in place of its RateLimit, DeviceGroupRetry and
+// TopicRateBackoff.  Sends in progress keep the retry policy they started
+// with.  The other settings, e.g. the credentials or MaxConcurrentRequests,
+// require a new Sender.
+func (s *Sender) Reload(c *Config) error {
+	policy, ok := deviceGroupRetryPolicies[c.Retry.DeviceGroup]
+	if !ok {
+		return fmt.Errorf("unknown device group retry policy %q", c.Retry.DeviceGroup)
+	}
+	s.initLimits()
+	s.limitMu.Lock()
+	s.limiter = nil
+	if rate := c.RateLimit.RequestsPerSecond; rate > 0 {
+		s.limiter = newRateLimiter(rate)
+	}
+	s.limitMu.Unlock()
+	s.reloadMu.Lock()
+	s.reloaded = &senderPolicy{policy, int(time.Duration(c.Retry.TopicRateBackoff) / time.Millisecond)}
+	s.reloadMu.Unlock()
+	return nil
+}
+
+func classByName(name string) (Class, bool) {
+	for class, n := range classNames {
+		if n == name {
+			return class, true
+		}
+	}
+	return 0, false
+}
+
+// Reload applies the class rate limits and the quiet hours of a config to the
+// Queue while it is in use.  The classes not listed keep their rate limit; a
+// rate of zero makes a class unlimited.  Without quiet hours in the config,
+// the Queue keeps its own; quiet hours listing no classes lift them.
+func (q *Queue) Reload(c *Config) error {
+	limits := make(map[Class]float64)
+	for name, rate := range c.RateLimit.Classes {
+		class, ok := classByName(name)
+		if !ok {
+			return fmt.Errorf("unknown class %q", name)
+		}
+		limits[class] = rate
+	}
+	var quietHours *QuietHours
+	if c.QuietHours != nil {
+		var err error
+		if quietHours, err = c.QuietHours.QuietHours(); err != nil {
+			return err
+		}
+	}
+	q.mu.Lock()
+	if quietHours != nil {
+		q.quietHours = quietHours
+	}
+	for class, rate := range limits {
+		qc := q.class(class)
+		qc.limiter = nil
+		if rate > 0 {
+			qc.limiter = newRateLimiter(rate)
+		}
+	}
+	q.signal() // a waiting consumer may now dequeue
+	q.mu.Unlock()
+	return nil
+}
+
+// Reload applies the number of retries of a config to the Dispatcher while it
+// is in use, in place of its Retries.  Jobs being sent keep the number they
+// started with.
+func (d *Dispatcher) Reload(c *Config) error {
+	d.reloadMu.Lock()
+	d.reloadedRetries = &c.Retry.Retries
+	d.reloadMu.Unlock()
+	return nil
+}
+
+func (d *Dispatcher) retries() int {
+	d.reloadMu.RLock()
+	defer d.reloadMu.RUnlock()
+	if d.reloadedRetries != nil {
+		return *d.reloadedRetries
+	}
+	return d.Retries
+}
+
+// ConfigWatcher watches a config file and reloads the Sender, the Queue and
+// the Dispatcher whenever it changes, so that rate limits, retry policies and
+// quiet hours can be tuned without a restart.  A config that fails to load or to apply is ignored,
+// leaving the previous settings in place.
+type ConfigWatcher struct {
+	// Path is the config file, see LoadConfig.
+	Path string
+	// Sender, Queue and Dispatcher, if set, are reloaded.
+	Sender     *Sender
+	Queue      *Queue
+	Dispatcher *Dispatcher
+	// Interval is how often the file is checked.  Zero means 5s.
+	Interval time.Duration
+	// OnReload, if set, is called with each changed config, or the error that
+	// prevented it from being applied.  If not, errors are logged.
+	OnReload func(*Config, error)
+	// PanicPolicy decides what happens when OnReload panics.
+	PanicPolicy PanicPolicy
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// Check reloads the config file if it changed since the last Check, and
+// reports whether it did.
+func (w *ConfigWatcher) Check() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	config, err := LoadConfigFile(w.Path)
+	if err == nil {
+		err = w.apply(config)
+	}
+	if w.OnReload != nil {
+		protect(w.PanicPolicy, "OnReload", func() { w.OnReload(config, err) })
+	} else if err != nil {
+		log.Printf("failed to reload config %s: %v", w.Path, err)
+	}
+	return err == nil, err
+}
+
+// apply reloads the Sender, the Queue and the Dispatcher, validating the
+// config first so that none is changed if it is invalid.
+func (w *ConfigWatcher) apply(c *Config) error {
+	if _, ok := deviceGroupRetryPolicies[c.Retry.DeviceGroup]; !ok {
+		return fmt.Errorf("unknown device group retry policy %q", c.Retry.DeviceGroup)
+	}
+	for name := range c.RateLimit.Classes {
+		if _, ok := classByName(name); !ok {
+			return fmt.Errorf("unknown class %q", name)
+		}
+	}
+	if c.QuietHours != nil {
+		if _, err := c.QuietHours.QuietHours(); err != nil {
+			return err
+		}
+	}
+	if w.Sender != nil {
+		w.Sender.Reload(c)
+	}
+	if w.Queue != nil {
+		w.Queue.Reload(c)
+	}
+	if w.Dispatcher != nil {
+		w.Dispatcher.Reload(c)
+	}
+	return nil
+}
+
+// Run checks the config file every Interval until done is closed.  The file
+// is loaded right away.
+func (w *ConfigWatcher) Run(done <-chan struct{}) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// a missing file, e.g. while it is being replaced, is checked again
+		w.Check()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gcm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderReload(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &success},
+		&testResponse{response: &partialDeviceGroup},
+		&testResponse{response: &response{Success: 3}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.RateLimit = 1
+	_, err := s.SendNoRetry(msg, "1")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Reload(&Config{
+		Retry:     RetryConfig{DeviceGroup: "group"},
+		RateLimit: RateLimitConfig{RequestsPerSecond: 1000},
+	}))
+	assert.Equal(t, DeviceGroupRetryNone, s.DeviceGroupRetry, "fields are not modified")
+
+	start := time.Now()
+	_, err = s.SendNoRetry(msg, "1")
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "the reloaded rate limit applies")
+	result, err := s.SendWithRetries(msg, "group", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id1", "id2"}, result.RecoveredRegistrationIDs)
+
+	assert.EqualError(t, s.Reload(&Config{Retry: RetryConfig{DeviceGroup: "all"}}), `unknown device group retry policy "all"`)
+}
+
+func TestQueueReload(t *testing.T) {
+	q := NewQueue(QueueConfig{Classes: map[Class]ClassConfig{ClassMarketing: {RateLimit: 0.001}}})
+	q.Enqueue(&Job{Class: ClassMarketing, To: "1", Message: msg})
+	q.Enqueue(&Job{Class: ClassMarketing, To: "2", Message: msg})
+	job, _ := q.Dequeue()
+	assert.Equal(t, "1", job.To)
+
+	dequeued := make(chan *Job)
+	go func() {
+		job, _ := q.Dequeue()
+		dequeued <- job
+	}()
+	select {
+	case <-dequeued:
+		t.Fatal("dequeued above the rate limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.NoError(t, q.Reload(&Config{RateLimit: RateLimitConfig{Classes: map[string]float64{"marketing": 0}}}))
+	select {
+	case job := <-dequeued:
+		assert.Equal(t, "2", job.To)
+	case <-time.After(time.Second):
+		t.Fatal("not dequeued after the rate limit was lifted")
+	}
+
+	assert.EqualError(t, q.Reload(&Config{RateLimit: RateLimitConfig{Classes: map[string]float64{"promo": 1}}}), `unknown class "promo"`)
+}
+
+func TestConfigWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcm.yaml")
+	write := func(config string, modTime time.Time) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(config), 0600))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	s := NewSender("test-api-key")
+	var reloaded []*Config
+	var errs []error
+	w := &ConfigWatcher{Path: path, Sender: s, OnReload: func(c *Config, err error) {
+		reloaded = append(reloaded, c)
+		errs = append(errs, err)
+	}}
+	now := time.Now()
+	write("retry:\n  device_group: group\n  topic_rate_backoff: 2s\n", now)
+	ok, err := w.Check()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, senderPolicy{DeviceGroupRetryGroup, 2000}, s.policy())
+
+	ok, err = w.Check()
+	assert.False(t, ok, "unchanged")
+	assert.NoError(t, err)
+
+	write("retry:\n  device_group: sometimes\n", now.Add(time.Second))
+	ok, err = w.Check()
+	assert.False(t, ok)
+	assert.EqualError(t, err, `unknown device group retry policy "sometimes"`)
+	assert.Equal(t, senderPolicy{DeviceGroupRetryGroup, 2000}, s.policy(), "previous settings are kept")
+	assert.Len(t, reloaded, 2)
+	assert.Equal(t, []error{nil, err}, errs)
+}
+
+func TestQueueReloadQuietHours(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	now := time.Now().UTC()
+	assert.NoError(t, q.Reload(&Config{QuietHours: &QuietHoursConfig{
+		Start:   now.Add(-time.Hour).Format("15:04"),
+		End:     now.Add(time.Hour).Format("15:04"),
+		Classes: []string{"marketing"},
+	}}))
+	q.Enqueue(&Job{Class: ClassMarketing, To: "1", Message: msg})
+	q.Enqueue(&Job{Class: ClassTransactional, To: "2", Message: msg})
+	job, _ := q.Dequeue()
+	assert.Equal(t, "2", job.To)
+
+	dequeued := make(chan *Job)
+	go func() {
+		job, _ := q.Dequeue()
+		dequeued <- job
+	}()
+	select {
+	case <-dequeued:
+		t.Fatal("dequeued during quiet hours")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.NoError(t, q.Reload(&Config{}), "quiet hours are kept")
+	assert.NoError(t, q.Reload(&Config{QuietHours: &QuietHoursConfig{Start: "00:00", End: "00:00"}}))
+	select {
+	case job := <-dequeued:
+		assert.Equal(t, "1", job.To)
+	case <-time.After(time.Second):
+		t.Fatal("not dequeued after the quiet hours were lifted")
+	}
+
+	assert.EqualError(t, q.Reload(&Config{QuietHours: &QuietHoursConfig{Start: "22:00", End: "7"}}), `invalid quiet hours time "7"`)
+}
+
+func TestConfigWatcherQueueAndDispatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcm.yaml")
+	q := NewQueue(QueueConfig{})
+	d := &Dispatcher{Queue: q, Retries: 1}
+	w := &ConfigWatcher{Path: path, Queue: q, Dispatcher: d}
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("retry:\n  retries: 5\nquiet_hours:\n  start: \"22:00\"\n  end: \"07:00\"\n  classes:\n    - marketing\n"), 0600))
+	ok, err := w.Check()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, d.retries())
+	assert.Equal(t, 1, d.Retries, "fields are not modified")
+	assert.Equal(t, &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour, Classes: []Class{ClassMarketing}}, q.quietHours)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("retry:\n  retries: 2\nquiet_hours:\n  start: \"22:00\"\n  end: \"07:00\"\n  classes:\n    - promo\n"), 0600))
+	assert.NoError(t, os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+	_, err = w.Check()
+	assert.EqualError(t, err, `unknown class "promo"`)
+	assert.Equal(t, 5, d.retries(), "previous settings are kept")
+}
@@ -0,0 +1,45 @@
+package gcm
+
+import "errors"
+
+// ResendTargets returns the registration IDs to resend a message to given the
+// registration IDs it was sent to and their results: the canonical
+// registration IDs reported for outdated tokens and, if retryable is set, the
+// tokens that failed with Unavailable or InternalServerError.  Registration IDs
+// the message was already sent to are left out.
+func ResendTargets(regIDs []string, results []Result, retryable bool) []string {
+	var targets []string
+	add := func(regID string) {
+		if !contains(targets, regID) {
+			targets = append(targets, regID)
+		}
+	}
+	for i, res := range results[:min(len(regIDs), len(results))] {
+		if canonical := res.CanonicalRegistrationID; canonical != "" && !contains(regIDs, canonical) {
+			add(canonical)
+		} else if retryable && isUnavailable(res.Error) {
+			add(regIDs[i])
+		}
+	}
+	return targets
+}
+
+// ResendWithCanonical resends the message to the ResendTargets of a previous
+// send to regIDs with the given results, e.g. MulticastResult.Results or the
+// single Result of a message sent to one registration ID.  It returns the
+// registration IDs resent to along with their results, or no registration IDs
+// and a nil result if there is nothing to resend.
+//
+// Tokens with a canonical registration ID usually received the message, so set
+// a CollapseKey to keep devices from showing it twice.
+func (s *Sender) ResendWithCanonical(msg *Message, regIDs []string, results []Result, retryable bool, retries int) ([]string, *MulticastResult, error) {
+	if len(regIDs) != len(results) {
+		return nil, nil, errors.New("registration ids and results do not match")
+	}
+	targets := ResendTargets(regIDs, results, retryable)
+	if len(targets) == 0 {
+		return nil, nil, nil
+	}
+	result, err := s.SendMulticastWithRetries(msg, targets, retries)
+	return targets, result, err
+}
@@ -0,0 +1,38 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResendTargets(t *testing.T) {
+	regIDs := []string{"1", "2", "3", "4", "5"}
+	results := []Result{
+		{MessageID: "id1", CanonicalRegistrationID: "new1"},
+		{Error: ErrorUnavailable},
+		{Error: ErrorNotRegistered},
+		{MessageID: "id4", CanonicalRegistrationID: "5"},
+		{MessageID: "id5", CanonicalRegistrationID: "new1"},
+	}
+	assert.Equal(t, []string{"new1"}, ResendTargets(regIDs, results, false))
+	assert.Equal(t, []string{"new1", "2"}, ResendTargets(regIDs, results, true))
+}
+
+func TestResendWithCanonical(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	targets, result, err := s.ResendWithCanonical(msg, []string{"1"}, []Result{{MessageID: "id", CanonicalRegistrationID: "new1"}}, false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new1"}, targets)
+	assert.Equal(t, 1, result.Success)
+
+	targets, result, err = s.ResendWithCanonical(msg, []string{"1"}, []Result{{MessageID: "id"}}, true, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, targets)
+	assert.Nil(t, result)
+
+	_, _, err = s.ResendWithCanonical(msg, []string{"1"}, nil, true, 0)
+	assert.EqualError(t, err, "registration ids and results do not match")
+}
@@ -1,5 +1,10 @@
 package gcm
 
+import (
+	"errors"
+	"fmt"
+)
+
 // reference: https://developers.google.com/cloud-messaging/http-server-ref
 
 // response specifies the downstream HTTP message response body in JSON format.
@@ -19,6 +24,39 @@ type response struct {
 	Err       string `json:"error,omitempty"`
 	// device group messages only, see https://goo.gl/kx9ENj
 	FailedRegistrationIDs []string `json:"failed_registration_ids,omitempty"`
+
+	// raw response body
+	body []byte
+}
+
+// ErrResultCountMismatch matches any *ResultCountMismatchError with errors.Is.
+var ErrResultCountMismatch = errors.New("result count mismatch")
+
+// ResultCountMismatchError is returned when the GCM connection server responds
+// with a different number of results than the number of recipients, in which
+// case results cannot be safely attributed to recipients.
+type ResultCountMismatchError struct {
+	Expected int
+	Actual   int
+	// Body is the raw response body.
+	Body []byte
+}
+
+func (e *ResultCountMismatchError) Error() string {
+	return fmt.Sprintf("expected %d results, got %d: %s", e.Expected, e.Actual, e.Body)
+}
+
+// Is reports whether target is ErrResultCountMismatch.
+func (e *ResultCountMismatchError) Is(target error) bool {
+	return target == ErrResultCountMismatch
+}
+
+// checkResultCount checks that the response has one result per recipient.
+func (r *response) checkResultCount(recipients int) error {
+	if len(r.Results) != recipients {
+		return &ResultCountMismatchError{recipients, len(r.Results), r.body}
+	}
+	return nil
 }
 
 type result struct {
@@ -2,7 +2,8 @@ package gcm
 
 // Result represents the status of a processed message.
 //
-// Some fields are specific to device group messages: Success, Failure, FailedRegistrationIDs.
+// Some fields are specific to device group messages: Success, Failure,
+// FailedRegistrationIDs, RecoveredRegistrationIDs.
 type Result struct {
 	MessageID               string `json:"message_id,omitempty"`
 	CanonicalRegistrationID string `json:"canonical_registration_id,omitempty"`
@@ -11,6 +12,12 @@ type Result struct {
 	Success               int      `json:"success,omitempty"`
 	Failure               int      `json:"failure,omitempty"`
 	FailedRegistrationIDs []string `json:"failed_registration_ids,omitempty"`
+	// members that failed at first but received the message when retried
+	RecoveredRegistrationIDs []string `json:"recovered_registration_ids,omitempty"`
+	// trace ID injected into the data payload, if enabled
+	TraceID string `json:"trace_id,omitempty"`
+	// UUID injected into the data payload, if enabled
+	UUID string `json:"uuid,omitempty"`
 }
 
 // MulticastResult represents the response of a processed multicast message.
@@ -21,4 +28,9 @@ type MulticastResult struct {
 	MulticastID       int64    `json:"multicast_id"`
 	Results           []Result `json:"results,omitempty"`
 	RetryMulticastIDs []int64  `json:"retry_multicast_ids,omitempty"`
+	// multicast IDs of the batches after the first, if split, see
+	// Sender.SplitMulticast
+	BatchMulticastIDs []int64 `json:"batch_multicast_ids,omitempty"`
+	TraceID           string  `json:"trace_id,omitempty"`
+	UUID              string  `json:"uuid,omitempty"`
 }
@@ -0,0 +1,103 @@
+package gcm
+
+import "errors"
+
+// TokenState is the state of a registration token as reported by a Result.
+type TokenState int
+
+const (
+	// TokenOK means the message was accepted for the token.
+	TokenOK TokenState = iota
+	// TokenCanonical means the message was accepted, but the token has a
+	// canonical registration ID that replaces it.
+	TokenCanonical
+	// TokenDead means the token must be removed.
+	TokenDead
+	// TokenFailed means the message failed for another reason.
+	TokenFailed
+)
+
+var tokenStateNames = map[TokenState]string{
+	TokenOK:        "ok",
+	TokenCanonical: "canonical",
+	TokenDead:      "dead",
+	TokenFailed:    "failed",
+}
+
+func (s TokenState) String() string {
+	return tokenStateNames[s]
+}
+
+// StateOf returns the state of the token a Result is for.
+func StateOf(res Result) TokenState {
+	switch {
+	case res.Error == "" && res.CanonicalRegistrationID != "":
+		return TokenCanonical
+	case res.Error == "":
+		return TokenOK
+	case Classify(res.Error) == ActionRemoveToken:
+		return TokenDead
+	}
+	return TokenFailed
+}
+
+// TokenChange is a registration token whose state changed between two
+// broadcasts.
+type TokenChange struct {
+	RegistrationID string
+	Before         Result
+	After          Result
+}
+
+// ResultDiff reports the registration tokens whose state changed between two
+// broadcasts, for token health trend analysis.  Tokens sent to by only one of
+// them are ignored.
+type ResultDiff struct {
+	// NewlyDead lists the tokens that became TokenDead.
+	NewlyDead []TokenChange
+	// NewlyCanonical lists the tokens that became TokenCanonical or got a
+	// different canonical registration ID.
+	NewlyCanonical []TokenChange
+	// NewlyFailed lists the tokens that became TokenFailed.
+	NewlyFailed []TokenChange
+	// Recovered lists the tokens that became TokenOK.
+	Recovered []TokenChange
+}
+
+// DiffResults compares the results of two broadcasts, given the registration
+// IDs each was sent to and their results, e.g. yesterday's and today's.
+func DiffResults(beforeRegIDs []string, before *MulticastResult, afterRegIDs []string, after *MulticastResult) (*ResultDiff, error) {
+	if before == nil || after == nil {
+		return nil, errors.New("results cannot be nil")
+	}
+	if len(beforeRegIDs) != len(before.Results) || len(afterRegIDs) != len(after.Results) {
+		return nil, errors.New("registration ids and results do not match")
+	}
+	previous := make(map[string]Result, len(beforeRegIDs))
+	for i, regID := range beforeRegIDs {
+		previous[regID] = before.Results[i]
+	}
+
+	diff := &ResultDiff{}
+	for i, regID := range afterRegIDs {
+		b, ok := previous[regID]
+		if !ok {
+			continue
+		}
+		a := after.Results[i]
+		change := TokenChange{regID, b, a}
+		from, to := StateOf(b), StateOf(a)
+		switch {
+		case to == TokenCanonical && a.CanonicalRegistrationID != b.CanonicalRegistrationID:
+			diff.NewlyCanonical = append(diff.NewlyCanonical, change)
+		case from == to:
+		case to == TokenDead:
+			diff.NewlyDead = append(diff.NewlyDead, change)
+		case to == TokenFailed:
+			diff.NewlyFailed = append(diff.NewlyFailed, change)
+		case to == TokenOK:
+			diff.Recovered = append(diff.Recovered, change)
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,29 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffResults(t *testing.T) {
+	ok := Result{MessageID: "id"}
+	dead := Result{Error: ErrorNotRegistered}
+	failed := Result{Error: ErrorUnavailable}
+	canonical := Result{MessageID: "id", CanonicalRegistrationID: "c"}
+	before := &MulticastResult{Results: []Result{ok, ok, failed, dead, dead, ok}}
+	after := &MulticastResult{Results: []Result{ok, dead, canonical, ok, failed, ok}}
+	diff, err := DiffResults(
+		[]string{"same", "dies", "moves", "recovers", "fails", "gone"}, before,
+		[]string{"same", "dies", "moves", "recovers", "fails", "new"}, after,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []TokenChange{{"dies", ok, dead}}, diff.NewlyDead)
+	assert.Equal(t, []TokenChange{{"moves", failed, canonical}}, diff.NewlyCanonical)
+	assert.Equal(t, []TokenChange{{"fails", dead, failed}}, diff.NewlyFailed)
+	assert.Equal(t, []TokenChange{{"recovers", dead, ok}}, diff.Recovered)
+
+	_, err = DiffResults([]string{"a"}, before, nil, after)
+	assert.Error(t, err)
+	assert.Equal(t, "dead", StateOf(dead).String())
+}
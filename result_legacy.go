@@ -0,0 +1,105 @@
+package gcm
+
+import "encoding/json"
+
+// legacyResultFields maps the field names of results persisted by older
+// versions of Result, either with the field names of the wire format or
+// without JSON tags, to the names of ResultV1.
+var legacyResultFields = map[string]string{
+	"registration_id":          "canonical_registration_id",
+	"RegistrationID":           "canonical_registration_id",
+	"CanonicalRegistrationID":  "canonical_registration_id",
+	"MessageID":                "message_id",
+	"FailedRegistrationIDs":    "failed_registration_ids",
+	"RecoveredRegistrationIDs": "recovered_registration_ids",
+	"TraceID":                  "trace_id",
+}
+
+// legacyMulticastResultFields does the same as legacyResultFields for
+// MulticastResult.
+var legacyMulticastResultFields = map[string]string{
+	"BatchMulticastIDs": "batch_multicast_ids",
+	"CanonicalIds":      "canonical_ids",
+	"CanonicalIDs":      "canonical_ids",
+	"MulticastID":       "multicast_id",
+	"RetryMulticastIDs": "retry_multicast_ids",
+	"Results":           "results",
+	"TraceID":           "trace_id",
+}
+
+// ReadResult reads a result persisted by any version of this package: one
+// marshaled by MarshalResult, or Result JSON written by older versions of the
+// struct, including its pre-rename field names.
+func ReadResult(data []byte) (*Result, error) {
+	if checkSchemaVersion(data) != ErrMissingSchemaVersion {
+		return UnmarshalResult(data)
+	}
+	v1, err := readLegacyResult(data)
+	if err != nil {
+		return nil, err
+	}
+	return v1.Result(), nil
+}
+
+// ReadMulticastResult reads a multicast result persisted by any version of
+// this package, like ReadResult.
+func ReadMulticastResult(data []byte) (*MulticastResult, error) {
+	if checkSchemaVersion(data) != ErrMissingSchemaVersion {
+		return UnmarshalMulticastResult(data)
+	}
+	fields, err := renameFields(data, legacyMulticastResultFields)
+	if err != nil {
+		return nil, err
+	}
+	var results []json.RawMessage
+	if raw, ok := fields["results"]; ok {
+		delete(fields, "results")
+		if err := json.Unmarshal(raw, &results); err != nil {
+			return nil, err
+		}
+	}
+	b, _ := json.Marshal(fields)
+	v1 := &MulticastResultV1{}
+	if err := json.Unmarshal(b, v1); err != nil {
+		return nil, err
+	}
+	for _, raw := range results {
+		res, err := readLegacyResult(raw)
+		if err != nil {
+			return nil, err
+		}
+		v1.Results = append(v1.Results, *res)
+	}
+	return v1.MulticastResult(), nil
+}
+
+func readLegacyResult(data []byte) (*ResultV1, error) {
+	fields, err := renameFields(data, legacyResultFields)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(fields)
+	v1 := &ResultV1{}
+	if err := json.Unmarshal(b, v1); err != nil {
+		return nil, err
+	}
+	return v1, nil
+}
+
+// renameFields decodes the JSON object in data, renaming its fields found in
+// names.  Fields already using the new name take precedence.
+func renameFields(data []byte, names map[string]string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for old, name := range names {
+		if v, ok := fields[old]; ok {
+			delete(fields, old)
+			if _, ok := fields[name]; !ok {
+				fields[name] = v
+			}
+		}
+	}
+	return fields, nil
+}
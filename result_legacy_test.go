@@ -0,0 +1,55 @@
+package gcm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadResult(t *testing.T) {
+	expected := &Result{MessageID: "1", CanonicalRegistrationID: "c", TraceID: "t"}
+	for _, data := range []string{
+		`{"version":1,"message_id":"1","canonical_registration_id":"c","trace_id":"t"}`,
+		`{"message_id":"1","canonical_registration_id":"c","trace_id":"t"}`,
+		`{"message_id":"1","registration_id":"c","trace_id":"t"}`,
+		`{"MessageID":"1","CanonicalRegistrationID":"c","Error":"","TraceID":"t"}`,
+	} {
+		result, err := ReadResult([]byte(data))
+		assert.NoError(t, err, data)
+		assert.Equal(t, expected, result, data)
+	}
+
+	_, err := ReadResult([]byte(`{"version":2}`))
+	assert.EqualError(t, err, "unsupported result schema version 2")
+	_, err = ReadResult([]byte(`[]`))
+	assert.Error(t, err)
+}
+
+func TestReadMulticastResult(t *testing.T) {
+	expected := &MulticastResult{
+		Success:      1,
+		Failure:      1,
+		CanonicalIds: 1,
+		MulticastID:  7,
+		Results:      []Result{{MessageID: "1", CanonicalRegistrationID: "c"}, {Error: ErrorUnavailable}},
+	}
+	current, _ := json.Marshal(expected)
+	for _, data := range []string{
+		string(current),
+		`{"multicast_id":7,"success":1,"failure":1,"canonical_ids":1,"results":[{"message_id":"1","registration_id":"c"},{"error":"Unavailable"}]}`,
+		`{"MulticastID":7,"Success":1,"Failure":1,"CanonicalIds":1,"Results":[{"MessageID":"1","RegistrationID":"c"},{"Error":"Unavailable"}]}`,
+	} {
+		result, err := ReadMulticastResult([]byte(data))
+		assert.NoError(t, err, data)
+		assert.Equal(t, expected, result, data)
+	}
+
+	b, _ := MarshalMulticastResult(expected)
+	result, err := ReadMulticastResult(b)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	_, err = ReadMulticastResult([]byte(`{"results":{}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ResultSchemaVersion is the version of the schema written by MarshalResult
+// and MarshalMulticastResult.
+const ResultSchemaVersion = 1
+
+// ErrMissingSchemaVersion is returned when unmarshaling a result that was not
+// marshaled by MarshalResult or MarshalMulticastResult.
+var ErrMissingSchemaVersion = errors.New("missing result schema version")
+
+// ResultV1 is version 1 of the persisted Result schema.  Unlike Result, its
+// fields never change, so that results persisted as JSON stay readable as
+// fields are added to Result.
+type ResultV1 struct {
+	MessageID                string   `json:"message_id,omitempty"`
+	CanonicalRegistrationID  string   `json:"canonical_registration_id,omitempty"`
+	Error                    string   `json:"error,omitempty"`
+	Success                  int      `json:"success,omitempty"`
+	Failure                  int      `json:"failure,omitempty"`
+	FailedRegistrationIDs    []string `json:"failed_registration_ids,omitempty"`
+	RecoveredRegistrationIDs []string `json:"recovered_registration_ids,omitempty"`
+	TraceID                  string   `json:"trace_id,omitempty"`
+	UUID                     string   `json:"uuid,omitempty"`
+}
+
+// MulticastResultV1 is version 1 of the persisted MulticastResult schema.
+type MulticastResultV1 struct {
+	Success           int        `json:"success"`
+	Failure           int        `json:"failure"`
+	CanonicalIDs      int        `json:"canonical_ids"`
+	MulticastID       int64      `json:"multicast_id"`
+	Results           []ResultV1 `json:"results,omitempty"`
+	RetryMulticastIDs []int64    `json:"retry_multicast_ids,omitempty"`
+	BatchMulticastIDs []int64    `json:"batch_multicast_ids,omitempty"`
+	TraceID           string     `json:"trace_id,omitempty"`
+	UUID              string     `json:"uuid,omitempty"`
+}
+
+// NewResultV1 converts r to version 1 of the schema.
+func NewResultV1(r *Result) *ResultV1 {
+	return &ResultV1{
+		MessageID:                r.MessageID,
+		CanonicalRegistrationID:  r.CanonicalRegistrationID,
+		Error:                    r.Error,
+		Success:                  r.Success,
+		Failure:                  r.Failure,
+		FailedRegistrationIDs:    r.FailedRegistrationIDs,
+		RecoveredRegistrationIDs: r.RecoveredRegistrationIDs,
+		TraceID:                  r.TraceID,
+		UUID:                     r.UUID,
+	}
+}
+
+// Result converts r to the current Result.
+func (r *ResultV1) Result() *Result {
+	return &Result{
+		MessageID:                r.MessageID,
+		CanonicalRegistrationID:  r.CanonicalRegistrationID,
+		Error:                    r.Error,
+		Success:                  r.Success,
+		Failure:                  r.Failure,
+		FailedRegistrationIDs:    r.FailedRegistrationIDs,
+		RecoveredRegistrationIDs: r.RecoveredRegistrationIDs,
+		TraceID:                  r.TraceID,
+		UUID:                     r.UUID,
+	}
+}
+
+// NewMulticastResultV1 converts r to version 1 of the schema.
+func NewMulticastResultV1(r *MulticastResult) *MulticastResultV1 {
+	v1 := &MulticastResultV1{
+		Success:           r.Success,
+		Failure:           r.Failure,
+		CanonicalIDs:      r.CanonicalIds,
+		MulticastID:       r.MulticastID,
+		RetryMulticastIDs: r.RetryMulticastIDs,
+		BatchMulticastIDs: r.BatchMulticastIDs,
+		TraceID:           r.TraceID,
+		UUID:              r.UUID,
+	}
+	for i := range r.Results {
+		v1.Results = append(v1.Results, *NewResultV1(&r.Results[i]))
+	}
+	return v1
+}
+
+// MulticastResult converts r to the current MulticastResult.
+func (r *MulticastResultV1) MulticastResult() *MulticastResult {
+	result := &MulticastResult{
+		Success:           r.Success,
+		Failure:           r.Failure,
+		CanonicalIds:      r.CanonicalIDs,
+		MulticastID:       r.MulticastID,
+		RetryMulticastIDs: r.RetryMulticastIDs,
+		BatchMulticastIDs: r.BatchMulticastIDs,
+		TraceID:           r.TraceID,
+		UUID:              r.UUID,
+	}
+	for i := range r.Results {
+		result.Results = append(result.Results, *r.Results[i].Result())
+	}
+	return result
+}
+
+// MarshalResult marshals r to JSON in the current schema, tagged with its
+// version, for persisting.
+func MarshalResult(r *Result) ([]byte, error) {
+	return json.Marshal(struct {
+		Version int `json:"version"`
+		*ResultV1
+	}{ResultSchemaVersion, NewResultV1(r)})
+}
+
+// UnmarshalResult unmarshals a result marshaled by MarshalResult with any
+// schema version.
+func UnmarshalResult(data []byte) (*Result, error) {
+	if err := checkSchemaVersion(data); err != nil {
+		return nil, err
+	}
+	v1 := &ResultV1{}
+	if err := json.Unmarshal(data, v1); err != nil {
+		return nil, err
+	}
+	return v1.Result(), nil
+}
+
+// MarshalMulticastResult marshals r to JSON in the current schema, tagged
+// with its version, for persisting.
+func MarshalMulticastResult(r *MulticastResult) ([]byte, error) {
+	return json.Marshal(struct {
+		Version int `json:"version"`
+		*MulticastResultV1
+	}{ResultSchemaVersion, NewMulticastResultV1(r)})
+}
+
+// UnmarshalMulticastResult unmarshals a result marshaled by
+// MarshalMulticastResult with any schema version.
+func UnmarshalMulticastResult(data []byte) (*MulticastResult, error) {
+	if err := checkSchemaVersion(data); err != nil {
+		return nil, err
+	}
+	v1 := &MulticastResultV1{}
+	if err := json.Unmarshal(data, v1); err != nil {
+		return nil, err
+	}
+	return v1.MulticastResult(), nil
+}
+
+// checkSchemaVersion checks that data has a schema version that can be read.
+func checkSchemaVersion(data []byte) error {
+	var v struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case v.Version == nil:
+		return ErrMissingSchemaVersion
+	case *v.Version != 1:
+		return fmt.Errorf("unsupported result schema version %d", *v.Version)
+	}
+	return nil
+}
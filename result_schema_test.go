@@ -0,0 +1,44 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalResult(t *testing.T) {
+	r := &Result{MessageID: "1", CanonicalRegistrationID: "c", TraceID: "t"}
+	b, err := MarshalResult(r)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":1,"message_id":"1","canonical_registration_id":"c","trace_id":"t"}`, string(b))
+	decoded, err := UnmarshalResult(b)
+	assert.NoError(t, err)
+	assert.Equal(t, r, decoded)
+
+	_, err = UnmarshalResult([]byte(`{"message_id":"1"}`))
+	assert.Equal(t, ErrMissingSchemaVersion, err)
+	_, err = UnmarshalResult([]byte(`{"version":2,"message_id":"1"}`))
+	assert.EqualError(t, err, "unsupported result schema version 2")
+	_, err = UnmarshalResult([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestMarshalMulticastResult(t *testing.T) {
+	r := &MulticastResult{
+		Success:     1,
+		Failure:     1,
+		MulticastID: 7,
+		Results:     []Result{{MessageID: "1"}, {Error: ErrorUnavailable}},
+		UUID:        "u",
+	}
+	b, err := MarshalMulticastResult(r)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":1,"success":1,"failure":1,"canonical_ids":0,"multicast_id":7,"results":[{"message_id":"1"},{"error":"Unavailable"}],"uuid":"u"}`, string(b))
+	decoded, err := UnmarshalMulticastResult(b)
+	assert.NoError(t, err)
+	assert.Equal(t, r, decoded)
+	assert.Equal(t, r, NewMulticastResultV1(r).MulticastResult())
+
+	_, err = UnmarshalMulticastResult([]byte(`{"success":1}`))
+	assert.Equal(t, ErrMissingSchemaVersion, err)
+}
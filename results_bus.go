@@ -0,0 +1,121 @@
+package gcm
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ResultEvent is the outcome of a job published on a ResultBus.
+type ResultEvent struct {
+	// App names the app the job was sent for, if known.
+	App string
+	*JobResult
+}
+
+// ResultFilter selects the events a Subscription receives.  Empty fields match
+// any event.
+type ResultFilter struct {
+	// App selects the events of the named app.
+	App string
+	// Topic selects the events of jobs sent to the topic, with or without
+	// TopicPrefix.
+	Topic string
+	// Tags selects the events of jobs whose message has all the tags.
+	Tags map[string]string
+}
+
+func (f ResultFilter) match(ev *ResultEvent) bool {
+	if f.App != "" && f.App != ev.App {
+		return false
+	}
+	if f.Topic == "" && len(f.Tags) == 0 {
+		return true
+	}
+	if ev.JobResult == nil || ev.Job == nil {
+		return false
+	}
+	if f.Topic != "" {
+		topic := f.Topic
+		if !strings.HasPrefix(topic, TopicPrefix) {
+			topic = TopicPrefix + topic
+		}
+		if ev.Job.To != topic {
+			return false
+		}
+	}
+	for k, v := range f.Tags {
+		if ev.Job.Message == nil || ev.Job.Message.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ResultBus fans out the outcomes of jobs to subscribers, decoupling consumers
+// such as analytics, token hygiene and alerting from the dispatch loop.
+// Publishing never blocks: events are dropped for subscribers that fall behind.
+//
+// ResultBus is safe for concurrent use.
+type ResultBus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the events of a ResultBus that match its filter.
+type Subscription struct {
+	// C delivers the events.  It is closed by Close.
+	C <-chan *ResultEvent
+
+	c       chan *ResultEvent
+	filter  ResultFilter
+	bus     *ResultBus
+	dropped int64
+}
+
+// Subscribe subscribes to the events matching filter, buffering up to buffer
+// of them.
+func (b *ResultBus) Subscribe(filter ResultFilter, buffer int) *Subscription {
+	c := make(chan *ResultEvent, buffer)
+	sub := &Subscription{C: c, c: c, filter: filter, bus: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[*Subscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish publishes the outcome of a job sent for app.
+func (b *ResultBus) Publish(app string, jr *JobResult) {
+	ev := &ResultEvent{App: app, JobResult: jr}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if !sub.filter.match(ev) {
+			continue
+		}
+		select {
+		case sub.c <- ev:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer of the
+// Subscription was full.
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,56 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultBus(t *testing.T) {
+	var bus ResultBus
+	all := bus.Subscribe(ResultFilter{}, 10)
+	news := bus.Subscribe(ResultFilter{App: "a", Topic: "news"}, 1)
+	defer all.Close()
+
+	topicJob := &JobResult{Job: &Job{To: "/topics/news"}}
+	bus.Publish("a", topicJob)
+	bus.Publish("b", topicJob)
+	bus.Publish("a", &JobResult{Job: &Job{To: "regId"}})
+	bus.Publish("a", topicJob)
+
+	assert.Len(t, all.C, 4)
+	assert.Len(t, news.C, 1)
+	assert.Equal(t, int64(1), news.Dropped())
+	ev := <-news.C
+	assert.Equal(t, "a", ev.App)
+	assert.Equal(t, topicJob, ev.JobResult)
+
+	news.Close()
+	news.Close()
+	_, ok := <-news.C
+	assert.False(t, ok)
+	bus.Publish("a", topicJob)
+	assert.Len(t, all.C, 5)
+
+	campaign := bus.Subscribe(ResultFilter{Tags: map[string]string{"campaign": "c"}}, 1)
+	bus.Publish("a", topicJob)
+	tagged := &JobResult{Job: &Job{Message: &Message{Tags: map[string]string{"campaign": "c"}}, To: "regId"}}
+	bus.Publish("a", tagged)
+	assert.Equal(t, tagged, (<-campaign.C).JobResult)
+	campaign.Close()
+	assert.Len(t, all.C, 7)
+}
+
+func TestDispatcherPublishesResults(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "regId"}))
+	q.Close()
+	var bus ResultBus
+	sub := bus.Subscribe(ResultFilter{App: "app"}, 1)
+	(&Dispatcher{Queue: q, Sender: NewSender("test-api-key"), Results: &bus, App: "app"}).Run()
+	ev := <-sub.C
+	assert.NoError(t, ev.Err)
+	assert.Equal(t, "id", ev.Result.MessageID)
+}
@@ -0,0 +1,142 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyTransport answers requests to the connection server with a random
+// sequence of HTTP errors and per recipient errors drawn from a seeded source.
+type flakyTransport struct {
+	rand     *rand.Rand
+	requests int
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var msg message
+	json.NewDecoder(req.Body).Decode(&msg)
+	req.Body.Close()
+	t.requests++
+	reply := func(status int, body []byte) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			Header:     make(http.Header),
+			Request:    req,
+		}, nil
+	}
+	switch p := t.rand.Float64(); {
+	case p < 0.15:
+		return reply(http.StatusServiceUnavailable, nil)
+	case p < 0.2:
+		return reply(http.StatusInternalServerError, nil)
+	case p < 0.22:
+		return reply(http.StatusBadRequest, nil)
+	}
+	recipients := msg.registrationIds
+	if len(recipients) == 0 {
+		recipients = []string{msg.to}
+	}
+	resp := response{MulticastID: t.rand.Int63n(1000) + 1}
+	for range recipients {
+		var res result
+		switch p := t.rand.Float64(); {
+		case p < 0.3:
+			res.Err = ErrorUnavailable
+		case p < 0.35:
+			res.Err = ErrorInternalServerError
+		case p < 0.45:
+			res.Err = ErrorNotRegistered
+		default:
+			res.MessageID = fmt.Sprintf("m%d", t.rand.Int())
+		}
+		if res.Err != "" {
+			resp.Failure++
+		} else {
+			resp.Success++
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	body, _ := json.Marshal(resp)
+	return reply(http.StatusOK, body)
+}
+
+// sleepBudget is the most a Sender may sleep between the attempts of a send
+// with retries.
+func sleepBudget(retries int) time.Duration {
+	var budget time.Duration
+	backoff := BackoffInitialDelay
+	for i := 0; i < retries; i++ {
+		budget += time.Duration(backoff*3/2) * time.Millisecond
+		backoff = min(2*backoff, MaxBackoffDelay)
+	}
+	return budget
+}
+
+func newFlakySender(seed int64) (*Sender, *flakyTransport, *fakeTime) {
+	transport := &flakyTransport{rand: rand.New(rand.NewSource(seed))}
+	s := NewSenderWithHTTPClient("test-api-key", &http.Client{Transport: transport}).WithRandSource(rand.NewSource(seed))
+	clock := &fakeTime{now: time.Unix(0, 0)}
+	s.Time = clock
+	return s, transport, clock
+}
+
+func TestMulticastRetryInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 300; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		retries, tokens := r.Intn(6), make([]string, r.Intn(20)+1)
+		for i := range tokens {
+			tokens[i] = fmt.Sprintf("token%d", i)
+		}
+		s, transport, clock := newFlakySender(seed)
+		result, err := s.SendMulticastWithRetries(msg, tokens, retries)
+
+		ctx := fmt.Sprintf("seed %d, %d retries, %d tokens", seed, retries, len(tokens))
+		assert.True(t, transport.requests <= retries+1, fmt.Sprintf("%s: %d attempts", ctx, transport.requests))
+		slept := clock.Now().Sub(time.Unix(0, 0))
+		assert.True(t, slept <= sleepBudget(retries), fmt.Sprintf("%s: slept %v", ctx, slept))
+		if err != nil {
+			assert.Nil(t, result, ctx)
+			continue
+		}
+		// every token gets exactly one final status
+		if assert.Len(t, result.Results, len(tokens), ctx) {
+			success := 0
+			for i, res := range result.Results {
+				assert.True(t, (res.MessageID != "") != (res.Error != ""), fmt.Sprintf("%s: token %d has result %+v", ctx, i, res))
+				if res.MessageID != "" {
+					success++
+				}
+			}
+			assert.Equal(t, success, result.Success, ctx)
+			assert.Equal(t, len(tokens), result.Success+result.Failure, ctx)
+		}
+	}
+}
+
+func TestRetryInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 300; seed++ {
+		retries := rand.New(rand.NewSource(seed)).Intn(6)
+		s, transport, clock := newFlakySender(seed)
+		result, err := s.SendWithRetries(msg, "token", retries)
+
+		ctx := fmt.Sprintf("seed %d, %d retries", seed, retries)
+		assert.True(t, transport.requests <= retries+1, fmt.Sprintf("%s: %d attempts", ctx, transport.requests))
+		slept := clock.Now().Sub(time.Unix(0, 0))
+		assert.True(t, slept <= sleepBudget(retries), fmt.Sprintf("%s: slept %v", ctx, slept))
+		if err != nil {
+			assert.Nil(t, result, ctx)
+		} else {
+			assert.True(t, (result.MessageID != "") != (result.Error != ""), fmt.Sprintf("%s: result %+v", ctx, result))
+		}
+	}
+}
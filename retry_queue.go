@@ -0,0 +1,145 @@
+package gcm
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RetryQueue delivers a payload back to the application after a delay, e.g.
+// through Cloud Tasks or SQS, so that a DelayedRetrier resumes a send instead
+// of sleeping in-process until the next retry.
+type RetryQueue interface {
+	// Enqueue enqueues payload for delivery after delay.
+	Enqueue(payload []byte, delay time.Duration) error
+}
+
+// SQSClient sends messages to an SQS queue.  It lets SQSRetryQueue work with
+// any AWS SDK; e.g. with aws-sdk-go-v2:
+//
+//	gcm.SQSClientFunc(func(body string, delaySeconds int32) error {
+//		_, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+//			QueueUrl:     aws.String(queueURL),
+//			MessageBody:  aws.String(body),
+//			DelaySeconds: delaySeconds,
+//		})
+//		return err
+//	})
+type SQSClient interface {
+	SendMessage(body string, delaySeconds int32) error
+}
+
+// SQSClientFunc is a func that implements SQSClient.
+type SQSClientFunc func(body string, delaySeconds int32) error
+
+// SendMessage calls f(body, delaySeconds).
+func (f SQSClientFunc) SendMessage(body string, delaySeconds int32) error {
+	return f(body, delaySeconds)
+}
+
+// sqsMaxDelay is the max delay of an SQS message.
+const sqsMaxDelay = 15 * time.Minute
+
+// SQSRetryQueue is a RetryQueue on SQS.  Delays above the 15 minutes SQS
+// allows are cut short, and the DelayedRetrier enqueues the retry again for
+// the rest of the delay when the message is received early.
+type SQSRetryQueue struct {
+	Client SQSClient
+}
+
+// Enqueue sends payload to the queue with the delay rounded up to seconds.
+func (q *SQSRetryQueue) Enqueue(payload []byte, delay time.Duration) error {
+	if delay > sqsMaxDelay {
+		delay = sqsMaxDelay
+	}
+	return q.Client.SendMessage(string(payload), int32((delay+time.Second-1)/time.Second))
+}
+
+// CloudTasksClient creates Cloud Tasks tasks.  It lets CloudTasksRetryQueue
+// work with any client library; e.g. with cloud.google.com/go/cloudtasks:
+//
+//	gcm.CloudTasksClientFunc(func(body []byte, at time.Time) error {
+//		_, err := client.CreateTask(ctx, &taskspb.CreateTaskRequest{
+//			Parent: queuePath,
+//			Task: &taskspb.Task{
+//				MessageType: &taskspb.Task_HttpRequest{HttpRequest: &taskspb.HttpRequest{
+//					Url:  handlerURL,
+//					Body: body,
+//				}},
+//				ScheduleTime: timestamppb.New(at),
+//			},
+//		})
+//		return err
+//	})
+type CloudTasksClient interface {
+	CreateTask(body []byte, scheduleTime time.Time) error
+}
+
+// CloudTasksClientFunc is a func that implements CloudTasksClient.
+type CloudTasksClientFunc func(body []byte, scheduleTime time.Time) error
+
+// CreateTask calls f(body, scheduleTime).
+func (f CloudTasksClientFunc) CreateTask(body []byte, scheduleTime time.Time) error {
+	return f(body, scheduleTime)
+}
+
+// CloudTasksRetryQueue is a RetryQueue on a Cloud Tasks queue, whose handler
+// passes the task body to DelayedRetrier.Handle.
+type CloudTasksRetryQueue struct {
+	Client CloudTasksClient
+	// Time tells the time tasks are scheduled from.  Nil means SystemTime.
+	Time TimeSource
+}
+
+// Enqueue creates a task with payload as body scheduled after delay.
+func (q *CloudTasksRetryQueue) Enqueue(payload []byte, delay time.Duration) error {
+	clock := q.Time
+	if clock == nil {
+		clock = SystemTime
+	}
+	return q.Client.CreateTask(payload, clock.Now().Add(delay))
+}
+
+// DelayedRetrier sends downstream messages with retries, enqueueing each retry
+// onto a RetryQueue with the backoff of the Sender, instead of keeping a
+// goroutine asleep until then.  The application passes the payloads delivered
+// by the queue to Handle.
+type DelayedRetrier struct {
+	Sender *Sender
+	Queue  RetryQueue
+	// OnDone, if set, is called with the outcome of the last attempt once the
+	// message was sent, failed permanently or ran out of retries.
+	OnDone func(state *RetryState, result *Result, err error)
+}
+
+// Send makes the first attempt to send msg to to, enqueueing the retries.
+func (r *DelayedRetrier) Send(msg *Message, to string, retries int) error {
+	return r.resume(NewRetryState(msg, to, retries))
+}
+
+// Handle resumes the send of a payload delivered by the queue.  An error means
+// the payload was not handled and should be delivered again.
+func (r *DelayedRetrier) Handle(payload []byte) error {
+	state := &RetryState{}
+	if err := json.Unmarshal(payload, state); err != nil {
+		return err
+	}
+	return r.resume(state)
+}
+
+func (r *DelayedRetrier) resume(state *RetryState) error {
+	result, err := r.Sender.Attempt(state)
+	if err == ErrRetryDone {
+		return nil
+	}
+	if state.Done {
+		if r.OnDone != nil {
+			protect(r.Sender.PanicPolicy, "OnDone", func() { r.OnDone(state, result, err) })
+		}
+		return nil
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.Queue.Enqueue(payload, state.NextAttemptAt.Sub(r.Sender.clock().Now()))
+}
@@ -0,0 +1,78 @@
+package gcm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type enqueued struct {
+	payload []byte
+	delay   time.Duration
+}
+
+func TestDelayedRetrier(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &fail},
+		&testResponse{response: &fail},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	clock := &fakeTime{now: time.Unix(0, 0)}
+	s := NewSender("test-api-key")
+	s.Time = clock
+	var queue []enqueued
+	var done []*Result
+	r := &DelayedRetrier{
+		Sender: s,
+		Queue: &SQSRetryQueue{Client: SQSClientFunc(func(body string, delaySeconds int32) error {
+			queue = append(queue, enqueued{[]byte(body), time.Duration(delaySeconds) * time.Second})
+			return nil
+		})},
+		OnDone: func(state *RetryState, result *Result, err error) {
+			assert.NoError(t, err)
+			done = append(done, result)
+		},
+	}
+
+	assert.NoError(t, r.Send(msg, "regId", 2))
+	assert.Len(t, queue, 1)
+	assert.True(t, queue[0].delay >= time.Second && queue[0].delay <= 2*time.Second, queue[0].delay.String())
+
+	// delivered early, the retry is enqueued again
+	assert.NoError(t, r.Handle(queue[0].payload))
+	assert.Len(t, queue, 2)
+	assert.Empty(t, done)
+
+	for len(done) == 0 {
+		next := queue[len(queue)-1]
+		clock.Set(clock.Now().Add(next.delay))
+		assert.NoError(t, r.Handle(next.payload))
+	}
+	assert.Len(t, queue, 3)
+	assert.Equal(t, "id", done[0].MessageID)
+
+	assert.Error(t, r.Handle([]byte(`{`)))
+}
+
+func TestRetryQueues(t *testing.T) {
+	var delays []int32
+	sqs := &SQSRetryQueue{Client: SQSClientFunc(func(body string, delaySeconds int32) error {
+		delays = append(delays, delaySeconds)
+		return nil
+	})}
+	assert.NoError(t, sqs.Enqueue(nil, 1500*time.Millisecond))
+	assert.NoError(t, sqs.Enqueue(nil, time.Hour))
+	assert.Equal(t, []int32{2, 900}, delays)
+
+	clock := &fakeTime{now: time.Unix(100, 0)}
+	var scheduled []time.Time
+	tasks := &CloudTasksRetryQueue{Time: clock, Client: CloudTasksClientFunc(func(body []byte, at time.Time) error {
+		scheduled = append(scheduled, at)
+		return errors.New("unavailable")
+	})}
+	assert.EqualError(t, tasks.Enqueue(nil, time.Hour), "unavailable")
+	assert.Equal(t, []time.Time{time.Unix(3700, 0)}, scheduled)
+}
@@ -0,0 +1,91 @@
+package gcm
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRetryNotDue is returned by Sender.Attempt when called before the next
+// attempt of a RetryState is due.
+var ErrRetryNotDue = errors.New("retry not due")
+
+// ErrRetryDone is returned by Sender.Attempt when called with a RetryState
+// that is done.
+var ErrRetryDone = errors.New("retry state is done")
+
+// RetryState is the state of sending a downstream message with retries, for
+// external job schedulers, e.g. cron or Cloud Tasks, that own the timing of the
+// attempts, while Sender.Attempt owns sending and classifying the outcome.  It
+// marshals to JSON to be persisted between attempts.
+type RetryState struct {
+	Message *Message `json:"message"`
+	To      string   `json:"to"`
+	Retries int      `json:"retries"`
+	// Attempts is the number of attempts made so far.
+	Attempts int `json:"attempts"`
+	// Backoff and TopicBackoff are the backoffs in milliseconds to the attempt
+	// after the next one.
+	Backoff      int `json:"backoff"`
+	TopicBackoff int `json:"topic_backoff,omitempty"`
+	// NextAttemptAt is when the next attempt is due, if not Done.
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// LastError is the error or error code of the last attempt, if any.
+	LastError string `json:"last_error,omitempty"`
+	// Done reports whether the message was sent, failed permanently or ran out
+	// of retries.
+	Done bool `json:"done"`
+}
+
+// NewRetryState returns the state of sending msg to to with retries, due
+// right away.  TopicBackoff is set by the first Sender.Attempt.
+func NewRetryState(msg *Message, to string, retries int) *RetryState {
+	return &RetryState{Message: msg, To: to, Retries: retries, Backoff: BackoffInitialDelay, TopicBackoff: -1}
+}
+
+// Attempt makes the next attempt to send the message of state, if due, and
+// updates state with its outcome.  It retries the same errors as
+// SendWithRetries, except for device group members, and returns the result and
+// error of the attempt.  The caller schedules the next attempt at
+// state.NextAttemptAt unless state.Done.
+func (s *Sender) Attempt(state *RetryState) (*Result, error) {
+	if state.Done {
+		return nil, ErrRetryDone
+	}
+	now := s.clock().Now()
+	if now.Before(state.NextAttemptAt) {
+		return nil, ErrRetryNotDue
+	}
+	if state.TopicBackoff < 0 {
+		state.TopicBackoff = s.policy().TopicRateBackoff
+	}
+	if err := checkUnrecoverableErrors(s, state.To, nil, state.Message, state.Retries); err != nil {
+		state.Done, state.LastError = true, err.Error()
+		return nil, err
+	}
+
+	state.Attempts++
+	result, err := s.SendNoRetry(state.Message, state.To)
+	state.LastError = ""
+	if err != nil {
+		state.LastError = err.Error()
+	} else if result.Error != "" {
+		state.LastError = result.Error
+	}
+	var delay time.Duration
+	switch {
+	case state.Attempts > state.Retries:
+		state.Done = true
+	case result != nil && (result.Error == ErrorUnavailable || result.Error == ErrorInternalServerError), isRecoverable(err):
+		delay = s.jitter(state.Backoff)
+		state.Backoff = min(2*state.Backoff, MaxBackoffDelay)
+	case result != nil && result.Error == ErrorTopicsMessageRateExceeded && state.TopicBackoff > 0:
+		delay = s.jitter(state.TopicBackoff)
+		state.TopicBackoff = min(2*state.TopicBackoff, MaxTopicRateBackoffDelay)
+	default:
+		state.Done = true
+	}
+	if !state.Done {
+		state.NextAttemptAt = now.Add(delay)
+	}
+	return result, err
+}
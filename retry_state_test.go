@@ -0,0 +1,77 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttempt(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &fail},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	clock := &fakeTime{now: time.Unix(0, 0)}
+	s := NewSender("test-api-key")
+	s.Time = clock
+
+	state := NewRetryState(msg, "regId", 2)
+	result, err := s.Attempt(state)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorUnavailable, result.Error)
+	assert.False(t, state.Done)
+	assert.Equal(t, 1, state.Attempts)
+	assert.Equal(t, ErrorUnavailable, state.LastError)
+	assert.Equal(t, 2*BackoffInitialDelay, state.Backoff)
+	delay := state.NextAttemptAt.Sub(clock.Now())
+	assert.True(t, delay >= BackoffInitialDelay/2*time.Millisecond && delay < BackoffInitialDelay*3/2*time.Millisecond, delay.String())
+
+	_, err = s.Attempt(state)
+	assert.Equal(t, ErrRetryNotDue, err)
+
+	// the state survives being persisted between attempts
+	b, err := json.Marshal(state)
+	assert.NoError(t, err)
+	state = &RetryState{}
+	assert.NoError(t, json.Unmarshal(b, state))
+
+	clock.Set(state.NextAttemptAt)
+	_, err = s.Attempt(state)
+	assert.Error(t, err)
+	assert.False(t, state.Done)
+	assert.Equal(t, "503 error: 503 Service Unavailable", state.LastError)
+
+	clock.Set(state.NextAttemptAt)
+	result, err = s.Attempt(state)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", result.MessageID)
+	assert.True(t, state.Done)
+	assert.Equal(t, 3, state.Attempts)
+	assert.Equal(t, "", state.LastError)
+
+	_, err = s.Attempt(state)
+	assert.Equal(t, ErrRetryDone, err)
+}
+
+func TestAttemptExhausted(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &fail})
+	defer server.Close()
+	s := NewSender("test-api-key")
+
+	state := NewRetryState(msg, "regId", 0)
+	result, err := s.Attempt(state)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorUnavailable, result.Error)
+	assert.True(t, state.Done)
+
+	state = NewRetryState(nil, "regId", 1)
+	_, err = s.Attempt(state)
+	assert.EqualError(t, err, "message cannot be nil")
+	assert.True(t, state.Done)
+	assert.Equal(t, 0, state.Attempts)
+}
@@ -0,0 +1,67 @@
+package gcm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AppRouter picks the Sender of the app a message is for, so that a single
+// service can send messages for several apps belonging to different projects.
+//
+// AppRouter is safe for concurrent use as long as its fields are not modified
+// once it is in use.
+type AppRouter struct {
+	// Senders maps app IDs, usually package names, to their Senders.
+	Senders map[string]*Sender
+	// Default, if set, sends the messages for apps not in Senders.
+	Default *Sender
+}
+
+// UnknownAppError is returned when an AppRouter has no Sender for an app.
+type UnknownAppError struct {
+	App string
+}
+
+func (e *UnknownAppError) Error() string {
+	return fmt.Sprintf("no sender for app %q", e.App)
+}
+
+// Sender returns the Sender for the app ID.
+func (r *AppRouter) Sender(app string) (*Sender, error) {
+	if s, ok := r.Senders[app]; ok {
+		return s, nil
+	}
+	if r.Default != nil {
+		return r.Default, nil
+	}
+	return nil, &UnknownAppError{app}
+}
+
+// Route returns the Sender for the app the message is restricted to by its
+// RestrictedPackageName.
+func (r *AppRouter) Route(msg *Message) (*Sender, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	return r.Sender(msg.RestrictedPackageName)
+}
+
+// SendWithRetries sends a downstream message with retries with the Sender of
+// the app the message is restricted to.
+func (r *AppRouter) SendWithRetries(msg *Message, to string, retries int) (*Result, error) {
+	s, err := r.Route(msg)
+	if err != nil {
+		return nil, err
+	}
+	return s.SendWithRetries(msg, to, retries)
+}
+
+// SendMulticastWithRetries sends a multicast message with retries with the
+// Sender of the app the message is restricted to.
+func (r *AppRouter) SendMulticastWithRetries(msg *Message, regIDs []string, retries int) (*MulticastResult, error) {
+	s, err := r.Route(msg)
+	if err != nil {
+		return nil, err
+	}
+	return s.SendMulticastWithRetries(msg, regIDs, retries)
+}
@@ -0,0 +1,44 @@
+package gcm
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppRouter(t *testing.T) {
+	var keys []string
+	server := startTestServer(t, &testResponse{response: &success}, &testResponse{response: &success})
+	defer server.Close()
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		keys = append(keys, req.Header.Get("Authorization"))
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	r := &AppRouter{Senders: map[string]*Sender{
+		"com.example.a": NewSenderWithHTTPClient("key-a", client),
+		"com.example.b": NewSenderWithHTTPClient("key-b", client),
+	}}
+
+	_, err := r.SendWithRetries(&Message{RestrictedPackageName: "com.example.b"}, "regId", 0)
+	assert.NoError(t, err)
+	_, err = r.SendMulticastWithRetries(&Message{RestrictedPackageName: "com.example.a"}, []string{"regId"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key=key-b", "key=key-a"}, keys)
+
+	_, err = r.SendWithRetries(&Message{RestrictedPackageName: "com.example.c"}, "regId", 0)
+	assert.EqualError(t, err, `no sender for app "com.example.c"`)
+	_, err = r.Route(nil)
+	assert.EqualError(t, err, "message cannot be nil")
+
+	r.Default = r.Senders["com.example.a"]
+	s, err := r.Sender("com.example.c")
+	assert.NoError(t, err)
+	assert.Equal(t, r.Default, s)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
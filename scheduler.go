@@ -0,0 +1,277 @@
+package gcm
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultSchedulerMaxSleep bounds how long a Scheduler sleeps before reading
+// the clock again.
+const defaultSchedulerMaxSleep = time.Minute
+
+// TimeSource tells the time to a Scheduler or a Sender.  Tests can substitute a
+// fake clock.
+type TimeSource interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemTime struct{}
+
+func (systemTime) Now() time.Time                         { return time.Now() }
+func (systemTime) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemTime is the TimeSource of the system clock.
+var SystemTime TimeSource = systemTime{}
+
+// ScheduledJob is a job waiting in a Scheduler.
+type ScheduledJob struct {
+	ID  int64
+	Job *Job
+	// At is the instant, in UTC, the job is due.
+	At time.Time
+	// Local is the wall clock reading, in UTC, the job was scheduled for
+	// with ScheduleLocal, or the zero time.
+	Local time.Time
+	// Location is the location of Local.
+	Location *time.Location
+}
+
+// Scheduler holds jobs until they are due and then adds them to a Queue.
+//
+// Jobs are scheduled for absolute instants, kept in UTC, so that they are not
+// affected by the time zone of the host.  Jobs scheduled for a local wall
+// clock time have their instant recomputed in their location on every pass,
+// so that they follow DST transitions.  The clock is read again at least every
+// MaxSleep, so that jumps of the system clock, e.g. NTP corrections, delay or
+// release jobs accordingly instead of leaving them to an outdated timer.
+//
+// Scheduler is safe for concurrent use.
+type Scheduler struct {
+	// Queue receives the jobs when due.
+	Queue *Queue
+	// Time is the clock of the Scheduler.  Nil means SystemTime.
+	Time TimeSource
+	// MaxSleep bounds how long Run sleeps before reading the clock again.
+	// Zero means a minute.
+	MaxSleep time.Duration
+	// Tolerance is how early a job may be released, so that a clock slightly
+	// behind the timers does not cost another sleep.
+	Tolerance time.Duration
+
+	once   sync.Once
+	mu     sync.Mutex
+	jobs   []*ScheduledJob // ordered by At
+	nextID int64
+	wake   chan struct{}
+}
+
+func (s *Scheduler) init() {
+	s.once.Do(func() {
+		s.wake = make(chan struct{}, 1)
+	})
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Time == nil {
+		return SystemTime.Now()
+	}
+	return s.Time.Now()
+}
+
+func (s *Scheduler) after(d time.Duration) <-chan time.Time {
+	if s.Time == nil {
+		return SystemTime.After(d)
+	}
+	return s.Time.After(d)
+}
+
+// ScheduleAt schedules job for the instant at, returning the ID of the
+// scheduled job.
+func (s *Scheduler) ScheduleAt(job *Job, at time.Time) (int64, error) {
+	return s.schedule(&ScheduledJob{Job: job, At: at.UTC()})
+}
+
+// ScheduleLocal schedules job for the wall clock reading of wall, ignoring its
+// location, in loc, e.g. 9am in the time zone of the user, returning the ID
+// of the scheduled job.  A time skipped by a DST transition is moved forward
+// by the length of the transition, and a time occurring twice is due the
+// first time.
+func (s *Scheduler) ScheduleLocal(job *Job, wall time.Time, loc *time.Location) (int64, error) {
+	if loc == nil {
+		return 0, errors.New("location cannot be nil")
+	}
+	y, mo, d := wall.Date()
+	h, mi, sec := wall.Clock()
+	wall = time.Date(y, mo, d, h, mi, sec, wall.Nanosecond(), time.UTC)
+	return s.schedule(&ScheduledJob{Job: job, At: localInstant(wall, loc), Local: wall, Location: loc})
+}
+
+func (s *Scheduler) schedule(sj *ScheduledJob) (int64, error) {
+	if sj.Job == nil {
+		return 0, errors.New("job cannot be nil")
+	}
+	if sj.Job.Message == nil {
+		return 0, errors.New("message cannot be nil")
+	}
+	s.init()
+	s.mu.Lock()
+	s.nextID++
+	sj.ID = s.nextID
+	s.insert(sj)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return sj.ID, nil
+}
+
+// insert adds sj to the jobs, keeping them ordered by At.
+func (s *Scheduler) insert(sj *ScheduledJob) {
+	i := sort.Search(len(s.jobs), func(i int) bool { return s.jobs[i].At.After(sj.At) })
+	s.jobs = append(s.jobs, nil)
+	copy(s.jobs[i+1:], s.jobs[i:])
+	s.jobs[i] = sj
+}
+
+// Cancel removes a scheduled job, returning false if it is unknown or
+// already released.
+func (s *Scheduler) Cancel(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sj := range s.jobs {
+		if sj.ID == id {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns the scheduled jobs, ordered by due instant.
+func (s *Scheduler) Pending() []ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make([]ScheduledJob, len(s.jobs))
+	for i, sj := range s.jobs {
+		pending[i] = *sj
+	}
+	return pending
+}
+
+// Release adds the jobs that are due to the Queue, returning how many were
+// released.  Jobs the Queue refuses stay scheduled.
+func (s *Scheduler) Release() (int, error) {
+	now := s.now()
+	s.mu.Lock()
+	s.recompute()
+	var due []*ScheduledJob
+	for len(s.jobs) > 0 && !s.jobs[0].At.After(now.Add(s.Tolerance)) {
+		due = append(due, s.jobs[0])
+		s.jobs = s.jobs[1:]
+	}
+	s.mu.Unlock()
+
+	for i, sj := range due {
+		if err := s.Queue.Enqueue(sj.Job); err != nil {
+			s.mu.Lock()
+			for _, sj := range due[i:] {
+				s.insert(sj)
+			}
+			s.mu.Unlock()
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// recompute updates the instants of the jobs scheduled for a local time.
+func (s *Scheduler) recompute() {
+	changed := false
+	for _, sj := range s.jobs {
+		if sj.Local.IsZero() {
+			continue
+		}
+		if at := localInstant(sj.Local, sj.Location); !at.Equal(sj.At) {
+			sj.At, changed = at, true
+		}
+	}
+	if changed {
+		sort.SliceStable(s.jobs, func(i, j int) bool { return s.jobs[i].At.Before(s.jobs[j].At) })
+	}
+}
+
+// next returns how long to sleep before the next pass.
+func (s *Scheduler) next() time.Duration {
+	sleep := s.MaxSleep
+	if sleep <= 0 {
+		sleep = defaultSchedulerMaxSleep
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.jobs) > 0 {
+		if wait := s.jobs[0].At.Sub(s.now()); wait < sleep {
+			sleep = wait
+		}
+	}
+	return sleep
+}
+
+// Run releases the jobs as they become due until done is closed.
+func (s *Scheduler) Run(done <-chan struct{}) {
+	s.init()
+	for {
+		if _, err := s.Release(); err != nil {
+			log.Printf("failed to release scheduled jobs: %v", err)
+		}
+		sleep := s.next()
+		if sleep <= 0 {
+			// only the Queue refusing jobs leaves due jobs behind
+			sleep = defaultPollInterval
+		}
+		select {
+		case <-done:
+			return
+		case <-s.wake:
+		case <-s.after(sleep):
+		}
+	}
+}
+
+// localInstant returns the instant, in UTC, of the wall clock reading wall,
+// given in UTC, in loc.  Unlike time.Date, it resolves times skipped by a DST
+// transition forward, and times occurring twice to the first occurrence.
+func localInstant(wall time.Time, loc *time.Location) time.Time {
+	// DST transitions are far more than a day apart
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	if before == after {
+		return wall.Add(-time.Duration(before) * time.Second)
+	}
+	var first time.Time
+	for _, offset := range []int{before, after} {
+		at := wall.Add(-time.Duration(offset) * time.Second)
+		if sameWallClock(at.In(loc), wall) && (first.IsZero() || at.Before(first)) {
+			first = at
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+	// skipped: the smaller offset moves the time forward by the gap
+	if after < before {
+		before = after
+	}
+	return wall.Add(-time.Duration(before) * time.Second)
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	y, mo, d := t.Date()
+	wy, wmo, wd := wall.Date()
+	return y == wy && mo == wmo && d == wd && t.Hour() == wall.Hour() && t.Minute() == wall.Minute() &&
+		t.Second() == wall.Second()
+}
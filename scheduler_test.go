@@ -0,0 +1,139 @@
+package gcm
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTime is a TimeSource whose clock only moves when set or slept on.
+type fakeTime struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeTime) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeTime) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+func (f *fakeTime) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	c := make(chan time.Time, 1)
+	c <- f.now
+	return c
+}
+
+func newYork(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("missing time zone database")
+	}
+	return loc
+}
+
+func TestLocalInstant(t *testing.T) {
+	loc := newYork(t)
+	// regular
+	assert.Equal(t, time.Date(2026, 6, 1, 13, 0, 0, 0, time.UTC), localInstant(time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC), loc))
+	// skipped by the spring transition: 02:30 EST is 03:30 EDT
+	assert.Equal(t, time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC), localInstant(time.Date(2026, 3, 8, 2, 30, 0, 0, time.UTC), loc))
+	// repeated by the fall transition: first occurrence, in EDT
+	assert.Equal(t, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), localInstant(time.Date(2026, 11, 1, 1, 30, 0, 0, time.UTC), loc))
+}
+
+func TestSchedulerRelease(t *testing.T) {
+	clock := &fakeTime{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q := NewQueue(QueueConfig{})
+	s := &Scheduler{Queue: q, Time: clock, Tolerance: time.Second}
+
+	_, err := s.ScheduleAt(nil, clock.Now())
+	assert.NotNil(t, err)
+	_, err = s.ScheduleLocal(&Job{Class: ClassReminder, Message: msg, To: "a"}, clock.Now(), nil)
+	assert.NotNil(t, err)
+
+	later := &Job{Class: ClassReminder, Message: msg, To: "later"}
+	soon := &Job{Class: ClassReminder, Message: msg, To: "soon"}
+	canceled := &Job{Class: ClassReminder, Message: msg, To: "canceled"}
+	_, err = s.ScheduleAt(later, clock.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	_, err = s.ScheduleAt(soon, clock.Now().Add(time.Minute).In(time.FixedZone("UTC+8", 8*3600)))
+	assert.Nil(t, err)
+	id, err := s.ScheduleAt(canceled, clock.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.True(t, s.Cancel(id))
+	assert.False(t, s.Cancel(id))
+
+	pending := s.Pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, soon, pending[0].Job)
+	assert.Equal(t, time.UTC, pending[0].At.Location())
+
+	n, err := s.Release()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	// within the tolerance
+	clock.Set(clock.Now().Add(time.Minute - time.Second))
+	n, err = s.Release()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []*Job{soon}, q.Pending())
+
+	// the clock jumps back: nothing is released again
+	clock.Set(clock.Now().Add(-time.Hour))
+	n, _ = s.Release()
+	assert.Equal(t, 0, n)
+
+	// the clock jumps forward past the due instant
+	clock.Set(clock.Now().Add(3 * time.Hour))
+	n, _ = s.Release()
+	assert.Equal(t, 1, n)
+	assert.Empty(t, s.Pending())
+}
+
+func TestSchedulerReleaseQueueRefuses(t *testing.T) {
+	clock := &fakeTime{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q := NewQueue(QueueConfig{})
+	q.Close()
+	s := &Scheduler{Queue: q, Time: clock}
+	_, err := s.ScheduleAt(&Job{Class: ClassReminder, Message: msg, To: "a"}, clock.Now())
+	assert.Nil(t, err)
+	n, err := s.Release()
+	assert.Equal(t, ErrQueueClosed, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, s.Pending(), 1)
+}
+
+func TestSchedulerRunAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	clock := &fakeTime{now: time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)}
+	q := NewQueue(QueueConfig{})
+	s := &Scheduler{Queue: q, Time: clock, MaxSleep: time.Hour}
+	job := &Job{Class: ClassReminder, Message: msg, To: "a"}
+	_, err := s.ScheduleLocal(job, time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC), loc)
+	assert.Nil(t, err)
+	// 9am EDT, not 9am EST
+	assert.Equal(t, time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC), s.Pending()[0].At)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		j, ok := q.Dequeue()
+		assert.True(t, ok)
+		assert.Equal(t, job, j)
+	}()
+	s.Run(done)
+	assert.False(t, clock.Now().Before(time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,71 @@
+package gcm
+
+// Token is a registration token with metadata about the device it belongs to,
+// which results can be aggregated by.
+type Token struct {
+	RegistrationID string
+	Platform       string
+	AppVersion     string
+	// Labels holds any other metadata.
+	Labels map[string]string
+}
+
+// TokenResult is the result of sending a message to a Token.
+type TokenResult struct {
+	Token  Token
+	Result Result
+}
+
+// SendToTokens sends a message with retries to the tokens, in multicast
+// batches of up to MaxRegistrationIDs, and returns a result per token so that
+// results can be aggregated by the tokens' metadata.  On error, the results
+// cover the batches sent so far.
+func (s *Sender) SendToTokens(msg *Message, tokens []Token, retries int) ([]TokenResult, error) {
+	regIDs := make([]string, len(tokens))
+	for i, token := range tokens {
+		regIDs[i] = token.RegistrationID
+	}
+	results := make([]TokenResult, 0, len(tokens))
+	err := forEachBatch(regIDs, MaxRegistrationIDs, func(batch []string) error {
+		result, err := s.SendMulticastWithRetries(msg, batch, retries)
+		if err != nil {
+			return err
+		}
+		for _, res := range result.Results[:len(batch)] {
+			results = append(results, TokenResult{tokens[len(results)], res})
+		}
+		return nil
+	})
+	return results, err
+}
+
+// SegmentStats counts the outcomes of sends to the tokens of a segment.
+type SegmentStats struct {
+	Success int
+	Failure int
+}
+
+// FailureRate returns the ratio of failed sends.
+func (s SegmentStats) FailureRate() float64 {
+	if s.Success+s.Failure == 0 {
+		return 0
+	}
+	return float64(s.Failure) / float64(s.Success+s.Failure)
+}
+
+// Segment aggregates the results by the segment key returns for each token,
+// e.g. its AppVersion.
+func Segment(results []TokenResult, key func(Token) string) map[string]SegmentStats {
+	segments := make(map[string]SegmentStats)
+	for _, res := range results {
+		k := key(res.Token)
+		stats := segments[k]
+		if res.Result.MessageID != "" {
+			stats.Success++
+		} else {
+			stats.Failure++
+		}
+		segments[k] = stats
+	}
+	return segments
+}
@@ -0,0 +1,33 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendToTokens(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &response{
+		Success: 2,
+		Failure: 1,
+		Results: []result{{MessageID: "id1"}, {Err: ErrorNotRegistered}, {MessageID: "id3"}},
+	}})
+	defer server.Close()
+	tokens := []Token{
+		{RegistrationID: "1", Platform: "android", AppVersion: "2.0"},
+		{RegistrationID: "2", Platform: "android", AppVersion: "1.0"},
+		{RegistrationID: "3", Platform: "ios", AppVersion: "1.0"},
+	}
+	s := NewSender("test-api-key")
+	results, err := s.SendToTokens(msg, tokens, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, tokens[1], results[1].Token)
+		assert.Equal(t, ErrorNotRegistered, results[1].Result.Error)
+	}
+
+	byVersion := Segment(results, func(t Token) string { return t.AppVersion })
+	assert.Equal(t, map[string]SegmentStats{"1.0": {1, 1}, "2.0": {1, 0}}, byVersion)
+	assert.Equal(t, 0.5, byVersion["1.0"].FailureRate())
+	assert.Equal(t, 0.0, SegmentStats{}.FailureRate())
+}
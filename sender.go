@@ -2,15 +2,18 @@ package gcm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,6 +26,13 @@ const (
 	BackoffInitialDelay = 1000
 	// MaxBackoffDelay defines the max backoff period in milliseconds.
 	MaxBackoffDelay = 1024000
+	// TopicRateBackoffInitialDelay defines the suggested initial retry interval
+	// in milliseconds for topic messages rejected with TopicsMessageRateExceeded,
+	// whose quota takes minutes to recover.
+	TopicRateBackoffInitialDelay = 60000
+	// MaxTopicRateBackoffDelay defines the max backoff period in milliseconds
+	// for topic messages rejected with TopicsMessageRateExceeded.
+	MaxTopicRateBackoffDelay = 1800000
 )
 
 // GCMEndpoint by default points to the GCM connection server owned by Google,
@@ -30,11 +40,118 @@ const (
 var GCMEndpoint = ConnectionServerEndpoint
 
 // Sender sends GCM messages to the GCM connection server.
+//
+// Sender is safe for concurrent use by multiple goroutines, as long as its
+// fields are not modified once it is in use.
 type Sender struct {
 	// APIKey specifies the API key.
 	APIKey string
-	// Client is the http client used for transport.  By default it is just http.Client.
+	// Endpoint is the URL of the GCM connection server.  Empty means
+	// GCMEndpoint.
+	Endpoint string
+	// SecondaryAPIKey, if set, is used when the GCM connection server rejects
+	// APIKey with 401, e.g. while keys are being rotated.
+	SecondaryAPIKey string
+	// OnAPIKeyUsed, if set, is called after each request with whether it was
+	// served with SecondaryAPIKey.
+	OnAPIKeyUsed func(secondary bool)
+	// Client is the http client used for transport.  If nil, http.DefaultClient is used.
 	Client *http.Client
+	// DeviceGroupRetry decides whether SendWithRetries retries the members of
+	// a device group that failed to receive a message.
+	DeviceGroupRetry DeviceGroupRetryPolicy
+	// TopicRateBackoff, if positive, makes SendWithRetries also retry topic
+	// messages rejected with TopicsMessageRateExceeded, starting with this
+	// backoff in milliseconds (see TopicRateBackoffInitialDelay).
+	TopicRateBackoff int
+	// Profile, if set, provides defaults for the notification payload of every
+	// message sent.
+	Profile *SenderProfile
+	// Truncation, if set, truncates notification titles and bodies to the
+	// strictest limits of its platforms before they are sent.
+	Truncation *TruncationPolicy
+	// TrimRules, if set, trims the data payload of messages exceeding
+	// MaxPayloadSize before they are sent.
+	TrimRules *TrimRules
+	// SilentPushBudget, if set, limits the background pushes sent to each
+	// registration token.
+	SilentPushBudget *SilentPushBudget
+	// TraceIDKey, if set, is the data key under which a trace ID is injected
+	// into every message sent.  The trace ID is returned in the result.
+	TraceIDKey string
+	// TraceIDGenerator generates trace IDs.  If nil, random 128-bit hex IDs
+	// are used.
+	TraceIDGenerator func() string
+	// MessageUUIDKey, if set, is the data key under which a random UUID is
+	// injected into every message sent, giving it a durable identity for
+	// correlation across systems.  The UUID is returned in the result, and
+	// with the errors of the send, see MessageUUID.
+	MessageUUIDKey string
+	// Archiver, if set, archives a sample of the requests and responses.
+	Archiver *Archiver
+	// Signer, if set, signs each request before it is sent.
+	Signer RequestSigner
+	// PayloadSigner, if set, signs the data payload of each message under
+	// SignatureKey so that clients can verify it with VerifyData.
+	PayloadSigner PayloadSigner
+	// Listener, if set, is notified of the attempts and outcomes of sends.
+	Listener EventListener
+	// PartialResultErrors makes SendMulticastWithRetries return a
+	// *PartialResultError along with the partial results when an unrecoverable
+	// error ends the retries, instead of the partial results alone.  So does
+	// SendWithRetries when the DeviceGroupRetry of failed members ends on an
+	// error.
+	PartialResultErrors bool
+	// RetryExhaustedErrors makes SendWithRetries and SendMulticastWithRetries
+	// return a *RetryExhaustedError along with the results when the retries
+	// run out on results still failing with Unavailable or
+	// InternalServerError, instead of the results alone.
+	RetryExhaustedErrors bool
+	// SplitMulticast makes SendMulticastNoRetry and SendMulticastWithRetries
+	// send to more than MaxRegistrationIDs registration IDs in consecutive
+	// batches, instead of rejecting them before sending.  A batch failing
+	// without results ends the send with the results of the batches before
+	// it and a *PartialResultError.
+	SplitMulticast bool
+	// SanitizeRegistrationIDs makes SendMulticastNoRetry and
+	// SendMulticastWithRetries clean up the registration IDs with
+	// SanitizeTokens, giving InvalidRegistration results to the rejected ones
+	// without sending to them.
+	SanitizeRegistrationIDs bool
+	// PanicPolicy decides what happens when OnAPIKeyUsed, TraceIDGenerator,
+	// Signer or Listener panics.
+	PanicPolicy PanicPolicy
+	// Telemetry, if set, tracks the canonical ID and uninstall rates of the
+	// messages sent.
+	Telemetry *Telemetry
+	// MaxConcurrentRequests bounds the number of simultaneous requests to the
+	// GCM connection server made by this Sender.  Zero means unlimited.
+	MaxConcurrentRequests int
+	// Concurrency, if set, adapts the number of simultaneous requests to the
+	// load of the GCM connection server, within MaxConcurrentRequests.
+	Concurrency *ConcurrencyController
+	// RateLimit caps the number of requests per second made by this Sender.
+	// Zero means unlimited.
+	RateLimit float64
+	// Time is the clock the Sender waits on between retries.  Nil means
+	// SystemTime.
+	Time TimeSource
+	// Preferences, if set, is consulted before the jobs of a Dispatcher or an
+	// OutboxRelay are sent, so that recipients who do not want a message are
+	// skipped.  Messages sent directly are not checked.
+	Preferences PreferenceChecker
+	// Metrics, if set, collects the metrics of the requests, see MetricDescs.
+	Metrics *Metrics
+
+	stats    senderStats
+	semOnce  sync.Once
+	sem      chan struct{}
+	limitMu  sync.Mutex
+	limiter  *rateLimiter
+	reloadMu sync.RWMutex
+	reloaded *senderPolicy // set by Reload
+	randOnce sync.Once
+	rand     *lockedRand
 }
 
 // NewSender instantiates a Sender given the API key.
@@ -44,7 +161,93 @@ func NewSender(apiKey string) *Sender {
 
 // NewSenderWithHTTPClient instantiates a Sender given the API key and an http.Client.
 func NewSenderWithHTTPClient(apiKey string, client *http.Client) *Sender {
-	return &Sender{apiKey, client}
+	return &Sender{APIKey: apiKey, Client: client}
+}
+
+// WithRandSource sets the source of randomness used to jitter backoff delays,
+// so that backoff sequences can be reproduced, and returns the Sender.  It must
+// be called before the Sender is in use.  By default each Sender seeds its own
+// source from the current time.
+func (s *Sender) WithRandSource(src rand.Source) *Sender {
+	s.randOnce.Do(func() {})
+	s.rand = &lockedRand{r: rand.New(src)}
+	return s
+}
+
+func (s *Sender) random() *lockedRand {
+	s.randOnce.Do(func() {
+		s.rand = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	})
+	return s.rand
+}
+
+// lockedRand is a rand.Rand that is safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (s *Sender) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+func (s *Sender) initLimits() {
+	s.semOnce.Do(func() {
+		if s.MaxConcurrentRequests > 0 {
+			s.sem = make(chan struct{}, s.MaxConcurrentRequests)
+		}
+		if s.RateLimit > 0 {
+			s.limiter = newRateLimiter(s.RateLimit)
+		}
+	})
+}
+
+func (s *Sender) clock() TimeSource {
+	if s.Time == nil {
+		return SystemTime
+	}
+	return s.Time
+}
+
+// acquire blocks until a request slot is available and returns a function that
+// releases it given the outcome of the request.
+func (s *Sender) acquire() func(*response, error) {
+	s.initLimits()
+	s.limitMu.Lock()
+	if s.limiter != nil {
+		s.limiter.wait()
+	}
+	s.limitMu.Unlock()
+	if s.sem != nil {
+		s.sem <- struct{}{}
+	}
+	var done func(bool)
+	if s.Concurrency != nil {
+		done = s.Concurrency.acquire()
+	}
+	return func(resp *response, err error) {
+		if done != nil {
+			done(overloaded(resp, err))
+		}
+		if s.sem != nil {
+			<-s.sem
+		}
+	}
 }
 
 func checkUnrecoverableErrors(s *Sender, to string, regIDs []string, msg *Message, retries int) error {
@@ -52,20 +255,20 @@ func checkUnrecoverableErrors(s *Sender, to string, regIDs []string, msg *Messag
 	if s.APIKey == "" {
 		return fmt.Errorf("missing API key")
 	}
-	if s.Client == nil {
-		s.Client = new(http.Client)
-	}
 	// check message
 	if msg == nil {
 		return errors.New("message cannot be nil")
 	}
-	if msg.TimeToLive < 0 || msg.TimeToLive > 2419200 {
+	if msg.TimeToLive < 0 || msg.TimeToLive > maxTimeToLive {
 		return errors.New("TimeToLive should be non-negative and at most 4 weeks")
 	}
 	// check recipients
 	if to == "" && (regIDs == nil || len(regIDs) <= 0) {
 		return errors.New("missing recipient(s)")
 	}
+	if err := validateRecipient(to); err != nil {
+		return err
+	}
 	// check retries
 	if retries < 0 {
 		return errors.New("retries cannot be negative")
@@ -82,38 +285,162 @@ func (e httpError) Error() string {
 	return fmt.Sprintf("%d error: %s", e.statusCode, e.status)
 }
 
+// RetryExhaustedError is returned when a send still fails with a recoverable
+// error once its retries are exhausted.
+type RetryExhaustedError struct {
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("%v after %d attempts over %v", e.Err, e.Attempts, e.Elapsed.Round(time.Millisecond))
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// ResultError is the error of a result, e.g. Unavailable, as the error of the
+// last attempt of a RetryExhaustedError.
+type ResultError struct {
+	Code string
+}
+
+func (e *ResultError) Error() string {
+	return e.Code
+}
+
+// isRetryableResult reports whether the error of a result is retried.
+func isRetryableResult(code string) bool {
+	return code == ErrorUnavailable || code == ErrorInternalServerError
+}
+
+// PartialResultError is returned along with partial results when an
+// unrecoverable error ended the retries of a multicast message, or a batch of
+// a split multicast failed after others were sent.
+type PartialResultError struct {
+	Err error
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("multicast ended with partial results: %v", e.Err)
+}
+
+// Unwrap returns the unrecoverable error.
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
+
+func isRecoverable(err error) bool {
+	var httpErr httpError
+	return errors.As(err, &httpErr) && httpErr.statusCode >= http.StatusInternalServerError && httpErr.statusCode < 600
+}
+
 func (s *Sender) sendRaw(msg *message) (*response, error) {
-	if err := checkUnrecoverableErrors(s, msg.to, msg.registrationIds, &msg.Message, 0); err != nil {
+	to := msg.to
+	if msg.condition != "" {
+		to = msg.condition
+	}
+	if err := checkUnrecoverableErrors(s, to, msg.registrationIds, &msg.Message, 0); err != nil {
 		return nil, err
 	}
 
+	msg.Notification = s.Profile.apply(msg.Notification)
+	msg.Notification = s.Truncation.apply(msg.Notification)
+	if trimmed, _ := s.TrimRules.Trim(&msg.Message); trimmed != &msg.Message {
+		msg.Message = *trimmed
+	}
+	if s.PayloadSigner != nil && len(msg.Data) > 0 {
+		data, err := SignData(msg.Data, s.PayloadSigner)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = data
+	}
 	msgJSON, err := json.Marshal(msg)
 	if err != nil {
 		return nil, err
 	}
+	s.attempted(msg)
+	start := time.Now()
+	resp, err := s.post(contextWithTags(context.Background(), msg.Tags), msgJSON)
+	latency, target := time.Since(start), msg.targetType(resp)
+	s.stats.recordLatency(target, latency)
+	s.Metrics.observe(msg, target, latency, resp, err)
+	return resp, err
+}
 
-	req, err := http.NewRequest("POST", GCMEndpoint, bytes.NewBuffer(msgJSON))
+// post sends the JSON encoded message to the GCM connection server, failing
+// over to the secondary API key if the primary one is rejected.
+func (s *Sender) post(ctx context.Context, msgJSON []byte) (*response, error) {
+	resp, err := s.postWithKey(ctx, s.APIKey, msgJSON)
+	secondary := false
+	if httpErr, isHTTPErr := err.(httpError); isHTTPErr && httpErr.statusCode == http.StatusUnauthorized && s.SecondaryAPIKey != "" {
+		resp, err = s.postWithKey(ctx, s.SecondaryAPIKey, msgJSON)
+		secondary = true
+	}
+	if s.OnAPIKeyUsed != nil {
+		protect(s.PanicPolicy, "OnAPIKeyUsed", func() { s.OnAPIKeyUsed(secondary) })
+	}
+	s.stats.record(err)
+	return resp, err
+}
+
+func (s *Sender) postWithKey(ctx context.Context, apiKey string, msgJSON []byte) (result *response, err error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = GCMEndpoint
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(msgJSON))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("key=%s", s.APIKey))
+	req = req.WithContext(ctx)
+	req.Header.Add("Authorization", fmt.Sprintf("key=%s", apiKey))
 	req.Header.Add("Content-Type", "application/json")
+	if s.Signer != nil {
+		var err error
+		if perr := protect(s.PanicPolicy, "Signer", func() { err = s.Signer.SignRequest(req, msgJSON) }); perr != nil {
+			return nil, perr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	resp, err := s.Client.Do(req)
+	// archive after the request slot is released, as the store may be slow
+	var statusCode int
+	var body []byte
+	defer func() {
+		if statusCode != 0 {
+			s.archive(ctx, msgJSON, statusCode, body)
+		}
+	}()
+	release := s.acquire()
+	defer func() { release(result, err) }()
+
+	resp, err := s.client().Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	statusCode = resp.StatusCode
 
 	if resp.StatusCode != http.StatusOK {
 		// refer to https://goo.gl/nV1Nf6
 		// 400: bad json or contains invalid fields
 		// 401: sender authentication failure
 		// 5xx: GCM connection server internal error (retry later)
+		if resp.StatusCode == http.StatusBadRequest {
+			body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+			return nil, &BadRequestError{httpError{resp.StatusCode, resp.Status}, string(body), parseFieldErrors(body)}
+		}
 		return nil, httpError{resp.StatusCode, resp.Status}
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +451,7 @@ func (s *Sender) sendRaw(msg *message) (*response, error) {
 		log.Printf("failed to unmarshal json: %s", body)
 		return nil, err
 	}
+	response.body = body
 
 	return response, nil
 }
@@ -136,23 +464,47 @@ func (s *Sender) SendNoRetry(msg *Message, to string) (*Result, error) {
 	if err := checkUnrecoverableErrors(s, to, nil, msg, 0); err != nil {
 		return nil, err
 	}
+	record, err := s.checkSilentPush(msg, to)
+	if err != nil {
+		return nil, err
+	}
 	rawMsg := &message{Message: *msg, to: to}
+	s.injectIDs(rawMsg)
+	result, err := s.send(rawMsg)
+	s.sent(rawMsg, result, err)
+	if result != nil && !strings.HasPrefix(to, TopicPrefix) {
+		record(result)
+		s.observe(msg, *result)
+	}
+	return result, withUUID(rawMsg, err)
+}
 
+func (s *Sender) send(rawMsg *message) (*Result, error) {
 	resp, err := s.sendRaw(rawMsg)
 	if err != nil {
 		return nil, err
 	}
+	result, err := newResult(resp, strings.HasPrefix(rawMsg.to, TopicPrefix) || rawMsg.condition != "")
+	if err != nil {
+		return nil, err
+	}
+	result.TraceID, result.UUID = rawMsg.traceID, rawMsg.uuid
+	return result, nil
+}
 
+// newResult converts the response to a message sent to a single recipient, a
+// topic or condition, or a device group.
+func newResult(resp *response, topic bool) (*Result, error) {
 	result := new(Result)
 	if resp.Results != nil { // downstream message
-		if len(resp.Results) != 1 {
-			return nil, fmt.Errorf("invalid response.results: %v", resp.Results)
+		if err := resp.checkResultCount(1); err != nil {
+			return nil, err
 		}
 		res := resp.Results[0]
 		result.MessageID = res.MessageID
 		result.CanonicalRegistrationID = res.RegistrationID
 		result.Error = res.Err
-	} else if strings.HasPrefix(to, TopicPrefix) { // topic message
+	} else if topic { // topic message
 		if resp.MessageID != 0 {
 			result.MessageID = strconv.FormatInt(resp.MessageID, 10)
 		} else if resp.Err != "" {
@@ -174,47 +526,118 @@ func (s *Sender) SendWithRetries(msg *Message, to string, retries int) (result *
 	if err := checkUnrecoverableErrors(s, to, nil, msg, retries); err != nil {
 		return nil, err
 	}
-	attempt, backoff := 0, BackoffInitialDelay
+	record, err := s.checkSilentPush(msg, to)
+	if err != nil {
+		return nil, err
+	}
+	rawMsg := &message{Message: *msg, to: to}
+	s.injectIDs(rawMsg)
+	result, err = s.sendWithRetries(rawMsg, retries)
+	s.sent(rawMsg, result, err)
+	if err == nil && !strings.HasPrefix(to, TopicPrefix) {
+		record(result)
+		s.observe(msg, *result)
+	}
+	return result, withUUID(rawMsg, err)
+}
+
+func (s *Sender) sendWithRetries(rawMsg *message, retries int) (result *Result, err error) {
+	policy := s.policy()
+	attempt, backoff, topicBackoff := 0, BackoffInitialDelay, policy.TopicRateBackoff
+	start := time.Now()
 	for {
 		attempt++
-		result, err = s.SendNoRetry(msg, to)
+		result, err = s.send(rawMsg)
 		// NOTE: partial success for a device group message is considered successful
 
 		tryAgain := false
 		if attempt <= retries {
-			if result != nil && (result.Error == ErrorUnavailable || result.Error == ErrorInternalServerError) {
+			if result != nil && isRetryableResult(result.Error) {
 				tryAgain = true
+			} else if result != nil && result.Error == ErrorTopicsMessageRateExceeded && topicBackoff > 0 {
+				topicBackoff = s.sleepUpTo(rawMsg, topicBackoff, MaxTopicRateBackoffDelay)
+				continue
 			} else if err != nil {
-				if httpErr, isHTTPErr := err.(httpError); isHTTPErr {
-					tryAgain = httpErr.statusCode >= http.StatusInternalServerError && httpErr.statusCode < 600
-				}
+				tryAgain = isRecoverable(err)
 			}
 		}
 
 		if tryAgain {
-			sleepTime := backoff/2 + rand.Intn(backoff)
-			time.Sleep(time.Duration(sleepTime) * time.Millisecond)
-			backoff = min(2*backoff, MaxBackoffDelay)
+			backoff = s.sleep(rawMsg, backoff)
 		} else {
 			break
 		}
 	}
+	if retries > 0 && isRecoverable(err) {
+		return nil, &RetryExhaustedError{attempt, time.Since(start), err}
+	}
+	if retries > 0 && s.RetryExhaustedErrors && result != nil && isRetryableResult(result.Error) {
+		return result, &RetryExhaustedError{attempt, time.Since(start), &ResultError{result.Error}}
+	}
+	if err == nil && policy.DeviceGroupRetry != DeviceGroupRetryNone && len(result.FailedRegistrationIDs) > 0 && attempt <= retries {
+		return s.retryDeviceGroup(rawMsg, result, policy.DeviceGroupRetry, retries-attempt+1, backoff)
+	}
 	return
 }
 
+// sleep sleeps for a random period around backoff milliseconds before the next
+// attempt to send the message and returns the next backoff.
+func (s *Sender) sleep(m *message, backoff int) int {
+	return s.sleepUpTo(m, backoff, MaxBackoffDelay)
+}
+
+func (s *Sender) sleepUpTo(m *message, backoff, maxBackoff int) int {
+	sleepTime := s.jitter(backoff)
+	if s.Listener != nil {
+		protect(s.PanicPolicy, "OnRetryScheduled", func() { s.Listener.OnRetryScheduled(&m.Message, m.attempts+1, sleepTime) })
+	}
+	<-s.clock().After(sleepTime)
+	return min(2*backoff, maxBackoff)
+}
+
+// jitter returns a random period around backoff milliseconds.
+func (s *Sender) jitter(backoff int) time.Duration {
+	return time.Duration(backoff/2+s.random().Intn(backoff)) * time.Millisecond
+}
+
 // SendMulticastNoRetry sends a multicast message to multiple recipients without
 // retries.
 func (s *Sender) SendMulticastNoRetry(msg *Message, registrationIds []string) (*MulticastResult, error) {
 	if err := checkUnrecoverableErrors(s, "", registrationIds, msg, 0); err != nil {
 		return nil, err
 	}
+	if s.SanitizeRegistrationIDs {
+		if valid, report := SanitizeTokens(registrationIds); report.changed() {
+			return sendSanitized(valid, report, func(regIDs []string) (*MulticastResult, error) {
+				return s.SendMulticastNoRetry(msg, regIDs)
+			})
+		}
+	}
+	if len(registrationIds) > MaxRegistrationIDs {
+		msg := s.withIDs(msg)
+		return s.sendMulticastBatches(registrationIds, func(batch []string) (*MulticastResult, error) {
+			return s.SendMulticastNoRetry(msg, batch)
+		})
+	}
 	rawMsg := &message{Message: *msg, registrationIds: registrationIds}
+	s.injectIDs(rawMsg)
 
 	resp, err := s.sendRaw(rawMsg)
+	if err == nil {
+		err = resp.checkResultCount(len(registrationIds))
+	}
 	if err != nil {
-		return nil, err
+		s.done(rawMsg, err)
+		return nil, withUUID(rawMsg, err)
 	}
+	result := newMulticastResult(resp)
+	result.TraceID, result.UUID = rawMsg.traceID, rawMsg.uuid
+	s.done(rawMsg, nil, result.Results...)
+	s.observe(msg, result.Results...)
+	return result, nil
+}
 
+func newMulticastResult(resp *response) *MulticastResult {
 	result := new(MulticastResult)
 	result.Success = resp.Success
 	result.Failure = resp.Failure
@@ -230,7 +653,7 @@ func (s *Sender) SendMulticastNoRetry(msg *Message, registrationIds []string) (*
 			}
 		}
 	}
-	return result, nil
+	return result
 }
 
 // SendMulticastWithRetries sends a multicast message to the GCM connection
@@ -243,22 +666,77 @@ func (s *Sender) SendMulticastWithRetries(msg *Message, regIDs []string, retries
 	if err := checkUnrecoverableErrors(s, "", regIDs, msg, retries); err != nil {
 		return nil, err
 	}
+	if s.SanitizeRegistrationIDs {
+		if valid, report := SanitizeTokens(regIDs); report.changed() {
+			return sendSanitized(valid, report, func(regIDs []string) (*MulticastResult, error) {
+				return s.SendMulticastWithRetries(msg, regIDs, retries)
+			})
+		}
+	}
+	if len(regIDs) > MaxRegistrationIDs {
+		msg := s.withIDs(msg)
+		return s.sendMulticastBatches(regIDs, func(batch []string) (*MulticastResult, error) {
+			return s.SendMulticastWithRetries(msg, batch, retries)
+		})
+	}
 	rawMsg := &message{Message: *msg, registrationIds: regIDs}
+	s.injectIDs(rawMsg)
+	result, err := s.sendMulticastWithRetries(rawMsg, retries)
+	return result, withUUID(rawMsg, err)
+}
 
+func (s *Sender) sendMulticastWithRetries(rawMsg *message, retries int) (*MulticastResult, error) {
+	result, abortErr, err := s.multicastWithRetries(rawMsg, retries)
+	if err != nil {
+		s.done(rawMsg, err)
+		return nil, err
+	}
+	s.observe(&rawMsg.Message, result.Results...)
+	var exhausted *RetryExhaustedError
+	if errors.As(abortErr, &exhausted) {
+		s.done(rawMsg, abortErr)
+		return result, abortErr
+	}
+	if abortErr != nil && s.PartialResultErrors {
+		err := &PartialResultError{abortErr}
+		s.done(rawMsg, err)
+		return result, err
+	}
+	s.done(rawMsg, nil, result.Results...)
+	return result, nil
+}
+
+// multicastWithRetries sends a multicast message with retries, without
+// notifying the Listener or the Telemetry.  If an unrecoverable error ended
+// the retries after some results, it returns them with the error as abortErr.
+// So it does with a *RetryExhaustedError when the retries run out and the
+// Sender has RetryExhaustedErrors.
+func (s *Sender) multicastWithRetries(rawMsg *message, retries int) (_ *MulticastResult, abortErr, err error) {
+	regIDs := rawMsg.registrationIds
 	results := make(map[string]result, len(regIDs))
 	finalResult, backoff, firstResponse := new(MulticastResult), BackoffInitialDelay, true
+	finalResult.TraceID, finalResult.UUID = rawMsg.traceID, rawMsg.uuid
+	start, attempts, maxRetries := time.Now(), 0, retries
+	var lastErr, exhaustedErr error
 
 	for {
+		attempts++
 		resp, err := s.sendRaw(rawMsg)
+		if err == nil {
+			err = resp.checkResultCount(len(rawMsg.registrationIds))
+		}
 		if err != nil {
-			if httpErr, isHTTPErr := err.(httpError); isHTTPErr && httpErr.statusCode >= 500 && httpErr.statusCode < 600 {
+			if isRecoverable(err) {
 				// recoverable error, so continue to retry
+				lastErr = err
 			} else if firstResponse {
 				// unrecoverable first response
-				return nil, err
+				return nil, nil, err
 			} else {
 				// NOTE: unrecoverable error but we had partial results previously,
-				// so return partial results with nil error.
+				// so return partial results with nil error unless
+				// PartialResultErrors is set.
+				abortErr = err
 				break
 			}
 		}
@@ -277,11 +755,15 @@ func (s *Sender) SendMulticastWithRetries(msg *Message, regIDs []string, retries
 			for i := range resp.Results {
 				regID, result := rawMsg.registrationIds[i], resp.Results[i]
 				results[regID] = result
-				if result.Err == ErrorUnavailable || result.Err == ErrorInternalServerError {
+				if isRetryableResult(result.Err) {
+					if len(retryRegIds) == 0 {
+						exhaustedErr = &ResultError{result.Err}
+					}
 					retryRegIds = append(retryRegIds, regID)
 				}
 			}
 		} else {
+			exhaustedErr = err
 			retryRegIds = make([]string, len(rawMsg.registrationIds))
 			for i := range rawMsg.registrationIds {
 				retryRegIds[i] = rawMsg.registrationIds[i]
@@ -289,17 +771,30 @@ func (s *Sender) SendMulticastWithRetries(msg *Message, regIDs []string, retries
 		}
 
 		firstResponse = false
-		if retries <= 0 || len(retryRegIds) == 0 {
+		if len(retryRegIds) == 0 {
+			break
+		}
+		if retries <= 0 {
+			if maxRetries > 0 && s.RetryExhaustedErrors {
+				abortErr = &RetryExhaustedError{attempts, time.Since(start), exhaustedErr}
+			}
 			break
 		}
 
 		rawMsg.registrationIds = retryRegIds
-		sleepTime := backoff/2 + rand.Intn(backoff)
-		time.Sleep(time.Duration(sleepTime) * time.Millisecond)
-		backoff = min(2*backoff, MaxBackoffDelay)
+		backoff = s.sleep(rawMsg, backoff)
 		retries--
 	}
 
+	if len(results) == 0 && lastErr != nil {
+		// no attempt got a response
+		err := lastErr
+		if maxRetries > 0 {
+			err = &RetryExhaustedError{attempts, time.Since(start), lastErr}
+		}
+		return nil, nil, err
+	}
+
 	// reconstruct final results
 	finalResults := make([]Result, len(regIDs))
 	for i, regID := range regIDs {
@@ -319,7 +814,7 @@ func (s *Sender) SendMulticastWithRetries(msg *Message, regIDs []string, retries
 		}
 	}
 	finalResult.Results = finalResults
-	return finalResult, nil
+	return finalResult, abortErr, nil
 }
 
 func min(x, y int) int {
@@ -2,10 +2,14 @@ package gcm
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -130,6 +134,19 @@ func TestSendRetryFail_DueToTopicRateExceeded(t *testing.T) {
 	assert.Equal(t, Result{Error: ErrorTopicsMessageRateExceeded}, *result)
 }
 
+func TestSendRetryOk_DueToTopicRateExceededWithTopicRateBackoff(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Err: ErrorTopicsMessageRateExceeded}},
+		&testResponse{response: &response{MessageID: 10}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.TopicRateBackoff = 10
+	result, err := s.SendWithRetries(msg, topic, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "10"}, *result)
+}
+
 func TestSendRetryFail_DueToDeviceGroupPartialFail(t *testing.T) {
 	server := startTestServer(t, &testResponse{response: &partialDeviceGroup})
 	defer server.Close()
@@ -155,6 +172,34 @@ func TestSendMulticastRetryError_DueToUnrecoverableHttpError(t *testing.T) {
 	assert.EqualError(t, err, "400 error: 400 Bad Request")
 }
 
+func TestSendRetryFail_WithRetryExhaustedErrors(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &fail},
+		&testResponse{response: &fail},
+		&testResponse{response: &partialMulticast},
+		&testResponse{response: &response{MulticastID: 2, Failure: 1, Results: []result{{Err: ErrorInternalServerError}}}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.RetryExhaustedErrors = true
+	result, err := s.SendWithRetries(msg, "regId", 1)
+	assert.Equal(t, Result{Error: ErrorUnavailable}, *result)
+	var exhausted *RetryExhaustedError
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, 2, exhausted.Attempts)
+		assert.True(t, exhausted.Elapsed >= BackoffInitialDelay/2*time.Millisecond)
+		assert.Equal(t, &ResultError{ErrorUnavailable}, exhausted.Err)
+		assert.Contains(t, err.Error(), "Unavailable after 2 attempts over ")
+	}
+
+	multicastResult, err := s.SendMulticastWithRetries(msg, twoRecipients, 1)
+	assert.Equal(t, []Result{{MessageID: "id1"}, {Error: ErrorInternalServerError}}, multicastResult.Results)
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, 2, exhausted.Attempts)
+		assert.Equal(t, &ResultError{ErrorInternalServerError}, exhausted.Err)
+	}
+}
+
 func TestSendMulticastRetryOk(t *testing.T) {
 	server := startTestServer(t,
 		&testResponse{response: &partialMulticast},
@@ -202,14 +247,145 @@ func TestSendMulticastRetryPartialFail_DueToUnrecoverableError(t *testing.T) {
 	}, *result)
 }
 
+func TestSendMulticastRetryPartialFail_WithPartialResultErrors(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &partialMulticast},
+		&testResponse{statusCode: http.StatusBadRequest},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.PartialResultErrors = true
+	result, err := s.SendMulticastWithRetries(msg, twoRecipients, 1)
+	assert.EqualError(t, err, "multicast ended with partial results: 400 error: 400 Bad Request")
+	assert.ErrorIs(t, err, httpError{http.StatusBadRequest, "400 Bad Request"})
+	assert.Equal(t, []Result{{MessageID: "id1"}, {Error: ErrorUnavailable}}, result.Results)
+}
+
+func TestSendConcurrently(t *testing.T) {
+	const n = 20
+	responses := make([]*testResponse, n)
+	for i := range responses {
+		responses[i] = &testResponse{response: &success}
+	}
+	server := startTestServer(t, responses...)
+	defer server.Close()
+	s := &Sender{APIKey: "test-api-key"}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			result, err := s.SendWithRetries(msg, "regId", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, Result{MessageID: "id"}, *result)
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, s.Client)
+}
+
+func TestSenderWithRandSource(t *testing.T) {
+	a := NewSender("test-api-key").WithRandSource(rand.NewSource(1))
+	b := NewSender("test-api-key").WithRandSource(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, a.random().Intn(BackoffInitialDelay), b.random().Intn(BackoffInitialDelay))
+	}
+}
+
+func TestSendWithMaxConcurrentRequests(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		respBytes, _ := json.Marshal(success)
+		w.Write(respBytes)
+	}))
+	defer server.Close()
+	GCMEndpoint = server.URL
+
+	s := &Sender{APIKey: "test-api-key", MaxConcurrentRequests: 2}
+	var wg sync.WaitGroup
+	wg.Add(6)
+	for i := 0; i < 6; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := s.SendNoRetry(msg, "regId")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, peak)
+}
+
+func TestSendMulticastResultCountMismatch(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	_, err := s.SendMulticastNoRetry(msg, twoRecipients)
+	assert.True(t, errors.Is(err, ErrResultCountMismatch))
+	assert.EqualError(t, err, `expected 2 results, got 1: {"success":1,"results":[{"message_id":"id"}]}`)
+	_, err = s.SendMulticastWithRetries(msg, twoRecipients, 1)
+	if assert.IsType(t, &ResultCountMismatchError{}, err) {
+		mismatch := err.(*ResultCountMismatchError)
+		assert.Equal(t, 2, mismatch.Expected)
+		assert.Equal(t, 1, mismatch.Actual)
+	}
+}
+
+func TestSendWithSecondaryAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "key=new-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		respBytes, _ := json.Marshal(success)
+		w.Write(respBytes)
+	}))
+	defer server.Close()
+	GCMEndpoint = server.URL
+
+	var used []bool
+	s := NewSender("old-key")
+	s.SecondaryAPIKey = "new-key"
+	s.OnAPIKeyUsed = func(secondary bool) { used = append(used, secondary) }
+	result, err := s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "id"}, *result)
+
+	s.APIKey, s.SecondaryAPIKey = "new-key", ""
+	_, err = s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, used)
+
+	s.APIKey = "old-key"
+	_, err = s.SendNoRetry(msg, "regId")
+	assert.EqualError(t, err, "401 error: 401 Unauthorized")
+}
+
 type testResponse struct {
 	statusCode int
 	response   *response
+	body       string // for non-200 responses
 }
 
 func startTestServer(t *testing.T, responses ...*testResponse) *httptest.Server {
+	var mu sync.Mutex
 	i := 0
 	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
 		if i >= len(responses) {
 			t.Fatalf("server received %d requests, expected %d", i+1, len(responses))
 		}
@@ -221,6 +397,7 @@ func startTestServer(t *testing.T, responses ...*testResponse) *httptest.Server
 			fmt.Fprint(w, string(respBytes))
 		} else {
 			w.WriteHeader(status)
+			fmt.Fprint(w, resp.body)
 		}
 		i++
 	}
@@ -228,3 +405,47 @@ func startTestServer(t *testing.T, responses ...*testResponse) *httptest.Server
 	GCMEndpoint = server.URL
 	return server
 }
+
+func TestSendWithSigner(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	var signed []string
+	s := NewSender("test-api-key")
+	s.Signer = RequestSignerFunc(func(req *http.Request, body []byte) error {
+		if len(signed) > 0 {
+			return errors.New("signing failed")
+		}
+		req.Header.Set("X-Signature", "sig")
+		signed = append(signed, string(body))
+		return nil
+	})
+	_, err := s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"data":{"k":"v"},"to":"regId"}`}, signed)
+	_, err = s.SendNoRetry(msg, "regId")
+	assert.EqualError(t, err, "signing failed")
+}
+
+func TestSendRetryFail_DueToExhaustedRetries(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+		&testResponse{statusCode: http.StatusServiceUnavailable},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key").WithRandSource(rand.NewSource(1))
+	_, err := s.SendWithRetries(msg, "regId", 1)
+	var exhausted *RetryExhaustedError
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, 2, exhausted.Attempts)
+		assert.True(t, exhausted.Elapsed >= BackoffInitialDelay/2*time.Millisecond)
+		assert.ErrorIs(t, err, httpError{http.StatusServiceUnavailable, "503 Service Unavailable"})
+		assert.Contains(t, err.Error(), "503 error: 503 Service Unavailable after 2 attempts over ")
+	}
+
+	_, err = s.SendMulticastWithRetries(msg, twoRecipients, 1)
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, 2, exhausted.Attempts)
+	}
+}
@@ -0,0 +1,74 @@
+package gcm
+
+import (
+	"errors"
+	"sync"
+)
+
+// SentStore persists the message IDs returned by the GCM connection server,
+// keyed by a caller-chosen intent key, so that a message that was already
+// accepted is not sent again when the caller retries after a crash.
+type SentStore interface {
+	// Get returns the message ID recorded for key, or "" if none.
+	Get(key string) (string, error)
+	// Put records the message ID for key.
+	Put(key, messageID string) error
+}
+
+// NewMemorySentStore instantiates an in-memory SentStore.  It only guards
+// against duplicates within the lifetime of the process.
+func NewMemorySentStore() SentStore {
+	return &memorySentStore{ids: make(map[string]string)}
+}
+
+type memorySentStore struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func (m *memorySentStore) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ids[key], nil
+}
+
+func (m *memorySentStore) Put(key, messageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids[key] = messageID
+	return nil
+}
+
+// SendOnce sends a downstream message with retries unless a message with the
+// same key was already accepted by the GCM connection server, in which case
+// the recorded message ID is returned without contacting the server.
+//
+// The message ID is recorded right after the server accepts the message, so
+// a crash between the two steps can still cause a duplicate.  Device group
+// messages carry no message ID and are therefore never recorded.
+func (s *Sender) SendOnce(store SentStore, key string, msg *Message, to string, retries int) (*Result, error) {
+	if store == nil {
+		return nil, errors.New("sent store cannot be nil")
+	}
+	if key == "" {
+		return nil, errors.New("missing intent key")
+	}
+	messageID, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if messageID != "" {
+		return &Result{MessageID: messageID}, nil
+	}
+
+	result, err := s.SendWithRetries(msg, to, retries)
+	if err != nil {
+		return nil, err
+	}
+	if result.MessageID != "" {
+		if err := store.Put(key, result.MessageID); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendOnceSkipsRecordedIntent(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	store := NewMemorySentStore()
+	result, err := s.SendOnce(store, "intent", msg, "regId", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "id"}, *result)
+	// the test server fails on any further request
+	result, err = s.SendOnce(store, "intent", msg, "regId", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "id"}, *result)
+}
+
+func TestSendOnceDoesNotRecordFailure(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &fail},
+		&testResponse{response: &success},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	store := NewMemorySentStore()
+	result, err := s.SendOnce(store, "intent", msg, "regId", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Error: ErrorUnavailable}, *result)
+	id, _ := store.Get("intent")
+	assert.Equal(t, "", id)
+	result, err = s.SendOnce(store, "intent", msg, "regId", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{MessageID: "id"}, *result)
+}
+
+func TestSendOnceWithInvalidArguments(t *testing.T) {
+	s := NewSender("test-api-key")
+	_, err := s.SendOnce(nil, "intent", msg, "regId", 0)
+	assert.EqualError(t, err, "sent store cannot be nil")
+	_, err = s.SendOnce(NewMemorySentStore(), "", msg, "regId", 0)
+	assert.EqualError(t, err, "missing intent key")
+}
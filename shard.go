@@ -0,0 +1,48 @@
+package gcm
+
+import "hash/fnv"
+
+// Shard returns the shard in [0, n) a registration token belongs to.  The
+// assignment is stable across processes, and when n grows only about 1/n of
+// the tokens move, so dispatchers can each take a shard of a broadcast without
+// coordination.  It uses jump consistent hashing.
+func Shard(token string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(token))
+	key := h.Sum64()
+	b, j := int64(-1), int64(0)
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// ShardTokens partitions the registration tokens into n shards by Shard,
+// keeping their order within each shard.
+func ShardTokens(tokens []string, n int) [][]string {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([][]string, n)
+	for _, token := range tokens {
+		i := Shard(token, n)
+		shards[i] = append(shards[i], token)
+	}
+	return shards
+}
+
+// TokensForShard returns the registration tokens of shard i out of n.
+func TokensForShard(tokens []string, i, n int) []string {
+	var shard []string
+	for _, token := range tokens {
+		if Shard(token, n) == i {
+			shard = append(shard, token)
+		}
+	}
+	return shard
+}
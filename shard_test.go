@@ -0,0 +1,37 @@
+package gcm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShard(t *testing.T) {
+	tokens := make([]string, 10000)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%d", i)
+	}
+	shards := ShardTokens(tokens, 4)
+	total := 0
+	for i, shard := range shards {
+		assert.InDelta(t, 2500, len(shard), 250)
+		assert.Equal(t, shard, TokensForShard(tokens, i, 4))
+		total += len(shard)
+	}
+	assert.Equal(t, len(tokens), total)
+
+	// growing from 4 to 5 shards only moves tokens to the new shard
+	moved := 0
+	for _, token := range tokens {
+		if before, after := Shard(token, 4), Shard(token, 5); before != after {
+			assert.Equal(t, 4, after)
+			moved++
+		}
+	}
+	assert.InDelta(t, 2000, moved, 250)
+
+	assert.Equal(t, 0, Shard("token", 1))
+	assert.Equal(t, 0, Shard("token", 0))
+	assert.Equal(t, Shard("token", 7), Shard("token", 7))
+}
@@ -0,0 +1,115 @@
+package gcm
+
+import (
+	"sync"
+	"time"
+)
+
+// ShardOutcome describes how sending to one shard of a ShardedResult went.
+type ShardOutcome struct {
+	Shard int
+	// Recipients is the number of registration IDs in the shard.
+	Recipients int
+	// Attempts is the number of requests made for the shard.
+	Attempts int
+	Elapsed  time.Duration
+	Success  int
+	Failure  int
+	// Err is the first error that stopped sending to the shard, if any.
+	Err error
+}
+
+// ShardedResult is the result of SendMulticastSharded.
+type ShardedResult struct {
+	// MulticastResult aggregates the results of all shards, with Results in
+	// the order of the registration IDs.  Recipients of a shard that stopped
+	// on an error have empty results.  MulticastID, RetryMulticastIDs and
+	// BatchMulticastIDs are not set.
+	MulticastResult
+	Shards []ShardOutcome
+}
+
+// Err returns the error of the first shard that stopped on one, if any.
+func (r *ShardedResult) Err() error {
+	for _, shard := range r.Shards {
+		if shard.Err != nil {
+			return shard.Err
+		}
+	}
+	return nil
+}
+
+// SendMulticastSharded sends a multicast message with retries to the
+// registration IDs, partitioned into shards by ShardTokens and sent in
+// parallel in batches of up to MaxRegistrationIDs.  An error in one shard
+// stops that shard only, and is reported in its ShardOutcome, so that a bad
+// shard can be investigated independently.
+func (s *Sender) SendMulticastSharded(msg *Message, regIDs []string, shards, retries int) (*ShardedResult, error) {
+	if err := checkUnrecoverableErrors(s, "", regIDs, msg, retries); err != nil {
+		return nil, err
+	}
+	index := make(map[string][]int, len(regIDs))
+	for i, regID := range regIDs {
+		index[regID] = append(index[regID], i)
+	}
+
+	partitions := ShardTokens(regIDs, shards)
+	result := &ShardedResult{Shards: make([]ShardOutcome, len(partitions))}
+	result.Results = make([]Result, len(regIDs))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i, partition := range partitions {
+		wg.Add(1)
+		go func(i int, partition []string) {
+			defer wg.Done()
+			outcome := s.sendShard(msg, partition, retries)
+			outcome.Shard = i
+			result.Shards[i] = outcome.ShardOutcome
+
+			mu.Lock()
+			defer mu.Unlock()
+			for j, regID := range partition[:len(outcome.results)] {
+				for _, k := range index[regID] {
+					result.Results[k] = outcome.results[j]
+				}
+			}
+		}(i, partition)
+	}
+	wg.Wait()
+
+	for _, res := range result.Results {
+		if res.MessageID != "" {
+			result.Success++
+			if res.CanonicalRegistrationID != "" {
+				result.CanonicalIds++
+			}
+		} else {
+			result.Failure++
+		}
+	}
+	return result, nil
+}
+
+type shardOutcome struct {
+	ShardOutcome
+	results []Result
+}
+
+func (s *Sender) sendShard(msg *Message, regIDs []string, retries int) shardOutcome {
+	outcome := shardOutcome{ShardOutcome: ShardOutcome{Recipients: len(regIDs)}}
+	start := time.Now()
+	outcome.Err = forEachBatch(regIDs, MaxRegistrationIDs, func(batch []string) error {
+		rawMsg := &message{Message: *msg, registrationIds: batch}
+		s.injectIDs(rawMsg)
+		res, err := s.sendMulticastWithRetries(rawMsg, retries)
+		outcome.Attempts += rawMsg.attempts
+		if res != nil {
+			outcome.results = append(outcome.results, res.Results...)
+			outcome.Success += res.Success
+			outcome.Failure += res.Failure
+		}
+		return withUUID(rawMsg, err)
+	})
+	outcome.Elapsed = time.Since(start)
+	return outcome
+}
@@ -0,0 +1,79 @@
+package gcm
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendMulticastSharded(t *testing.T) {
+	sim := &SimulationTransport{Source: rand.NewSource(1)}
+	s := NewSenderWithHTTPClient("test-api-key", &http.Client{Transport: sim})
+	regIDs := []string{"a", "b", "c", "d", "e", "f", "g", "h", "a"}
+	result, err := s.SendMulticastSharded(msg, regIDs, 3, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err())
+	assert.Equal(t, len(regIDs), result.Success)
+	assert.Len(t, result.Shards, 3)
+	total := 0
+	for i, shard := range result.Shards {
+		assert.Equal(t, i, shard.Shard)
+		if shard.Recipients > 0 {
+			assert.Equal(t, 1, shard.Attempts)
+		}
+		total += shard.Recipients
+	}
+	assert.Equal(t, len(regIDs), total)
+	assert.Equal(t, result.Results[0], result.Results[8])
+}
+
+func TestSendMulticastShardedIsolatesFailures(t *testing.T) {
+	s := NewSender("test-api-key")
+	s.Client = &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("X-Poisoned") != "" {
+			return nil, errors.New("unreachable")
+		}
+		return (&SimulationTransport{}).RoundTrip(req)
+	})}
+	s.Signer = RequestSignerFunc(func(req *http.Request, body []byte) error {
+		if strings.Contains(string(body), `"bad"`) {
+			req.Header.Set("X-Poisoned", "1")
+		}
+		return nil
+	})
+	regIDs := []string{"bad", "a", "b", "c", "d", "e", "f"}
+	result, err := s.SendMulticastSharded(msg, regIDs, 2, 0)
+	assert.NoError(t, err)
+	assert.Error(t, result.Err())
+	bad := Shard("bad", 2)
+	good := 1 - bad
+	assert.Error(t, result.Shards[bad].Err)
+	assert.Equal(t, 1, result.Shards[bad].Attempts)
+	assert.NoError(t, result.Shards[good].Err)
+	assert.Equal(t, result.Shards[good].Recipients, result.Success)
+	assert.Equal(t, result.Shards[bad].Recipients, result.Failure)
+}
+
+func TestSendMulticastShardedKeepsResultsOfFailedBatch(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 1, Failure: 1, Results: []result{{MessageID: "id"}, {Err: ErrorUnavailable}}}},
+		&testResponse{response: &response{Failure: 1, Results: []result{{Err: ErrorUnavailable}}}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.RetryExhaustedErrors = true
+	s.Time = &fakeTime{now: time.Now()}
+	result, err := s.SendMulticastSharded(msg, twoRecipients, 1, 1)
+	assert.NoError(t, err)
+	var exhausted *RetryExhaustedError
+	assert.True(t, errors.As(result.Shards[0].Err, &exhausted))
+	assert.Equal(t, 1, result.Success, "the delivered recipient is not a failure")
+	assert.Equal(t, 1, result.Failure)
+	assert.Equal(t, "id", result.Results[0].MessageID)
+	assert.Equal(t, ErrorUnavailable, result.Results[1].Error)
+}
@@ -0,0 +1,21 @@
+package gcm
+
+import "net/http"
+
+// RequestSigner signs the requests to the GCM connection server once they are
+// built, e.g. by adding HMAC headers, for environments where all egress must
+// be signed or attested.
+type RequestSigner interface {
+	// SignRequest signs the request with the given body.  An error aborts the
+	// request.
+	SignRequest(req *http.Request, body []byte) error
+}
+
+// The RequestSignerFunc type is an adapter to allow the use of ordinary
+// functions as RequestSigners.
+type RequestSignerFunc func(req *http.Request, body []byte) error
+
+// SignRequest calls f(req, body).
+func (f RequestSignerFunc) SignRequest(req *http.Request, body []byte) error {
+	return f(req, body)
+}
@@ -0,0 +1,121 @@
+package gcm
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSilentPushesPerHour is the default budget of background pushes per
+// token per hour.  APNs throttles background pushes beyond a few per hour, and
+// over-sending silently degrades delivery.
+const DefaultSilentPushesPerHour = 3
+
+// SilentPushPolicy defines what happens to a background push over budget.
+type SilentPushPolicy int
+
+const (
+	// SilentPushWarn logs a warning and sends the push anyway.
+	SilentPushWarn SilentPushPolicy = iota
+	// SilentPushDefer does not send the push and returns a
+	// *SilentPushDeferredError telling when it fits in the budget.
+	SilentPushDefer
+)
+
+// SilentPushDeferredError is returned when a background push is deferred
+// because the token is over its budget.
+type SilentPushDeferredError struct {
+	Token      string
+	RetryAfter time.Duration
+}
+
+func (e *SilentPushDeferredError) Error() string {
+	return fmt.Sprintf("silent push budget exceeded for %s, retry after %v", e.Token, e.RetryAfter)
+}
+
+// SilentPushBudget tracks background pushes, i.e. messages with
+// ContentAvailable and no Notification, sent to each token within the last
+// hour.  Only messages sent to a single registration token are tracked.
+//
+// SilentPushBudget is safe for concurrent use.
+type SilentPushBudget struct {
+	// MaxPerHour is the budget per token.  Zero means DefaultSilentPushesPerHour.
+	MaxPerHour int
+	// Policy decides what happens to a push over budget.
+	Policy SilentPushPolicy
+
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+func isSilentPush(msg *Message) bool {
+	return msg.ContentAvailable && msg.Notification == nil
+}
+
+func (b *SilentPushBudget) max() int {
+	if b.MaxPerHour <= 0 {
+		return DefaultSilentPushesPerHour
+	}
+	return b.MaxPerHour
+}
+
+func (b *SilentPushBudget) prune(token string, now time.Time) []time.Time {
+	sent := b.sent[token]
+	i := 0
+	for i < len(sent) && now.Sub(sent[i]) >= time.Hour {
+		i++
+	}
+	sent = sent[i:]
+	if len(sent) == 0 {
+		delete(b.sent, token)
+	} else {
+		b.sent[token] = sent
+	}
+	return sent
+}
+
+// Allow reports whether another background push to token fits in the budget.
+// If not, it also returns how long until it does.
+func (b *SilentPushBudget) Allow(token string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	sent := b.prune(token, now)
+	if len(sent) < b.max() {
+		return true, 0
+	}
+	return false, sent[len(sent)-b.max()].Add(time.Hour).Sub(now)
+}
+
+// Record records a background push sent to token.
+func (b *SilentPushBudget) Record(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sent == nil {
+		b.sent = make(map[string][]time.Time)
+	}
+	b.sent[token] = append(b.prune(token, time.Now()), time.Now())
+}
+
+// checkSilentPush checks the Sender's SilentPushBudget, if any, before a
+// message is sent to a single recipient.  It returns a function recording the
+// push once sent.
+func (s *Sender) checkSilentPush(msg *Message, to string) (func(*Result), error) {
+	b := s.SilentPushBudget
+	if b == nil || !isSilentPush(msg) || strings.HasPrefix(to, TopicPrefix) {
+		return func(*Result) {}, nil
+	}
+	if ok, retryAfter := b.Allow(to); !ok {
+		if b.Policy == SilentPushDefer {
+			return nil, &SilentPushDeferredError{to, retryAfter}
+		}
+		log.Printf("silent push budget of %d per hour exceeded for %s", b.max(), to)
+	}
+	return func(result *Result) {
+		if result.MessageID != "" {
+			b.Record(to)
+		}
+	}, nil
+}
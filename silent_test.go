@@ -0,0 +1,38 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSilentPushBudget(t *testing.T) {
+	b := &SilentPushBudget{MaxPerHour: 2}
+	b.Record("token")
+	ok, _ := b.Allow("token")
+	assert.True(t, ok)
+	b.Record("token")
+	ok, retryAfter := b.Allow("token")
+	assert.False(t, ok)
+	assert.True(t, retryAfter > 59*time.Minute && retryAfter <= time.Hour)
+	ok, _ = b.Allow("other")
+	assert.True(t, ok)
+}
+
+func TestSendSilentPushDeferred(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.SilentPushBudget = &SilentPushBudget{MaxPerHour: 1, Policy: SilentPushDefer}
+	silent := &Message{ContentAvailable: true}
+	_, err := s.SendNoRetry(silent, "token")
+	assert.NoError(t, err)
+	_, err = s.SendWithRetries(silent, "token", 1)
+	assert.IsType(t, &SilentPushDeferredError{}, err)
+	// topic messages and messages with a notification are not tracked
+	_, err = s.checkSilentPush(silent, topic)
+	assert.NoError(t, err)
+	_, err = s.checkSilentPush(&Message{ContentAvailable: true, Notification: &Notification{}}, "token")
+	assert.NoError(t, err)
+}
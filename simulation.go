@@ -0,0 +1,145 @@
+package gcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SimulationTransport is an http.RoundTripper that answers requests to the
+// connection server with synthetic outcomes instead of sending them, so that
+// capacity tests and chaos drills can run the whole dispatch pipeline without
+// contacting Google.  Use it as the Transport of a Sender's Client.
+//
+// SimulationTransport is safe for concurrent use as long as its fields are not
+// modified once it is in use.
+type SimulationTransport struct {
+	// Errors maps result errors, e.g. ErrorNotRegistered, to the probability
+	// (0 to 1) of each recipient getting them.  Other recipients succeed.
+	Errors map[string]float64
+	// HTTPErrorRate is the probability of a request failing with 503.
+	HTTPErrorRate float64
+	// Latency is the base latency of each request.
+	Latency time.Duration
+	// Jitter is the max random latency added to Latency.
+	Jitter time.Duration
+	// Source is the source of randomness; nil means a time-seeded one.
+	Source rand.Source
+
+	once sync.Once
+	mu   sync.Mutex
+	rand *rand.Rand
+	seq  int64
+}
+
+func (t *SimulationTransport) random() (float64, time.Duration, int64) {
+	t.once.Do(func() {
+		src := t.Source
+		if src == nil {
+			src = rand.NewSource(time.Now().UnixNano())
+		}
+		t.rand = rand.New(src)
+	})
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var jitter time.Duration
+	if t.Jitter > 0 {
+		jitter = time.Duration(t.rand.Int63n(int64(t.Jitter)))
+	}
+	t.seq++
+	return t.rand.Float64(), jitter, t.seq
+}
+
+// outcome draws the result error of a recipient, or "" for success.
+func (t *SimulationTransport) outcome() string {
+	p, _, _ := t.random()
+	for _, code := range sortedKeys(t.Errors) {
+		if p < t.Errors[code] {
+			return code
+		}
+		p -= t.Errors[code]
+	}
+	return ""
+}
+
+// RoundTrip answers the request with a synthetic response.
+func (t *SimulationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var msg message
+	if req.Body != nil {
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+			return t.respond(req, http.StatusBadRequest, []byte(err.Error())), nil
+		}
+	}
+
+	p, jitter, seq := t.random()
+	if delay := t.Latency + jitter; delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if p < t.HTTPErrorRate {
+		return t.respond(req, http.StatusServiceUnavailable, nil), nil
+	}
+
+	resp := &response{}
+	if msg.condition != "" || strings.HasPrefix(msg.to, TopicPrefix) {
+		if code := t.outcome(); code != "" {
+			resp.Err = code
+		} else {
+			resp.MessageID = seq
+		}
+	} else {
+		resp.MulticastID = seq
+		recipients := len(msg.registrationIds)
+		if msg.to != "" {
+			recipients = 1
+		}
+		for i := 0; i < recipients; i++ {
+			if code := t.outcome(); code != "" {
+				resp.Results = append(resp.Results, result{Err: code})
+				resp.Failure++
+			} else {
+				resp.Results = append(resp.Results, result{MessageID: fmt.Sprintf("0:%d.%d", seq, i)})
+				resp.Success++
+			}
+		}
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return t.respond(req, http.StatusOK, body), nil
+}
+
+func (t *SimulationTransport) respond(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
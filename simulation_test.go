@@ -0,0 +1,44 @@
+package gcm
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulationTransport(t *testing.T) {
+	sim := &SimulationTransport{
+		Errors:  map[string]float64{ErrorNotRegistered: 0.2, ErrorUnavailable: 0.1},
+		Latency: time.Millisecond,
+		Jitter:  time.Millisecond,
+		Source:  rand.NewSource(1),
+	}
+	s := NewSenderWithHTTPClient("test-api-key", &http.Client{Transport: sim})
+	regIDs := make([]string, MaxRegistrationIDs)
+	for i := range regIDs {
+		regIDs[i] = "regId"
+	}
+	start := time.Now()
+	result, err := s.SendMulticastNoRetry(msg, regIDs)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= time.Millisecond)
+	counts := make(map[string]int)
+	for _, res := range result.Results {
+		counts[res.Error]++
+	}
+	assert.InDelta(t, 700, counts[""], 60)
+	assert.InDelta(t, 200, counts[ErrorNotRegistered], 50)
+	assert.InDelta(t, 100, counts[ErrorUnavailable], 40)
+	assert.Equal(t, counts[""], result.Success)
+
+	res, err := s.SendNoRetry(msg, topic)
+	assert.NoError(t, err)
+	assert.True(t, res.MessageID != "" || res.Error != "")
+
+	sim.HTTPErrorRate = 1
+	_, err = s.SendNoRetry(msg, "regId")
+	assert.EqualError(t, err, "503 error: 503 Service Unavailable")
+}
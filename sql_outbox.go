@@ -0,0 +1,182 @@
+package gcm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect defines the SQL dialect of the database of a SQLOutbox.
+type SQLDialect int
+
+const (
+	// SQLDialectPostgres is the dialect of PostgreSQL 9.5 or later.
+	SQLDialectPostgres SQLDialect = iota
+	// SQLDialectMySQL is the dialect of MySQL 8.0 or later.
+	SQLDialectMySQL
+)
+
+// DefaultSQLClaimTTL is the ClaimTTL used by a SQLOutbox when none is set.
+const DefaultSQLClaimTTL = time.Minute
+
+// SQLOutbox is an Outbox on a database table, so that a job can be added in
+// the same transaction as the changes it notifies about with AddTx, and is
+// only sent once they are committed, without an extra broker.  Entries are
+// claimed with SELECT ... FOR UPDATE SKIP LOCKED, so that OutboxRelay replicas
+// share the jobs without blocking each other.
+//
+// The table must have the following columns, e.g. for PostgreSQL:
+//
+//	CREATE TABLE gcm_outbox (
+//		id            BIGSERIAL PRIMARY KEY,
+//		job           BYTEA NOT NULL,
+//		claimed_until TIMESTAMPTZ
+//	)
+//
+// or for MySQL:
+//
+//	CREATE TABLE gcm_outbox (
+//		id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+//		job           LONGBLOB NOT NULL,
+//		claimed_until DATETIME(6) NULL
+//	)
+type SQLOutbox struct {
+	// DB is the database.
+	DB *sql.DB
+	// Dialect is the SQL dialect of DB.
+	Dialect SQLDialect
+	// Table is the name of the table.
+	Table string
+	// ClaimTTL is how long a claimed entry stays unacknowledged before it can
+	// be claimed again.  Zero means DefaultSQLClaimTTL.
+	ClaimTTL time.Duration
+	// Codec encodes the jobs.  Nil means JSONCodec.
+	Codec Codec
+}
+
+// Add adds a job to the table.
+func (o *SQLOutbox) Add(job *Job) error {
+	return o.add(o.DB, job)
+}
+
+// AddTx adds a job to the table within tx, so that it is only sent if tx is
+// committed.
+func (o *SQLOutbox) AddTx(tx *sql.Tx, job *Job) error {
+	return o.add(tx, job)
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (o *SQLOutbox) add(db sqlExecer, job *Job) error {
+	data, err := codecOrDefault(o.Codec).Encode(job)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("INSERT INTO %s (job) VALUES (%s)", o.Table, o.placeholder(1)), data)
+	return err
+}
+
+// Claim claims up to n entries that are not claimed or whose claim expired,
+// oldest first.
+func (o *SQLOutbox) Claim(n int) (entries []OutboxEntry, err error) {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	rows, err := tx.Query(fmt.Sprintf(
+		"SELECT id, job FROM %s WHERE claimed_until IS NULL OR claimed_until < %s ORDER BY id LIMIT %d FOR UPDATE SKIP LOCKED",
+		o.Table, o.placeholder(1), n), now)
+	if err != nil {
+		return nil, err
+	}
+	var ids, bad []interface{}
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err = rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		job, derr := codecOrDefault(o.Codec).Decode(data)
+		if derr != nil {
+			log.Printf("dropping outbox entry %d that failed to decode: %v", id, derr)
+			bad = append(bad, id)
+			continue
+		}
+		ids = append(ids, id)
+		entries = append(entries, OutboxEntry{ID: strconv.FormatInt(id, 10), Job: job})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(bad) > 0 {
+		if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", o.Table, o.placeholders(1, len(bad))), bad...); err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return nil, tx.Commit()
+	}
+
+	ttl := o.ClaimTTL
+	if ttl <= 0 {
+		ttl = DefaultSQLClaimTTL
+	}
+	args := append([]interface{}{now.Add(ttl)}, ids...)
+	if _, err = tx.Exec(fmt.Sprintf("UPDATE %s SET claimed_until = %s WHERE id IN (%s)",
+		o.Table, o.placeholder(1), o.placeholders(2, len(ids))), args...); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Ack deletes the entries.
+func (o *SQLOutbox) Ack(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return errors.New("invalid outbox entry id: " + id)
+		}
+		args[i] = n
+	}
+	_, err := o.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", o.Table, o.placeholders(1, len(ids))), args...)
+	return err
+}
+
+// placeholder returns the placeholder of the i-th argument, counting from 1.
+func (o *SQLOutbox) placeholder(i int) string {
+	if o.Dialect == SQLDialectPostgres {
+		return "$" + strconv.Itoa(i)
+	}
+	return "?"
+}
+
+// placeholders returns n comma-separated placeholders starting with the i-th
+// argument.
+func (o *SQLOutbox) placeholders(i, n int) string {
+	p := make([]string, n)
+	for j := range p {
+		p[j] = o.placeholder(i + j)
+	}
+	return strings.Join(p, ", ")
+}
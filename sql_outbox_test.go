@@ -0,0 +1,145 @@
+package gcm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOutboxDriver is a database/sql driver that understands the statements of
+// a SQLOutbox.
+type fakeOutboxDriver struct {
+	mu      sync.Mutex
+	nextID  int64
+	rows    map[int64]*fakeOutboxRow
+	queries []string
+}
+
+type fakeOutboxRow struct {
+	job          []byte
+	claimedUntil time.Time
+}
+
+func (d *fakeOutboxDriver) Open(name string) (driver.Conn, error) { return fakeOutboxConn{d}, nil }
+
+type fakeOutboxConn struct{ d *fakeOutboxDriver }
+
+func (c fakeOutboxConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeOutboxStmt{c.d, query}, nil
+}
+func (c fakeOutboxConn) Close() error              { return nil }
+func (c fakeOutboxConn) Begin() (driver.Tx, error) { return c, nil }
+func (c fakeOutboxConn) Commit() error             { return nil }
+func (c fakeOutboxConn) Rollback() error           { return nil }
+
+type fakeOutboxStmt struct {
+	d     *fakeOutboxDriver
+	query string
+}
+
+func (s fakeOutboxStmt) Close() error  { return nil }
+func (s fakeOutboxStmt) NumInput() int { return -1 }
+
+func (s fakeOutboxStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, s.query)
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		d.nextID++
+		d.rows[d.nextID] = &fakeOutboxRow{job: args[0].([]byte)}
+	case strings.HasPrefix(s.query, "UPDATE"):
+		for _, id := range args[1:] {
+			d.rows[id.(int64)].claimedUntil = args[0].(time.Time)
+		}
+	case strings.HasPrefix(s.query, "DELETE"):
+		for _, id := range args {
+			delete(d.rows, id.(int64))
+		}
+	default:
+		return nil, fmt.Errorf("unexpected exec: %s", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeOutboxStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, s.query)
+	var limit int
+	if i := strings.Index(s.query, "LIMIT "); i < 0 || !strings.HasSuffix(s.query, "FOR UPDATE SKIP LOCKED") {
+		return nil, fmt.Errorf("unexpected query: %s", s.query)
+	} else {
+		fmt.Sscanf(s.query[i:], "LIMIT %d", &limit)
+	}
+	rows := &fakeOutboxRows{}
+	for id := int64(1); id <= d.nextID && len(rows.values) < limit; id++ {
+		if row, ok := d.rows[id]; ok && row.claimedUntil.Before(args[0].(time.Time)) {
+			rows.values = append(rows.values, []driver.Value{id, row.job})
+		}
+	}
+	return rows, nil
+}
+
+type fakeOutboxRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeOutboxRows) Columns() []string { return []string{"id", "job"} }
+func (r *fakeOutboxRows) Close() error      { return nil }
+func (r *fakeOutboxRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var fakeOutbox = &fakeOutboxDriver{rows: map[int64]*fakeOutboxRow{}}
+
+func init() {
+	sql.Register("fakeoutbox", fakeOutbox)
+}
+
+func TestSQLOutbox(t *testing.T) {
+	db, err := sql.Open("fakeoutbox", "")
+	assert.NoError(t, err)
+	defer db.Close()
+	a := &SQLOutbox{DB: db, Table: "gcm_outbox", ClaimTTL: time.Millisecond}
+	b := &SQLOutbox{DB: db, Table: "gcm_outbox", Dialect: SQLDialectMySQL}
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, a.AddTx(tx, &Job{Class: ClassReminder, Message: msg, To: "1"}))
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, b.Add(&Job{Class: ClassReminder, Message: msg, To: "2"}))
+
+	entries, err := a.Claim(1)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "1", entries[0].Job.To)
+
+	// the claim of a expires before b claims
+	time.Sleep(2 * time.Millisecond)
+	entries, err = b.Claim(10)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, b.Ack(entries[0].ID, entries[1].ID))
+	entries, err = a.Claim(10)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	assert.Contains(t, fakeOutbox.queries, "INSERT INTO gcm_outbox (job) VALUES ($1)")
+	assert.Contains(t, fakeOutbox.queries, "DELETE FROM gcm_outbox WHERE id IN (?, ?)")
+	assert.Contains(t, fakeOutbox.queries, "SELECT id, job FROM gcm_outbox WHERE claimed_until IS NULL OR claimed_until < ? ORDER BY id LIMIT 10 FOR UPDATE SKIP LOCKED")
+}
@@ -0,0 +1,159 @@
+package gcm
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxLastErrors is the number of recent errors kept in SenderStats.
+const maxLastErrors = 10
+
+// ErrorRecord is an error that occurred at some time.
+type ErrorRecord struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// SenderStats holds the request statistics of a Sender since it was created.
+type SenderStats struct {
+	// Requests is the number of requests made to the GCM connection server.
+	Requests int64 `json:"requests"`
+	// Failures is the number of requests that failed with a transport or HTTP
+	// error.
+	Failures int64 `json:"failures"`
+	// SuccessRate is the ratio of requests that did not fail.
+	SuccessRate float64 `json:"success_rate"`
+	// LastErrors lists the most recent failures, the latest last.
+	LastErrors []ErrorRecord `json:"last_errors,omitempty"`
+	// Latency holds the distribution of request latencies by TargetType.
+	Latency map[string]LatencyHistogram `json:"latency,omitempty"`
+}
+
+type senderStats struct {
+	mu         sync.Mutex
+	requests   int64
+	failures   int64
+	lastErrors []ErrorRecord
+	latencies  [numTargetTypes]LatencyHistogram
+	// authFailures is the number of consecutive requests rejected with 401.
+	authFailures int
+}
+
+func (st *senderStats) record(err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.requests++
+	var httpErr httpError
+	if errors.As(err, &httpErr) && httpErr.statusCode == http.StatusUnauthorized {
+		st.authFailures++
+	} else {
+		st.authFailures = 0
+	}
+	if err == nil {
+		return
+	}
+	st.failures++
+	st.lastErrors = append(st.lastErrors, ErrorRecord{time.Now(), err.Error()})
+	if len(st.lastErrors) > maxLastErrors {
+		st.lastErrors = st.lastErrors[len(st.lastErrors)-maxLastErrors:]
+	}
+}
+
+func (st *senderStats) recordLatency(t TargetType, d time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.latencies[t].observe(d)
+}
+
+// Stats returns the request statistics of the Sender.
+func (s *Sender) Stats() SenderStats {
+	st := &s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stats := SenderStats{
+		Requests:   st.requests,
+		Failures:   st.failures,
+		LastErrors: append([]ErrorRecord(nil), st.lastErrors...),
+	}
+	for t, h := range st.latencies {
+		if h.Count == 0 {
+			continue
+		}
+		if stats.Latency == nil {
+			stats.Latency = make(map[string]LatencyHistogram)
+		}
+		stats.Latency[TargetType(t).String()] = h.copy()
+	}
+	if st.requests > 0 {
+		stats.SuccessRate = float64(st.requests-st.failures) / float64(st.requests)
+	}
+	return stats
+}
+
+// QueueStats holds the statistics of a Queue.
+type QueueStats struct {
+	// Depth is the number of pending jobs.
+	Depth int `json:"depth"`
+	// ClassDepth is the number of pending jobs per class.
+	ClassDepth map[string]int `json:"class_depth"`
+	// Dropped is the number of jobs dropped because the Queue was full.
+	Dropped int64 `json:"dropped"`
+	// DrainTime is the estimated time to dequeue all pending jobs, if known.
+	DrainTime *time.Duration `json:"drain_time_ns,omitempty"`
+	// Shedder holds the state of the LoadShedder of the Queue, if any.
+	Shedder *ShedderStats `json:"shedder,omitempty"`
+}
+
+// ShedderStats holds the state of a LoadShedder.
+type ShedderStats struct {
+	// Shedding reports whether less urgent jobs are currently shed.
+	Shedding bool `json:"shedding"`
+	// Shed is the number of jobs dropped by ShedDrop per class.
+	Shed map[string]int64 `json:"shed"`
+}
+
+// Stats returns the statistics of the Queue.
+func (q *Queue) Stats() QueueStats {
+	stats := QueueStats{
+		Dropped:    q.Dropped(),
+		ClassDepth: make(map[string]int),
+	}
+	if q.shedder != nil {
+		stats.Shedder = &ShedderStats{Shedding: q.shedder.Shedding(), Shed: make(map[string]int64)}
+	}
+	q.mu.Lock()
+	for _, qc := range q.classes {
+		stats.ClassDepth[qc.class.String()] = len(qc.jobs)
+		stats.Depth += len(qc.jobs)
+		if stats.Shedder != nil {
+			stats.Shedder.Shed[qc.class.String()] = q.shedder.ShedCount(qc.class)
+		}
+	}
+	q.mu.Unlock()
+	if d, ok := q.DrainTime(); ok {
+		stats.DrainTime = &d
+	}
+	return stats
+}
+
+// NewStatsHandler returns an http.Handler serving the statistics of the Sender
+// and the Queue as JSON, e.g. to be mounted on an ops port.  Either may be nil.
+func NewStatsHandler(s *Sender, q *Queue) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var stats struct {
+			Sender *SenderStats `json:"sender,omitempty"`
+			Queue  *QueueStats  `json:"queue,omitempty"`
+		}
+		if s != nil {
+			senderStats := s.Stats()
+			stats.Sender = &senderStats
+		}
+		if q != nil {
+			queueStats := q.Stats()
+			stats.Queue = &queueStats
+		}
+		writeJSON(w, stats)
+	})
+}
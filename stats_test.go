@@ -0,0 +1,73 @@
+package gcm
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderStats(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{statusCode: http.StatusBadRequest},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.SendNoRetry(msg, "regId")
+	s.SendNoRetry(msg, "regId")
+	stats := s.Stats()
+	assert.Equal(t, int64(2), stats.Requests)
+	assert.Equal(t, int64(1), stats.Failures)
+	assert.Equal(t, 0.5, stats.SuccessRate)
+	if assert.Len(t, stats.LastErrors, 1) {
+		assert.Equal(t, "400 error: 400 Bad Request", stats.LastErrors[0].Error)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	h := NewStatsHandler(NewSender("test-api-key"), q)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var stats map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, `{"requests":0,"failures":0,"success_rate":0}`, string(stats["sender"]))
+	assert.Equal(t, `{"depth":1,"class_depth":{"marketing":1,"reminder":0,"transactional":0},"dropped":0}`, string(stats["queue"]))
+}
+
+func TestQueueStatsShedder(t *testing.T) {
+	assert.Nil(t, NewQueue(QueueConfig{}).Stats().Shedder)
+
+	l := &LoadShedder{MaxErrorRate: 0.5, MinSamples: 1}
+	q := NewQueue(QueueConfig{Shedder: l})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "1"}))
+	assert.Equal(t, &ShedderStats{Shed: map[string]int64{"marketing": 0, "reminder": 0, "transactional": 0}}, q.Stats().Shedder)
+
+	l.Observe(0, true)
+	dequeueClasses(t, q, 1)
+	assert.Equal(t, &ShedderStats{Shedding: true, Shed: map[string]int64{"marketing": 1, "reminder": 0, "transactional": 0}}, q.Stats().Shedder)
+}
+
+// expvarRuns makes the expvar names of each run of TestPublishExpvar unique,
+// as expvar cannot unpublish them, e.g. with -count=2.
+var expvarRuns int32
+
+func TestPublishExpvar(t *testing.T) {
+	prefix := fmt.Sprint("test", atomic.AddInt32(&expvarRuns, 1))
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassReminder, Message: msg, To: "1"}))
+	PublishExpvar(prefix, NewSender("test-api-key"), q)
+	assert.Equal(t, `{"requests":0,"failures":0,"success_rate":0}`, expvar.Get(prefix+".sender").String())
+	assert.Equal(t, `{"depth":1,"class_depth":{"marketing":0,"reminder":1,"transactional":0},"dropped":0}`, expvar.Get(prefix+".queue").String())
+	assert.Panics(t, func() { PublishExpvar(prefix, NewSender("test-api-key"), nil) })
+}
@@ -0,0 +1,55 @@
+package gcm
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type tagsKey struct{}
+
+func contextWithTags(ctx context.Context, tags map[string]string) context.Context {
+	if len(tags) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// TagsFromContext returns the Tags of the message being sent, e.g. from the
+// context of the request given to a RequestSigner.
+func TagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// job has the fields of Job without its methods.
+type job Job
+
+// MarshalJSON marshals the job to json, including the Tags of its message,
+// so that they survive persistence.
+func (j Job) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		job
+		Tags map[string]string `json:"tags,omitempty"`
+	}{job: job(j)}
+	if j.Message != nil {
+		aux.Tags = j.Message.Tags
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON unmarshals the job from json, restoring the Tags of its
+// message.
+func (j *Job) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		job
+		Tags map[string]string `json:"tags,omitempty"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*j = Job(aux.job)
+	if j.Message != nil && aux.Tags != nil {
+		j.Message.Tags = aux.Tags
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagsAreNotSent(t *testing.T) {
+	b, err := json.Marshal(message{Message: Message{Tags: map[string]string{"campaign": "c"}}, to: "regId"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"to":"regId"}`, string(b))
+}
+
+func TestJobTagsSurviveJSON(t *testing.T) {
+	job := &Job{Class: ClassMarketing, Message: &Message{Tags: map[string]string{"campaign": "c"}}, To: "regId"}
+	b, err := json.Marshal(job)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"class":3,"message":{},"to":"regId","tags":{"campaign":"c"}}`, string(b))
+	decoded, err := JSONCodec{}.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, job, decoded)
+}
+
+func TestSendCarriesTags(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	store := NewMemoryBlobStore()
+	s := NewSender("test-api-key")
+	s.Archiver = &Archiver{Store: store, SampleRate: 1}
+	var signed map[string]string
+	s.Signer = RequestSignerFunc(func(req *http.Request, body []byte) error {
+		signed = TagsFromContext(req.Context())
+		return nil
+	})
+	tags := map[string]string{"campaign": "c"}
+	_, err := s.SendNoRetry(&Message{Tags: tags}, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, tags, signed)
+
+	var record ArchiveRecord
+	assert.NoError(t, json.Unmarshal(store.Get(store.Keys()[0]), &record))
+	assert.Equal(t, tags, record.Tags)
+}
@@ -0,0 +1,126 @@
+package gcm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTelemetryWindow = time.Hour
+	telemetryBuckets       = 60
+)
+
+// Telemetry tracks rolling rates of canonical registration IDs and
+// NotRegistered errors per app, as identified by the RestrictedPackageName of
+// the sent messages.  A spike in canonical IDs usually indicates a client-side
+// token rotation bug, while a spike in NotRegistered errors indicates mass
+// uninstalls.  Topic and device group messages do not report per-token
+// results and are not tracked.
+//
+// Telemetry is safe for concurrent use.
+type Telemetry struct {
+	// Window is the period covered by the rates.  Zero means an hour.
+	Window time.Duration
+
+	mu   sync.Mutex
+	apps map[string][]telemetryBucket
+}
+
+type telemetryBucket struct {
+	start         time.Time
+	total         int64
+	canonical     int64
+	notRegistered int64
+}
+
+// TokenHealth holds the per-token results of an app within the Telemetry window.
+type TokenHealth struct {
+	Total         int64
+	Canonical     int64
+	NotRegistered int64
+	// CanonicalRate is the ratio of results with a canonical registration ID.
+	CanonicalRate float64
+	// UninstallRate is the ratio of results with a NotRegistered error.
+	UninstallRate float64
+}
+
+func (t *Telemetry) window() time.Duration {
+	if t.Window <= 0 {
+		return defaultTelemetryWindow
+	}
+	return t.Window
+}
+
+// Observe records the per-token results of a message sent for app.
+func (t *Telemetry) Observe(app string, results ...Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.apps == nil {
+		t.apps = make(map[string][]telemetryBucket)
+	}
+	now := time.Now()
+	buckets := t.prune(t.apps[app], now)
+	width := t.window() / telemetryBuckets
+	if len(buckets) == 0 || now.Sub(buckets[len(buckets)-1].start) >= width {
+		buckets = append(buckets, telemetryBucket{start: now})
+	}
+	bucket := &buckets[len(buckets)-1]
+	for _, res := range results {
+		if res.MessageID == "" && res.Error == "" {
+			continue
+		}
+		bucket.total++
+		if res.CanonicalRegistrationID != "" {
+			bucket.canonical++
+		}
+		if res.Error == ErrorNotRegistered {
+			bucket.notRegistered++
+		}
+	}
+	t.apps[app] = buckets
+}
+
+func (t *Telemetry) prune(buckets []telemetryBucket, now time.Time) []telemetryBucket {
+	i := 0
+	for i < len(buckets) && now.Sub(buckets[i].start) > t.window() {
+		i++
+	}
+	return buckets[i:]
+}
+
+// Health returns the token health of app within the window.
+func (t *Telemetry) Health(app string) TokenHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var health TokenHealth
+	for _, bucket := range t.prune(t.apps[app], time.Now()) {
+		health.Total += bucket.total
+		health.Canonical += bucket.canonical
+		health.NotRegistered += bucket.notRegistered
+	}
+	if health.Total > 0 {
+		health.CanonicalRate = float64(health.Canonical) / float64(health.Total)
+		health.UninstallRate = float64(health.NotRegistered) / float64(health.Total)
+	}
+	return health
+}
+
+// Apps returns the apps with results recorded, in sorted order.
+func (t *Telemetry) Apps() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	apps := make([]string, 0, len(t.apps))
+	for app := range t.apps {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// observe records the results in the Sender's Telemetry, if any.
+func (s *Sender) observe(msg *Message, results ...Result) {
+	if s.Telemetry != nil && msg != nil {
+		s.Telemetry.Observe(msg.RestrictedPackageName, results...)
+	}
+}
@@ -0,0 +1,51 @@
+package gcm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelemetryHealth(t *testing.T) {
+	tel := &Telemetry{}
+	tel.Observe("com.example",
+		Result{MessageID: "1", CanonicalRegistrationID: "new"},
+		Result{MessageID: "2"},
+		Result{Error: ErrorNotRegistered},
+		Result{Error: ErrorUnavailable},
+		Result{Success: 1}, // device group results are ignored
+	)
+	assert.Equal(t, TokenHealth{
+		Total:         4,
+		Canonical:     1,
+		NotRegistered: 1,
+		CanonicalRate: 0.25,
+		UninstallRate: 0.25,
+	}, tel.Health("com.example"))
+	assert.Equal(t, TokenHealth{}, tel.Health("com.other"))
+	assert.Equal(t, []string{"com.example"}, tel.Apps())
+}
+
+func TestTelemetryWindow(t *testing.T) {
+	tel := &Telemetry{Window: 20 * time.Millisecond}
+	tel.Observe("app", Result{Error: ErrorNotRegistered})
+	time.Sleep(30 * time.Millisecond)
+	tel.Observe("app", Result{MessageID: "1"})
+	assert.Equal(t, TokenHealth{Total: 1}, tel.Health("app"))
+}
+
+func TestSendMulticastWithTelemetry(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &response{
+		MulticastID: 1, Success: 1, Failure: 1, CanonicalIds: 1,
+		Results: []result{{MessageID: "id1", RegistrationID: "new"}, {Err: ErrorNotRegistered}},
+	}})
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Telemetry = &Telemetry{}
+	_, err := s.SendMulticastNoRetry(&Message{RestrictedPackageName: "com.example"}, twoRecipients)
+	assert.NoError(t, err)
+	health := s.Telemetry.Health("com.example")
+	assert.Equal(t, 0.5, health.CanonicalRate)
+	assert.Equal(t, 0.5, health.UninstallRate)
+}
@@ -0,0 +1,106 @@
+package gcm
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs returns the functions of notification templates rendered for
+// locale, which pick texts that are grammatically correct in its language:
+//
+//	plural N CASE TEXT...
+//		picks the TEXT for "=N", else for the CLDR plural category of N in
+//		locale, e.g. "one" or "few", else for "other", and replaces # in it
+//		with N
+//	select VALUE CASE TEXT...
+//		picks the TEXT for VALUE, e.g. a gender, else for "other"
+//
+// For example:
+//
+//	{{plural .Count "=0" "No new messages" "one" "# new message" "other" "# new messages"}}
+func TemplateFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"plural": func(n interface{}, cases ...string) (string, error) {
+			number, category, err := pluralOf(locale, n)
+			if err != nil {
+				return "", err
+			}
+			text, err := pickCase(cases, "="+number, category)
+			return strings.Replace(text, "#", number, -1), err
+		},
+		"select": func(value interface{}, cases ...string) (string, error) {
+			return pickCase(cases, fmt.Sprint(value))
+		},
+	}
+}
+
+// pluralOf returns the number n formatted, and its plural category.
+func pluralOf(locale string, n interface{}) (string, string, error) {
+	switch n := n.(type) {
+	case int:
+		return strconv.Itoa(n), PluralCategory(locale, int64(n)), nil
+	case int32:
+		return strconv.Itoa(int(n)), PluralCategory(locale, int64(n)), nil
+	case int64:
+		return strconv.FormatInt(n, 10), PluralCategory(locale, n), nil
+	case float64:
+		if n == float64(int64(n)) {
+			return pluralOf(locale, int64(n))
+		}
+		// the plural rules of fractions are not supported
+		return strconv.FormatFloat(n, 'f', -1, 64), PluralOther, nil
+	}
+	return "", "", fmt.Errorf("plural expects a number, got %T", n)
+}
+
+// pickCase returns the text of the first of keys found in cases, a list of
+// case and text pairs, or else of "other".
+func pickCase(cases []string, keys ...string) (string, error) {
+	if len(cases)%2 != 0 {
+		return "", fmt.Errorf("expected case and text pairs, got %d arguments", len(cases))
+	}
+	for _, key := range append(keys, PluralOther) {
+		for i := 0; i < len(cases); i += 2 {
+			if cases[i] == key {
+				return cases[i+1], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no case for %q or %q", keys[0], PluralOther)
+}
+
+// NotificationTemplate renders the title and body of notifications from
+// text/template templates with TemplateFuncs, e.g. on the server rather than
+// with localization keys.
+type NotificationTemplate struct {
+	Title string
+	Body  string
+}
+
+// Render renders the templates with data for locale.
+func (t *NotificationTemplate) Render(locale string, data interface{}) (*Notification, error) {
+	title, err := renderTemplate("title", t.Title, locale, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := renderTemplate("body", t.Body, locale, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Notification{Title: title, Body: body}, nil
+}
+
+func renderTemplate(name, text, locale string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(TemplateFuncs(locale)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,39 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationTemplate(t *testing.T) {
+	tmpl := &NotificationTemplate{
+		Title: `{{select .Gender "female" "Elle" "male" "Il" "other" "Iel"}} vous a écrit`,
+		Body:  `{{plural .Count "=0" "Aucun message" "one" "# nouveau message" "other" "# nouveaux messages"}}`,
+	}
+	n, err := tmpl.Render("fr", map[string]interface{}{"Gender": "female", "Count": 0})
+	assert.NoError(t, err)
+	assert.Equal(t, &Notification{Title: "Elle vous a écrit", Body: "Aucun message"}, n)
+	n, err = tmpl.Render("fr", map[string]interface{}{"Gender": "", "Count": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, &Notification{Title: "Iel vous a écrit", Body: "1 nouveau message"}, n)
+	n, err = tmpl.Render("fr", map[string]interface{}{"Count": 1.5})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5 nouveaux messages", n.Body)
+
+	ru := &NotificationTemplate{Body: `{{plural .Count "one" "# сообщение" "few" "# сообщения" "many" "# сообщений"}}`}
+	for count, body := range map[int64]string{1: "1 сообщение", 3: "3 сообщения", 11: "11 сообщений", 22: "22 сообщения"} {
+		n, err := ru.Render("ru", map[string]int64{"Count": count})
+		assert.NoError(t, err)
+		assert.Equal(t, body, n.Body)
+	}
+
+	_, err = (&NotificationTemplate{Body: `{{plural .Count "one"}}`}).Render("en", map[string]int{"Count": 1})
+	assert.Error(t, err)
+	_, err = (&NotificationTemplate{Body: `{{plural .Count "one" "x"}}`}).Render("en", map[string]int{"Count": 2})
+	assert.Error(t, err, "no case for other")
+	_, err = (&NotificationTemplate{Body: `{{plural .Count "other" "x"}}`}).Render("en", map[string]string{"Count": "2"})
+	assert.Error(t, err)
+	_, err = (&NotificationTemplate{Title: `{{`}).Render("en", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package gcm
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minTokenLength and maxTokenLength bound the length of plausible
+	// registration tokens, which are usually around 150 characters.
+	minTokenLength = 32
+	maxTokenLength = 4096
+)
+
+// SanitizedToken is a registration token changed by SanitizeTokens.
+type SanitizedToken struct {
+	// Index is the position of the token in the tokens sanitized.
+	Index    int
+	Original string
+	Token    string
+}
+
+// RejectedToken is a registration token that SanitizeTokens found malformed.
+type RejectedToken struct {
+	Index  int
+	Token  string
+	Reason string
+}
+
+// SanitizeReport reports the tokens changed and rejected by SanitizeTokens.
+type SanitizeReport struct {
+	Sanitized []SanitizedToken
+	Rejected  []RejectedToken
+}
+
+// SanitizeTokens cleans up registration tokens read from a database or a
+// file, so that one bad row does not fail a whole multicast with 400.  It
+// trims whitespace, quotes and invisible characters, and rejects the tokens
+// that are empty, too short or too long, or have characters registration
+// tokens never have.  It returns the tokens that are not rejected, in order.
+func SanitizeTokens(tokens []string) ([]string, *SanitizeReport) {
+	report := &SanitizeReport{}
+	valid := make([]string, 0, len(tokens))
+	for i, original := range tokens {
+		token := strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Cf, r) { // e.g. zero width spaces and BOMs
+				return -1
+			}
+			return r
+		}, original)
+		token = strings.TrimFunc(token, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '"' || r == '\''
+		})
+		if reason := malformedToken(token); reason != "" {
+			report.Rejected = append(report.Rejected, RejectedToken{i, original, reason})
+			continue
+		}
+		if token != original {
+			report.Sanitized = append(report.Sanitized, SanitizedToken{i, original, token})
+		}
+		valid = append(valid, token)
+	}
+	return valid, report
+}
+
+// malformedToken returns why token cannot be a registration token, if so.
+func malformedToken(token string) string {
+	switch {
+	case token == "":
+		return "empty"
+	case len(token) < minTokenLength:
+		return "too short"
+	case len(token) > maxTokenLength:
+		return "too long"
+	}
+	for _, r := range token {
+		// registration tokens are made of base64url characters and colons
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == ':') {
+			return "invalid character " + strconv.QuoteRune(r)
+		}
+	}
+	return ""
+}
+
+// changed reports whether SanitizeTokens changed or rejected any token.
+func (r *SanitizeReport) changed() bool {
+	return len(r.Sanitized) > 0 || len(r.Rejected) > 0
+}
+
+// sendSanitized sends with send to the valid registration IDs returned by
+// SanitizeTokens, and gives InvalidRegistration results to the rejected ones
+// without sending to them.
+func sendSanitized(valid []string, report *SanitizeReport, send func(regIDs []string) (*MulticastResult, error)) (*MulticastResult, error) {
+	result := &MulticastResult{}
+	var err error
+	if len(valid) > 0 {
+		if result, err = send(valid); result == nil {
+			return nil, err
+		}
+	}
+	// partial results, with err, may cover only the first valid tokens
+	results := make([]Result, 0, len(valid)+len(report.Rejected))
+	next := 0
+	for _, rejected := range report.Rejected {
+		for len(results) < rejected.Index && next < len(result.Results) {
+			results = append(results, result.Results[next])
+			next++
+		}
+		if len(results) < rejected.Index {
+			break
+		}
+		results = append(results, Result{Error: ErrorInvalidRegistration})
+		result.Failure++
+	}
+	result.Results = append(results, result.Results[next:]...)
+	return result, err
+}
@@ -0,0 +1,75 @@
+package gcm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var validToken = "dQw4w9WgXcQ:APA91bH" + strings.Repeat("x", 130)
+
+func TestSanitizeTokens(t *testing.T) {
+	valid, report := SanitizeTokens([]string{
+		validToken,
+		"  " + validToken + "\n",
+		"\ufeff\"" + validToken + "\u200b\"",
+		"",
+		"short",
+		validToken + " " + validToken,
+		strings.Repeat("x", maxTokenLength+1),
+	})
+	assert.Equal(t, []string{validToken, validToken, validToken}, valid)
+	assert.Equal(t, []SanitizedToken{
+		{1, "  " + validToken + "\n", validToken},
+		{2, "\ufeff\"" + validToken + "\u200b\"", validToken},
+	}, report.Sanitized)
+	assert.Equal(t, []RejectedToken{
+		{3, "", "empty"},
+		{4, "short", "too short"},
+		{5, validToken + " " + validToken, "invalid character ' '"},
+		{6, strings.Repeat("x", maxTokenLength+1), "too long"},
+	}, report.Rejected)
+
+	valid, report = SanitizeTokens([]string{validToken})
+	assert.Equal(t, []string{validToken}, valid)
+	assert.False(t, report.changed())
+}
+
+func TestSendMulticastSanitized(t *testing.T) {
+	var sent [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m message
+		json.NewDecoder(r.Body).Decode(&m)
+		sent = append(sent, m.registrationIds)
+		resp := response{Success: len(m.registrationIds)}
+		for range m.registrationIds {
+			resp.Results = append(resp.Results, result{MessageID: "id"})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Endpoint = server.URL
+	s.SanitizeRegistrationIDs = true
+
+	result, err := s.SendMulticastWithRetries(msg, []string{"bad", " " + validToken, "", validToken}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{validToken, validToken}}, sent)
+	assert.Equal(t, 2, result.Success)
+	assert.Equal(t, 2, result.Failure)
+	assert.Equal(t, []Result{
+		{Error: ErrorInvalidRegistration},
+		{MessageID: "id"},
+		{Error: ErrorInvalidRegistration},
+		{MessageID: "id"},
+	}, result.Results)
+
+	result, err = s.SendMulticastNoRetry(msg, []string{"bad"})
+	assert.NoError(t, err)
+	assert.Len(t, sent, 1, "nothing is sent")
+	assert.Equal(t, []Result{{Error: ErrorInvalidRegistration}}, result.Results)
+}
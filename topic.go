@@ -0,0 +1,69 @@
+package gcm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var topicNamePattern = regexp.MustCompile(`^[a-zA-Z0-9-_.~%]+$`)
+
+// reservedTopicPrefixes are the prefixes of topic names reserved by Google.
+var reservedTopicPrefixes = []string{"google", "gcm"}
+
+// misprefixedTopicPattern matches recipients that look like a topic with a
+// misspelled or missing TopicPrefix.
+var misprefixedTopicPattern = regexp.MustCompile(`^/?topics?[/:]([a-zA-Z0-9-_.~%]+)$`)
+
+// TopicError is returned when a topic name is invalid.
+type TopicError struct {
+	Name   string
+	Reason string
+}
+
+func (e *TopicError) Error() string {
+	return fmt.Sprintf("invalid topic %q: %s", e.Name, e.Reason)
+}
+
+// Topic returns the recipient for the named topic, adding TopicPrefix unless
+// name already has it.  Topic names must match [a-zA-Z0-9-_.~%]+ and not start
+// with a prefix reserved by Google, e.g. "google".
+func Topic(name string) (string, error) {
+	topic := name
+	if !strings.HasPrefix(topic, TopicPrefix) {
+		topic = TopicPrefix + topic
+	}
+	if err := validateTopic(topic); err != nil {
+		return "", err
+	}
+	return topic, nil
+}
+
+func validateTopic(topic string) error {
+	name := strings.TrimPrefix(topic, TopicPrefix)
+	if name == "" {
+		return &TopicError{topic, "missing topic name"}
+	}
+	if !topicNamePattern.MatchString(name) {
+		return &TopicError{topic, "topic name should match [a-zA-Z0-9-_.~%]+"}
+	}
+	lower := strings.ToLower(name)
+	for _, prefix := range reservedTopicPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return &TopicError{topic, fmt.Sprintf("topic names starting with %q are reserved, choose another name", prefix)}
+		}
+	}
+	return nil
+}
+
+// validateRecipient checks the topic of a recipient, and that a registration
+// token is not a topic missing TopicPrefix.
+func validateRecipient(to string) error {
+	if strings.HasPrefix(to, TopicPrefix) {
+		return validateTopic(to)
+	}
+	if m := misprefixedTopicPattern.FindStringSubmatch(to); m != nil {
+		return &TopicError{to, fmt.Sprintf("looks like a topic without the %q prefix, use Topic(%q) to send to the topic", TopicPrefix, m[1])}
+	}
+	return nil
+}
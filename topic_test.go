@@ -0,0 +1,48 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopic(t *testing.T) {
+	params := []struct {
+		name  string
+		topic string
+		err   string
+	}{
+		{"news", "/topics/news", ""},
+		{"/topics/news", "/topics/news", ""},
+		{"a-Z_0.9~%20", "/topics/a-Z_0.9~%20", ""},
+		{"", "", `invalid topic "/topics/": missing topic name`},
+		{"/topics/", "", `invalid topic "/topics/": missing topic name`},
+		{"breaking news", "", `invalid topic "/topics/breaking news": topic name should match [a-zA-Z0-9-_.~%]+`},
+		{"news/sports", "", `invalid topic "/topics/news/sports": topic name should match [a-zA-Z0-9-_.~%]+`},
+		{"Google-news", "", `invalid topic "/topics/Google-news": topic names starting with "google" are reserved, choose another name`},
+		{"/topics/gcm", "", `invalid topic "/topics/gcm": topic names starting with "gcm" are reserved, choose another name`},
+	}
+	for _, param := range params {
+		topic, err := Topic(param.name)
+		if param.err != "" {
+			assert.EqualError(t, err, param.err)
+			_, isTopicErr := err.(*TopicError)
+			assert.True(t, isTopicErr)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, param.topic, topic)
+		}
+	}
+}
+
+func TestSendToInvalidTopic(t *testing.T) {
+	s := NewSender("test-api-key")
+	_, err := s.SendNoRetry(msg, "/topics/breaking news")
+	assert.EqualError(t, err, `invalid topic "/topics/breaking news": topic name should match [a-zA-Z0-9-_.~%]+`)
+	_, err = s.SendNoRetry(msg, "/topics/google.alerts")
+	assert.EqualError(t, err, `invalid topic "/topics/google.alerts": topic names starting with "google" are reserved, choose another name`)
+	for _, to := range []string{"topics/news", "/topic/news", "topics:news"} {
+		_, err = s.SendNoRetry(msg, to)
+		assert.EqualError(t, err, `invalid topic "`+to+`": looks like a topic without the "/topics/" prefix, use Topic("news") to send to the topic`)
+	}
+}
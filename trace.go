@@ -0,0 +1,45 @@
+package gcm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// newTraceID returns a random 128-bit trace ID in hex.
+func newTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// injectTraceID adds a trace ID to the data payload of the message under the
+// Sender's TraceIDKey, unless the payload already has one, so that client-side
+// logs can be correlated with the Result of the send.
+func (s *Sender) injectTraceID(m *message) {
+	if s.TraceIDKey == "" {
+		return
+	}
+	if id, ok := m.Data[s.TraceIDKey]; ok {
+		m.traceID = id
+		return
+	}
+	generate := s.TraceIDGenerator
+	if generate == nil {
+		generate = newTraceID
+	}
+	if err := protect(s.PanicPolicy, "TraceIDGenerator", func() { m.traceID = generate() }); err != nil {
+		m.traceID = newTraceID()
+	}
+	m.setData(s.TraceIDKey, m.traceID)
+}
+
+// setData sets a key of the data payload on a copy, leaving the payload of the
+// caller's Message untouched.
+func (m *message) setData(key, value string) {
+	data := make(map[string]string, len(m.Data)+1)
+	for k, v := range m.Data {
+		data[k] = v
+	}
+	data[key] = value
+	m.Data = data
+}
@@ -0,0 +1,51 @@
+package gcm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendWithTraceID(t *testing.T) {
+	var sent []message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var m message
+		json.Unmarshal(body, &m)
+		sent = append(sent, m)
+		var resp response
+		if len(m.registrationIds) > 0 {
+			resp = response{Success: 2, Results: []result{{MessageID: "1"}, {MessageID: "2"}}}
+		} else {
+			resp = success
+		}
+		respBytes, _ := json.Marshal(resp)
+		w.Write(respBytes)
+	}))
+	defer server.Close()
+	GCMEndpoint = server.URL
+
+	s := NewSender("test-api-key")
+	s.TraceIDKey = "trace"
+	s.TraceIDGenerator = func() string { return "t1" }
+	result, err := s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", result.TraceID)
+	assert.Equal(t, map[string]string{"k": "v", "trace": "t1"}, sent[0].Data)
+	assert.Equal(t, map[string]string{"k": "v"}, msg.Data, "original is not modified")
+
+	multicastResult, err := s.SendMulticastWithRetries(&Message{Data: map[string]string{"trace": "mine"}}, twoRecipients, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "mine", multicastResult.TraceID)
+	assert.Equal(t, map[string]string{"trace": "mine"}, sent[1].Data)
+}
+
+func TestNewTraceID(t *testing.T) {
+	id := newTraceID()
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, newTraceID())
+}
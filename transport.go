@@ -0,0 +1,113 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Capabilities describes what a Transport supports, so that callers can adapt
+// messages or pick another Transport.
+type Capabilities struct {
+	// Notification and Data tell whether the notification and data payloads
+	// are supported.
+	Notification bool
+	Data         bool
+	// Topics tells whether topics can be sent to.
+	Topics bool
+	// MaxRecipients is the max number of tokens per Push.
+	MaxRecipients int
+	// MaxPayloadSize is the max size in bytes of the converted payload, or
+	// zero if unknown.
+	MaxPayloadSize int
+}
+
+// Transport is a push provider, e.g. GCM, APNs, or a vendor service such as
+// Huawei Push Kit, Baidu Push or Amazon Device Messaging.  Implementations
+// convert the Message model to the payload of their provider and report the
+// errors of their provider as the equivalent GCM error codes, so that results
+// can be handled alike whatever the provider.
+type Transport interface {
+	// Name identifies the provider, e.g. "gcm" or "hms".
+	Name() string
+	// Capabilities describes what the provider supports.
+	Capabilities() Capabilities
+	// Convert converts a message to the payload of the provider.
+	Convert(msg *Message) ([]byte, error)
+	// Push sends a message to at most MaxRecipients tokens of the provider,
+	// returning their results in order.
+	Push(msg *Message, tokens []string) ([]Result, error)
+}
+
+// GCMTransport is the Transport of a Sender.
+type GCMTransport struct {
+	Sender *Sender
+	// Retries is the number of retries of each Push.
+	Retries int
+}
+
+// Name returns "gcm".
+func (t *GCMTransport) Name() string {
+	return "gcm"
+}
+
+// Capabilities returns the capabilities of GCM.
+func (t *GCMTransport) Capabilities() Capabilities {
+	return Capabilities{Notification: true, Data: true, Topics: true, MaxRecipients: MaxRegistrationIDs, MaxPayloadSize: MaxPayloadSize}
+}
+
+// Convert returns the JSON encoding of the message, without recipients.
+func (t *GCMTransport) Convert(msg *Message) ([]byte, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	return json.Marshal(msg)
+}
+
+// Push sends a message with retries to a registration ID or topic, or to
+// several registration IDs.
+func (t *GCMTransport) Push(msg *Message, tokens []string) ([]Result, error) {
+	if len(tokens) == 1 {
+		result, err := t.Sender.SendWithRetries(msg, tokens[0], t.Retries)
+		if err != nil {
+			return nil, err
+		}
+		return []Result{*result}, nil
+	}
+	result, err := t.Sender.SendMulticastWithRetries(msg, tokens, t.Retries)
+	if result == nil {
+		return nil, err
+	}
+	return result.Results, err
+}
+
+// Name returns "apns".
+func (t *APNsTransport) Name() string {
+	return "apns"
+}
+
+// Capabilities returns the capabilities of APNs.
+func (t *APNsTransport) Capabilities() Capabilities {
+	return Capabilities{Notification: true, Data: true, MaxRecipients: 1, MaxPayloadSize: 4096}
+}
+
+// Convert returns the APNs payload of the message, see ToAPNs.
+func (t *APNsTransport) Convert(msg *Message) ([]byte, error) {
+	n, err := ToAPNs(msg)
+	if err != nil {
+		return nil, err
+	}
+	return n.Payload, nil
+}
+
+// Push sends a message to APNs device tokens, one at a time.
+func (t *APNsTransport) Push(msg *Message, tokens []string) ([]Result, error) {
+	results := make([]Result, 0, len(tokens))
+	for _, token := range tokens {
+		result, err := t.Send(msg, token)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
@@ -0,0 +1,34 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ Transport = &GCMTransport{}
+	_ Transport = &APNsTransport{}
+	_ Transport = &HMSTransport{}
+)
+
+func TestGCMTransport(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &success},
+		&testResponse{response: &response{MulticastID: 1, Success: 1, Failure: 1, Results: []result{{MessageID: "id1"}, {Err: ErrorNotRegistered}}}},
+	)
+	defer server.Close()
+	tr := &GCMTransport{Sender: NewSender("test-api-key")}
+	assert.Equal(t, "gcm", tr.Name())
+	assert.Equal(t, MaxRegistrationIDs, tr.Capabilities().MaxRecipients)
+	payload, err := tr.Convert(msg)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, payload)
+
+	results, err := tr.Push(msg, []string{"regId"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{MessageID: "id"}}, results)
+	results, err = tr.Push(msg, twoRecipients)
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{MessageID: "id1"}, {Error: ErrorNotRegistered}}, results)
+}
@@ -0,0 +1,98 @@
+package gcm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultEllipsis is the Ellipsis of a TruncationPolicy when none is set.
+const DefaultEllipsis = "…"
+
+// TextLimits are the max lengths, in characters, of the title and body of a
+// notification shown on a platform.  Zero means unlimited.
+type TextLimits struct {
+	Title int
+	Body  int
+}
+
+// TruncationPolicy shortens notification titles and bodies exceeding the
+// limits of a platform, at a word boundary and with an ellipsis, rather than
+// letting the OS cut them arbitrarily.
+type TruncationPolicy struct {
+	// Limits maps platforms, e.g. "android" or "ios", to their limits.
+	Limits map[string]TextLimits
+	// Ellipsis is appended to truncated texts.  Empty means DefaultEllipsis.
+	Ellipsis string
+}
+
+// Truncate returns a copy of n with its title and body truncated to the limits
+// of platform, or n itself if they fit.  An empty platform means the strictest
+// limits of all platforms, since a message may reach any of them.
+func (p *TruncationPolicy) Truncate(n *Notification, platform string) *Notification {
+	if p == nil || n == nil {
+		return n
+	}
+	limits := p.strictest()
+	if platform != "" {
+		limits = p.Limits[platform]
+	}
+	title, body := p.truncate(n.Title, limits.Title), p.truncate(n.Body, limits.Body)
+	if title == n.Title && body == n.Body {
+		return n
+	}
+	truncated := *n
+	truncated.Title, truncated.Body = title, body
+	return &truncated
+}
+
+// apply truncates n to the strictest limits when sending.
+func (p *TruncationPolicy) apply(n *Notification) *Notification {
+	return p.Truncate(n, "")
+}
+
+func (p *TruncationPolicy) strictest() TextLimits {
+	var strictest TextLimits
+	for _, limits := range p.Limits {
+		strictest.Title = minLimit(strictest.Title, limits.Title)
+		strictest.Body = minLimit(strictest.Body, limits.Body)
+	}
+	return strictest
+}
+
+// minLimit returns the stricter of two limits, where zero means unlimited.
+func minLimit(a, b int) int {
+	if a == 0 || b != 0 && b < a {
+		return b
+	}
+	return a
+}
+
+// truncate shortens s to at most max characters, including the ellipsis,
+// preferably at the end of a word.
+func (p *TruncationPolicy) truncate(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+	ellipsis := p.Ellipsis
+	if ellipsis == "" {
+		ellipsis = DefaultEllipsis
+	}
+	keep := max - len([]rune(ellipsis))
+	if keep <= 0 {
+		return string(runes[:max])
+	}
+	cut := keep
+	// back up to a word boundary, unless that loses more than half of the
+	// text, e.g. for languages written without spaces
+	for i := keep; i > keep/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	kept := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || r == '\u200d'
+	})
+	return kept + ellipsis
+}
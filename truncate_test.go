@@ -0,0 +1,58 @@
+package gcm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncationPolicy(t *testing.T) {
+	p := &TruncationPolicy{Limits: map[string]TextLimits{
+		"android": {Title: 20, Body: 30},
+		"ios":     {Title: 12},
+	}}
+	n := &Notification{Title: "Your order has shipped", Body: "It will arrive on Tuesday, between 9am and noon"}
+
+	truncated := p.Truncate(n, "android")
+	assert.Equal(t, "Your order has…", truncated.Title)
+	assert.Equal(t, "It will arrive on Tuesday…", truncated.Body, "trailing punctuation is dropped")
+	assert.Equal(t, "Your order has shipped", n.Title, "the notification is not modified")
+
+	truncated = p.Truncate(n, "")
+	assert.Equal(t, "Your order…", truncated.Title, "the strictest limits apply")
+	assert.Equal(t, "It will arrive on Tuesday…", truncated.Body)
+
+	assert.True(t, n == p.Truncate(n, "web"), "no limits")
+	short := &Notification{Title: "Hi"}
+	assert.True(t, short == p.Truncate(short, ""))
+
+	// text without spaces is cut at the limit, without splitting emoji
+	p = &TruncationPolicy{Limits: map[string]TextLimits{"android": {Title: 6}}, Ellipsis: "..."}
+	assert.Equal(t, "注文が...", p.Truncate(&Notification{Title: "注文が発送されました"}, "").Title)
+	assert.Equal(t, "\U0001F600\U0001F600\U0001F600...", p.Truncate(&Notification{Title: "\U0001F600\U0001F600\U0001F600\U0001F600\U0001F600\U0001F600\U0001F600"}, "").Title)
+	assert.Equal(t, "ab", (&TruncationPolicy{Limits: map[string]TextLimits{"ios": {Title: 2}}, Ellipsis: "..."}).Truncate(&Notification{Title: "abcd"}, "").Title)
+}
+
+func TestSendWithTruncation(t *testing.T) {
+	var sent message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &sent)
+		respBytes, _ := json.Marshal(success)
+		w.Write(respBytes)
+	}))
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.Endpoint = server.URL
+	s.Truncation = &TruncationPolicy{Limits: map[string]TextLimits{"android": {Body: 10}}}
+
+	n := &Notification{Title: "t", Body: "a long long body"}
+	_, err := s.SendNoRetry(&Message{Notification: n}, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, "a long…", sent.Notification.Body)
+	assert.Equal(t, "a long long body", n.Body)
+}
@@ -0,0 +1,171 @@
+package gcm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EncodeData flattens the exported fields of a struct into a data payload, one
+// string value per field, so that data-only messages can have a typed
+// contract.  Keys default to the field names and can be set with a `gcm` tag,
+// e.g. `gcm:"order_id"`, with an optional omitempty option.  A tag of "-"
+// skips the field.  Strings, bools and numbers are formatted with strconv and
+// any other values as JSON.
+func EncodeData(v interface{}) (map[string]string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %T", v)
+	}
+	data := make(map[string]string)
+	for i, t := 0, rv.Type(); i < t.NumField(); i++ {
+		key, omitEmpty, ok := dataKey(t.Field(i))
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		s, err := formatDataValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", t.Field(i).Name, err)
+		}
+		data[key] = s
+	}
+	return data, nil
+}
+
+// DecodeData parses a data payload flattened by EncodeData into the struct v
+// points to.  Keys missing from data leave their fields unchanged.
+func DecodeData(data map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+	for i, t := 0, rv.Type(); i < t.NumField(); i++ {
+		key, _, ok := dataKey(t.Field(i))
+		if !ok {
+			continue
+		}
+		s, ok := data[key]
+		if !ok {
+			continue
+		}
+		if err := parseDataValue(s, rv.Field(i)); err != nil {
+			return fmt.Errorf("key %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func dataKey(f reflect.StructField) (key string, omitEmpty, ok bool) {
+	if f.PkgPath != "" { // unexported
+		return "", false, false
+	}
+	tag := f.Tag.Get("gcm")
+	if tag == "-" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	key = parts[0]
+	if key == "" {
+		key = f.Name
+	}
+	for _, opt := range parts[1:] {
+		omitEmpty = omitEmpty || opt == "omitempty"
+	}
+	return key, omitEmpty, true
+}
+
+func formatDataValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+func parseDataValue(s string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
+
+// EncodeDataJSON returns a data payload holding v as JSON under key.
+func EncodeDataJSON(key string, v interface{}) (map[string]string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{key: string(b)}, nil
+}
+
+// DecodeDataJSON parses the JSON under key in a data payload into v.
+func DecodeDataJSON(data map[string]string, key string, v interface{}) error {
+	s, ok := data[key]
+	if !ok {
+		return fmt.Errorf("missing data key %s", key)
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+// EncodeDataBinary returns a data payload holding b, e.g. a serialized
+// protocol buffer, in base64 under key.
+func EncodeDataBinary(key string, b []byte) map[string]string {
+	return map[string]string{key: base64.StdEncoding.EncodeToString(b)}
+}
+
+// DecodeDataBinary returns the bytes held in base64 under key in a data
+// payload.
+func DecodeDataBinary(data map[string]string, key string) ([]byte, error) {
+	s, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("missing data key %s", key)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,61 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type orderShipped struct {
+	OrderID  string   `gcm:"order_id"`
+	Count    int      `gcm:"count"`
+	Price    float64  `gcm:"price"`
+	Express  bool     `gcm:"express,omitempty"`
+	Items    []string `gcm:"items"`
+	Internal string   `gcm:"-"`
+	Carrier  string
+	secret   string
+}
+
+func TestEncodeDecodeData(t *testing.T) {
+	in := orderShipped{OrderID: "o1", Count: 2, Price: 9.5, Items: []string{"a", "b"}, Internal: "x", Carrier: "ups", secret: "s"}
+	data, err := EncodeData(&in)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"order_id": "o1",
+		"count":    "2",
+		"price":    "9.5",
+		"items":    `["a","b"]`,
+		"Carrier":  "ups",
+	}, data)
+
+	var out orderShipped
+	assert.NoError(t, DecodeData(data, &out))
+	in.Internal, in.secret = "", ""
+	assert.Equal(t, in, out)
+
+	assert.EqualError(t, DecodeData(map[string]string{"count": "x"}, &out), `key count: strconv.ParseInt: parsing "x": invalid syntax`)
+	assert.Error(t, DecodeData(data, out))
+	_, err = EncodeData("string")
+	assert.EqualError(t, err, "expected a struct, got string")
+}
+
+func TestEncodeDecodeDataJSON(t *testing.T) {
+	data, err := EncodeDataJSON("payload", map[string]int{"n": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"payload": `{"n":1}`}, data)
+	var out map[string]int
+	assert.NoError(t, DecodeDataJSON(data, "payload", &out))
+	assert.Equal(t, map[string]int{"n": 1}, out)
+	assert.EqualError(t, DecodeDataJSON(data, "other", &out), "missing data key other")
+}
+
+func TestEncodeDecodeDataBinary(t *testing.T) {
+	data := EncodeDataBinary("proto", []byte{0x08, 0x96, 0x01})
+	assert.Equal(t, map[string]string{"proto": "CJYB"}, data)
+	b, err := DecodeDataBinary(data, "proto")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x08, 0x96, 0x01}, b)
+	_, err = DecodeDataBinary(data, "other")
+	assert.EqualError(t, err, "missing data key other")
+}
@@ -0,0 +1,298 @@
+package gcm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UserDataStore is a store holding personal data of users, which can be
+// deleted on request, e.g. to comply with the GDPR.  Queue, DeadLetterQueue,
+// Archiver and NotificationKeyCache are UserDataStores.  A SentStore is not,
+// since its keys are chosen by the caller: if they identify users, the
+// caller should register a UserDataStore that deletes them.
+type UserDataStore interface {
+	// DeleteUserData deletes the data of user, whose registration tokens
+	// are given, and returns the number of deleted items.
+	DeleteUserData(user string, tokens []string) (int, error)
+}
+
+// UserDataRegistry is a set of named UserDataStores whose user data is
+// deleted together.  The zero value is an empty registry.
+//
+// UserDataRegistry is safe for concurrent use.
+type UserDataRegistry struct {
+	mu     sync.Mutex
+	stores map[string]UserDataStore
+}
+
+// Register registers a store under name, replacing the store registered
+// under the same name, if any.  A store that is also a TokenStore tells
+// DeleteUserData the tokens of the user.
+func (r *UserDataRegistry) Register(name string, store UserDataStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stores == nil {
+		r.stores = make(map[string]UserDataStore)
+	}
+	r.stores[name] = store
+}
+
+// Unregister unregisters the store registered under name.
+func (r *UserDataRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.stores, name)
+}
+
+// UserDataDeletionError is returned by DeleteUserData when some stores failed.
+type UserDataDeletionError struct {
+	// Errors maps the names of the failed stores to their errors.
+	Errors map[string]error
+}
+
+func (e *UserDataDeletionError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("failed to delete user data from %d store(s), first %s: %v", len(names), names[0], e.Errors[names[0]])
+}
+
+// DeleteUserData deletes the data of user from all registered stores, e.g. its
+// tokens, queued messages, audit records and cached device group keys, and
+// returns the number of deleted items by store name.  The tokens of the user
+// are gathered from the registered TokenStores first.  All stores are tried
+// even if some fail, in which case a *UserDataDeletionError is returned, and
+// the call can be repeated.
+func (r *UserDataRegistry) DeleteUserData(user string) (map[string]int, error) {
+	if user == "" {
+		return nil, errors.New("user cannot be empty")
+	}
+	r.mu.Lock()
+	stores := make(map[string]UserDataStore, len(r.stores))
+	for name, store := range r.stores {
+		stores[name] = store
+	}
+	r.mu.Unlock()
+
+	failed := make(map[string]error)
+	var tokens []string
+	for name, store := range stores {
+		if ts, ok := store.(TokenStore); ok {
+			t, err := ts.Tokens(user)
+			if err != nil {
+				failed[name] = err
+			}
+			tokens = append(tokens, t...)
+		}
+	}
+	if len(failed) > 0 {
+		// deleting without all the tokens would leave data behind
+		return nil, &UserDataDeletionError{failed}
+	}
+
+	deleted := make(map[string]int, len(stores))
+	for name, store := range stores {
+		n, err := store.DeleteUserData(user, tokens)
+		deleted[name] = n
+		if err != nil {
+			failed[name] = err
+		}
+	}
+	if len(failed) > 0 {
+		return deleted, &UserDataDeletionError{failed}
+	}
+	return deleted, nil
+}
+
+// tokenSet returns the set of tokens.
+func tokenSet(tokens []string) map[string]bool {
+	set := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		set[token] = true
+	}
+	return set
+}
+
+// withoutRecipients returns job without the given recipients, nil if none is
+// left, or job itself if it has none of them.
+func withoutRecipients(job *Job, tokens map[string]bool) *Job {
+	if len(job.RegistrationIDs) == 0 {
+		if tokens[job.To] {
+			return nil
+		}
+		return job
+	}
+	kept := make([]string, 0, len(job.RegistrationIDs))
+	for _, regID := range job.RegistrationIDs {
+		if !tokens[regID] {
+			kept = append(kept, regID)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case len(job.RegistrationIDs):
+		return job
+	}
+	stripped := *job
+	stripped.RegistrationIDs = kept
+	return &stripped
+}
+
+// DeleteUserData removes the tokens from the pending jobs, removing the jobs
+// left without recipients, and returns the number of jobs changed or removed.
+// The WAL, if any, is then compacted so that its file no longer holds the
+// tokens.
+func (q *Queue) DeleteUserData(user string, tokens []string) (int, error) {
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	set := tokenSet(tokens)
+	var removed, replaced, replacements []*Job
+	q.mu.Lock()
+	for _, qc := range q.classes {
+		jobs := qc.jobs[:0]
+		for _, job := range qc.jobs {
+			switch stripped := withoutRecipients(job, set); stripped {
+			case nil:
+				removed = append(removed, job)
+			case job:
+				jobs = append(jobs, job)
+			default:
+				replaced, replacements = append(replaced, job), append(replacements, stripped)
+				jobs = append(jobs, stripped)
+			}
+		}
+		for i := len(jobs); i < len(qc.jobs); i++ {
+			qc.jobs[i] = nil
+		}
+		qc.jobs = jobs
+	}
+	if len(removed) > 0 {
+		q.releaseSpace()
+	}
+	q.mu.Unlock()
+
+	var err error
+	for _, job := range replacements {
+		if lerr := q.log(job); lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+	for _, job := range append(removed, replaced...) {
+		if aerr := q.Ack(job); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	n := len(removed) + len(replaced)
+	if q.wal != nil && n > 0 && err == nil {
+		err = q.wal.Compact()
+	}
+	return n, err
+}
+
+// DeleteUserData removes the tokens from the jobs of the dead letters,
+// removing the dead letters left without recipients, and returns the number of
+// dead letters changed or removed.
+func (dl *DeadLetterQueue) DeleteUserData(user string, tokens []string) (int, error) {
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	set := tokenSet(tokens)
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	n := 0
+	letters := dl.letters[:0]
+	for _, letter := range dl.letters {
+		stripped := withoutRecipients(letter.Job, set)
+		if stripped != letter.Job {
+			n++
+		}
+		if stripped == letter.Job {
+			letters = append(letters, letter)
+		} else if stripped != nil {
+			// letters returned by List are not changed
+			copied := *letter
+			copied.Job = stripped
+			letters = append(letters, &copied)
+		}
+	}
+	for i := len(letters); i < len(dl.letters); i++ {
+		dl.letters[i] = nil
+	}
+	dl.letters = letters
+	return n, nil
+}
+
+// deletableBlobStore is a BlobStore whose records can be deleted one by one.
+type deletableBlobStore interface {
+	Keys() []string
+	Get(key string) []byte
+	Delete(key string) error
+}
+
+// DeleteUserData deletes the archived records of requests to the tokens, found
+// by their hashes, and returns the number of deleted records.  The Store must
+// be able to list and delete records, like MemoryBlobStore.
+func (a *Archiver) DeleteUserData(user string, tokens []string) (int, error) {
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	store, ok := a.Store.(deletableBlobStore)
+	if !ok {
+		return 0, errors.New("archive store cannot delete records")
+	}
+	hashes := make([][]byte, len(tokens))
+	for i, token := range tokens {
+		hashes[i] = []byte(scrub(token))
+	}
+	n := 0
+	for _, key := range store.Keys() {
+		blob := store.Get(key)
+		for _, hash := range hashes {
+			if bytes.Contains(blob, hash) {
+				if err := store.Delete(key); err != nil {
+					return n, err
+				}
+				n++
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// DeleteUserData removes the cached notification keys of the device group
+// named after user, as kept by a GroupReconciler, and returns their number.
+func (c *NotificationKeyCache) DeleteUserData(user string, tokens []string) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key := range c.keys {
+		if strings.HasSuffix(key, cacheKey("", user)) {
+			delete(c.keys, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteUserData forgets the members of the device group named after user.
+func (m *memoryMembershipStore) DeleteUserData(user string, tokens []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.members[user]; !ok {
+		return 0, nil
+	}
+	delete(m.members, user)
+	return 1, nil
+}
@@ -0,0 +1,115 @@
+package gcm
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// userTokens is a TokenStore of a single user's tokens.
+type userTokens struct {
+	user   string
+	tokens []string
+	err    error
+}
+
+func (u *userTokens) Tokens(user string) ([]string, error) {
+	if user != u.user {
+		return nil, u.err
+	}
+	return u.tokens, u.err
+}
+
+func (u *userTokens) DeleteUserData(user string, tokens []string) (int, error) {
+	if user != u.user {
+		return 0, nil
+	}
+	n := len(u.tokens)
+	u.tokens = nil
+	return n, nil
+}
+
+func TestDeleteUserData(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "t1"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, RegistrationIDs: []string{"t2", "other"}}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "other"}))
+	dl := &DeadLetterQueue{}
+	letter := dl.Add(&Job{Class: ClassReminder, Message: msg, RegistrationIDs: []string{"t1", "t2"}}, errors.New("failed"))
+	dl.Add(&Job{Class: ClassReminder, Message: msg, RegistrationIDs: []string{"other", "t1"}}, errors.New("failed"))
+	blobs := NewMemoryBlobStore()
+	archiver := &Archiver{Store: blobs, SampleRate: 1}
+	assert.NoError(t, archiver.archive(0, []byte(`{"to":"t1"}`), 200, nil, nil))
+	assert.NoError(t, archiver.archive(0, []byte(`{"to":"other"}`), 200, nil, nil))
+	cache := &NotificationKeyCache{}
+	cache.Put("sender", "alice", "key")
+	cache.Put("sender", "bob", "key")
+	tokens := &userTokens{user: "alice", tokens: []string{"t1", "t2"}}
+
+	var registry UserDataRegistry
+	stores := map[string]UserDataStore{"tokens": tokens, "queue": q, "dead letters": dl, "archive": archiver, "keys": cache}
+	for name, store := range stores {
+		registry.Register(name, store)
+	}
+
+	_, err := registry.DeleteUserData("")
+	assert.NotNil(t, err)
+	deleted, err := registry.DeleteUserData("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"tokens": 2, "queue": 2, "dead letters": 2, "archive": 1, "keys": 1}, deleted)
+
+	pending := q.Pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, []string{"other"}, pending[0].RegistrationIDs)
+	assert.Equal(t, "other", pending[1].To)
+	letters := dl.List()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, []string{"other"}, letters[0].Job.RegistrationIDs)
+	assert.Equal(t, []string{"t1", "t2"}, letter.Job.RegistrationIDs)
+	assert.Len(t, blobs.Keys(), 1)
+	_, ok := cache.Get("sender", "alice")
+	assert.False(t, ok)
+	_, ok = cache.Get("sender", "bob")
+	assert.True(t, ok)
+}
+
+func TestDeleteUserDataTokenStoreFails(t *testing.T) {
+	failure := errors.New("unavailable")
+	var registry UserDataRegistry
+	registry.Register("tokens", &userTokens{user: "alice", err: failure})
+	registry.Register("queue", NewQueue(QueueConfig{}))
+	registry.Register("bogus", NewQueue(QueueConfig{}))
+	registry.Unregister("bogus")
+
+	_, err := registry.DeleteUserData("alice")
+	var delErr *UserDataDeletionError
+	assert.True(t, errors.As(err, &delErr))
+	assert.Equal(t, map[string]error{"tokens": failure}, delErr.Errors)
+}
+
+func TestArchiverDeleteUserDataUnsupportedStore(t *testing.T) {
+	a := &Archiver{Store: struct{ BlobStore }{NewMemoryBlobStore()}}
+	_, err := a.DeleteUserData("alice", []string{"t1"})
+	assert.NotNil(t, err)
+}
+
+func TestQueueDeleteUserDataCompactsWAL(t *testing.T) {
+	path := tempWALPath(t)
+	wal, err := OpenWAL(WALConfig{Path: path})
+	assert.NoError(t, err)
+	defer wal.Close()
+	q := NewQueue(QueueConfig{WAL: wal})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "alice-token"}))
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, RegistrationIDs: []string{"alice-token", "other"}}))
+
+	n, err := q.DeleteUserData("alice", []string{"alice-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(b), "alice-token"))
+	assert.True(t, strings.Contains(string(b), "other"))
+}
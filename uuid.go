@@ -0,0 +1,77 @@
+package gcm
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// injectIDs adds the trace ID and the UUID to the data payload of the message.
+func (s *Sender) injectIDs(m *message) {
+	s.injectTraceID(m)
+	s.injectUUID(m)
+}
+
+// withIDs returns a copy of msg with the trace ID and the UUID in its data
+// payload, so that the batches of a split send all carry the same ones.
+func (s *Sender) withIDs(msg *Message) *Message {
+	m := &message{Message: *msg}
+	s.injectIDs(m)
+	return &m.Message
+}
+
+// injectUUID adds a UUID to the data payload of the message under the
+// Sender's MessageUUIDKey, unless the payload already has one.
+func (s *Sender) injectUUID(m *message) {
+	if s.MessageUUIDKey == "" {
+		return
+	}
+	if id, ok := m.Data[s.MessageUUIDKey]; ok {
+		m.uuid = id
+		return
+	}
+	m.uuid = newUUID()
+	m.setData(s.MessageUUIDKey, m.uuid)
+}
+
+// MessageUUIDError is the error of a send whose message has a UUID, see
+// Sender.MessageUUIDKey.
+type MessageUUIDError struct {
+	UUID string
+	Err  error
+}
+
+func (e *MessageUUIDError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MessageUUIDError) Unwrap() error {
+	return e.Err
+}
+
+// withUUID attaches the UUID of the message, if any, to an error of its send.
+func withUUID(m *message, err error) error {
+	if err == nil || m.uuid == "" {
+		return err
+	}
+	return &MessageUUIDError{m.uuid, err}
+}
+
+// MessageUUID returns the UUID of the message whose send failed with err, if
+// the Sender has a MessageUUIDKey.
+func MessageUUID(err error) (string, bool) {
+	var uuidErr *MessageUUIDError
+	if errors.As(err, &uuidErr) {
+		return uuidErr.UUID, true
+	}
+	return "", false
+}
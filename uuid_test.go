@@ -0,0 +1,62 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUID(t *testing.T) {
+	id := newUUID()
+	assert.True(t, uuidPattern.MatchString(id), id)
+	assert.NotEqual(t, id, newUUID())
+}
+
+func TestSendWithMessageUUID(t *testing.T) {
+	var sent []message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var m message
+		json.Unmarshal(body, &m)
+		sent = append(sent, m)
+		if len(sent) == 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		respBytes, _ := json.Marshal(success)
+		w.Write(respBytes)
+	}))
+	defer server.Close()
+	GCMEndpoint = server.URL
+
+	s := NewSender("test-api-key")
+	s.MessageUUIDKey = "uuid"
+	result, err := s.SendNoRetry(msg, "regId")
+	assert.NoError(t, err)
+	assert.True(t, uuidPattern.MatchString(result.UUID), result.UUID)
+	assert.Equal(t, result.UUID, sent[0].Data["uuid"])
+	assert.Equal(t, map[string]string{"k": "v"}, msg.Data, "original is not modified")
+
+	result, err = s.SendNoRetry(&Message{Data: map[string]string{"uuid": "mine"}}, "regId")
+	assert.NoError(t, err)
+	assert.Equal(t, "mine", result.UUID)
+
+	_, err = s.SendWithRetries(msg, "regId", 0)
+	assert.Error(t, err)
+	id, ok := MessageUUID(err)
+	assert.True(t, ok)
+	assert.Equal(t, sent[2].Data["uuid"], id)
+	var httpErr httpError
+	assert.True(t, errors.As(err, &httpErr), "the error is wrapped")
+
+	_, ok = MessageUUID(httpErr)
+	assert.False(t, ok)
+}
@@ -0,0 +1,76 @@
+package gcm
+
+// TokenValidator checks registration tokens in bulk by sending dry run
+// messages, e.g. as the first step of a migration to another endpoint or
+// project.
+type TokenValidator struct {
+	Sender *Sender
+	// Message is the message sent as a dry run; nil means an empty one.
+	Message *Message
+	// BatchSize is the number of tokens per request.  Zero means
+	// MaxRegistrationIDs.
+	BatchSize int
+	// RateLimit caps the number of requests per second.  Zero means
+	// unlimited.
+	RateLimit float64
+	// Retries is the number of retries for each request.
+	Retries int
+}
+
+// ValidationReport classifies the tokens checked by a TokenValidator.
+type ValidationReport struct {
+	// Valid lists the tokens that are valid as they are.
+	Valid []string
+	// Canonical maps the tokens that should be replaced to their canonical
+	// registration IDs.
+	Canonical map[string]string
+	// Dead lists the tokens that are no longer valid and should be removed.
+	Dead []string
+	// Failed maps the tokens that could not be checked to their errors.
+	Failed map[string]string
+}
+
+// Validate checks the tokens in batches and reports which are valid,
+// canonical or dead.  On error, the report covers the tokens checked so far.
+func (v *TokenValidator) Validate(tokens []string) (*ValidationReport, error) {
+	dryRun := Message{}
+	if v.Message != nil {
+		dryRun = *v.Message
+	}
+	dryRun.DryRun = true
+	batchSize := v.BatchSize
+	if batchSize <= 0 || batchSize > MaxRegistrationIDs {
+		batchSize = MaxRegistrationIDs
+	}
+	var limiter *rateLimiter
+	if v.RateLimit > 0 {
+		limiter = newRateLimiter(v.RateLimit)
+	}
+
+	report := &ValidationReport{Canonical: make(map[string]string), Failed: make(map[string]string)}
+	err := forEachBatch(tokens, batchSize, func(batch []string) error {
+		if limiter != nil {
+			limiter.wait()
+		}
+		result, err := v.Sender.SendMulticastWithRetries(&dryRun, batch, v.Retries)
+		if result == nil {
+			return err
+		}
+		// classify the results that come with an error too
+		for i, res := range result.Results {
+			token := batch[i]
+			switch {
+			case res.CanonicalRegistrationID != "":
+				report.Canonical[token] = res.CanonicalRegistrationID
+			case res.Error == "":
+				report.Valid = append(report.Valid, token)
+			case Classify(res.Error) == ActionRemoveToken:
+				report.Dead = append(report.Dead, token)
+			default:
+				report.Failed[token] = res.Error
+			}
+		}
+		return err
+	})
+	return report, err
+}
@@ -0,0 +1,45 @@
+package gcm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenValidator(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Success: 2, Results: []result{{MessageID: "fake"}, {MessageID: "fake", RegistrationID: "new2"}}}},
+		&testResponse{response: &response{Failure: 2, Results: []result{{Err: ErrorNotRegistered}, {Err: ErrorMismatchSenderID}}}},
+	)
+	defer server.Close()
+	v := &TokenValidator{Sender: NewSender("test-api-key"), BatchSize: 2, RateLimit: 10}
+	start := time.Now()
+	report, err := v.Validate([]string{"1", "2", "3", "4"})
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, &ValidationReport{
+		Valid:     []string{"1"},
+		Canonical: map[string]string{"2": "new2"},
+		Dead:      []string{"3"},
+		Failed:    map[string]string{"4": ErrorMismatchSenderID},
+	}, report)
+}
+
+func TestTokenValidatorKeepsResultsOfFailedBatch(t *testing.T) {
+	server := startTestServer(t,
+		&testResponse{response: &response{Failure: 2, Results: []result{{Err: ErrorNotRegistered}, {Err: ErrorUnavailable}}}},
+		&testResponse{response: &response{Failure: 1, Results: []result{{Err: ErrorUnavailable}}}},
+	)
+	defer server.Close()
+	s := NewSender("test-api-key")
+	s.RetryExhaustedErrors = true
+	s.Time = &fakeTime{now: time.Now()}
+	v := &TokenValidator{Sender: s, Retries: 1}
+	report, err := v.Validate([]string{"1", "2"})
+	var exhausted *RetryExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Equal(t, []string{"1"}, report.Dead, "classified before the error")
+	assert.Equal(t, map[string]string{"2": ErrorUnavailable}, report.Failed)
+}
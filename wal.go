@@ -0,0 +1,364 @@
+package gcm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncPolicy defines when a WAL forces its writes to stable storage, trading
+// durability for throughput.  Every record is written to the file right away,
+// so it survives a restart of the process regardless of the policy; the policy
+// only matters if the machine itself crashes.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the file after every record.
+	SyncAlways SyncPolicy = iota
+	// SyncPeriodic syncs the file every SyncInterval if it was written to.
+	SyncPeriodic
+	// SyncNever leaves syncing to the operating system.
+	SyncNever
+)
+
+// defaultSyncInterval is the SyncInterval used when none is configured.
+const defaultSyncInterval = time.Second
+
+// WALConfig configures a WAL.
+type WALConfig struct {
+	// Path is the path of the log file.
+	Path string
+	// Sync decides when writes are synced to stable storage.
+	Sync SyncPolicy
+	// SyncInterval is how often SyncPeriodic syncs.  Zero means 1s.
+	SyncInterval time.Duration
+	// Codec encodes the logged jobs.  Nil means JSONCodec.  A log must be
+	// opened with the Codec it was written with.
+	Codec Codec
+}
+
+// WAL is a file-backed write-ahead log of the jobs of a Queue.  Jobs are
+// logged when enqueued and acknowledged once done with, so that the jobs that
+// were not acknowledged when the process stopped are replayed into the Queue
+// when it is instantiated again with a WAL on the same file.
+//
+// WAL is safe for concurrent use.
+type WAL struct {
+	mu       sync.Mutex
+	path     string
+	f        *os.File
+	sync     SyncPolicy
+	codec    Codec
+	dirty    bool
+	nextID   uint64
+	ids      map[*Job]uint64
+	jobs     map[uint64]*Job
+	replayed []*Job
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// walRecord is a record of the log.  It is framed as the ID, the length of
+// the encoded job and the encoded job, with a zero length for
+// acknowledgements.
+type walRecord struct {
+	ID  uint64
+	Job *Job // nil for acknowledgements
+}
+
+const walHeaderLen = 12
+
+// ErrWALClosed is returned when writing to a closed WAL.
+var ErrWALClosed = errors.New("wal is closed")
+
+// OpenWAL opens the WAL at config.Path, creating the file if needed.  The jobs
+// that were logged but not acknowledged are kept for replay, and the file is
+// compacted to contain only them.
+func OpenWAL(config WALConfig) (*WAL, error) {
+	if config.Path == "" {
+		return nil, errors.New("missing wal path")
+	}
+	w := &WAL{
+		path:  config.Path,
+		sync:  config.Sync,
+		codec: codecOrDefault(config.Codec),
+		ids:   make(map[*Job]uint64),
+		jobs:  make(map[uint64]*Job),
+	}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	if err := w.compact(); err != nil {
+		return nil, err
+	}
+	if w.sync == SyncPeriodic {
+		interval := config.SyncInterval
+		if interval <= 0 {
+			interval = defaultSyncInterval
+		}
+		w.done, w.stopped = make(chan struct{}), make(chan struct{})
+		go w.syncPeriodically(interval)
+	}
+	return w, nil
+}
+
+// load reads the unacknowledged jobs of the log file.  A record that cannot be
+// decoded, typically one torn by a crash, ends the log.
+func (w *WAL) load() error {
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		rec, err := w.read(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Printf("ignoring the rest of wal %s: %v", w.path, err)
+			break
+		}
+		if rec.ID >= w.nextID {
+			w.nextID = rec.ID + 1
+		}
+		if rec.Job != nil {
+			w.jobs[rec.ID] = rec.Job
+		} else {
+			delete(w.jobs, rec.ID)
+		}
+	}
+	ids := make([]uint64, 0, len(w.jobs))
+	for id, job := range w.jobs {
+		ids = append(ids, id)
+		w.ids[job] = id
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		w.replayed = append(w.replayed, w.jobs[id])
+	}
+	return nil
+}
+
+// compact atomically rewrites the log file with the unacknowledged jobs only
+// and opens it for appending.  It must be called with w.mu held, or before the
+// WAL is shared.
+func (w *WAL) compact() error {
+	ids := make([]uint64, 0, len(w.jobs))
+	for id := range w.jobs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tmp, err := ioutil.TempFile(filepath.Dir(w.path), filepath.Base(w.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(tmp)
+	for _, id := range ids {
+		var frame []byte
+		if frame, err = w.encode(walRecord{ID: id, Job: w.jobs[id]}); err != nil {
+			break
+		}
+		if _, err = buf.Write(frame); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), w.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if w.f != nil {
+		w.f.Close()
+	}
+	w.f, w.dirty = f, false
+	return nil
+}
+
+// Compact rewrites the log file with the unacknowledged jobs only, reclaiming
+// the space taken by the acknowledged ones.
+func (w *WAL) Compact() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return ErrWALClosed
+	}
+	return w.compact()
+}
+
+// Len returns the number of unacknowledged jobs.
+func (w *WAL) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.jobs)
+}
+
+// replay returns the jobs left unacknowledged by the previous process, oldest
+// first, and forgets them so that they are replayed only once.
+func (w *WAL) replay() []*Job {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	jobs := w.replayed
+	w.replayed = nil
+	return jobs
+}
+
+// append logs that job was enqueued.
+func (w *WAL) append(job *Job) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextID
+	if err := w.write(walRecord{ID: id, Job: job}); err != nil {
+		return err
+	}
+	w.nextID++
+	w.ids[job] = id
+	w.jobs[id] = job
+	return nil
+}
+
+// ack logs that job is done with.  Jobs that were not logged are ignored.
+func (w *WAL) ack(job *Job) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id, ok := w.ids[job]
+	if !ok {
+		return nil
+	}
+	if err := w.write(walRecord{ID: id}); err != nil {
+		return err
+	}
+	delete(w.ids, job)
+	delete(w.jobs, id)
+	return nil
+}
+
+// encode frames rec.
+func (w *WAL) encode(rec walRecord) ([]byte, error) {
+	var data []byte
+	if rec.Job != nil {
+		var err error
+		if data, err = w.codec.Encode(rec.Job); err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, errors.New("codec encoded job as empty data")
+		}
+	}
+	frame := make([]byte, walHeaderLen, walHeaderLen+len(data))
+	binary.BigEndian.PutUint64(frame, rec.ID)
+	binary.BigEndian.PutUint32(frame[8:], uint32(len(data)))
+	return append(frame, data...), nil
+}
+
+// read reads the next record.  It returns io.EOF at the end of the log.
+func (w *WAL) read(r io.Reader) (walRecord, error) {
+	var header [walHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("truncated record")
+		}
+		return walRecord{}, err
+	}
+	rec := walRecord{ID: binary.BigEndian.Uint64(header[:])}
+	n := binary.BigEndian.Uint32(header[8:])
+	if n == 0 {
+		return rec, nil
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return walRecord{}, errors.New("truncated record")
+	}
+	job, err := w.codec.Decode(data)
+	if err != nil {
+		return walRecord{}, err
+	}
+	rec.Job = job
+	return rec, nil
+}
+
+func (w *WAL) write(rec walRecord) error {
+	if w.f == nil {
+		return ErrWALClosed
+	}
+	frame, err := w.encode(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.Write(frame); err != nil {
+		return err
+	}
+	if w.sync == SyncAlways {
+		return w.f.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+func (w *WAL) syncPeriodically(interval time.Duration) {
+	defer close(w.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.dirty && w.f != nil {
+				if err := w.f.Sync(); err != nil {
+					log.Printf("failed to sync wal %s: %v", w.path, err)
+				}
+				w.dirty = false
+			}
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Close syncs and closes the log file.
+func (w *WAL) Close() error {
+	if w.done != nil {
+		close(w.done)
+		<-w.stopped
+		w.done = nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return ErrWALClosed
+	}
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
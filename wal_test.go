@@ -0,0 +1,113 @@
+package gcm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempWALPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "queue.wal")
+}
+
+func TestWALReplaysUnacknowledgedJobs(t *testing.T) {
+	path := tempWALPath(t)
+	wal, err := OpenWAL(WALConfig{Path: path})
+	assert.NoError(t, err)
+	q := NewQueue(QueueConfig{WAL: wal})
+	for _, to := range []string{"1", "2", "3"} {
+		assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: to}))
+	}
+	job, _ := q.Dequeue()
+	assert.NoError(t, q.Ack(job))
+	q.Dequeue() // dequeued but not acknowledged before the restart
+	assert.NoError(t, wal.Close())
+
+	wal, err = OpenWAL(WALConfig{Path: path, Sync: SyncPeriodic, SyncInterval: time.Millisecond})
+	assert.NoError(t, err)
+	defer wal.Close()
+	assert.Equal(t, 2, wal.Len())
+	q = NewQueue(QueueConfig{WAL: wal})
+	assert.Equal(t, 2, q.Len())
+	job, _ = q.Dequeue()
+	assert.Equal(t, "2", job.To)
+	assert.Equal(t, msg, job.Message)
+	assert.NoError(t, q.Ack(job))
+	assert.Equal(t, 1, wal.Len())
+	assert.NoError(t, wal.Compact())
+	assert.Equal(t, 1, wal.Len())
+}
+
+func TestWALIgnoresTornRecord(t *testing.T) {
+	path := tempWALPath(t)
+	wal, err := OpenWAL(WALConfig{Path: path, Sync: SyncNever})
+	assert.NoError(t, err)
+	q := NewQueue(QueueConfig{WAL: wal})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "1"}))
+	assert.NoError(t, wal.Close())
+	assert.Equal(t, ErrWALClosed, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "2"}))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 100, '{'})
+	f.Close()
+
+	wal, err = OpenWAL(WALConfig{Path: path})
+	assert.NoError(t, err)
+	defer wal.Close()
+	q = NewQueue(QueueConfig{WAL: wal})
+	assert.Equal(t, 1, q.Len())
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "2"}))
+	assert.Equal(t, 2, wal.Len())
+}
+
+func TestDispatcherAcknowledgesJobs(t *testing.T) {
+	server := startTestServer(t, &testResponse{response: &success})
+	defer server.Close()
+	wal, err := OpenWAL(WALConfig{Path: tempWALPath(t)})
+	assert.NoError(t, err)
+	defer wal.Close()
+	q := NewQueue(QueueConfig{WAL: wal})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassTransactional, Message: msg, To: "regId"}))
+	q.Close()
+	(&Dispatcher{Queue: q, Sender: NewSender("test-api-key")}).Run()
+	assert.Equal(t, 0, wal.Len())
+}
+
+type upperCodec struct{ JSONCodec }
+
+func (c upperCodec) Encode(job *Job) ([]byte, error) {
+	b, err := c.JSONCodec.Encode(job)
+	return bytes.ToUpper(b), err
+}
+
+func (c upperCodec) Decode(data []byte) (*Job, error) {
+	return c.JSONCodec.Decode(bytes.ToLower(data))
+}
+
+func TestWALCodec(t *testing.T) {
+	path := tempWALPath(t)
+	wal, err := OpenWAL(WALConfig{Path: path, Codec: upperCodec{}})
+	assert.NoError(t, err)
+	q := NewQueue(QueueConfig{WAL: wal})
+	assert.NoError(t, q.Enqueue(&Job{Class: ClassMarketing, Message: msg, To: "a"}))
+	assert.NoError(t, wal.Close())
+	b, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(b), `"TO":"A"`)
+
+	wal, err = OpenWAL(WALConfig{Path: path, Codec: upperCodec{}})
+	assert.NoError(t, err)
+	defer wal.Close()
+	job, _ := NewQueue(QueueConfig{WAL: wal}).Dequeue()
+	assert.Equal(t, "a", job.To)
+}
@@ -0,0 +1,125 @@
+package gcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// maxWebPushTopicLength is the max length of a web push Topic.
+const maxWebPushTopicLength = 32
+
+// WebPushUrgency defines how urgent a web push message is, so that browsers
+// on battery can defer less urgent ones.  Refer to RFC 8030, section 5.3.
+type WebPushUrgency string
+
+const (
+	// WebPushUrgencyVeryLow is for messages that can wait for power and wifi,
+	// e.g. advertisements.
+	WebPushUrgencyVeryLow WebPushUrgency = "very-low"
+	// WebPushUrgencyLow is for messages that can wait for power or wifi,
+	// e.g. topic updates.
+	WebPushUrgencyLow WebPushUrgency = "low"
+	// WebPushUrgencyNormal is for messages that can wait for the device to
+	// wake up, e.g. chats.  It is the default.
+	WebPushUrgencyNormal WebPushUrgency = "normal"
+	// WebPushUrgencyHigh is for time-sensitive messages, e.g. incoming calls.
+	WebPushUrgencyHigh WebPushUrgency = "high"
+)
+
+// WebPushConfig holds the web push options of a Message, which browsers honor
+// differently than the Android priority and collapse key.
+type WebPushConfig struct {
+	// Urgency overrides the urgency derived from the Priority of the
+	// message.
+	Urgency WebPushUrgency
+	// Topic replaces a pending message with the same topic, like a collapse
+	// key.  It is at most 32 characters of the URL and filename safe base64
+	// alphabet.  Empty means the CollapseKey of the message.
+	Topic string
+}
+
+// WebPushNotification is a Message converted for a web push service.
+type WebPushNotification struct {
+	// TTL is how long in seconds the push service keeps the message.
+	TTL int
+	// Urgency is the urgency of the message, or empty for the default.
+	Urgency WebPushUrgency
+	// Topic is the topic of the message, if any.
+	Topic string
+	// Payload is the JSON payload, with the notification and the data of the
+	// message, to be encrypted for the subscription.
+	Payload []byte
+}
+
+// Header returns the TTL, Urgency and Topic headers of the notification.
+func (n *WebPushNotification) Header() http.Header {
+	h := make(http.Header)
+	h.Set("TTL", strconv.Itoa(n.TTL))
+	if n.Urgency != "" {
+		h.Set("Urgency", string(n.Urgency))
+	}
+	if n.Topic != "" {
+		h.Set("Topic", n.Topic)
+	}
+	return h
+}
+
+// ToWebPush converts a message for a web push service: TimeToLive becomes the
+// TTL, or 4 weeks like GCM if not set, Priority the urgency and CollapseKey the
+// topic, unless the WebPush config of the message overrides them.
+func ToWebPush(msg *Message) (*WebPushNotification, error) {
+	if msg == nil {
+		return nil, errors.New("message cannot be nil")
+	}
+	if msg.TimeToLive < 0 || msg.TimeToLive > maxTimeToLive {
+		return nil, errors.New("TimeToLive should be non-negative and at most 4 weeks")
+	}
+	n := &WebPushNotification{TTL: maxTimeToLive, Topic: msg.CollapseKey}
+	if msg.TimeToLive > 0 || msg.TimeToLiveSet {
+		n.TTL = msg.TimeToLive
+	}
+	switch msg.Priority {
+	case PriorityHigh:
+		n.Urgency = WebPushUrgencyHigh
+	case PriorityNormal:
+		n.Urgency = WebPushUrgencyNormal
+	}
+	if c := msg.WebPush; c != nil {
+		switch c.Urgency {
+		case "":
+		case WebPushUrgencyVeryLow, WebPushUrgencyLow, WebPushUrgencyNormal, WebPushUrgencyHigh:
+			n.Urgency = c.Urgency
+		default:
+			return nil, fmt.Errorf("unknown web push urgency %q", c.Urgency)
+		}
+		if c.Topic != "" {
+			n.Topic = c.Topic
+		}
+	}
+	if !isWebPushTopic(n.Topic) {
+		return nil, fmt.Errorf("web push topic should be at most %d characters of the URL safe base64 alphabet, got %q", maxWebPushTopicLength, n.Topic)
+	}
+
+	var err error
+	n.Payload, err = json.Marshal(struct {
+		Notification *Notification     `json:"notification,omitempty"`
+		Data         map[string]string `json:"data,omitempty"`
+	}{msg.Notification, msg.Data})
+	return n, err
+}
+
+// isWebPushTopic reports whether s is a valid Topic header, or empty.
+func isWebPushTopic(s string) bool {
+	if len(s) > maxWebPushTopicLength {
+		return false
+	}
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
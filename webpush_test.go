@@ -0,0 +1,40 @@
+package gcm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToWebPush(t *testing.T) {
+	n, err := ToWebPush(&Message{
+		CollapseKey:  "score",
+		TimeToLive:   60,
+		Priority:     PriorityHigh,
+		Data:         map[string]string{"id": "42"},
+		Notification: &Notification{Title: "Goal"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "60", n.Header().Get("TTL"))
+	assert.Equal(t, "high", n.Header().Get("Urgency"))
+	assert.Equal(t, "score", n.Header().Get("Topic"))
+	assertJSON(t, `{"notification":{"title":"Goal"},"data":{"id":"42"}}`, n.Payload)
+
+	n, err = ToWebPush(&Message{CollapseKey: "score", WebPush: &WebPushConfig{Urgency: WebPushUrgencyVeryLow, Topic: "scores_2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &WebPushNotification{TTL: maxTimeToLive, Urgency: WebPushUrgencyVeryLow, Topic: "scores_2", Payload: []byte(`{}`)}, n)
+
+	n, err = ToWebPush(&Message{TimeToLiveSet: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "0", n.Header().Get("TTL"), "an explicit zero TTL is now or never")
+	assert.Empty(t, n.Header().Get("Urgency"))
+
+	_, err = ToWebPush(&Message{WebPush: &WebPushConfig{Urgency: "urgent"}})
+	assert.NotNil(t, err)
+	_, err = ToWebPush(&Message{CollapseKey: "score update"})
+	assert.NotNil(t, err)
+	_, err = ToWebPush(&Message{WebPush: &WebPushConfig{Topic: "a-topic-longer-than-thirty-two-characters"}})
+	assert.NotNil(t, err)
+	_, err = ToWebPush(&Message{TimeToLive: -1})
+	assert.NotNil(t, err)
+}